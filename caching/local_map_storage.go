@@ -1,64 +1,481 @@
 package caching
 
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/dropbox/godropbox/time2"
+)
+
+// The eviction policy used by LocalMapStorage once its size bounds are
+// exceeded.
+type EvictionPolicy int
+
+const (
+	// Evicts the least recently used item.
+	LRUEviction EvictionPolicy = iota
+
+	// Evicts the least frequently used item.  Ties are broken by evicting the
+	// least recently used item.
+	LFUEviction
+)
+
+// The reason an item is removed from LocalMapStorage without being deleted
+// explicitly.
+type EvictionReason int
+
+const (
+	// The item is evicted since the storage's size bounds are exceeded.
+	EvictedForCapacity EvictionReason = iota
+
+	// The item's ttl has passed.
+	EvictedForExpiration
+)
+
+// Options used in LocalMapStorage construction.
+type LocalMapStorageOptions struct {
+	// The maximum number of stored items.  Zero means unlimited.
+	MaxEntries int
+
+	// The maximum total size of the stored items, as measured by SizeFunc
+	// (which is required when MaxBytes is set).  Items larger than MaxBytes
+	// are never stored.  Zero means unlimited.
+	MaxBytes int
+	SizeFunc func(item interface{}) int
+
+	// The policy used for choosing which items to evict once MaxEntries or
+	// MaxBytes is exceeded.
+	EvictionPolicy EvictionPolicy
+
+	// The ttl of stored items.  When TTLFunc is non-nil, it is used for
+	// computing each item's ttl instead.  A non-positive ttl means the item
+	// never expires.
+	TTL     time.Duration
+	TTLFunc func(item interface{}) time.Duration
+
+	// The clock used for item expiration.  Defaults to time2.DefaultClock.
+	Clock time2.Clock
+
+	// When non-nil, this is called (while the storage is locked) when items
+	// are evicted or expired.  This is not called for items which are
+	// removed by Delete, DeleteMulti, Flush, or replaced by Set.
+	OnEvict func(item interface{}, reason EvictionReason)
+}
+
+// LocalMapStorage's statistics.
+type LocalMapStorageStats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64 // evicted for capacity
+	Expirations uint64
+
+	Entries int
+	Bytes   int
+}
+
+// A local non-persistent storage, with optional size bounds and item
+// expiration.  See LocalMapStorageOptions for additional information.
 type LocalMapStorage struct {
+	Storage
+
+	localMap *localMapStorage
+}
+
+// This returns the storage's statistics.
+func (s *LocalMapStorage) Stats() LocalMapStorageStats {
+	return s.localMap.stats()
+}
+
+type localMapEntry struct {
+	key      string
+	item     interface{}
+	size     int
+	expireAt time.Time // zero if the item does not expire
+
+	// Used for eviction ordering.
+	uses       uint64
+	lastUsed   uint64
+	queueIndex int
+
+	// The entry's index in the expiration queue, or -1 if the item does not
+	// expire.
+	expirationIndex int
+}
+
+// A min-heap of entries ordered by their eviction priority.
+type evictionQueue struct {
+	policy  EvictionPolicy
+	entries []*localMapEntry
+}
+
+func (q *evictionQueue) Len() int {
+	return len(q.entries)
+}
+
+func (q *evictionQueue) Less(i int, j int) bool {
+	a := q.entries[i]
+	b := q.entries[j]
+	if q.policy == LFUEviction && a.uses != b.uses {
+		return a.uses < b.uses
+	}
+	return a.lastUsed < b.lastUsed
+}
+
+func (q *evictionQueue) Swap(i int, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+	q.entries[i].queueIndex = i
+	q.entries[j].queueIndex = j
+}
+
+func (q *evictionQueue) Push(x interface{}) {
+	entry := x.(*localMapEntry)
+	entry.queueIndex = len(q.entries)
+	q.entries = append(q.entries, entry)
+}
+
+func (q *evictionQueue) Pop() interface{} {
+	last := len(q.entries) - 1
+	entry := q.entries[last]
+	q.entries[last] = nil
+	q.entries = q.entries[:last]
+	entry.queueIndex = -1
+	return entry
+}
+
+// A min-heap of expiring entries ordered by their expiration time.
+type expirationQueue struct {
+	entries []*localMapEntry
+}
+
+func (q *expirationQueue) Len() int {
+	return len(q.entries)
+}
+
+func (q *expirationQueue) Less(i int, j int) bool {
+	return q.entries[i].expireAt.Before(q.entries[j].expireAt)
+}
+
+func (q *expirationQueue) Swap(i int, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+	q.entries[i].expirationIndex = i
+	q.entries[j].expirationIndex = j
+}
+
+func (q *expirationQueue) Push(x interface{}) {
+	entry := x.(*localMapEntry)
+	entry.expirationIndex = len(q.entries)
+	q.entries = append(q.entries, entry)
+}
+
+func (q *expirationQueue) Pop() interface{} {
+	last := len(q.entries) - 1
+	entry := q.entries[last]
+	q.entries[last] = nil
+	q.entries = q.entries[:last]
+	entry.expirationIndex = -1
+	return entry
 }
 
 type localMapStorage struct {
 	keyStringFunc       ToStringFunc
 	itemToKeyStringFunc ToStringFunc
 
-	keyVal map[string]interface{}
+	options LocalMapStorageOptions
+
+	// True if the storage has size bounds or item expiration.  Otherwise, the
+	// eviction and expiration queues are not maintained, and gets do not lock
+	// the mutex (GenericStorage already excludes gets from writes).
+	bounded bool
+
+	// NOTE: GenericStorage allows concurrent gets, which mutate the eviction
+	// state.
+	mutex sync.Mutex
+
+	keyVal      map[string]*localMapEntry
+	queue       *evictionQueue
+	expirations *expirationQueue
+	numBytes    int
+	useCount    uint64
+
+	hits      uint64 // atomic
+	misses    uint64 // atomic
+	evictions uint64
+	expired   uint64
+}
+
+// This method assumes that s.mutex is locked.
+func (s *localMapStorage) touchLocked(entry *localMapEntry) {
+	s.useCount++
+	entry.uses++
+	entry.lastUsed = s.useCount
+	heap.Fix(s.queue, entry.queueIndex)
+}
+
+// This method assumes that s.mutex is locked.
+func (s *localMapStorage) removeLocked(entry *localMapEntry) {
+	delete(s.keyVal, entry.key)
+	if s.bounded {
+		heap.Remove(s.queue, entry.queueIndex)
+	}
+	if entry.expirationIndex >= 0 {
+		heap.Remove(s.expirations, entry.expirationIndex)
+	}
+	s.numBytes -= entry.size
+}
+
+// This method assumes that s.mutex is locked.
+func (s *localMapStorage) evictLocked(
+	entry *localMapEntry,
+	reason EvictionReason) {
+
+	s.removeLocked(entry)
+	if reason == EvictedForExpiration {
+		s.expired++
+	} else {
+		s.evictions++
+	}
+
+	if s.options.OnEvict != nil {
+		s.options.OnEvict(entry.item, reason)
+	}
+}
+
+func (s *localMapStorage) isExpired(entry *localMapEntry) bool {
+	return !entry.expireAt.IsZero() &&
+		!s.options.Clock.Now().Before(entry.expireAt)
+}
+
+// Evicts all expired entries, so that expired entries do not accumulate
+// (i.e., are not only reclaimed by gets).  This method assumes that s.mutex is
+// locked.
+func (s *localMapStorage) purgeExpiredLocked() {
+	for s.expirations.Len() > 0 && s.isExpired(s.expirations.entries[0]) {
+		s.evictLocked(s.expirations.entries[0], EvictedForExpiration)
+	}
 }
 
 func (s *localMapStorage) get(key interface{}) (interface{}, error) {
-	if item, inMap := s.keyVal[s.keyStringFunc(key)]; inMap {
-		return item, nil
+	if !s.bounded {
+		entry, inMap := s.keyVal[s.keyStringFunc(key)]
+		if !inMap {
+			atomic.AddUint64(&s.misses, 1)
+			return nil, nil
+		}
+		atomic.AddUint64(&s.hits, 1)
+		return entry.item, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, inMap := s.keyVal[s.keyStringFunc(key)]
+	if inMap && s.isExpired(entry) {
+		s.evictLocked(entry, EvictedForExpiration)
+		inMap = false
+	}
+
+	if !inMap {
+		atomic.AddUint64(&s.misses, 1)
+		return nil, nil
+	}
+
+	atomic.AddUint64(&s.hits, 1)
+	s.touchLocked(entry)
+	return entry.item, nil
+}
+
+// This method assumes that s.mutex is locked.
+func (s *localMapStorage) isOverCapacityLocked() bool {
+	return (s.options.MaxEntries > 0 && len(s.keyVal) > s.options.MaxEntries) ||
+		(s.options.MaxBytes > 0 && s.numBytes > s.options.MaxBytes)
+}
+
+// Returns the entry with the highest eviction priority, excluding the newly
+// set entry (otherwise, LFU would always evict new entries).  This method
+// assumes that s.mutex is locked.
+func (s *localMapStorage) evictionCandidateLocked(
+	newEntry *localMapEntry) *localMapEntry {
+
+	if s.queue.entries[0] != newEntry {
+		return s.queue.entries[0]
+	}
+
+	// The second highest priority entry is one of the root's children.
+	candidate := 1
+	if s.queue.Len() > 2 && s.queue.Less(2, 1) {
+		candidate = 2
 	}
-	return nil, nil
+	return s.queue.entries[candidate]
 }
 
 func (s *localMapStorage) set(item interface{}) error {
-	s.keyVal[s.itemToKeyStringFunc(item)] = item
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry := &localMapEntry{
+		key:             s.itemToKeyStringFunc(item),
+		item:            item,
+		expirationIndex: -1,
+	}
+
+	if s.options.SizeFunc != nil {
+		entry.size = s.options.SizeFunc(item)
+	}
+
+	if !s.bounded {
+		if old, inMap := s.keyVal[entry.key]; inMap {
+			s.removeLocked(old)
+		}
+		s.keyVal[entry.key] = entry
+		s.numBytes += entry.size
+		return nil
+	}
+
+	s.purgeExpiredLocked()
+
+	ttl := s.options.TTL
+	if s.options.TTLFunc != nil {
+		ttl = s.options.TTLFunc(item)
+	}
+	if ttl > 0 {
+		entry.expireAt = s.options.Clock.Now().Add(ttl)
+	}
+
+	if old, inMap := s.keyVal[entry.key]; inMap {
+		entry.uses = old.uses
+		s.removeLocked(old)
+	}
+
+	if s.options.MaxBytes > 0 && entry.size > s.options.MaxBytes {
+		s.evictions++
+		if s.options.OnEvict != nil {
+			s.options.OnEvict(item, EvictedForCapacity)
+		}
+		return nil
+	}
+
+	s.keyVal[entry.key] = entry
+	heap.Push(s.queue, entry)
+	if !entry.expireAt.IsZero() {
+		heap.Push(s.expirations, entry)
+	}
+	s.numBytes += entry.size
+	s.touchLocked(entry)
+
+	// NOTE: expired entries are purged above, before evicting for capacity.
+	for s.isOverCapacityLocked() {
+		s.evictLocked(s.evictionCandidateLocked(entry), EvictedForCapacity)
+	}
+
 	return nil
 }
 
 func (s *localMapStorage) del(key interface{}) error {
-	delete(s.keyVal, s.keyStringFunc(key))
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if entry, inMap := s.keyVal[s.keyStringFunc(key)]; inMap {
+		s.removeLocked(entry)
+	}
 	return nil
 }
 
 func (s *localMapStorage) flush() error {
-	s.keyVal = make(map[string]interface{})
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.keyVal = make(map[string]*localMapEntry)
+	s.queue = &evictionQueue{policy: s.options.EvictionPolicy}
+	s.expirations = &expirationQueue{}
+	s.numBytes = 0
 	return nil
 }
 
 func (s *localMapStorage) size() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.purgeExpiredLocked()
+
 	return len(s.keyVal)
 }
 
-func newLocalMapStorage(
+func (s *localMapStorage) stats() LocalMapStorageStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.purgeExpiredLocked()
+
+	return LocalMapStorageStats{
+		Hits:        atomic.LoadUint64(&s.hits),
+		Misses:      atomic.LoadUint64(&s.misses),
+		Evictions:   s.evictions,
+		Expirations: s.expired,
+		Entries:     len(s.keyVal),
+		Bytes:       s.numBytes,
+	}
+}
+
+func newLocalMapStorageWithOptions(
 	name string,
 	KeyStringFunc ToStringFunc,
-	ItemToKeyStringFunc ToStringFunc) (*localMapStorage, Storage) {
+	ItemToKeyStringFunc ToStringFunc,
+	options LocalMapStorageOptions) (*localMapStorage, Storage, error) {
+
+	if options.MaxBytes > 0 && options.SizeFunc == nil {
+		return nil, nil, errors.Newf(
+			"'%s' requires SizeFunc when MaxBytes is set",
+			name)
+	}
+	if options.Clock == nil {
+		options.Clock = time2.DefaultClock
+	}
+
+	bounded := options.MaxEntries > 0 ||
+		options.MaxBytes > 0 ||
+		options.TTL > 0 ||
+		options.TTLFunc != nil
 
 	storage := &localMapStorage{
 		keyStringFunc:       KeyStringFunc,
 		itemToKeyStringFunc: ItemToKeyStringFunc,
-		keyVal:              make(map[string]interface{}),
+		options:             options,
+		bounded:             bounded,
+		keyVal:              make(map[string]*localMapEntry),
+		queue:               &evictionQueue{policy: options.EvictionPolicy},
+		expirations:         &expirationQueue{},
 	}
 
-	options := GenericStorageOptions{
+	genericOptions := GenericStorageOptions{
 		GetFunc:   storage.get,
 		SetFunc:   storage.set,
 		DelFunc:   storage.del,
 		FlushFunc: storage.flush,
 	}
 
-	return storage, NewGenericStorage(name, options)
+	return storage, NewGenericStorage(name, genericOptions), nil
+}
+
+func newLocalMapStorage(
+	name string,
+	KeyStringFunc ToStringFunc,
+	ItemToKeyStringFunc ToStringFunc) (*localMapStorage, Storage) {
+
+	// Unbounded storages never fail construction.
+	storage, generic, _ := newLocalMapStorageWithOptions(
+		name,
+		KeyStringFunc,
+		ItemToKeyStringFunc,
+		LocalMapStorageOptions{})
+	return storage, generic
 }
 
 // This returns a local non-persistent storage which uses map[string]interface{}
-// as its underlying storage.
+// as its underlying storage.  The storage is unbounded, and items never
+// expire.
 func NewLocalMapStorage(
 	name string,
 	KeyStringFunc ToStringFunc,
@@ -67,3 +484,27 @@ func NewLocalMapStorage(
 	_, storage := newLocalMapStorage(name, KeyStringFunc, ItemToKeyStringFunc)
 	return storage
 }
+
+// This returns a local non-persistent storage with size bounds and item
+// expiration, i.e., an in-process cache.  See LocalMapStorageOptions for
+// additional information.
+func NewLocalMapStorageWithOptions(
+	name string,
+	KeyStringFunc ToStringFunc,
+	ItemToKeyStringFunc ToStringFunc,
+	options LocalMapStorageOptions) (*LocalMapStorage, error) {
+
+	localMap, storage, err := newLocalMapStorageWithOptions(
+		name,
+		KeyStringFunc,
+		ItemToKeyStringFunc,
+		options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalMapStorage{
+		Storage:  storage,
+		localMap: localMap,
+	}, nil
+}
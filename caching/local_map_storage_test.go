@@ -1,7 +1,12 @@
 package caching
 
 import (
+	"strconv"
+	"time"
+
 	. "gopkg.in/check.v1"
+
+	"github.com/dropbox/godropbox/time2"
 )
 
 type testKeyVal struct {
@@ -122,3 +127,260 @@ func (s *LocalMapStorageSuite) TestFlush(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(s.localMap.size(), Equals, 0)
 }
+
+type evictedItem struct {
+	key    string
+	reason EvictionReason
+}
+
+type BoundedLocalMapStorageSuite struct {
+	clock   *time2.MockClock
+	evicted []evictedItem
+}
+
+var _ = Suite(&BoundedLocalMapStorageSuite{})
+
+func (s *BoundedLocalMapStorageSuite) SetUpTest(c *C) {
+	s.clock = time2.NewMockClock(time.Unix(1500000000, 0))
+	s.evicted = nil
+}
+
+func (s *BoundedLocalMapStorageSuite) newStorage(
+	c *C,
+	options LocalMapStorageOptions) *LocalMapStorage {
+
+	options.Clock = s.clock
+	options.OnEvict = func(item interface{}, reason EvictionReason) {
+		s.evicted = append(
+			s.evicted,
+			evictedItem{item.(*testKeyVal).key, reason})
+	}
+
+	storage, err := NewLocalMapStorageWithOptions(
+		"test",
+		testKeyStr,
+		testItemKeyStr,
+		options)
+	c.Assert(err, IsNil)
+	return storage
+}
+
+func (s *BoundedLocalMapStorageSuite) keys(
+	c *C,
+	storage Storage,
+	keys ...interface{}) []interface{} {
+
+	results, err := storage.GetMulti(keys...)
+	c.Assert(err, IsNil)
+
+	found := []interface{}{}
+	for _, result := range results {
+		if result != nil {
+			found = append(found, result.(*testKeyVal).key)
+		}
+	}
+	return found
+}
+
+func (s *BoundedLocalMapStorageSuite) TestMissingSizeFunc(c *C) {
+	_, err := NewLocalMapStorageWithOptions(
+		"test",
+		testKeyStr,
+		testItemKeyStr,
+		LocalMapStorageOptions{MaxBytes: 10})
+	c.Assert(err, NotNil)
+}
+
+func (s *BoundedLocalMapStorageSuite) TestUnbounded(c *C) {
+	storage := s.newStorage(
+		c,
+		LocalMapStorageOptions{
+			SizeFunc: func(item interface{}) int { return item.(*testKeyVal).val },
+		})
+
+	_ = storage.Set(&testKeyVal{"a", 1})
+	_ = storage.Set(&testKeyVal{"b", 2})
+	_ = storage.Set(&testKeyVal{"a", 3})
+	c.Assert(s.keys(c, storage, "a", "b", "c"), DeepEquals, []interface{}{"a", "b"})
+
+	// The eviction queue is not maintained.
+	c.Assert(storage.localMap.queue.Len(), Equals, 0)
+
+	_ = storage.Delete("b")
+	c.Assert(s.evicted, HasLen, 0)
+
+	stats := storage.Stats()
+	c.Assert(stats.Hits, Equals, uint64(2))
+	c.Assert(stats.Misses, Equals, uint64(1))
+	c.Assert(stats.Entries, Equals, 1)
+	c.Assert(stats.Bytes, Equals, 3)
+}
+
+func (s *BoundedLocalMapStorageSuite) TestLRUEviction(c *C) {
+	storage := s.newStorage(c, LocalMapStorageOptions{MaxEntries: 2})
+
+	_ = storage.Set(&testKeyVal{"a", 1})
+	_ = storage.Set(&testKeyVal{"b", 2})
+	_, _ = storage.Get("a")
+	_ = storage.Set(&testKeyVal{"c", 3})
+
+	c.Assert(s.evicted, DeepEquals, []evictedItem{{"b", EvictedForCapacity}})
+	c.Assert(s.keys(c, storage, "a", "b", "c"), DeepEquals, []interface{}{"a", "c"})
+
+	stats := storage.Stats()
+	c.Assert(stats.Hits, Equals, uint64(3))
+	c.Assert(stats.Misses, Equals, uint64(1))
+	c.Assert(stats.Evictions, Equals, uint64(1))
+	c.Assert(stats.Entries, Equals, 2)
+}
+
+func (s *BoundedLocalMapStorageSuite) TestLFUEviction(c *C) {
+	storage := s.newStorage(c, LocalMapStorageOptions{
+		MaxEntries:     2,
+		EvictionPolicy: LFUEviction,
+	})
+
+	_ = storage.Set(&testKeyVal{"a", 1})
+	_ = storage.Set(&testKeyVal{"b", 2})
+	_, _ = storage.Get("a")
+	_, _ = storage.Get("a")
+	_, _ = storage.Get("b")
+
+	// "b" is used less often than "a", even though it's used more recently.
+	_ = storage.Set(&testKeyVal{"c", 3})
+	c.Assert(s.evicted, DeepEquals, []evictedItem{{"b", EvictedForCapacity}})
+
+	// New items are not evicted immediately.
+	_ = storage.Set(&testKeyVal{"d", 4})
+	c.Assert(s.keys(c, storage, "a", "c", "d"), DeepEquals, []interface{}{"a", "d"})
+}
+
+func (s *BoundedLocalMapStorageSuite) TestMaxBytes(c *C) {
+	storage := s.newStorage(c, LocalMapStorageOptions{
+		MaxBytes: 10,
+		SizeFunc: func(item interface{}) int {
+			return item.(*testKeyVal).val
+		},
+	})
+
+	_ = storage.Set(&testKeyVal{"a", 4})
+	_ = storage.Set(&testKeyVal{"b", 4})
+	c.Assert(storage.Stats().Bytes, Equals, 8)
+
+	_ = storage.Set(&testKeyVal{"c", 5})
+	c.Assert(s.keys(c, storage, "a", "b", "c"), DeepEquals, []interface{}{"b", "c"})
+	c.Assert(storage.Stats().Bytes, Equals, 9)
+
+	// Replacing an item updates the size.
+	_ = storage.Set(&testKeyVal{"c", 2})
+	c.Assert(storage.Stats().Bytes, Equals, 6)
+
+	// Items larger than MaxBytes are not stored.
+	_ = storage.Set(&testKeyVal{"d", 11})
+	c.Assert(s.keys(c, storage, "b", "c", "d"), DeepEquals, []interface{}{"b", "c"})
+	c.Assert(s.evicted, DeepEquals, []evictedItem{
+		{"a", EvictedForCapacity},
+		{"d", EvictedForCapacity},
+	})
+}
+
+func (s *BoundedLocalMapStorageSuite) TestTTL(c *C) {
+	storage := s.newStorage(c, LocalMapStorageOptions{
+		TTL: time.Minute,
+		TTLFunc: func(item interface{}) time.Duration {
+			if item.(*testKeyVal).key == "forever" {
+				return 0
+			}
+			return time.Duration(item.(*testKeyVal).val) * time.Second
+		},
+	})
+
+	_ = storage.Set(&testKeyVal{"a", 10})
+	_ = storage.Set(&testKeyVal{"b", 20})
+	_ = storage.Set(&testKeyVal{"forever", 1})
+
+	s.clock.Advance(10 * time.Second)
+	c.Assert(
+		s.keys(c, storage, "a", "b", "forever"),
+		DeepEquals,
+		[]interface{}{"b", "forever"})
+
+	s.clock.Advance(time.Hour)
+	c.Assert(
+		s.keys(c, storage, "a", "b", "forever"),
+		DeepEquals,
+		[]interface{}{"forever"})
+
+	c.Assert(s.evicted, DeepEquals, []evictedItem{
+		{"a", EvictedForExpiration},
+		{"b", EvictedForExpiration},
+	})
+
+	stats := storage.Stats()
+	c.Assert(stats.Expirations, Equals, uint64(2))
+	c.Assert(stats.Entries, Equals, 1)
+}
+
+func (s *BoundedLocalMapStorageSuite) TestTTLOnlyMemoryBound(c *C) {
+	storage := s.newStorage(c, LocalMapStorageOptions{TTL: time.Minute})
+
+	// Expired entries are reclaimed even though they are never read.
+	for i := 0; i < 1000; i++ {
+		_ = storage.Set(&testKeyVal{strconv.Itoa(i), i})
+		s.clock.Advance(time.Second)
+		c.Assert(storage.Stats().Entries <= 60, Equals, true)
+	}
+
+	stats := storage.Stats()
+	c.Assert(stats.Entries, Equals, 59)
+	c.Assert(stats.Expirations, Equals, uint64(941))
+	c.Assert(stats.Misses, Equals, uint64(0))
+
+	s.clock.Advance(time.Minute)
+	c.Assert(storage.Stats().Entries, Equals, 0)
+}
+
+func (s *BoundedLocalMapStorageSuite) TestExpiredPurgedBeforeEviction(
+	c *C) {
+
+	storage := s.newStorage(c, LocalMapStorageOptions{
+		MaxEntries:     2,
+		EvictionPolicy: LFUEviction,
+		TTLFunc: func(item interface{}) time.Duration {
+			return time.Duration(item.(*testKeyVal).val) * time.Second
+		},
+	})
+
+	_ = storage.Set(&testKeyVal{"a", 10})
+	_ = storage.Set(&testKeyVal{"b", 60})
+	_, _ = storage.Get("a")
+	_, _ = storage.Get("a")
+
+	// The expired (but frequently used) item is removed instead of
+	// evicting a live item.
+	s.clock.Advance(20 * time.Second)
+	_ = storage.Set(&testKeyVal{"c", 60})
+
+	c.Assert(
+		s.keys(c, storage, "a", "b", "c"),
+		DeepEquals,
+		[]interface{}{"b", "c"})
+	c.Assert(s.evicted, DeepEquals, []evictedItem{
+		{"a", EvictedForExpiration},
+	})
+}
+
+func (s *BoundedLocalMapStorageSuite) TestDeleteAndFlush(c *C) {
+	storage := s.newStorage(c, LocalMapStorageOptions{MaxEntries: 2})
+
+	_ = storage.SetMulti(&testKeyVal{"a", 1}, &testKeyVal{"b", 2})
+	c.Assert(storage.Delete("a"), IsNil)
+	_ = storage.Set(&testKeyVal{"c", 3})
+	c.Assert(storage.Stats().Entries, Equals, 2)
+
+	c.Assert(storage.Flush(), IsNil)
+	c.Assert(storage.Stats().Entries, Equals, 0)
+
+	// Explicit removals are not evictions.
+	c.Assert(s.evicted, HasLen, 0)
+}
@@ -0,0 +1,301 @@
+package caching
+
+import (
+	"encoding/json"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/dropbox/godropbox/memcache"
+)
+
+// Codec converts items to / from memcache values.
+type Codec interface {
+	// This serializes the item into a memcache value and flags.
+	Encode(item interface{}) (value []byte, flags uint32, err error)
+
+	// This deserializes the memcache value and flags into an item.
+	Decode(value []byte, flags uint32) (item interface{}, err error)
+}
+
+// A Codec which serializes items as json.
+type jsonCodec struct {
+	newItem func() interface{}
+}
+
+// This returns a Codec which serializes items as json.  newItem must return
+// a pointer to a new (empty) item, which the value is unmarshaled into.
+func NewJSONCodec(newItem func() interface{}) Codec {
+	return &jsonCodec{newItem: newItem}
+}
+
+func (c *jsonCodec) Encode(item interface{}) ([]byte, uint32, error) {
+	value, err := json.Marshal(item)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "Failed to encode item")
+	}
+	return value, 0, nil
+}
+
+func (c *jsonCodec) Decode(value []byte, flags uint32) (interface{}, error) {
+	item := c.newItem()
+	if err := json.Unmarshal(value, item); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode item")
+	}
+	return item, nil
+}
+
+// Options used in MemcacheStorage construction.
+type MemcacheStorageOptions struct {
+	// These map keys and items to memcache keys.  Both are required.
+	KeyStringFunc       ToStringFunc
+	ItemToKeyStringFunc ToStringFunc
+
+	// Converts items to / from memcache values.  Required.
+	Codec Codec
+
+	// The expiration of stored items (see memcache.Item.Expiration for the
+	// expiration format).  Zero means the items never expire.
+	Expiration uint32
+
+	// The maximum number of keys (or items) sent in a single memcache multi
+	// request.  Larger requests are split into batches.  A non-positive value
+	// means requests are never split.
+	MaxBatchSize int
+
+	// When both GetCasIdFunc and SetCasIdFunc are specified, Set and SetMulti
+	// are CAS-aware:  SetCasIdFunc is called with the item's memcache data
+	// version id (aka CAS) when the item is retrieved, items for which
+	// GetCasIdFunc returns a nonzero id are stored using check-and-set, and
+	// items with zero ids (e.g., cache fills) are stored using add.  When a
+	// check-and-set or add fails (i.e., the item was modified, evicted or
+	// filled concurrently), the memcache entry is deleted instead, so the
+	// next Get reads through to the backing storage.
+	GetCasIdFunc func(item interface{}) uint64
+	SetCasIdFunc func(item interface{}, casId uint64)
+}
+
+// A storage implementation backed by memcache.  This is typically used as the
+// cache layer of CacheOnStorage.  Missing entries are returned as nil items.
+//
+// NOTE: Flush always returns error, since flushing memcache would wipe the
+// entries of every user sharing the memcache cluster.
+type MemcacheStorage struct {
+	name    string
+	client  memcache.Client
+	options MemcacheStorageOptions
+}
+
+// This returns a MemcacheStorage.  See MemcacheStorageOptions for additional
+// information.
+func NewMemcacheStorage(
+	name string,
+	client memcache.Client,
+	options MemcacheStorageOptions) (Storage, error) {
+
+	if options.KeyStringFunc == nil || options.ItemToKeyStringFunc == nil {
+		return nil, errors.Newf("'%s' is missing key string funcs", name)
+	}
+	if options.Codec == nil {
+		return nil, errors.Newf("'%s' is missing codec", name)
+	}
+	if (options.GetCasIdFunc == nil) != (options.SetCasIdFunc == nil) {
+		return nil, errors.Newf(
+			"'%s' must set both or neither of GetCasIdFunc and SetCasIdFunc",
+			name)
+	}
+
+	return &MemcacheStorage{
+		name:    name,
+		client:  client,
+		options: options,
+	}, nil
+}
+
+func (s *MemcacheStorage) isCasAware() bool {
+	return s.options.GetCasIdFunc != nil
+}
+
+// Splits [0, n) into [start, end) batches of at most MaxBatchSize.
+func (s *MemcacheStorage) batches(n int) [][2]int {
+	size := s.options.MaxBatchSize
+	if size <= 0 || size > n {
+		size = n
+	}
+
+	results := [][2]int{}
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		results = append(results, [2]int{start, end})
+	}
+	return results
+}
+
+func (s *MemcacheStorage) decode(resp memcache.GetResponse) (
+	interface{},
+	error) {
+
+	if resp.Status() == memcache.StatusKeyNotFound {
+		return nil, nil
+	}
+	if err := resp.Error(); err != nil {
+		return nil, errors.Wrapf(
+			err,
+			"'%s' failed to get %s",
+			s.name,
+			resp.Key())
+	}
+
+	item, err := s.options.Codec.Decode(resp.Value(), resp.Flags())
+	if err != nil {
+		return nil, err
+	}
+	if s.isCasAware() {
+		s.options.SetCasIdFunc(item, resp.DataVersionId())
+	}
+	return item, nil
+}
+
+// See Storage for documentation.
+func (s *MemcacheStorage) Get(key interface{}) (interface{}, error) {
+	return s.decode(s.client.Get(s.options.KeyStringFunc(key)))
+}
+
+// See Storage for documentation.
+func (s *MemcacheStorage) GetMulti(
+	keys ...interface{}) ([]interface{}, error) {
+
+	keyStrings := make([]string, len(keys))
+	for i, key := range keys {
+		keyStrings[i] = s.options.KeyStringFunc(key)
+	}
+
+	responses := make(map[string]memcache.GetResponse, len(keys))
+	for _, batch := range s.batches(len(keyStrings)) {
+		batchKeys := keyStrings[batch[0]:batch[1]]
+		for key, resp := range s.client.GetMulti(batchKeys) {
+			responses[key] = resp
+		}
+	}
+
+	results := make([]interface{}, len(keys))
+	for i, key := range keyStrings {
+		resp, ok := responses[key]
+		if !ok {
+			return nil, errors.Newf(
+				"'%s' is missing get response for %s",
+				s.name,
+				key)
+		}
+
+		item, err := s.decode(resp)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = item
+	}
+	return results, nil
+}
+
+// See Storage/MemcacheStorageOptions for documentation.
+func (s *MemcacheStorage) Set(item interface{}) error {
+	return s.SetMulti(item)
+}
+
+// See Storage/MemcacheStorageOptions for documentation.
+func (s *MemcacheStorage) SetMulti(items ...interface{}) error {
+	memcacheItems := make([]*memcache.Item, len(items))
+	for i, item := range items {
+		value, flags, err := s.options.Codec.Encode(item)
+		if err != nil {
+			return err
+		}
+
+		memcacheItems[i] = &memcache.Item{
+			Key:        s.options.ItemToKeyStringFunc(item),
+			Value:      value,
+			Flags:      flags,
+			Expiration: s.options.Expiration,
+		}
+		if s.isCasAware() {
+			memcacheItems[i].DataVersionId = s.options.GetCasIdFunc(item)
+		}
+	}
+
+	conflicts := []string{}
+	for _, batch := range s.batches(len(memcacheItems)) {
+		batchItems := memcacheItems[batch[0]:batch[1]]
+
+		var responses []memcache.MutateResponse
+		if s.isCasAware() {
+			responses = s.client.CasMulti(batchItems)
+		} else {
+			responses = s.client.SetMulti(batchItems)
+		}
+
+		for _, resp := range responses {
+			status := resp.Status()
+			if s.isCasAware() &&
+				(status == memcache.StatusKeyExists ||
+					status == memcache.StatusKeyNotFound ||
+					status == memcache.StatusItemNotStored) {
+
+				conflicts = append(conflicts, resp.Key())
+				continue
+			}
+
+			if err := resp.Error(); err != nil {
+				return errors.Wrapf(
+					err,
+					"'%s' failed to set %s",
+					s.name,
+					resp.Key())
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return s.deleteKeyStrings(conflicts)
+	}
+	return nil
+}
+
+func (s *MemcacheStorage) deleteKeyStrings(keys []string) error {
+	for _, batch := range s.batches(len(keys)) {
+		batchKeys := keys[batch[0]:batch[1]]
+		for _, resp := range s.client.DeleteMulti(batchKeys) {
+			if resp.Status() == memcache.StatusKeyNotFound {
+				continue
+			}
+
+			if err := resp.Error(); err != nil {
+				return errors.Wrapf(
+					err,
+					"'%s' failed to delete %s",
+					s.name,
+					resp.Key())
+			}
+		}
+	}
+	return nil
+}
+
+// See Storage for documentation.
+func (s *MemcacheStorage) Delete(key interface{}) error {
+	return s.DeleteMulti(key)
+}
+
+// See Storage for documentation.
+func (s *MemcacheStorage) DeleteMulti(keys ...interface{}) error {
+	keyStrings := make([]string, len(keys))
+	for i, key := range keys {
+		keyStrings[i] = s.options.KeyStringFunc(key)
+	}
+	return s.deleteKeyStrings(keyStrings)
+}
+
+// See MemcacheStorage for documentation.
+func (s *MemcacheStorage) Flush() error {
+	return errors.Newf("'%s' does not support Flush", s.name)
+}
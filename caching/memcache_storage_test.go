@@ -0,0 +1,216 @@
+package caching
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/dropbox/godropbox/memcache"
+)
+
+type testRow struct {
+	Key   string
+	Value int
+
+	casId uint64
+}
+
+func testRowKeyStr(item interface{}) string {
+	return item.(*testRow).Key
+}
+
+type MemcacheStorageSuite struct {
+	client  *memcache.MockClient
+	storage Storage
+}
+
+var _ = Suite(&MemcacheStorageSuite{})
+
+func (s *MemcacheStorageSuite) newStorage(
+	c *C,
+	client memcache.Client,
+	casAware bool) Storage {
+
+	options := MemcacheStorageOptions{
+		KeyStringFunc:       testKeyStr,
+		ItemToKeyStringFunc: testRowKeyStr,
+		Codec: NewJSONCodec(func() interface{} {
+			return &testRow{}
+		}),
+		MaxBatchSize: 2,
+	}
+	if casAware {
+		options.GetCasIdFunc = func(item interface{}) uint64 {
+			return item.(*testRow).casId
+		}
+		options.SetCasIdFunc = func(item interface{}, casId uint64) {
+			item.(*testRow).casId = casId
+		}
+	}
+
+	storage, err := NewMemcacheStorage("memcache", client, options)
+	c.Assert(err, IsNil)
+	return storage
+}
+
+func (s *MemcacheStorageSuite) SetUpTest(c *C) {
+	s.client = memcache.NewMockClientWithOptions(memcache.MockClientOptions{})
+	s.storage = s.newStorage(c, s.client, true)
+}
+
+func (s *MemcacheStorageSuite) TestInvalidOptions(c *C) {
+	_, err := NewMemcacheStorage(
+		"memcache",
+		s.client,
+		MemcacheStorageOptions{})
+	c.Assert(err, NotNil)
+
+	_, err = NewMemcacheStorage(
+		"memcache",
+		s.client,
+		MemcacheStorageOptions{
+			KeyStringFunc:       testKeyStr,
+			ItemToKeyStringFunc: testRowKeyStr,
+			Codec:               NewJSONCodec(func() interface{} { return &testRow{} }),
+			GetCasIdFunc:        func(item interface{}) uint64 { return 0 },
+		})
+	c.Assert(err, NotNil)
+}
+
+func (s *MemcacheStorageSuite) TestGetAndSet(c *C) {
+	result, err := s.storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(result, IsNil)
+
+	c.Assert(s.storage.Set(&testRow{Key: "foo", Value: 1}), IsNil)
+
+	result, err = s.storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(result.(*testRow).Key, Equals, "foo")
+	c.Assert(result.(*testRow).Value, Equals, 1)
+	c.Assert(result.(*testRow).casId, Not(Equals), uint64(0))
+}
+
+func (s *MemcacheStorageSuite) TestMultiBatching(c *C) {
+	err := s.storage.SetMulti(
+		&testRow{Key: "a", Value: 1},
+		&testRow{Key: "b", Value: 2},
+		&testRow{Key: "c", Value: 3})
+	c.Assert(err, IsNil)
+
+	results, err := s.storage.GetMulti("c", "zzz", "a", "b")
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 4)
+	c.Assert(results[0].(*testRow).Value, Equals, 3)
+	c.Assert(results[1], IsNil)
+	c.Assert(results[2].(*testRow).Value, Equals, 1)
+	c.Assert(results[3].(*testRow).Value, Equals, 2)
+
+	c.Assert(s.storage.DeleteMulti("a", "b", "zzz"), IsNil)
+	c.Assert(s.client.NumItems(), Equals, 1)
+
+	names := []string{}
+	for _, op := range s.client.Operations() {
+		c.Assert(len(op.Keys) <= 2, Equals, true)
+		names = append(names, op.Name)
+	}
+	c.Assert(
+		names,
+		DeepEquals,
+		[]string{
+			"cas_multi", "cas_multi",
+			"get_multi", "get_multi",
+			"delete_multi", "delete_multi",
+		})
+}
+
+func (s *MemcacheStorageSuite) TestCasConflict(c *C) {
+	c.Assert(s.storage.Set(&testRow{Key: "foo", Value: 1}), IsNil)
+
+	result1, err := s.storage.Get("foo")
+	c.Assert(err, IsNil)
+	result2, err := s.storage.Get("foo")
+	c.Assert(err, IsNil)
+
+	result1.(*testRow).Value = 2
+	c.Assert(s.storage.Set(result1), IsNil)
+
+	result, err := s.storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(result.(*testRow).Value, Equals, 2)
+
+	// The stale write invalidates the entry instead.
+	result2.(*testRow).Value = 3
+	c.Assert(s.storage.Set(result2), IsNil)
+
+	result, err = s.storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(result, IsNil)
+
+	// Non CAS-aware storages overwrite unconditionally.
+	storage := s.newStorage(c, s.client, false)
+	c.Assert(storage.Set(result2), IsNil)
+	c.Assert(storage.Set(result1), IsNil)
+
+	result, err = storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(result.(*testRow).Value, Equals, 2)
+}
+
+func (s *MemcacheStorageSuite) TestFillInvalidateRace(c *C) {
+	// A reader fills a stale item read from the backing storage after the
+	// writer stored the new item.
+	c.Assert(s.storage.Set(&testRow{Key: "foo", Value: 2}), IsNil)
+	c.Assert(s.storage.Set(&testRow{Key: "foo", Value: 1}), IsNil)
+
+	result, err := s.storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(result, IsNil)
+
+	// The writer stores the new item after the reader filled the stale item.
+	c.Assert(s.storage.Set(&testRow{Key: "bar", Value: 1}), IsNil)
+	c.Assert(s.storage.Set(&testRow{Key: "bar", Value: 2}), IsNil)
+
+	result, err = s.storage.Get("bar")
+	c.Assert(err, IsNil)
+	c.Assert(result, IsNil)
+
+	// Fills are sent as adds.
+	s.client.ClearOperations()
+	c.Assert(s.storage.Set(&testRow{Key: "baz", Value: 1}), IsNil)
+	c.Assert(
+		s.client.Operations(),
+		DeepEquals,
+		[]memcache.MockOperation{{Name: "cas_multi", Keys: []string{"baz"}}})
+}
+
+func (s *MemcacheStorageSuite) TestErrors(c *C) {
+	storage := s.newStorage(c, memcache.NewMockClientFailEverything(), true)
+
+	_, err := storage.Get("foo")
+	c.Assert(err, NotNil)
+	_, err = storage.GetMulti("foo", "bar")
+	c.Assert(err, NotNil)
+	c.Assert(storage.Set(&testRow{Key: "foo"}), NotNil)
+	c.Assert(storage.Delete("foo"), NotNil)
+	c.Assert(storage.Flush(), NotNil)
+}
+
+func (s *MemcacheStorageSuite) TestCacheOnStorage(c *C) {
+	backing := NewLocalMapStorage("backing", testKeyStr, testRowKeyStr)
+	combined := NewCacheOnStorage(s.storage, backing)
+
+	c.Assert(backing.Set(&testRow{Key: "foo", Value: 10}), IsNil)
+
+	result, err := combined.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(result.(*testRow).Value, Equals, 10)
+
+	// Filled the cache.
+	result, err = s.storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(result.(*testRow).Value, Equals, 10)
+
+	c.Assert(combined.Delete("foo"), IsNil)
+	result, err = s.storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(result, IsNil)
+}
@@ -0,0 +1,242 @@
+package typed
+
+import (
+	"github.com/dropbox/godropbox/caching"
+	"github.com/dropbox/godropbox/errors"
+)
+
+// A typed view of an untyped caching.Storage.
+type untypedStorage[K comparable, V any] struct {
+	storage caching.Storage
+}
+
+// This returns a typed view of the untyped storage.  The storage's nil items
+// are treated as missing items, and items which are not of type V are
+// reported as errors.
+func FromStorage[K comparable, V any](storage caching.Storage) Storage[K, V] {
+	if typed, ok := storage.(*typedStorage[K, V]); ok {
+		return typed.storage
+	}
+	return &untypedStorage[K, V]{storage: storage}
+}
+
+func toItem[V any](item interface{}) (V, bool, error) {
+	var empty V
+	if item == nil {
+		return empty, false, nil
+	}
+
+	typed, ok := item.(V)
+	if !ok {
+		return empty, false, errors.Newf(
+			"Unexpected item type: got %T, expected %T",
+			item,
+			empty)
+	}
+	return typed, true, nil
+}
+
+// See Storage for documentation.
+func (s *untypedStorage[K, V]) Get(key K) (V, bool, error) {
+	item, err := s.storage.Get(key)
+	if err != nil {
+		var empty V
+		return empty, false, err
+	}
+	return toItem[V](item)
+}
+
+// See Storage for documentation.
+func (s *untypedStorage[K, V]) GetMulti(keys ...K) (map[K]V, error) {
+	untypedKeys := make([]interface{}, len(keys))
+	for i, key := range keys {
+		untypedKeys[i] = key
+	}
+
+	items, err := s.storage.GetMulti(untypedKeys...)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) != len(keys) {
+		return nil, errors.Newf(
+			"Unexpected number of items: got %d, expected %d",
+			len(items),
+			len(keys))
+	}
+
+	results := make(map[K]V, len(keys))
+	for i, item := range items {
+		typed, found, err := toItem[V](item)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			results[keys[i]] = typed
+		}
+	}
+	return results, nil
+}
+
+// See Storage for documentation.
+func (s *untypedStorage[K, V]) Set(item V) error {
+	return s.storage.Set(item)
+}
+
+// See Storage for documentation.
+func (s *untypedStorage[K, V]) SetMulti(items ...V) error {
+	untypedItems := make([]interface{}, len(items))
+	for i, item := range items {
+		untypedItems[i] = item
+	}
+	return s.storage.SetMulti(untypedItems...)
+}
+
+// See Storage for documentation.
+func (s *untypedStorage[K, V]) Delete(key K) error {
+	return s.storage.Delete(key)
+}
+
+// See Storage for documentation.
+func (s *untypedStorage[K, V]) DeleteMulti(keys ...K) error {
+	untypedKeys := make([]interface{}, len(keys))
+	for i, key := range keys {
+		untypedKeys[i] = key
+	}
+	return s.storage.DeleteMulti(untypedKeys...)
+}
+
+// See Storage for documentation.
+func (s *untypedStorage[K, V]) Flush() error {
+	return s.storage.Flush()
+}
+
+// An untyped caching.Storage view of a typed storage.
+type typedStorage[K comparable, V any] struct {
+	storage Storage[K, V]
+}
+
+// This returns an untyped caching.Storage view of the typed storage.  Missing
+// items are returned as nil, and keys / items which are not of type K / V
+// are reported as errors.
+func ToStorage[K comparable, V any](storage Storage[K, V]) caching.Storage {
+	if untyped, ok := storage.(*untypedStorage[K, V]); ok {
+		return untyped.storage
+	}
+	return &typedStorage[K, V]{storage: storage}
+}
+
+func toKey[K comparable](key interface{}) (K, error) {
+	typed, ok := key.(K)
+	if !ok {
+		return typed, errors.Newf(
+			"Unexpected key type: got %T, expected %T",
+			key,
+			typed)
+	}
+	return typed, nil
+}
+
+func toKeys[K comparable](keys []interface{}) ([]K, error) {
+	typedKeys := make([]K, len(keys))
+	for i, key := range keys {
+		typed, err := toKey[K](key)
+		if err != nil {
+			return nil, err
+		}
+		typedKeys[i] = typed
+	}
+	return typedKeys, nil
+}
+
+func toItems[V any](items []interface{}) ([]V, error) {
+	typedItems := make([]V, len(items))
+	for i, item := range items {
+		typed, ok := item.(V)
+		if !ok {
+			return nil, errors.Newf(
+				"Unexpected item type: got %T, expected %T",
+				item,
+				typed)
+		}
+		typedItems[i] = typed
+	}
+	return typedItems, nil
+}
+
+// See caching.Storage for documentation.
+func (s *typedStorage[K, V]) Get(key interface{}) (interface{}, error) {
+	typedKey, err := toKey[K](key)
+	if err != nil {
+		return nil, err
+	}
+
+	item, found, err := s.storage.Get(typedKey)
+	if err != nil || !found {
+		return nil, err
+	}
+	return item, nil
+}
+
+// See caching.Storage for documentation.
+func (s *typedStorage[K, V]) GetMulti(
+	keys ...interface{}) ([]interface{}, error) {
+
+	typedKeys, err := toKeys[K](keys)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.storage.GetMulti(typedKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]interface{}, len(keys))
+	for i, key := range typedKeys {
+		if item, found := items[key]; found {
+			results[i] = item
+		}
+	}
+	return results, nil
+}
+
+// See caching.Storage for documentation.
+func (s *typedStorage[K, V]) Set(item interface{}) error {
+	typedItems, err := toItems[V]([]interface{}{item})
+	if err != nil {
+		return err
+	}
+	return s.storage.Set(typedItems[0])
+}
+
+// See caching.Storage for documentation.
+func (s *typedStorage[K, V]) SetMulti(items ...interface{}) error {
+	typedItems, err := toItems[V](items)
+	if err != nil {
+		return err
+	}
+	return s.storage.SetMulti(typedItems...)
+}
+
+// See caching.Storage for documentation.
+func (s *typedStorage[K, V]) Delete(key interface{}) error {
+	typedKey, err := toKey[K](key)
+	if err != nil {
+		return err
+	}
+	return s.storage.Delete(typedKey)
+}
+
+// See caching.Storage for documentation.
+func (s *typedStorage[K, V]) DeleteMulti(keys ...interface{}) error {
+	typedKeys, err := toKeys[K](keys)
+	if err != nil {
+		return err
+	}
+	return s.storage.DeleteMulti(typedKeys...)
+}
+
+// See caching.Storage for documentation.
+func (s *typedStorage[K, V]) Flush() error {
+	return s.storage.Flush()
+}
@@ -0,0 +1,86 @@
+package typed
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/dropbox/godropbox/caching"
+)
+
+type AdaptersSuite struct {
+	untyped caching.Storage
+	typed   Storage[string, *testKeyVal]
+}
+
+var _ = Suite(&AdaptersSuite{})
+
+func (s *AdaptersSuite) SetUpTest(c *C) {
+	s.untyped = caching.NewLocalMapStorage(
+		"untyped",
+		func(key interface{}) string { return key.(string) },
+		func(item interface{}) string { return item.(*testKeyVal).key })
+	s.typed = FromStorage[string, *testKeyVal](s.untyped)
+}
+
+func (s *AdaptersSuite) TestFromStorage(c *C) {
+	c.Assert(s.typed.SetMulti(&testKeyVal{"foo", 1}, &testKeyVal{"bar", 2}), IsNil)
+
+	item, err := s.untyped.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(item.(*testKeyVal).val, Equals, 1)
+
+	result, found, err := s.typed.Get("bar")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(result.val, Equals, 2)
+
+	_, found, err = s.typed.Get("zzz")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+
+	results, err := s.typed.GetMulti("foo", "zzz", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 2)
+	c.Assert(results["foo"].val, Equals, 1)
+
+	c.Assert(s.typed.DeleteMulti("foo", "bar"), IsNil)
+	results, err = s.typed.GetMulti("foo", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 0)
+}
+
+func (s *AdaptersSuite) TestFromStorageWrongItemType(c *C) {
+	c.Assert(s.untyped.Set(&testKeyVal{"foo", 1}), IsNil)
+
+	wrong := FromStorage[string, string](s.untyped)
+	_, _, err := wrong.Get("foo")
+	c.Assert(err, NotNil)
+}
+
+func (s *AdaptersSuite) TestToStorage(c *C) {
+	local := NewLocalMapStorage("typed", testItemKey)
+	untyped := ToStorage(local)
+
+	c.Assert(untyped.Set(&testKeyVal{"foo", 1}), IsNil)
+	c.Assert(untyped.Set("not an item"), NotNil)
+
+	items, err := untyped.GetMulti("foo", "zzz")
+	c.Assert(err, IsNil)
+	c.Assert(items, HasLen, 2)
+	c.Assert(items[0].(*testKeyVal).val, Equals, 1)
+	c.Assert(items[1], IsNil)
+
+	_, err = untyped.Get(1)
+	c.Assert(err, NotNil)
+
+	// Typed storages can be layered under untyped storages.
+	combined := caching.NewCacheOnStorage(s.untyped, untyped)
+	item, err := combined.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(item.(*testKeyVal).val, Equals, 1)
+	cached, err := s.untyped.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(cached, NotNil)
+
+	c.Assert(ToStorage(s.typed), Equals, s.untyped)
+	c.Assert(FromStorage[string, *testKeyVal](untyped), Equals, local)
+}
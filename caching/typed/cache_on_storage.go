@@ -0,0 +1,129 @@
+package typed
+
+// A storage implementation where a cache is layered on top of a storage.  This
+// implementation DOES NOT ensure data consistent between cache and storage
+// when setting items.  See caching.CacheOnStorage for additional information.
+type CacheOnStorage[K comparable, V any] struct {
+	cache   Storage[K, V]
+	storage Storage[K, V]
+}
+
+// This returns a CacheOnStorage, which adds a cache layer on top of the
+// storage.
+func NewCacheOnStorage[K comparable, V any](
+	cache Storage[K, V],
+	storage Storage[K, V]) Storage[K, V] {
+
+	return &CacheOnStorage[K, V]{
+		cache:   cache,
+		storage: storage,
+	}
+}
+
+// See Storage for documentation.
+func (s *CacheOnStorage[K, V]) Get(key K) (V, bool, error) {
+	var empty V
+	if item, found, err := s.cache.Get(key); err != nil {
+		return empty, false, err
+	} else if found {
+		return item, true, nil
+	}
+
+	item, found, err := s.storage.Get(key)
+	if err != nil || !found {
+		return empty, false, err
+	}
+
+	if err := s.cache.Set(item); err != nil {
+		// XXX: Maybe make this a non error
+		return empty, false, err
+	}
+	return item, true, nil
+}
+
+// See Storage for documentation.
+func (s *CacheOnStorage[K, V]) GetMulti(keys ...K) (map[K]V, error) {
+	results, err := s.cache.GetMulti(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	uncachedKeys := make([]K, 0, len(keys))
+	for _, key := range keys {
+		if _, found := results[key]; !found {
+			uncachedKeys = append(uncachedKeys, key)
+		}
+	}
+	if len(uncachedKeys) == 0 {
+		return results, nil
+	}
+
+	uncachedItems, err := s.storage.GetMulti(uncachedKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	foundItems := make([]V, 0, len(uncachedItems))
+	for _, item := range uncachedItems {
+		foundItems = append(foundItems, item)
+	}
+
+	if err := s.cache.SetMulti(foundItems...); err != nil {
+		// XXX: Maybe make this a non error
+		return nil, err
+	}
+
+	if results == nil {
+		results = make(map[K]V, len(uncachedItems))
+	}
+	for key, item := range uncachedItems {
+		results[key] = item
+	}
+
+	return results, nil
+}
+
+// See Storage for documentation.
+func (s *CacheOnStorage[K, V]) Set(item V) error {
+	if err := s.storage.Set(item); err != nil {
+		return err
+	}
+
+	return s.cache.Set(item)
+}
+
+// See Storage for documentation.
+func (s *CacheOnStorage[K, V]) SetMulti(items ...V) error {
+	if err := s.storage.SetMulti(items...); err != nil {
+		return err
+	}
+
+	return s.cache.SetMulti(items...)
+}
+
+// See Storage for documentation.
+func (s *CacheOnStorage[K, V]) Delete(key K) error {
+	if err := s.storage.Delete(key); err != nil {
+		return err
+	}
+
+	return s.cache.Delete(key)
+}
+
+// See Storage for documentation.
+func (s *CacheOnStorage[K, V]) DeleteMulti(keys ...K) error {
+	if err := s.storage.DeleteMulti(keys...); err != nil {
+		return err
+	}
+
+	return s.cache.DeleteMulti(keys...)
+}
+
+// See Storage for documentation.
+func (s *CacheOnStorage[K, V]) Flush() error {
+	if err := s.storage.Flush(); err != nil {
+		return err
+	}
+
+	return s.cache.Flush()
+}
@@ -0,0 +1,87 @@
+package typed
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type CacheOnStorageSuite struct {
+	cache    Storage[string, *testKeyVal]
+	storage  Storage[string, *testKeyVal]
+	combined Storage[string, *testKeyVal]
+}
+
+var _ = Suite(&CacheOnStorageSuite{})
+
+func (s *CacheOnStorageSuite) SetUpTest(c *C) {
+	s.cache = NewLocalMapStorage("cache", testItemKey)
+	s.storage = NewLocalMapStorage("storage", testItemKey)
+	s.combined = NewCacheOnStorage(
+		s.cache,
+		NewRateLimitedStorage(s.storage, 2))
+}
+
+func (s *CacheOnStorageSuite) TestGet(c *C) {
+	_ = s.cache.Set(&testKeyVal{"foo", 1})
+	_ = s.storage.Set(&testKeyVal{"foo", 10})
+	_ = s.storage.Set(&testKeyVal{"bar", 20})
+
+	// Cache hit
+	result, found, err := s.combined.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(result.val, Equals, 1)
+
+	// Cache miss
+	result, found, err = s.combined.Get("bar")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(result.val, Equals, 20)
+
+	result, found, err = s.cache.Get("bar")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(result.val, Equals, 20)
+
+	// Missing
+	_, found, err = s.combined.Get("zzz")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+}
+
+func (s *CacheOnStorageSuite) TestGetMulti(c *C) {
+	_ = s.cache.Set(&testKeyVal{"zzz", 1})
+	_ = s.storage.Set(&testKeyVal{"foo", 10})
+	_ = s.storage.Set(&testKeyVal{"zzz", 123})
+
+	results, err := s.combined.GetMulti("foo", "bar", "zzz")
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 2)
+	c.Assert(results["foo"].val, Equals, 10)
+	c.Assert(results["zzz"].val, Equals, 1)
+
+	// cache set
+	result, found, err := s.cache.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(result.val, Equals, 10)
+}
+
+func (s *CacheOnStorageSuite) TestWrites(c *C) {
+	c.Assert(
+		s.combined.SetMulti(&testKeyVal{"foo", 1}, &testKeyVal{"bar", 2}),
+		IsNil)
+
+	for _, storage := range []Storage[string, *testKeyVal]{s.cache, s.storage} {
+		results, err := storage.GetMulti("foo", "bar")
+		c.Assert(err, IsNil)
+		c.Assert(results, HasLen, 2)
+	}
+
+	c.Assert(s.combined.DeleteMulti("foo", "bar"), IsNil)
+
+	for _, storage := range []Storage[string, *testKeyVal]{s.cache, s.storage} {
+		results, err := storage.GetMulti("foo", "bar")
+		c.Assert(err, IsNil)
+		c.Assert(results, HasLen, 0)
+	}
+}
@@ -0,0 +1,222 @@
+package typed
+
+import (
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+// Options used in GenericStorage construction.
+type GenericStorageOptions[K comparable, V any] struct {
+	// GenericStorage will call either GetFunc or GetMultiFunc in its
+	// Get and GetMulti implementations.  When neither one is available,
+	// GenericStorage will return error.
+	GetFunc      func(key K) (V, bool, error)
+	GetMultiFunc func(keys ...K) (map[K]V, error)
+
+	// GenericStorage will call either SetFunc or SetMultiFunc in its
+	// Set and SetMulti implementations.  When neither one is available,
+	// GenericStorage will return error.
+	SetFunc      func(item V) error
+	SetMultiFunc func(items ...V) error
+
+	// GenericStorage will call either DelFunc or DelMultiFunc in its
+	// Del and DelMulti implementations.  When neither one is available,
+	// GenericStorage will return error.
+	DelFunc      func(key K) error
+	DelMultiFunc func(keys ...K) error
+
+	// When ErrorOnFlush is true, GenericStorage will always return error
+	// on Flush calls.
+	ErrorOnFlush bool
+
+	// GenericStorage will call FlushFunc in its Flush implementation.  When
+	// FlushFunc is unavailable (and ErrorOnFlush is false), GenericStorage
+	// will do nothing and return nil.
+	FlushFunc func() error
+}
+
+// A generic storage implementation.  The functionalities are provided by the
+// user through GenericStorageOptions.
+type GenericStorage[K comparable, V any] struct {
+	name string
+
+	rwMutex sync.RWMutex
+
+	get      func(key K) (V, bool, error)
+	getMulti func(keys ...K) (map[K]V, error)
+	set      func(item V) error
+	setMulti func(items ...V) error
+	del      func(key K) error
+	delMulti func(keys ...K) error
+
+	errorOnFlush bool
+	flush        func() error
+}
+
+// This creates a GenericStorage.  See GenericStorageOptions for additional
+// information.
+func NewGenericStorage[K comparable, V any](
+	name string,
+	options GenericStorageOptions[K, V]) Storage[K, V] {
+
+	return &GenericStorage[K, V]{
+		name:         name,
+		get:          options.GetFunc,
+		getMulti:     options.GetMultiFunc,
+		set:          options.SetFunc,
+		setMulti:     options.SetMultiFunc,
+		del:          options.DelFunc,
+		delMulti:     options.DelMultiFunc,
+		errorOnFlush: options.ErrorOnFlush,
+		flush:        options.FlushFunc,
+	}
+}
+
+// See Storage/GenericStorageOptions for documentation.
+func (s *GenericStorage[K, V]) Get(key K) (V, bool, error) {
+	var empty V
+	if s.get == nil && s.getMulti == nil {
+		return empty, false, errors.Newf(
+			"'%s' does not have Get/GetMulti implementation",
+			s.name)
+	}
+
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+
+	if s.get != nil {
+		return s.get(key)
+	}
+
+	items, err := s.getMulti(key)
+	if err != nil {
+		return empty, false, err
+	}
+	item, found := items[key]
+	return item, found, nil
+}
+
+// See Storage/GenericStorageOptions for documentation.
+func (s *GenericStorage[K, V]) GetMulti(keys ...K) (map[K]V, error) {
+	if s.get == nil && s.getMulti == nil {
+		return nil, errors.Newf(
+			"'%s' does not have Get/GetMulti implementation",
+			s.name)
+	}
+
+	s.rwMutex.RLock()
+	defer s.rwMutex.RUnlock()
+
+	if s.getMulti != nil {
+		return s.getMulti(keys...)
+	}
+
+	results := make(map[K]V, len(keys))
+	for _, key := range keys {
+		item, found, err := s.get(key)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			results[key] = item
+		}
+	}
+	return results, nil
+}
+
+// See Storage/GenericStorageOptions for documentation.
+func (s *GenericStorage[K, V]) Set(item V) error {
+	if s.set == nil && s.setMulti == nil {
+		return errors.Newf(
+			"'%s' does not have Set/SetMulti implementation",
+			s.name)
+	}
+
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+
+	if s.set != nil {
+		return s.set(item)
+	}
+	return s.setMulti(item)
+}
+
+// See Storage/GenericStorageOptions for documentation.
+func (s *GenericStorage[K, V]) SetMulti(items ...V) error {
+	if s.set == nil && s.setMulti == nil {
+		return errors.Newf(
+			"'%s' does not have Set/SetMulti implementation",
+			s.name)
+	}
+
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+
+	if s.setMulti != nil {
+		return s.setMulti(items...)
+	}
+
+	for _, item := range items {
+		if err := s.set(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// See Storage/GenericStorageOptions for documentation.
+func (s *GenericStorage[K, V]) Delete(key K) error {
+	if s.del == nil && s.delMulti == nil {
+		return errors.Newf(
+			"'%s' does not have Delete/DeleteMulti implementation",
+			s.name)
+	}
+
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+
+	if s.del != nil {
+		return s.del(key)
+	}
+	return s.delMulti(key)
+}
+
+// See Storage/GenericStorageOptions for documentation.
+func (s *GenericStorage[K, V]) DeleteMulti(keys ...K) error {
+	if s.del == nil && s.delMulti == nil {
+		return errors.Newf(
+			"'%s' does not have Delete/DeleteMulti implementation",
+			s.name)
+	}
+
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+
+	if s.delMulti != nil {
+		return s.delMulti(keys...)
+	}
+
+	for _, key := range keys {
+		if err := s.del(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// See Storage/GenericStorageOptions for documentation.
+func (s *GenericStorage[K, V]) Flush() error {
+	if s.errorOnFlush {
+		return errors.Newf("'%s' does not support Flush", s.name)
+	}
+
+	if s.flush == nil {
+		return nil
+	}
+
+	s.rwMutex.Lock()
+	defer s.rwMutex.Unlock()
+
+	return s.flush()
+}
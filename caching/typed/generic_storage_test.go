@@ -0,0 +1,84 @@
+package typed
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into go test runner
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type GenericStorageSuite struct {
+	local   Storage[string, *testKeyVal]
+	generic Storage[string, *testKeyVal]
+}
+
+var _ = Suite(&GenericStorageSuite{})
+
+func (s *GenericStorageSuite) SetUpTest(c *C) {
+	// NOTE: GenericStorage using GetFunc, SetFunc, DelFunc, and FlushFunc are
+	// tested via local map storage.
+	s.local = NewLocalMapStorage("local", testItemKey)
+
+	options := GenericStorageOptions[string, *testKeyVal]{
+		GetMultiFunc: s.local.GetMulti,
+		SetMultiFunc: s.local.SetMulti,
+		DelMultiFunc: s.local.DeleteMulti,
+		ErrorOnFlush: true,
+	}
+
+	s.generic = NewGenericStorage("generic", options)
+}
+
+func (s *GenericStorageSuite) TestGet(c *C) {
+	_ = s.local.Set(&testKeyVal{"foo", 1})
+
+	result, found, err := s.generic.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(result.val, Equals, 1)
+
+	result, found, err = s.generic.Get("zzz")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+	c.Assert(result, IsNil)
+}
+
+func (s *GenericStorageSuite) TestSetAndDelete(c *C) {
+	c.Assert(s.generic.Set(&testKeyVal{"foo", 1}), IsNil)
+	c.Assert(s.generic.Set(&testKeyVal{"bar", 2}), IsNil)
+
+	results, err := s.local.GetMulti("foo", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 2)
+
+	c.Assert(s.generic.Delete("foo"), IsNil)
+
+	results, err = s.generic.GetMulti("foo", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+	c.Assert(results["bar"].val, Equals, 2)
+}
+
+func (s *GenericStorageSuite) TestFlush(c *C) {
+	c.Assert(s.generic.Flush(), NotNil)
+}
+
+func (s *GenericStorageSuite) TestMissingFuncs(c *C) {
+	storage := NewGenericStorage(
+		"empty",
+		GenericStorageOptions[string, int]{})
+
+	_, _, err := storage.Get("foo")
+	c.Assert(err, NotNil)
+	_, err = storage.GetMulti("foo")
+	c.Assert(err, NotNil)
+	c.Assert(storage.Set(1), NotNil)
+	c.Assert(storage.SetMulti(1), NotNil)
+	c.Assert(storage.Delete("foo"), NotNil)
+	c.Assert(storage.DeleteMulti("foo"), NotNil)
+	c.Assert(storage.Flush(), IsNil)
+}
@@ -0,0 +1,37 @@
+// Package typed provides a generics-based variant of the caching package's
+// Storage API, so that key and item types are checked at compile time.  Use
+// FromStorage / ToStorage to mix typed and untyped storages in the same
+// caching stack.
+package typed
+
+// A typed key value storage interface.  The storage may be persistent (e.g.,
+// a database) or volatile (e.g., cache).  Similar to caching.Storage, the
+// storage stores items, and each item's key is derived from the item itself.
+// All Storage implementations must be thread safe.
+type Storage[K comparable, V any] interface {
+	// This retrieves a single item from the storage.  found is false when
+	// the item is not in the storage.
+	Get(key K) (item V, found bool, err error)
+
+	// This retrieves multiple items from the storage.  Items which are not
+	// in the storage are omitted from the result.
+	GetMulti(keys ...K) (map[K]V, error)
+
+	// This stores a single item into the storage.
+	Set(item V) error
+
+	// This stores multiple items into the storage.
+	SetMulti(items ...V) error
+
+	// This removes a single item from the storage.
+	Delete(key K) error
+
+	// This removes multiple items from the storage.
+	DeleteMulti(keys ...K) error
+
+	// This wipes all items from the storage.
+	Flush() error
+}
+
+// Returns the item's key.
+type KeyFunc[K comparable, V any] func(item V) K
@@ -0,0 +1,60 @@
+package typed
+
+type localMapStorage[K comparable, V any] struct {
+	itemKeyFunc KeyFunc[K, V]
+
+	keyVal map[K]V
+}
+
+func (s *localMapStorage[K, V]) get(key K) (V, bool, error) {
+	item, inMap := s.keyVal[key]
+	return item, inMap, nil
+}
+
+func (s *localMapStorage[K, V]) set(item V) error {
+	s.keyVal[s.itemKeyFunc(item)] = item
+	return nil
+}
+
+func (s *localMapStorage[K, V]) del(key K) error {
+	delete(s.keyVal, key)
+	return nil
+}
+
+func (s *localMapStorage[K, V]) flush() error {
+	s.keyVal = make(map[K]V)
+	return nil
+}
+
+func (s *localMapStorage[K, V]) size() int {
+	return len(s.keyVal)
+}
+
+func newLocalMapStorage[K comparable, V any](
+	name string,
+	itemKeyFunc KeyFunc[K, V]) (*localMapStorage[K, V], Storage[K, V]) {
+
+	storage := &localMapStorage[K, V]{
+		itemKeyFunc: itemKeyFunc,
+		keyVal:      make(map[K]V),
+	}
+
+	options := GenericStorageOptions[K, V]{
+		GetFunc:   storage.get,
+		SetFunc:   storage.set,
+		DelFunc:   storage.del,
+		FlushFunc: storage.flush,
+	}
+
+	return storage, NewGenericStorage(name, options)
+}
+
+// This returns a local non-persistent storage which uses map[K]V as its
+// underlying storage.
+func NewLocalMapStorage[K comparable, V any](
+	name string,
+	itemKeyFunc KeyFunc[K, V]) Storage[K, V] {
+
+	_, storage := newLocalMapStorage(name, itemKeyFunc)
+	return storage
+}
@@ -0,0 +1,83 @@
+package typed
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type testKeyVal struct {
+	key string
+	val int
+}
+
+func testItemKey(item *testKeyVal) string {
+	return item.key
+}
+
+type LocalMapStorageSuite struct {
+	localMap *localMapStorage[string, *testKeyVal]
+	storage  Storage[string, *testKeyVal]
+}
+
+var _ = Suite(&LocalMapStorageSuite{})
+
+func (s *LocalMapStorageSuite) SetUpTest(c *C) {
+	s.localMap, s.storage = newLocalMapStorage("test", testItemKey)
+}
+
+func (s *LocalMapStorageSuite) TestGet(c *C) {
+	_ = s.localMap.set(&testKeyVal{"foo", 1})
+
+	result, found, err := s.storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(result.val, Equals, 1)
+
+	_, found, err = s.storage.Get("zzz")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+}
+
+func (s *LocalMapStorageSuite) TestGetMulti(c *C) {
+	_ = s.localMap.set(&testKeyVal{"foo", 1})
+	_ = s.localMap.set(&testKeyVal{"bar", 2})
+
+	results, err := s.storage.GetMulti("foo", "zzz", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 2)
+	c.Assert(results["foo"].val, Equals, 1)
+	c.Assert(results["bar"].val, Equals, 2)
+}
+
+func (s *LocalMapStorageSuite) TestSetMulti(c *C) {
+	err := s.storage.SetMulti(&testKeyVal{"foo", 1}, &testKeyVal{"bar", 2})
+	c.Assert(err, IsNil)
+	c.Assert(s.localMap.size(), Equals, 2)
+
+	err = s.storage.Set(&testKeyVal{"foo", 3})
+	c.Assert(err, IsNil)
+	c.Assert(s.localMap.size(), Equals, 2)
+
+	result, _, err := s.storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(result.val, Equals, 3)
+}
+
+func (s *LocalMapStorageSuite) TestDelete(c *C) {
+	_ = s.storage.SetMulti(
+		&testKeyVal{"foo", 1},
+		&testKeyVal{"bar", 2},
+		&testKeyVal{"zzz", 3})
+
+	c.Assert(s.storage.Delete("foo"), IsNil)
+	c.Assert(s.localMap.size(), Equals, 2)
+
+	c.Assert(s.storage.DeleteMulti("bar", "zzz", "missing"), IsNil)
+	c.Assert(s.localMap.size(), Equals, 0)
+}
+
+func (s *LocalMapStorageSuite) TestFlush(c *C) {
+	_ = s.storage.SetMulti(&testKeyVal{"foo", 1}, &testKeyVal{"bar", 2})
+
+	c.Assert(s.storage.Flush(), IsNil)
+	c.Assert(s.localMap.size(), Equals, 0)
+}
@@ -0,0 +1,93 @@
+package typed
+
+// A storage implementation which limits the maximum number of concurrent
+// operations.
+type RateLimitedStorage[K comparable, V any] struct {
+	semaphore chan bool
+	storage   Storage[K, V]
+}
+
+// This returns a RateLimitedStorage.  This is useful for cases where
+// high concurrent load may degrade the underlying storage's performance.
+// NOTE: when maxConcurrency is non-positive, the original storage is returned
+// (i.e., the storage is not rate limited).
+func NewRateLimitedStorage[K comparable, V any](
+	storage Storage[K, V],
+	maxConcurrency int) Storage[K, V] {
+
+	if maxConcurrency < 1 {
+		return storage
+	}
+
+	return &RateLimitedStorage[K, V]{
+		semaphore: make(chan bool, maxConcurrency),
+		storage:   storage,
+	}
+}
+
+func (s *RateLimitedStorage[K, V]) wait() {
+	s.semaphore <- true
+}
+
+func (s *RateLimitedStorage[K, V]) signal() {
+	select {
+	case <-s.semaphore:
+	default:
+	}
+}
+
+// See Storage for documentation.
+func (s *RateLimitedStorage[K, V]) Get(key K) (V, bool, error) {
+	s.wait()
+	defer s.signal()
+
+	return s.storage.Get(key)
+}
+
+// See Storage for documentation.
+func (s *RateLimitedStorage[K, V]) GetMulti(keys ...K) (map[K]V, error) {
+	s.wait()
+	defer s.signal()
+
+	return s.storage.GetMulti(keys...)
+}
+
+// See Storage for documentation.
+func (s *RateLimitedStorage[K, V]) Set(item V) error {
+	s.wait()
+	defer s.signal()
+
+	return s.storage.Set(item)
+}
+
+// See Storage for documentation.
+func (s *RateLimitedStorage[K, V]) SetMulti(items ...V) error {
+	s.wait()
+	defer s.signal()
+
+	return s.storage.SetMulti(items...)
+}
+
+// See Storage for documentation.
+func (s *RateLimitedStorage[K, V]) Delete(key K) error {
+	s.wait()
+	defer s.signal()
+
+	return s.storage.Delete(key)
+}
+
+// See Storage for documentation.
+func (s *RateLimitedStorage[K, V]) DeleteMulti(keys ...K) error {
+	s.wait()
+	defer s.signal()
+
+	return s.storage.DeleteMulti(keys...)
+}
+
+// See Storage for documentation.
+func (s *RateLimitedStorage[K, V]) Flush() error {
+	s.wait()
+	defer s.signal()
+
+	return s.storage.Flush()
+}
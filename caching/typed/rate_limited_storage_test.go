@@ -0,0 +1,136 @@
+package typed
+
+import (
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// A storage whose gets block until released, which tracks the number of
+// concurrent gets.
+type blockingStorage struct {
+	Storage[string, *testKeyVal]
+
+	release chan struct{}
+
+	mutex     sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (s *blockingStorage) numActive() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.active
+}
+
+func (s *blockingStorage) Get(key string) (*testKeyVal, bool, error) {
+	s.mutex.Lock()
+	s.active++
+	if s.active > s.maxActive {
+		s.maxActive = s.active
+	}
+	s.mutex.Unlock()
+
+	<-s.release
+
+	s.mutex.Lock()
+	s.active--
+	s.mutex.Unlock()
+
+	return s.Storage.Get(key)
+}
+
+type RateLimitedStorageSuite struct {
+	local Storage[string, *testKeyVal]
+}
+
+var _ = Suite(&RateLimitedStorageSuite{})
+
+func (s *RateLimitedStorageSuite) SetUpTest(c *C) {
+	s.local = NewLocalMapStorage("test", testItemKey)
+}
+
+func (s *RateLimitedStorageSuite) TestUnlimited(c *C) {
+	c.Assert(NewRateLimitedStorage(s.local, 0), Equals, s.local)
+	c.Assert(NewRateLimitedStorage(s.local, -1), Equals, s.local)
+}
+
+func (s *RateLimitedStorageSuite) TestOperations(c *C) {
+	// Every operation must release its slot, otherwise the next operation
+	// blocks forever.
+	storage := NewRateLimitedStorage(s.local, 1)
+
+	c.Assert(storage.Set(&testKeyVal{"foo", 1}), IsNil)
+	err := storage.SetMulti(&testKeyVal{"bar", 2}, &testKeyVal{"baz", 3})
+	c.Assert(err, IsNil)
+
+	item, found, err := storage.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+	c.Assert(item.val, Equals, 1)
+
+	items, err := storage.GetMulti("bar", "baz", "zzz")
+	c.Assert(err, IsNil)
+	c.Assert(len(items), Equals, 2)
+	c.Assert(items["baz"].val, Equals, 3)
+
+	c.Assert(storage.Delete("foo"), IsNil)
+	c.Assert(storage.DeleteMulti("bar"), IsNil)
+
+	_, found, err = s.local.Get("foo")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+
+	c.Assert(storage.Flush(), IsNil)
+
+	_, found, err = s.local.Get("baz")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, false)
+}
+
+func (s *RateLimitedStorageSuite) TestMaxConcurrency(c *C) {
+	c.Assert(s.local.Set(&testKeyVal{"foo", 1}), IsNil)
+
+	blocking := &blockingStorage{
+		Storage: s.local,
+		release: make(chan struct{}),
+	}
+	storage := NewRateLimitedStorage[string, *testKeyVal](blocking, 2)
+
+	wg := sync.WaitGroup{}
+	results := make(chan int, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			item, found, err := storage.Get("foo")
+			if err == nil && found {
+				results <- item.val
+			}
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for blocking.numActive() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	c.Assert(blocking.numActive(), Equals, 2)
+
+	// The remaining gets are blocked by the rate limiter.
+	time.Sleep(20 * time.Millisecond)
+	c.Assert(blocking.numActive(), Equals, 2)
+
+	close(blocking.release)
+	wg.Wait()
+	close(results)
+
+	c.Assert(blocking.maxActive, Equals, 2)
+	c.Assert(len(results), Equals, 5)
+	for val := range results {
+		c.Assert(val, Equals, 1)
+	}
+}
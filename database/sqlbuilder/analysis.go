@@ -0,0 +1,674 @@
+// Analysis of the tables, columns and predicates referenced by statements
+
+package sqlbuilder
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/dropbox/godropbox/database/sqltypes"
+	"github.com/dropbox/godropbox/errors"
+)
+
+// Identifies a materialized column by table name and column name.
+type ColumnRef struct {
+	Table  string
+	Column string
+}
+
+func (r ColumnRef) String() string {
+	if r.Table == "" {
+		return r.Column
+	}
+	return r.Table + "." + r.Column
+}
+
+// Visitor receives the tables, columns and column constraints referenced by a
+// statement.  See Walk.
+type Visitor interface {
+	// Called for each table reference, in the order the tables appear in the
+	// generated sql.
+	VisitTable(table *Table)
+
+	// Called for each column reference, in the order the columns appear in
+	// the generated sql.  Alias columns are not reported (the columns in the
+	// aliased expressions are reported instead).
+	VisitColumn(column ColumnRef)
+
+	// Called once for each column whose value is restricted by the statement
+	// to a finite set of values, i.e., via "col = literal" and
+	// "col IN (literals)" predicates in the WHERE clause, or via the VALUES
+	// rows of an INSERT statement.  values is empty if the predicates cannot
+	// be satisfied.  Constraints are reported after all tables and columns,
+	// ordered by column reference.
+	VisitConstraint(column ColumnRef, values []sqltypes.Value)
+}
+
+// Walks the statement's clause tree and reports the referenced tables,
+// columns and column constraints to the visitor.
+//
+// Constraints are only derived from predicates which must hold for every
+// affected row: predicates nested under NOT are ignored, and a column is
+// constrained by an OR expression only if it is constrained by each of the
+// OR's operands.  Join ON conditions do not produce constraints.
+func Walk(stmt Statement, visitor Visitor) error {
+	w := &walker{visitor: visitor}
+
+	constraints, err := w.walkStatement(stmt)
+	if err != nil {
+		return err
+	}
+
+	refs := make([]ColumnRef, 0, len(constraints))
+	for ref := range constraints {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i int, j int) bool {
+		if refs[i].Table != refs[j].Table {
+			return refs[i].Table < refs[j].Table
+		}
+		return refs[i].Column < refs[j].Column
+	})
+
+	for _, ref := range refs {
+		visitor.VisitConstraint(ref, constraints[ref])
+	}
+	return nil
+}
+
+// The result of Analyze.
+type QueryInfo struct {
+	// The names of the referenced tables, in order of first appearance.
+	Tables []string
+
+	// The referenced columns, in order of first appearance.
+	Columns []ColumnRef
+
+	// The set of values each constrained column is restricted to (see
+	// Visitor.VisitConstraint).
+	Constraints map[ColumnRef][]sqltypes.Value
+}
+
+type queryInfoVisitor struct {
+	info    *QueryInfo
+	tables  map[string]bool
+	columns map[ColumnRef]bool
+}
+
+func (v *queryInfoVisitor) VisitTable(table *Table) {
+	if !v.tables[table.Name()] {
+		v.tables[table.Name()] = true
+		v.info.Tables = append(v.info.Tables, table.Name())
+	}
+}
+
+func (v *queryInfoVisitor) VisitColumn(column ColumnRef) {
+	if !v.columns[column] {
+		v.columns[column] = true
+		v.info.Columns = append(v.info.Columns, column)
+	}
+}
+
+func (v *queryInfoVisitor) VisitConstraint(
+	column ColumnRef,
+	values []sqltypes.Value) {
+
+	v.info.Constraints[column] = values
+}
+
+// Returns the tables, columns and column constraints referenced by the
+// statement.  This is useful for e.g., checking whether a query is
+// restricted to a single shard.
+func Analyze(stmt Statement) (*QueryInfo, error) {
+	v := &queryInfoVisitor{
+		info: &QueryInfo{
+			Constraints: make(map[ColumnRef][]sqltypes.Value),
+		},
+		tables:  make(map[string]bool),
+		columns: make(map[ColumnRef]bool),
+	}
+
+	if err := Walk(stmt, v); err != nil {
+		return nil, err
+	}
+	return v.info, nil
+}
+
+//
+// Constraints ================================================================
+//
+
+// Maps each constrained column to the set of values it may take.  A nil map
+// means no column is constrained.
+type columnConstraints map[ColumnRef][]sqltypes.Value
+
+func valueKey(value sqltypes.Value) string {
+	buf := &bytes.Buffer{}
+	value.EncodeSql(buf)
+	return buf.String()
+}
+
+func dedupValues(values []sqltypes.Value) []sqltypes.Value {
+	seen := make(map[string]bool)
+	result := make([]sqltypes.Value, 0, len(values))
+	for _, v := range values {
+		key := valueKey(v)
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Returns the constraints which hold when both a and b hold.
+func andConstraints(a, b columnConstraints) columnConstraints {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+
+	result := make(columnConstraints)
+	for ref, values := range a {
+		result[ref] = values
+	}
+	for ref, values := range b {
+		existing, ok := result[ref]
+		if !ok {
+			result[ref] = values
+			continue
+		}
+
+		allowed := make(map[string]bool)
+		for _, v := range values {
+			allowed[valueKey(v)] = true
+		}
+		intersection := make([]sqltypes.Value, 0)
+		for _, v := range existing {
+			if allowed[valueKey(v)] {
+				intersection = append(intersection, v)
+			}
+		}
+		result[ref] = intersection
+	}
+	return result
+}
+
+// Returns the constraints which hold when either a or b holds.
+func orConstraints(a, b columnConstraints) columnConstraints {
+	var result columnConstraints
+	for ref, values := range a {
+		other, ok := b[ref]
+		if !ok {
+			continue
+		}
+		if result == nil {
+			result = make(columnConstraints)
+		}
+		union := make([]sqltypes.Value, 0, len(values)+len(other))
+		union = append(union, values...)
+		union = append(union, other...)
+		result[ref] = dedupValues(union)
+	}
+	return result
+}
+
+// Returns the column reference if the expression is a materialized column.
+func asColumnRef(expr Clause) (ColumnRef, bool, error) {
+	switch c := expr.(type) {
+	case *deferredLookupColumn:
+		if c.table == nil {
+			return ColumnRef{}, false, errors.Newf(
+				"nil table for column %s",
+				c.colName)
+		}
+		if _, err := c.table.getColumn(c.colName); err != nil {
+			return ColumnRef{}, false, err
+		}
+		return ColumnRef{Table: c.table.Name(), Column: c.colName}, true, nil
+	case *aliasColumn:
+		return ColumnRef{}, false, nil
+	case interface {
+		NonAliasColumn
+		tableName() string
+	}:
+		return ColumnRef{Table: c.tableName(), Column: c.Name()}, true, nil
+	}
+	return ColumnRef{}, false, nil
+}
+
+func asLiteral(expr Clause) (sqltypes.Value, bool) {
+	switch l := expr.(type) {
+	case *literalExpression:
+		return l.value, true
+	case literalExpression:
+		return l.value, true
+	}
+	return sqltypes.Value{}, false
+}
+
+// Returns the constraints implied by the boolean expression.
+func whereConstraints(expr BoolExpression) (columnConstraints, error) {
+	switch e := expr.(type) {
+	case *conjunctExpression:
+		isAnd := string(e.conjunction) == " AND "
+		var result columnConstraints
+		for i, nested := range e.expressions {
+			constraints, err := whereConstraints(nested)
+			if err != nil {
+				return nil, err
+			}
+			if isAnd {
+				result = andConstraints(result, constraints)
+			} else if i == 0 {
+				result = constraints
+			} else {
+				result = orConstraints(result, constraints)
+			}
+		}
+		return result, nil
+	case *boolExpression:
+		op := string(e.operator)
+		if op != "=" && op != " IS " {
+			return nil, nil
+		}
+
+		lhs, rhs := Clause(e.lhs), Clause(e.rhs)
+		if _, ok := asLiteral(lhs); ok {
+			lhs, rhs = rhs, lhs
+		}
+
+		ref, isCol, err := asColumnRef(lhs)
+		if err != nil || !isCol {
+			return nil, err
+		}
+		value, isLit := asLiteral(rhs)
+		if !isLit {
+			return nil, nil
+		}
+		return columnConstraints{ref: {value}}, nil
+	case *inExpression:
+		if e.err != nil {
+			return nil, errors.Wrap(e.err, "Invalid IN expression")
+		}
+
+		ref, isCol, err := asColumnRef(e.lhs)
+		if err != nil || !isCol {
+			return nil, err
+		}
+
+		values := make([]sqltypes.Value, 0)
+		if e.rhs != nil {
+			for _, clause := range e.rhs.clauses {
+				value, isLit := asLiteral(clause)
+				if !isLit {
+					return nil, nil
+				}
+				values = append(values, value)
+			}
+		}
+		return columnConstraints{ref: dedupValues(values)}, nil
+	}
+	return nil, nil
+}
+
+//
+// Tree walking ===============================================================
+//
+
+type walker struct {
+	visitor Visitor
+}
+
+func (w *walker) walkStatement(stmt Statement) (columnConstraints, error) {
+	switch s := stmt.(type) {
+	case *selectStatementImpl:
+		return w.walkSelect(s)
+	case *unionStatementImpl:
+		return w.walkUnion(s)
+	case *insertStatementImpl:
+		return w.walkInsert(s)
+	case *updateStatementImpl:
+		return w.walkUpdate(s)
+	case *deleteStatementImpl:
+		return w.walkDelete(s)
+	case *lockStatementImpl:
+		for _, lock := range s.locks {
+			if err := w.walkTable(lock.t); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case *unlockStatementImpl, *gtidNextStatementImpl:
+		return nil, nil
+	case *createTableStatementImpl:
+		if err := w.walkTable(s.table); err != nil {
+			return nil, err
+		}
+		return nil, w.walkColumns(s.table.columns)
+	case *alterTableStatementImpl:
+		if err := w.walkTable(s.table); err != nil {
+			return nil, err
+		}
+		for _, alteration := range s.alterations {
+			if err := w.walkClause(alteration.col); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case *dropTableStatementImpl:
+		return nil, w.walkTable(s.table)
+	}
+	return nil, errors.Newf("Unsupported statement type %T", stmt)
+}
+
+func (w *walker) walkSelect(
+	s *selectStatementImpl) (columnConstraints, error) {
+
+	for _, projection := range s.projections {
+		if err := w.walkProjection(projection); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.walkTable(s.table); err != nil {
+		return nil, err
+	}
+	return w.walkWhere(s.where, s.group, s.order)
+}
+
+func (w *walker) walkUnion(
+	s *unionStatementImpl) (columnConstraints, error) {
+
+	var result columnConstraints
+	for i, stmt := range s.selects {
+		constraints, err := w.walkStatement(stmt)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			result = constraints
+		} else {
+			result = orConstraints(result, constraints)
+		}
+	}
+
+	constraints, err := w.walkWhere(s.where, s.group, s.order)
+	if err != nil {
+		return nil, err
+	}
+	return andConstraints(result, constraints), nil
+}
+
+func (w *walker) walkInsert(
+	s *insertStatementImpl) (columnConstraints, error) {
+
+	if err := w.walkTable(s.table); err != nil {
+		return nil, err
+	}
+	if err := w.walkColumns(s.columns); err != nil {
+		return nil, err
+	}
+
+	var result columnConstraints
+	if s.query != nil {
+		var err error
+		if result, err = w.walkStatement(s.query); err != nil {
+			return nil, err
+		}
+	} else if len(s.rows) > 0 {
+		result = make(columnConstraints)
+		for i, col := range s.columns {
+			ref, isCol, err := asColumnRef(col)
+			if err != nil {
+				return nil, err
+			}
+
+			values := make([]sqltypes.Value, 0, len(s.rows))
+			for _, row := range s.rows {
+				if i >= len(row) {
+					isCol = false
+					break
+				}
+				value, isLit := asLiteral(row[i])
+				if !isLit {
+					isCol = false
+					break
+				}
+				values = append(values, value)
+			}
+
+			if isCol {
+				result[ref] = dedupValues(values)
+			}
+		}
+
+		for _, row := range s.rows {
+			for _, value := range row {
+				if err := w.walkClause(value); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, assignment := range s.onDuplicateKeyUpdates {
+		if err := w.walkClause(assignment.col); err != nil {
+			return nil, err
+		}
+		if err := w.walkClause(assignment.expr); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (w *walker) walkUpdate(
+	s *updateStatementImpl) (columnConstraints, error) {
+
+	if err := w.walkTable(s.table); err != nil {
+		return nil, err
+	}
+
+	// Report the assignments in table column order (as in the generated sql).
+	assignments := make(map[ColumnRef]Expression)
+	for col, expr := range s.updateValues {
+		ref, isCol, err := asColumnRef(col)
+		if err != nil {
+			return nil, err
+		}
+		if isCol {
+			assignments[ref] = expr
+		}
+	}
+	for _, col := range s.table.Columns() {
+		ref, isCol, err := asColumnRef(col)
+		if err != nil {
+			return nil, err
+		}
+		expr, ok := assignments[ref]
+		if !isCol || !ok {
+			continue
+		}
+		w.visitor.VisitColumn(ref)
+		if err := w.walkClause(expr); err != nil {
+			return nil, err
+		}
+	}
+
+	return w.walkWhere(s.where, nil, s.order)
+}
+
+func (w *walker) walkDelete(
+	s *deleteStatementImpl) (columnConstraints, error) {
+
+	for _, t := range s.deleteFrom {
+		if err := w.walkTable(t); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.walkTable(s.table); err != nil {
+		return nil, err
+	}
+	return w.walkWhere(s.where, nil, s.order)
+}
+
+// Walks the WHERE, GROUP BY and ORDER BY clauses, and returns the WHERE
+// clause's constraints.
+func (w *walker) walkWhere(
+	where BoolExpression,
+	group *listClause,
+	order *listClause) (columnConstraints, error) {
+
+	var result columnConstraints
+	if where != nil {
+		if err := w.walkClause(where); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if result, err = whereConstraints(where); err != nil {
+			return nil, err
+		}
+	}
+
+	if group != nil {
+		if err := w.walkClause(group); err != nil {
+			return nil, err
+		}
+	}
+	if order != nil {
+		if err := w.walkClause(order); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (w *walker) walkTable(table interface{}) error {
+	switch t := table.(type) {
+	case *Table:
+		if t == nil {
+			return errors.New("nil table")
+		}
+		w.visitor.VisitTable(t)
+		return nil
+	case *joinTable:
+		if err := w.walkTable(t.lhs); err != nil {
+			return err
+		}
+		if err := w.walkTable(t.rhs); err != nil {
+			return err
+		}
+		if t.onCondition != nil {
+			return w.walkClause(t.onCondition)
+		}
+		return nil
+	}
+	return errors.Newf("Unsupported table type %T", table)
+}
+
+func (w *walker) walkColumns(columns []NonAliasColumn) error {
+	for _, col := range columns {
+		if err := w.walkClause(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) walkProjection(projection Projection) error {
+	if alias, ok := projection.(*aliasColumn); ok {
+		// Unlike other references to the alias, the projection includes the
+		// aliased expression.
+		if alias.expression == nil {
+			return nil
+		}
+		return w.walkClause(alias.expression)
+	}
+	return w.walkClause(projection)
+}
+
+func (w *walker) walkClauses(clauses []Clause) error {
+	for _, clause := range clauses {
+		if err := w.walkClause(clause); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) walkClause(clause Clause) error {
+	if clause == nil {
+		return nil
+	}
+
+	switch c := clause.(type) {
+	case *orderByClause:
+		return w.walkClause(c.expression)
+	case literalExpression, *literalExpression:
+		return nil
+	case *placeholderExpression, *intervalExpression:
+		return nil
+	case *conjunctExpression:
+		for _, expr := range c.expressions {
+			if err := w.walkClause(expr); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *arithmeticExpression:
+		for _, expr := range c.expressions {
+			if err := w.walkClause(expr); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *tupleExpression:
+		return w.walkClauses(c.elements.clauses)
+	case *listClause:
+		return w.walkClauses(c.clauses)
+	case *negateExpression:
+		return w.walkClause(c.nested)
+	case *binaryExpression:
+		return w.walkClauses([]Clause{c.lhs, c.rhs})
+	case *boolExpression:
+		return w.walkClauses([]Clause{c.lhs, c.rhs})
+	case *funcExpression:
+		if c.args == nil {
+			return nil
+		}
+		return w.walkClause(c.args)
+	case *inExpression:
+		if c.err != nil {
+			return errors.Wrap(c.err, "Invalid IN expression")
+		}
+		if err := w.walkClause(c.lhs); err != nil {
+			return err
+		}
+		if c.rhs == nil {
+			return nil
+		}
+		return w.walkClause(c.rhs)
+	case *ifExpression:
+		return w.walkClauses(
+			[]Clause{c.conditional, c.trueExpression, c.falseExpression})
+	case *columnValueExpression:
+		return w.walkClause(c.column)
+	case *aliasColumn:
+		// References to an alias (e.g., in ORDER BY) do not reference the
+		// aliased expression's columns again.
+		return nil
+	}
+
+	ref, isCol, err := asColumnRef(clause)
+	if err != nil {
+		return err
+	}
+	if !isCol {
+		return errors.Newf("Unsupported clause type %T", clause)
+	}
+	w.visitor.VisitColumn(ref)
+	return nil
+}
@@ -0,0 +1,214 @@
+package sqlbuilder
+
+import (
+	"github.com/dropbox/godropbox/database/sqltypes"
+
+	gc "gopkg.in/check.v1"
+)
+
+type AnalysisSuite struct {
+}
+
+var _ = gc.Suite(&AnalysisSuite{})
+
+func constraintStrings(info *QueryInfo) map[string][]string {
+	result := make(map[string][]string)
+	for ref, values := range info.Constraints {
+		strs := make([]string, 0, len(values))
+		for _, v := range values {
+			strs = append(strs, valueKey(v))
+		}
+		result[ref.String()] = strs
+	}
+	return result
+}
+
+func (s *AnalysisSuite) TestSelect(c *gc.C) {
+	join := table1.InnerJoinOn(table2, Eq(table1Col3, table2Col3))
+	q := join.Select(table1Col1, Alias("total", SqlFunc("sum", table2Col4))).
+		Where(And(
+			EqL(table1Col1, 1),
+			In(table1.C("col2"), []int{2, 3}),
+			GtL(table2Col4, 4))).
+		GroupBy(table1Col1).
+		OrderBy(Desc(table1Col4))
+
+	info, err := Analyze(q)
+	c.Assert(err, gc.IsNil)
+	c.Assert(info.Tables, gc.DeepEquals, []string{"table1", "table2"})
+	c.Assert(
+		info.Columns,
+		gc.DeepEquals,
+		[]ColumnRef{
+			{"table1", "col1"},
+			{"table2", "col4"},
+			{"table1", "col3"},
+			{"table2", "col3"},
+			{"table1", "col2"},
+			{"table1", "col4"},
+		})
+	c.Assert(
+		constraintStrings(info),
+		gc.DeepEquals,
+		map[string][]string{
+			"table1.col1": {"1"},
+			"table1.col2": {"2", "3"},
+		})
+}
+
+func (s *AnalysisSuite) TestOrConstraints(c *gc.C) {
+	q := table1.Select(table1Col1).Where(Or(
+		And(EqL(table1Col1, 1), EqL(table1Col2, 5)),
+		In(table1Col1, []int{1, 2}),
+		EqL(table1Col2, 6)))
+
+	info, err := Analyze(q)
+	c.Assert(err, gc.IsNil)
+	c.Assert(constraintStrings(info), gc.DeepEquals, map[string][]string{})
+
+	q = table1.Select(table1Col1).Where(Or(
+		And(EqL(table1Col1, 1), EqL(table1Col2, 5)),
+		In(table1Col1, []int{1, 2})))
+
+	info, err = Analyze(q)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		constraintStrings(info),
+		gc.DeepEquals,
+		map[string][]string{"table1.col1": {"1", "2"}})
+}
+
+func (s *AnalysisSuite) TestAndConstraints(c *gc.C) {
+	q := table1.Select(table1Col1).Where(And(
+		In(table1Col1, []int{1, 2, 3}),
+		Eq(Literal(2), table1Col1),
+		Not(EqL(table1Col2, 1)),
+		Eq(table1Col3, table1Col2)))
+
+	info, err := Analyze(q)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		constraintStrings(info),
+		gc.DeepEquals,
+		map[string][]string{"table1.col1": {"2"}})
+
+	q = table1.Select(table1Col1).Where(
+		And(EqL(table1Col1, 1), EqL(table1Col1, 2)))
+
+	info, err = Analyze(q)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		constraintStrings(info),
+		gc.DeepEquals,
+		map[string][]string{"table1.col1": {}})
+}
+
+func (s *AnalysisSuite) TestUnion(c *gc.C) {
+	q := Union(
+		table1.Select(table1Col1).Where(EqL(table1Col1, 1)),
+		table1.Select(table1Col1).Where(EqL(table1Col1, 2)))
+
+	info, err := Analyze(q)
+	c.Assert(err, gc.IsNil)
+	c.Assert(info.Tables, gc.DeepEquals, []string{"table1"})
+	c.Assert(
+		constraintStrings(info),
+		gc.DeepEquals,
+		map[string][]string{"table1.col1": {"1", "2"}})
+}
+
+func (s *AnalysisSuite) TestInsert(c *gc.C) {
+	q := table1.Insert(table1Col1, table1Col2).
+		Add(Literal(1), Literal(2)).
+		Add(Literal(1), SqlFunc("now")).
+		AddOnDuplicateKeyUpdateValues(table1Col2)
+
+	info, err := Analyze(q)
+	c.Assert(err, gc.IsNil)
+	c.Assert(info.Tables, gc.DeepEquals, []string{"table1"})
+	c.Assert(
+		info.Columns,
+		gc.DeepEquals,
+		[]ColumnRef{{"table1", "col1"}, {"table1", "col2"}})
+	c.Assert(
+		constraintStrings(info),
+		gc.DeepEquals,
+		map[string][]string{"table1.col1": {"1"}})
+}
+
+func (s *AnalysisSuite) TestUpdate(c *gc.C) {
+	q := table1.Update().
+		Set(table1Col2, Add(table1Col3, Literal(1))).
+		Where(EqL(table1Col1, 1))
+
+	info, err := Analyze(q)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		info.Columns,
+		gc.DeepEquals,
+		[]ColumnRef{
+			{"table1", "col2"},
+			{"table1", "col3"},
+			{"table1", "col1"},
+		})
+	c.Assert(
+		constraintStrings(info),
+		gc.DeepEquals,
+		map[string][]string{"table1.col1": {"1"}})
+}
+
+func (s *AnalysisSuite) TestMultiTableDelete(c *gc.C) {
+	q := NewMultiTableDelete(
+		table1.InnerJoinOn(table2, Eq(table1Col3, table2Col3)),
+		table2).
+		Where(EqL(table1Col1, "a"))
+
+	info, err := Analyze(q)
+	c.Assert(err, gc.IsNil)
+	c.Assert(info.Tables, gc.DeepEquals, []string{"table2", "table1"})
+	c.Assert(
+		constraintStrings(info),
+		gc.DeepEquals,
+		map[string][]string{"table1.col1": {"'a'"}})
+}
+
+func (s *AnalysisSuite) TestUnknownColumn(c *gc.C) {
+	_, err := Analyze(table1.Select(table1.C("foo")))
+	c.Assert(err, gc.NotNil)
+}
+
+type recordingVisitor struct {
+	events []string
+}
+
+func (v *recordingVisitor) VisitTable(table *Table) {
+	v.events = append(v.events, "table "+table.Name())
+}
+
+func (v *recordingVisitor) VisitColumn(column ColumnRef) {
+	v.events = append(v.events, "column "+column.String())
+}
+
+func (v *recordingVisitor) VisitConstraint(
+	column ColumnRef,
+	values []sqltypes.Value) {
+
+	v.events = append(
+		v.events,
+		"constraint "+column.String()+" "+valueKey(values[0]))
+}
+
+func (s *AnalysisSuite) TestWalk(c *gc.C) {
+	q := table2.Delete().Where(EqL(table2Col4, 1))
+
+	v := &recordingVisitor{}
+	c.Assert(Walk(q, v), gc.IsNil)
+	c.Assert(
+		v.events,
+		gc.DeepEquals,
+		[]string{
+			"table table2",
+			"column table2.col4",
+			"constraint table2.col4 1",
+		})
+}
@@ -0,0 +1,104 @@
+// sqlbuildergen generates go source declaring sqlbuilder tables from a mysql
+// schema dump (SHOW CREATE TABLE or mysqldump --no-data output).
+//
+// Usage:
+//
+//	sqlbuildergen -schema schema.sql -package models -out tables.go
+//
+// With -check, the generated code is compared against the existing -out file
+// instead of being written.  A unified diff is printed and the command exits
+// with status 1 if they differ.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/dropbox/godropbox/database/sqlbuilder/schemagen"
+)
+
+var (
+	schemaFile = flag.String("schema", "", "Schema dump to generate from")
+	pkg        = flag.String("package", "", "Package name of the generated code")
+	outFile    = flag.String(
+		"out",
+		"",
+		"Output file.  The generated code is written to stdout if empty")
+	check = flag.Bool(
+		"check",
+		false,
+		"Diff the generated code against -out instead of writing it")
+)
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(2)
+}
+
+func main() {
+	flag.Parse()
+
+	if *schemaFile == "" || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	schema, err := ioutil.ReadFile(*schemaFile)
+	if err != nil {
+		fatalf("Failed to read schema: %s", err)
+	}
+
+	tables, err := schemagen.ParseSchema(string(schema))
+	if err != nil {
+		fatalf("Failed to parse schema: %s", err)
+	}
+
+	src, err := schemagen.Generate(*pkg, filepath.Base(*schemaFile), tables)
+	if err != nil {
+		fatalf("%s", err)
+	}
+
+	if !*check {
+		if *outFile == "" {
+			_, _ = os.Stdout.Write(src)
+			return
+		}
+		if err = ioutil.WriteFile(*outFile, src, 0644); err != nil {
+			fatalf("Failed to write output: %s", err)
+		}
+		return
+	}
+
+	if *outFile == "" {
+		fatalf("-check requires -out")
+	}
+
+	existing, err := ioutil.ReadFile(*outFile)
+	if err != nil {
+		fatalf("Failed to read %s: %s", *outFile, err)
+	}
+
+	if bytes.Equal(existing, src) {
+		return
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(existing)),
+		B:        difflib.SplitLines(string(src)),
+		FromFile: *outFile,
+		ToFile:   *schemaFile,
+		Context:  3,
+	})
+	if err != nil {
+		fatalf("Failed to diff: %s", err)
+	}
+
+	fmt.Print(diff)
+	os.Exit(1)
+}
@@ -21,11 +21,25 @@ type Column interface {
 	// Serialization for use in an expression (Clause)
 	SerializeSql(out *bytes.Buffer) error
 
+	// Dialect aware versions of the above
+	serializeSqlForColumnList(out *sqlWriter) error
+	serializeSql(out *sqlWriter) error
+
 	// Internal function for tracking table that a column belongs to
 	// for the purpose of serialization
 	setTableName(table string) error
 }
 
+// A materialized column which can be described in DDL statements.
+type definableColumn interface {
+	NonAliasColumn
+
+	setOptions(options ColumnOptions)
+
+	// Serialization for use in CREATE TABLE / ALTER TABLE ADD COLUMN
+	serializeSqlForDefinition(out *sqlWriter) error
+}
+
 type NullableColumn bool
 
 const (
@@ -55,6 +69,42 @@ const (
 	UTF8 Charset = "utf8"
 )
 
+// Column attributes which are only used for generating DDL statements (see
+// CreateTableStatement and AlterTableStatement).  These do not affect query
+// generation.
+type ColumnOptions struct {
+	// For integer columns, the storage size in bytes (1, 2, 3, 4 or 8, i.e.,
+	// TINYINT through BIGINT); zero means BIGINT.  For string and bytes
+	// columns, the maximum length (i.e., VARCHAR(n) / VARBINARY(n)); zero
+	// means TEXT / BLOB.  For datetime columns, the fractional seconds
+	// precision.  Ignored by all other column types.
+	Size int
+
+	// Only applicable to integer columns.
+	Unsigned bool
+
+	// Only applicable to integer columns.
+	AutoIncrement bool
+
+	// The column's default value.  The DEFAULT clause is omitted when nil.
+	Default Expression
+}
+
+// Sets the DDL attributes of a materialized column (i.e., a column created by
+// IntColumn, StrColumn, etc) and returns the column.  This function will panic
+// if col is not a materialized column.
+func WithColumnOptions(
+	col NonAliasColumn,
+	options ColumnOptions) NonAliasColumn {
+
+	c, ok := col.(definableColumn)
+	if !ok {
+		panic("Cannot set options on a non-materialized column")
+	}
+	c.setOptions(options)
+	return c
+}
+
 // The base type for real materialized columns.
 type baseColumn struct {
 	isProjection
@@ -62,26 +112,77 @@ type baseColumn struct {
 	name     string
 	nullable NullableColumn
 	table    string
+	options  ColumnOptions
 }
 
 func (c *baseColumn) Name() string {
 	return c.name
 }
 
+func (c *baseColumn) tableName() string {
+	return c.table
+}
+
 func (c *baseColumn) setTableName(table string) error {
 	c.table = table
 	return nil
 }
 
+func (c *baseColumn) setOptions(options ColumnOptions) {
+	c.options = options
+}
+
+// Writes the column name followed by the given sql type.
+func (c *baseColumn) serializeNameAndType(sqlType string, out *sqlWriter) {
+	out.writeIdentifier(c.name)
+	_ = out.WriteByte(' ')
+	_, _ = out.WriteString(sqlType)
+}
+
+// Writes the NULL / DEFAULT / AUTO_INCREMENT attributes shared by all column
+// types.
+func (c *baseColumn) serializeAttributes(out *sqlWriter) error {
+	if c.nullable == NotNullable {
+		_, _ = out.WriteString(" NOT NULL")
+	}
+
+	if c.options.Default != nil {
+		_, _ = out.WriteString(" DEFAULT ")
+		if err := c.options.Default.serializeSql(out); err != nil {
+			return err
+		}
+	}
+
+	if c.options.AutoIncrement {
+		if err := out.requireMySql("AUTO_INCREMENT"); err != nil {
+			return err
+		}
+		_, _ = out.WriteString(" AUTO_INCREMENT")
+	}
+
+	return nil
+}
+
+// Returns an error if integer-only options are set on a non-integer column.
+func (c *baseColumn) checkNonIntegerOptions() error {
+	if c.options.Unsigned || c.options.AutoIncrement {
+		return errors.Newf(
+			"UNSIGNED / AUTO_INCREMENT are only valid for integer columns: %s",
+			c.name)
+	}
+	return nil
+}
+
 func (c *baseColumn) SerializeSqlForColumnList(out *bytes.Buffer) error {
+	return c.serializeSqlForColumnList(defaultSqlWriter(out))
+}
+
+func (c *baseColumn) serializeSqlForColumnList(out *sqlWriter) error {
 	if c.table != "" {
-		_ = out.WriteByte('`')
-		_, _ = out.WriteString(c.table)
-		_, _ = out.WriteString("`.")
+		out.writeIdentifier(c.table)
+		_ = out.WriteByte('.')
 	}
-	_, _ = out.WriteString("`")
-	_, _ = out.WriteString(c.name)
-	_ = out.WriteByte('`')
+	out.writeIdentifier(c.name)
 	return nil
 }
 
@@ -89,6 +190,10 @@ func (c *baseColumn) SerializeSql(out *bytes.Buffer) error {
 	return c.SerializeSqlForColumnList(out)
 }
 
+func (c *baseColumn) serializeSql(out *sqlWriter) error {
+	return c.serializeSqlForColumnList(out)
+}
+
 type bytesColumn struct {
 	baseColumn
 	isExpression
@@ -106,6 +211,18 @@ func BytesColumn(name string, nullable NullableColumn) NonAliasColumn {
 	return bc
 }
 
+func (c *bytesColumn) serializeSqlForDefinition(out *sqlWriter) error {
+	if err := c.checkNonIntegerOptions(); err != nil {
+		return err
+	}
+
+	c.serializeNameAndType(
+		out.dialect.ColumnType(BytesColumnKind, c.options.Size),
+		out)
+
+	return c.serializeAttributes(out)
+}
+
 type stringColumn struct {
 	baseColumn
 	isExpression
@@ -130,6 +247,39 @@ func StrColumn(
 	return sc
 }
 
+func (c *stringColumn) serializeSqlForDefinition(out *sqlWriter) error {
+	if err := c.checkNonIntegerOptions(); err != nil {
+		return err
+	}
+
+	c.serializeNameAndType(
+		out.dialect.ColumnType(StrColumnKind, c.options.Size),
+		out)
+
+	// Charsets and collations are mysql specific.
+	if !out.dialect.MySqlCompatible() {
+		return c.serializeAttributes(out)
+	}
+
+	if c.charset != "" {
+		if !validIdentifierName(string(c.charset)) {
+			return errors.Newf("Invalid charset: %s", c.charset)
+		}
+		_, _ = out.WriteString(" CHARACTER SET ")
+		_, _ = out.WriteString(string(c.charset))
+	}
+
+	if c.collation != "" {
+		if !validIdentifierName(string(c.collation)) {
+			return errors.Newf("Invalid collation: %s", c.collation)
+		}
+		_, _ = out.WriteString(" COLLATE ")
+		_, _ = out.WriteString(string(c.collation))
+	}
+
+	return c.serializeAttributes(out)
+}
+
 type dateTimeColumn struct {
 	baseColumn
 	isExpression
@@ -147,6 +297,25 @@ func DateTimeColumn(name string, nullable NullableColumn) NonAliasColumn {
 	return dc
 }
 
+func (c *dateTimeColumn) serializeSqlForDefinition(out *sqlWriter) error {
+	if err := c.checkNonIntegerOptions(); err != nil {
+		return err
+	}
+
+	if c.options.Size < 0 || c.options.Size > 6 {
+		return errors.Newf(
+			"Invalid fractional seconds precision for %s: %d",
+			c.name,
+			c.options.Size)
+	}
+
+	c.serializeNameAndType(
+		out.dialect.ColumnType(DateTimeColumnKind, c.options.Size),
+		out)
+
+	return c.serializeAttributes(out)
+}
+
 type integerColumn struct {
 	baseColumn
 	isExpression
@@ -164,6 +333,26 @@ func IntColumn(name string, nullable NullableColumn) NonAliasColumn {
 	return ic
 }
 
+func (c *integerColumn) serializeSqlForDefinition(out *sqlWriter) error {
+	switch c.options.Size {
+	case 0, 1, 2, 3, 4, 8:
+	default:
+		return errors.Newf(
+			"Invalid integer size for %s: %d",
+			c.name,
+			c.options.Size)
+	}
+
+	sqlType := out.dialect.ColumnType(IntColumnKind, c.options.Size)
+
+	if c.options.Unsigned && out.dialect.MySqlCompatible() {
+		sqlType += " UNSIGNED"
+	}
+
+	c.serializeNameAndType(sqlType, out)
+	return c.serializeAttributes(out)
+}
+
 type doubleColumn struct {
 	baseColumn
 	isExpression
@@ -181,6 +370,15 @@ func DoubleColumn(name string, nullable NullableColumn) NonAliasColumn {
 	return ic
 }
 
+func (c *doubleColumn) serializeSqlForDefinition(out *sqlWriter) error {
+	if err := c.checkNonIntegerOptions(); err != nil {
+		return err
+	}
+
+	c.serializeNameAndType(out.dialect.ColumnType(DoubleColumnKind, 0), out)
+	return c.serializeAttributes(out)
+}
+
 type booleanColumn struct {
 	baseColumn
 	isExpression
@@ -201,19 +399,34 @@ func BoolColumn(name string, nullable NullableColumn) NonAliasColumn {
 	return bc
 }
 
+func (c *booleanColumn) serializeSqlForDefinition(out *sqlWriter) error {
+	if err := c.checkNonIntegerOptions(); err != nil {
+		return err
+	}
+
+	c.serializeNameAndType(out.dialect.ColumnType(BoolColumnKind, 0), out)
+	return c.serializeAttributes(out)
+}
+
 type aliasColumn struct {
 	baseColumn
 	expression Expression
 }
 
 func (c *aliasColumn) SerializeSql(out *bytes.Buffer) error {
-	_ = out.WriteByte('`')
-	_, _ = out.WriteString(c.name)
-	_ = out.WriteByte('`')
+	return c.serializeSql(defaultSqlWriter(out))
+}
+
+func (c *aliasColumn) serializeSql(out *sqlWriter) error {
+	out.writeIdentifier(c.name)
 	return nil
 }
 
 func (c *aliasColumn) SerializeSqlForColumnList(out *bytes.Buffer) error {
+	return c.serializeSqlForColumnList(defaultSqlWriter(out))
+}
+
+func (c *aliasColumn) serializeSqlForColumnList(out *sqlWriter) error {
 	if !validIdentifierName(c.name) {
 		return errors.Newf(
 			"Invalid alias name `%s`.  Generated sql: %s",
@@ -230,12 +443,11 @@ func (c *aliasColumn) SerializeSqlForColumnList(out *bytes.Buffer) error {
 	if c.expression == nil {
 		return errors.Newf("nil alias clause.  Generate sql: %s", out.String())
 	}
-	if err := c.expression.SerializeSql(out); err != nil {
+	if err := c.expression.serializeSql(out); err != nil {
 		return err
 	}
-	_, _ = out.WriteString(") AS `")
-	_, _ = out.WriteString(c.name)
-	_ = out.WriteByte('`')
+	_, _ = out.WriteString(") AS ")
+	out.writeIdentifier(c.name)
 	return nil
 }
 
@@ -281,9 +493,19 @@ func (c *deferredLookupColumn) SerializeSqlForColumnList(
 	return c.SerializeSql(out)
 }
 
+func (c *deferredLookupColumn) serializeSqlForColumnList(
+	out *sqlWriter) error {
+
+	return c.serializeSql(out)
+}
+
 func (c *deferredLookupColumn) SerializeSql(out *bytes.Buffer) error {
+	return c.serializeSql(defaultSqlWriter(out))
+}
+
+func (c *deferredLookupColumn) serializeSql(out *sqlWriter) error {
 	if c.cachedColumn != nil {
-		return c.cachedColumn.SerializeSql(out)
+		return c.cachedColumn.serializeSql(out)
 	}
 
 	col, err := c.table.getColumn(c.colName)
@@ -292,7 +514,7 @@ func (c *deferredLookupColumn) SerializeSql(out *bytes.Buffer) error {
 	}
 
 	c.cachedColumn = col
-	return col.SerializeSql(out)
+	return col.serializeSql(out)
 }
 
 func (c *deferredLookupColumn) setTableName(table string) error {
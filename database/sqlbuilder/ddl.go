@@ -0,0 +1,315 @@
+// Generation of DDL statements from table definitions
+
+package sqlbuilder
+
+import (
+	"github.com/dropbox/godropbox/errors"
+)
+
+// CreateTableStatement generates a CREATE TABLE statement from a table's
+// columns (see ColumnOptions for column attributes), primary key and indexes.
+type CreateTableStatement interface {
+	Statement
+
+	IfNotExists() CreateTableStatement
+
+	// Returns the CREATE TABLE statement followed by the CREATE INDEX
+	// statements for the table's indexes.  mysql declares indexes inline in
+	// the CREATE TABLE statement; other dialects require separate CREATE
+	// INDEX statements, which are omitted by String / StringForDialect.
+	StringsForDialect(database string, dialect Dialect) ([]string, error)
+}
+
+// AlterTableStatement generates an ALTER TABLE statement which adds and/or
+// drops columns.  Alterations are applied in the order they are specified.
+type AlterTableStatement interface {
+	Statement
+
+	// Adds a column.  The column does not need to be part of the table's
+	// current definition.
+	AddColumn(column NonAliasColumn) AlterTableStatement
+	DropColumn(column NonAliasColumn) AlterTableStatement
+}
+
+type DropTableStatement interface {
+	Statement
+
+	IfExists() DropTableStatement
+}
+
+func writeTableName(database string, t *Table, buf *sqlWriter) error {
+	if t == nil {
+		return errors.Newf("nil table.  Generated sql: %s", buf.String())
+	}
+
+	buf.writeIdentifier(database)
+	_ = buf.WriteByte('.')
+	buf.writeIdentifier(t.name)
+	return nil
+}
+
+func writeColumnDefinition(col NonAliasColumn, buf *sqlWriter) error {
+	if col == nil {
+		return errors.Newf("nil column.  Generated sql: %s", buf.String())
+	}
+
+	c, ok := col.(definableColumn)
+	if !ok {
+		return errors.Newf(
+			"Column '%s' is not a materialized column.  Generated sql: %s",
+			col.Name(),
+			buf.String())
+	}
+
+	return c.serializeSqlForDefinition(buf)
+}
+
+func writeKeyColumns(columns []NonAliasColumn, buf *sqlWriter) {
+	_ = buf.WriteByte('(')
+	for i, col := range columns {
+		if i > 0 {
+			_ = buf.WriteByte(',')
+		}
+		buf.writeIdentifier(col.Name())
+	}
+	_ = buf.WriteByte(')')
+}
+
+//
+// CREATE TABLE statement =====================================================
+//
+
+func newCreateTableStatement(table *Table) CreateTableStatement {
+	return &createTableStatementImpl{
+		table: table,
+	}
+}
+
+type createTableStatementImpl struct {
+	table       *Table
+	ifNotExists bool
+}
+
+func (s *createTableStatementImpl) IfNotExists() CreateTableStatement {
+	s.ifNotExists = true
+	return s
+}
+
+func (s *createTableStatementImpl) String(database string) (sql string, err error) {
+	return s.StringForDialect(database, MySqlDialect)
+}
+
+func (s *createTableStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
+	if !validIdentifierName(database) {
+		return "", errors.New("Invalid database name specified")
+	}
+
+	buf := newSqlWriter(dialect)
+	_, _ = buf.WriteString("CREATE TABLE ")
+	if s.ifNotExists {
+		_, _ = buf.WriteString("IF NOT EXISTS ")
+	}
+
+	if err = writeTableName(database, s.table, buf); err != nil {
+		return
+	}
+
+	_, _ = buf.WriteString(" (")
+	for i, col := range s.table.columns {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+
+		if err = writeColumnDefinition(col, buf); err != nil {
+			return
+		}
+	}
+
+	if len(s.table.primaryKey) > 0 {
+		_, _ = buf.WriteString(", PRIMARY KEY ")
+		writeKeyColumns(s.table.primaryKey, buf)
+	}
+
+	// See StringsForDialect for non-mysql indexes.
+	for _, index := range s.table.indexes {
+		if !dialect.MySqlCompatible() {
+			break
+		}
+
+		if index.Unique {
+			_, _ = buf.WriteString(", UNIQUE KEY ")
+		} else {
+			_, _ = buf.WriteString(", KEY ")
+		}
+		buf.writeIdentifier(index.Name)
+		_ = buf.WriteByte(' ')
+		writeKeyColumns(index.Columns, buf)
+	}
+
+	_ = buf.WriteByte(')')
+	return buf.String(), nil
+}
+
+func (s *createTableStatementImpl) StringsForDialect(
+	database string,
+	dialect Dialect) ([]string, error) {
+
+	createTable, err := s.StringForDialect(database, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []string{createTable}
+	if dialect.MySqlCompatible() {
+		return results, nil
+	}
+
+	for _, index := range s.table.indexes {
+		buf := newSqlWriter(dialect)
+		dialect.WriteCreateIndex(
+			database,
+			s.table.name,
+			index.Name,
+			index.Unique,
+			s.ifNotExists,
+			buf.Buffer)
+		_ = buf.WriteByte(' ')
+		writeKeyColumns(index.Columns, buf)
+
+		results = append(results, buf.String())
+	}
+	return results, nil
+}
+
+//
+// ALTER TABLE statement ======================================================
+//
+
+func newAlterTableStatement(table *Table) AlterTableStatement {
+	return &alterTableStatementImpl{
+		table: table,
+	}
+}
+
+type columnAlteration struct {
+	col  NonAliasColumn
+	drop bool
+}
+
+type alterTableStatementImpl struct {
+	table       *Table
+	alterations []columnAlteration
+}
+
+func (s *alterTableStatementImpl) AddColumn(
+	column NonAliasColumn) AlterTableStatement {
+
+	s.alterations = append(s.alterations, columnAlteration{col: column})
+	return s
+}
+
+func (s *alterTableStatementImpl) DropColumn(
+	column NonAliasColumn) AlterTableStatement {
+
+	s.alterations = append(
+		s.alterations,
+		columnAlteration{col: column, drop: true})
+	return s
+}
+
+func (s *alterTableStatementImpl) String(database string) (sql string, err error) {
+	return s.StringForDialect(database, MySqlDialect)
+}
+
+func (s *alterTableStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
+	if !validIdentifierName(database) {
+		return "", errors.New("Invalid database name specified")
+	}
+
+	buf := newSqlWriter(dialect)
+	_, _ = buf.WriteString("ALTER TABLE ")
+
+	if err = writeTableName(database, s.table, buf); err != nil {
+		return
+	}
+
+	if len(s.alterations) == 0 {
+		return "", errors.Newf(
+			"No column altered.  Generated sql: %s",
+			buf.String())
+	}
+
+	for i, alteration := range s.alterations {
+		if i > 0 {
+			_ = buf.WriteByte(',')
+		}
+
+		if alteration.drop {
+			if alteration.col == nil {
+				return "", errors.Newf(
+					"nil column.  Generated sql: %s",
+					buf.String())
+			}
+			_, _ = buf.WriteString(" DROP COLUMN ")
+			buf.writeIdentifier(alteration.col.Name())
+		} else {
+			_, _ = buf.WriteString(" ADD COLUMN ")
+			if err = writeColumnDefinition(alteration.col, buf); err != nil {
+				return
+			}
+		}
+	}
+
+	return buf.String(), nil
+}
+
+//
+// DROP TABLE statement =======================================================
+//
+
+func newDropTableStatement(table *Table) DropTableStatement {
+	return &dropTableStatementImpl{
+		table: table,
+	}
+}
+
+type dropTableStatementImpl struct {
+	table    *Table
+	ifExists bool
+}
+
+func (s *dropTableStatementImpl) IfExists() DropTableStatement {
+	s.ifExists = true
+	return s
+}
+
+func (s *dropTableStatementImpl) String(database string) (sql string, err error) {
+	return s.StringForDialect(database, MySqlDialect)
+}
+
+func (s *dropTableStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
+	if !validIdentifierName(database) {
+		return "", errors.New("Invalid database name specified")
+	}
+
+	buf := newSqlWriter(dialect)
+	_, _ = buf.WriteString("DROP TABLE ")
+	if s.ifExists {
+		_, _ = buf.WriteString("IF EXISTS ")
+	}
+
+	if err = writeTableName(database, s.table, buf); err != nil {
+		return
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,140 @@
+package sqlbuilder
+
+import (
+	gc "gopkg.in/check.v1"
+)
+
+type DdlSuite struct {
+}
+
+var _ = gc.Suite(&DdlSuite{})
+
+func newDdlTestTable() *Table {
+	id := WithColumnOptions(
+		IntColumn("id", NotNullable),
+		ColumnOptions{Unsigned: true, AutoIncrement: true})
+	name := WithColumnOptions(
+		StrColumn("name", UTF8, UTF8CaseInsensitive, NotNullable),
+		ColumnOptions{Size: 255, Default: Literal("")})
+	owner := WithColumnOptions(
+		IntColumn("owner", Nullable),
+		ColumnOptions{Size: 4})
+	created := WithColumnOptions(
+		DateTimeColumn("created", NotNullable),
+		ColumnOptions{Size: 6})
+
+	t := NewTable(
+		"ddl_table",
+		id,
+		name,
+		owner,
+		created,
+		BytesColumn("data", Nullable),
+		BoolColumn("active", NotNullable))
+
+	return t.SetPrimaryKey(id).
+		AddUniqueIndex("name_idx", name).
+		AddIndex("owner_created_idx", owner, t.C("created"))
+}
+
+func (s *DdlSuite) TestCreateTable(c *gc.C) {
+	sql, err := newDdlTestTable().CreateTable().String("db")
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		"CREATE TABLE `db`.`ddl_table` ("+
+			"`id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT, "+
+			"`name` VARCHAR(255) CHARACTER SET utf8 "+
+			"COLLATE utf8_unicode_ci NOT NULL DEFAULT '', "+
+			"`owner` INT, "+
+			"`created` DATETIME(6) NOT NULL, "+
+			"`data` BLOB, "+
+			"`active` TINYINT(1) NOT NULL, "+
+			"PRIMARY KEY (`id`), "+
+			"UNIQUE KEY `name_idx` (`name`), "+
+			"KEY `owner_created_idx` (`owner`,`created`))")
+}
+
+func (s *DdlSuite) TestCreateTableIfNotExists(c *gc.C) {
+	t := NewTable("t", IntColumn("a", Nullable))
+
+	sql, err := t.CreateTable().IfNotExists().String("db")
+	c.Assert(err, gc.IsNil)
+	c.Assert(sql, gc.Equals, "CREATE TABLE IF NOT EXISTS `db`.`t` (`a` BIGINT)")
+}
+
+func (s *DdlSuite) TestCreateTableInvalidDatabase(c *gc.C) {
+	_, err := newDdlTestTable().CreateTable().String("db\x00")
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *DdlSuite) TestCreateTableInvalidOptions(c *gc.C) {
+	t := NewTable(
+		"t",
+		WithColumnOptions(
+			StrColumn("a", UTF8, UTF8Binary, Nullable),
+			ColumnOptions{AutoIncrement: true}))
+	_, err := t.CreateTable().String("db")
+	c.Assert(err, gc.NotNil)
+
+	t = NewTable(
+		"t",
+		WithColumnOptions(IntColumn("a", Nullable), ColumnOptions{Size: 5}))
+	_, err = t.CreateTable().String("db")
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *DdlSuite) TestWithColumnOptionsOnLookupColumn(c *gc.C) {
+	c.Assert(
+		func() { WithColumnOptions(table1.C("col1"), ColumnOptions{}) },
+		gc.PanicMatches,
+		".*non-materialized.*")
+}
+
+func (s *DdlSuite) TestKeyOnUnknownColumn(c *gc.C) {
+	t := NewTable("t", IntColumn("a", Nullable))
+	c.Assert(
+		func() { t.SetPrimaryKey(IntColumn("b", Nullable)) },
+		gc.PanicMatches,
+		"Key column b is not in table t")
+	c.Assert(func() { t.AddIndex("idx") }, gc.PanicMatches, "Empty key.*")
+}
+
+func (s *DdlSuite) TestAlterTable(c *gc.C) {
+	t := newDdlTestTable()
+
+	sql, err := t.AlterTable().
+		AddColumn(WithColumnOptions(
+			BytesColumn("extra", NotNullable),
+			ColumnOptions{Size: 16})).
+		DropColumn(t.C("data")).
+		String("db")
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		"ALTER TABLE `db`.`ddl_table` "+
+			"ADD COLUMN `extra` VARBINARY(16) NOT NULL, DROP COLUMN `data`")
+}
+
+func (s *DdlSuite) TestAlterTableNoAlterations(c *gc.C) {
+	_, err := newDdlTestTable().AlterTable().String("db")
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *DdlSuite) TestAlterTableAddLookupColumn(c *gc.C) {
+	t := newDdlTestTable()
+	_, err := t.AlterTable().AddColumn(t.C("data")).String("db")
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *DdlSuite) TestDropTable(c *gc.C) {
+	sql, err := newDdlTestTable().DropTable().String("db")
+	c.Assert(err, gc.IsNil)
+	c.Assert(sql, gc.Equals, "DROP TABLE `db`.`ddl_table`")
+
+	sql, err = newDdlTestTable().DropTable().IfExists().String("db")
+	c.Assert(err, gc.IsNil)
+	c.Assert(sql, gc.Equals, "DROP TABLE IF EXISTS `db`.`ddl_table`")
+}
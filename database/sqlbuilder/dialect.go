@@ -0,0 +1,659 @@
+// Modeling of sql variants
+
+package sqlbuilder
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/database/sqltypes"
+	"github.com/dropbox/godropbox/encoding2"
+	"github.com/dropbox/godropbox/errors"
+)
+
+// Dialect controls the parts of the generated sql which differ between sql
+// variants.  Statements are generated using MySqlDialect unless a dialect is
+// passed to StringForDialect.
+type Dialect interface {
+	// Writes name as a quoted identifier.
+	QuoteIdentifier(name string, out *bytes.Buffer)
+
+	// Writes value as an escaped literal.
+	EncodeLiteral(value sqltypes.Value, out *bytes.Buffer)
+
+	// Returns the placeholder for the n-th (1-based) bind parameter of a
+	// statement.
+	Placeholder(n int) string
+
+	// Writes the LIMIT / OFFSET clause.  offset is negative if unspecified.
+	WriteLimit(limit int64, offset int64, out *bytes.Buffer)
+
+	// Writes the row locking clause of a SELECT statement.
+	WriteSelectLock(forUpdate bool, withSharedLock bool, out *bytes.Buffer) error
+
+	// Writes the beginning of an INSERT statement, up to and including INTO.
+	WriteInsertInto(replace bool, ignore bool, out *bytes.Buffer) error
+
+	// Writes the upsert clause which follows the inserted rows.  hasUpdates is
+	// true if the clause is followed by a column assignment list.  key is the
+	// table's primary key, which is empty if undefined.
+	WriteOnConflict(
+		ignore bool,
+		hasUpdates bool,
+		key []string,
+		out *bytes.Buffer) error
+
+	// Writes a reference to the value that would have been inserted into the
+	// column, for use in the upsert assignment list.
+	WriteInsertedValue(column string, out *bytes.Buffer)
+
+	// Returns true if assigned columns (i.e., INSERT column lists and
+	// UPDATE ... SET targets) may be qualified by table name.
+	QualifiesAssignedColumns() bool
+
+	// Returns true if UPDATE / DELETE statements accept ORDER BY and LIMIT
+	// clauses.
+	LimitsMutations() bool
+
+	// Returns true if the SELECT of an INSERT ... SELECT statement with an
+	// upsert clause must have a WHERE clause (i.e., the upsert clause is
+	// otherwise ambiguous with a join constraint).
+	UpsertSelectRequiresWhere() bool
+
+	// Returns the column type used in DDL statements.  size is the column's
+	// ColumnOptions.Size, which is validated by the caller.
+	ColumnType(kind ColumnKind, size int) string
+
+	// Writes the beginning of a CREATE INDEX statement, up to and including
+	// the indexed table's name.
+	WriteCreateIndex(
+		database string,
+		table string,
+		index string,
+		unique bool,
+		ifNotExists bool,
+		out *bytes.Buffer)
+
+	// Returns true if the dialect accepts mysql specific syntax, i.e., LOCK
+	// TABLES, SET GTID_NEXT, FORCE INDEX, INTERVAL, IF(), multi-table
+	// UPDATE / DELETE and AUTO_INCREMENT.  The UNSIGNED, CHARACTER SET and COLLATE
+	// column attributes are omitted for non-mysql dialects.
+	MySqlCompatible() bool
+}
+
+// The kinds of materialized columns, used for mapping column types in DDL
+// statements.
+type ColumnKind int
+
+const (
+	BoolColumnKind ColumnKind = iota
+	IntColumnKind
+	DoubleColumnKind
+	StrColumnKind
+	BytesColumnKind
+	DateTimeColumnKind
+)
+
+// Serialization state shared by all clauses of a statement.
+type sqlWriter struct {
+	*bytes.Buffer
+
+	dialect Dialect
+
+	// The number of placeholders written so far.
+	numPlaceholders int
+}
+
+func newSqlWriter(dialect Dialect) *sqlWriter {
+	return &sqlWriter{
+		Buffer:  new(bytes.Buffer),
+		dialect: dialect,
+	}
+}
+
+// Returns a writer which appends to out using the default (mysql) dialect.
+// This is used by the exported SerializeSql methods.
+func defaultSqlWriter(out *bytes.Buffer) *sqlWriter {
+	return &sqlWriter{
+		Buffer:  out,
+		dialect: MySqlDialect,
+	}
+}
+
+// Returns a writer for serializing into a scratch buffer.  The scratch
+// writer's output must be appended with merge, or discarded.
+func (w *sqlWriter) scratch() *sqlWriter {
+	return &sqlWriter{
+		Buffer:          new(bytes.Buffer),
+		dialect:         w.dialect,
+		numPlaceholders: w.numPlaceholders,
+	}
+}
+
+func (w *sqlWriter) merge(scratch *sqlWriter) {
+	_, _ = w.Write(scratch.Bytes())
+	w.numPlaceholders = scratch.numPlaceholders
+}
+
+func (w *sqlWriter) writeIdentifier(name string) {
+	w.dialect.QuoteIdentifier(name, w.Buffer)
+}
+
+func (w *sqlWriter) writePlaceholder() {
+	w.numPlaceholders++
+	_, _ = w.WriteString(w.dialect.Placeholder(w.numPlaceholders))
+}
+
+// Returns an error if the dialect is not mysql compatible.
+func (w *sqlWriter) requireMySql(feature string) error {
+	if !w.dialect.MySqlCompatible() {
+		return errors.Newf(
+			"%s is not supported by the sql dialect.  Generated sql: %s",
+			feature,
+			w.String())
+	}
+	return nil
+}
+
+//
+// MySQL ======================================================================
+//
+
+type mySqlDialect struct{}
+
+// The default dialect.
+var MySqlDialect Dialect = mySqlDialect{}
+
+func (mySqlDialect) QuoteIdentifier(name string, out *bytes.Buffer) {
+	_ = out.WriteByte('`')
+	_, _ = out.WriteString(name)
+	_ = out.WriteByte('`')
+}
+
+func (mySqlDialect) EncodeLiteral(value sqltypes.Value, out *bytes.Buffer) {
+	value.EncodeSql(out)
+}
+
+func (mySqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (mySqlDialect) WriteLimit(limit int64, offset int64, out *bytes.Buffer) {
+	_, _ = out.WriteString(" LIMIT ")
+	if offset >= 0 {
+		_, _ = out.WriteString(strconv.FormatInt(offset, 10))
+		_, _ = out.WriteString(", ")
+	}
+	_, _ = out.WriteString(strconv.FormatInt(limit, 10))
+}
+
+func (mySqlDialect) WriteSelectLock(
+	forUpdate bool,
+	withSharedLock bool,
+	out *bytes.Buffer) error {
+
+	if forUpdate {
+		_, _ = out.WriteString(" FOR UPDATE")
+	} else if withSharedLock {
+		_, _ = out.WriteString(" LOCK IN SHARE MODE")
+	}
+	return nil
+}
+
+func (mySqlDialect) WriteInsertInto(
+	replace bool,
+	ignore bool,
+	out *bytes.Buffer) error {
+
+	if replace {
+		_, _ = out.WriteString("REPLACE ")
+	} else {
+		_, _ = out.WriteString("INSERT ")
+	}
+	if ignore {
+		_, _ = out.WriteString("IGNORE ")
+	}
+	_, _ = out.WriteString("INTO ")
+	return nil
+}
+
+func (mySqlDialect) WriteOnConflict(
+	ignore bool,
+	hasUpdates bool,
+	key []string,
+	out *bytes.Buffer) error {
+
+	if hasUpdates {
+		_, _ = out.WriteString(" ON DUPLICATE KEY UPDATE ")
+	}
+	return nil
+}
+
+func (d mySqlDialect) WriteInsertedValue(column string, out *bytes.Buffer) {
+	_, _ = out.WriteString("VALUES(")
+	d.QuoteIdentifier(column, out)
+	_ = out.WriteByte(')')
+}
+
+func (mySqlDialect) QualifiesAssignedColumns() bool {
+	return true
+}
+
+func (mySqlDialect) LimitsMutations() bool {
+	return true
+}
+
+func (mySqlDialect) UpsertSelectRequiresWhere() bool {
+	return false
+}
+
+func (mySqlDialect) ColumnType(kind ColumnKind, size int) string {
+	switch kind {
+	case BoolColumnKind:
+		return "TINYINT(1)"
+	case IntColumnKind:
+		switch size {
+		case 1:
+			return "TINYINT"
+		case 2:
+			return "SMALLINT"
+		case 3:
+			return "MEDIUMINT"
+		case 4:
+			return "INT"
+		}
+		return "BIGINT"
+	case DoubleColumnKind:
+		return "DOUBLE"
+	case BytesColumnKind:
+		if size > 0 {
+			return "VARBINARY(" + strconv.Itoa(size) + ")"
+		}
+		return "BLOB"
+	case DateTimeColumnKind:
+		if size > 0 {
+			return "DATETIME(" + strconv.Itoa(size) + ")"
+		}
+		return "DATETIME"
+	}
+	return standardStrColumnType(size)
+}
+
+func (d mySqlDialect) WriteCreateIndex(
+	database string,
+	table string,
+	index string,
+	unique bool,
+	ifNotExists bool,
+	out *bytes.Buffer) {
+
+	// NOTE: mysql does not support CREATE INDEX IF NOT EXISTS.
+	writeCreateIndexPrefix(unique, false, out)
+	d.QuoteIdentifier(index, out)
+	_, _ = out.WriteString(" ON ")
+	d.QuoteIdentifier(database, out)
+	_ = out.WriteByte('.')
+	d.QuoteIdentifier(table, out)
+}
+
+func (mySqlDialect) MySqlCompatible() bool {
+	return true
+}
+
+//
+// Standard sql helpers =======================================================
+//
+
+func quoteStandardIdentifier(name string, out *bytes.Buffer) {
+	_ = out.WriteByte('"')
+	_, _ = out.WriteString(strings.Replace(name, "\"", "\"\"", -1))
+	_ = out.WriteByte('"')
+}
+
+// Writes a string literal using standard sql escaping (i.e., quotes are
+// doubled, and backslashes are not special).
+func encodeStandardString(s []byte, out *bytes.Buffer) {
+	_ = out.WriteByte('\'')
+	for _, c := range s {
+		if c == '\'' {
+			_ = out.WriteByte('\'')
+		}
+		_ = out.WriteByte(c)
+	}
+	_ = out.WriteByte('\'')
+}
+
+func writeStandardLimit(limit int64, offset int64, out *bytes.Buffer) {
+	_, _ = out.WriteString(" LIMIT ")
+	_, _ = out.WriteString(strconv.FormatInt(limit, 10))
+	if offset >= 0 {
+		_, _ = out.WriteString(" OFFSET ")
+		_, _ = out.WriteString(strconv.FormatInt(offset, 10))
+	}
+}
+
+func standardStrColumnType(size int) string {
+	if size > 0 {
+		return "VARCHAR(" + strconv.Itoa(size) + ")"
+	}
+	return "TEXT"
+}
+
+// Maps integer storage sizes to standard sql integer types.
+func standardIntColumnType(size int) string {
+	switch size {
+	case 1, 2:
+		return "SMALLINT"
+	case 3, 4:
+		return "INTEGER"
+	}
+	return "BIGINT"
+}
+
+func writeCreateIndexPrefix(unique bool, ifNotExists bool, out *bytes.Buffer) {
+	_, _ = out.WriteString("CREATE ")
+	if unique {
+		_, _ = out.WriteString("UNIQUE ")
+	}
+	_, _ = out.WriteString("INDEX ")
+	if ifNotExists {
+		_, _ = out.WriteString("IF NOT EXISTS ")
+	}
+}
+
+func writeConflictTarget(key []string, out *bytes.Buffer) {
+	_ = out.WriteByte('(')
+	for i, col := range key {
+		if i > 0 {
+			_ = out.WriteByte(',')
+		}
+		quoteStandardIdentifier(col, out)
+	}
+	_ = out.WriteByte(')')
+}
+
+//
+// SQLite =====================================================================
+//
+
+type sqliteDialect struct{}
+
+// Dialect for SQLite 3.35+ (upserts on tables without a primary key use
+// ON CONFLICT without a conflict target, which requires 3.35).  SELECT
+// locking clauses are omitted since sqlite transactions are serializable.
+// UPDATE / DELETE with ORDER BY / LIMIT requires sqlite to be compiled with
+// SQLITE_ENABLE_UPDATE_DELETE_LIMIT.  NOTE: sqlite only recognizes "main",
+// "temp" and attached database names as the statement's database.
+var SqliteDialect Dialect = sqliteDialect{}
+
+func (sqliteDialect) QuoteIdentifier(name string, out *bytes.Buffer) {
+	quoteStandardIdentifier(name, out)
+}
+
+func (sqliteDialect) EncodeLiteral(value sqltypes.Value, out *bytes.Buffer) {
+	if value.IsString() && !value.IsUtf8String() {
+		// Blob literal
+		_, _ = out.WriteString("X'")
+		encoding2.HexEncodeToWriter(out, value.Raw())
+		_ = out.WriteByte('\'')
+	} else if value.IsString() {
+		encodeStandardString(value.Raw(), out)
+	} else {
+		value.EncodeSql(out)
+	}
+}
+
+func (sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (sqliteDialect) WriteLimit(limit int64, offset int64, out *bytes.Buffer) {
+	writeStandardLimit(limit, offset, out)
+}
+
+func (sqliteDialect) WriteSelectLock(
+	forUpdate bool,
+	withSharedLock bool,
+	out *bytes.Buffer) error {
+
+	return nil
+}
+
+func (sqliteDialect) WriteInsertInto(
+	replace bool,
+	ignore bool,
+	out *bytes.Buffer) error {
+
+	if replace {
+		_, _ = out.WriteString("REPLACE ")
+	} else if ignore {
+		_, _ = out.WriteString("INSERT OR IGNORE ")
+	} else {
+		_, _ = out.WriteString("INSERT ")
+	}
+	_, _ = out.WriteString("INTO ")
+	return nil
+}
+
+func (sqliteDialect) WriteOnConflict(
+	ignore bool,
+	hasUpdates bool,
+	key []string,
+	out *bytes.Buffer) error {
+
+	if !hasUpdates {
+		return nil
+	}
+
+	_, _ = out.WriteString(" ON CONFLICT")
+	if len(key) > 0 {
+		writeConflictTarget(key, out)
+	}
+	_, _ = out.WriteString(" DO UPDATE SET ")
+	return nil
+}
+
+func (sqliteDialect) WriteInsertedValue(column string, out *bytes.Buffer) {
+	_, _ = out.WriteString("excluded.")
+	quoteStandardIdentifier(column, out)
+}
+
+func (sqliteDialect) QualifiesAssignedColumns() bool {
+	return false
+}
+
+func (sqliteDialect) LimitsMutations() bool {
+	return true
+}
+
+func (sqliteDialect) UpsertSelectRequiresWhere() bool {
+	return true
+}
+
+func (sqliteDialect) ColumnType(kind ColumnKind, size int) string {
+	switch kind {
+	case BoolColumnKind:
+		return "BOOLEAN"
+	case IntColumnKind:
+		return standardIntColumnType(size)
+	case DoubleColumnKind:
+		return "REAL"
+	case BytesColumnKind:
+		return "BLOB"
+	case DateTimeColumnKind:
+		return "TIMESTAMP"
+	}
+	return standardStrColumnType(size)
+}
+
+// NOTE: sqlite qualifies the index name (rather than the table name) by
+// database.
+func (sqliteDialect) WriteCreateIndex(
+	database string,
+	table string,
+	index string,
+	unique bool,
+	ifNotExists bool,
+	out *bytes.Buffer) {
+
+	writeCreateIndexPrefix(unique, ifNotExists, out)
+	quoteStandardIdentifier(database, out)
+	_ = out.WriteByte('.')
+	quoteStandardIdentifier(index, out)
+	_, _ = out.WriteString(" ON ")
+	quoteStandardIdentifier(table, out)
+}
+
+func (sqliteDialect) MySqlCompatible() bool {
+	return false
+}
+
+//
+// PostgreSQL =================================================================
+//
+
+type postgreSqlDialect struct{}
+
+// Dialect for PostgreSQL 9.5+.  Upserts use the table's primary key (see
+// Table.SetPrimaryKey) as the conflict target.  REPLACE, and UPDATE / DELETE
+// with ORDER BY / LIMIT are not supported.
+var PostgreSqlDialect Dialect = postgreSqlDialect{}
+
+func (postgreSqlDialect) QuoteIdentifier(name string, out *bytes.Buffer) {
+	quoteStandardIdentifier(name, out)
+}
+
+func (postgreSqlDialect) EncodeLiteral(
+	value sqltypes.Value,
+	out *bytes.Buffer) {
+
+	if value.IsString() && !value.IsUtf8String() {
+		_, _ = out.WriteString("'\\x")
+		encoding2.HexEncodeToWriter(out, value.Raw())
+		_, _ = out.WriteString("'::bytea")
+	} else if value.IsString() {
+		encodeStandardString(value.Raw(), out)
+	} else {
+		value.EncodeSql(out)
+	}
+}
+
+func (postgreSqlDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgreSqlDialect) WriteLimit(
+	limit int64,
+	offset int64,
+	out *bytes.Buffer) {
+
+	writeStandardLimit(limit, offset, out)
+}
+
+func (postgreSqlDialect) WriteSelectLock(
+	forUpdate bool,
+	withSharedLock bool,
+	out *bytes.Buffer) error {
+
+	if forUpdate {
+		_, _ = out.WriteString(" FOR UPDATE")
+	} else if withSharedLock {
+		_, _ = out.WriteString(" FOR SHARE")
+	}
+	return nil
+}
+
+func (postgreSqlDialect) WriteInsertInto(
+	replace bool,
+	ignore bool,
+	out *bytes.Buffer) error {
+
+	if replace {
+		return errors.New("REPLACE is not supported by postgresql")
+	}
+	_, _ = out.WriteString("INSERT INTO ")
+	return nil
+}
+
+func (postgreSqlDialect) WriteOnConflict(
+	ignore bool,
+	hasUpdates bool,
+	key []string,
+	out *bytes.Buffer) error {
+
+	if hasUpdates {
+		if len(key) == 0 {
+			return errors.New(
+				"postgresql upserts require the table's primary key")
+		}
+		_, _ = out.WriteString(" ON CONFLICT ")
+		writeConflictTarget(key, out)
+		_, _ = out.WriteString(" DO UPDATE SET ")
+	} else if ignore {
+		_, _ = out.WriteString(" ON CONFLICT DO NOTHING")
+	}
+	return nil
+}
+
+func (postgreSqlDialect) WriteInsertedValue(
+	column string,
+	out *bytes.Buffer) {
+
+	_, _ = out.WriteString("EXCLUDED.")
+	quoteStandardIdentifier(column, out)
+}
+
+func (postgreSqlDialect) QualifiesAssignedColumns() bool {
+	return false
+}
+
+func (postgreSqlDialect) LimitsMutations() bool {
+	return false
+}
+
+func (postgreSqlDialect) UpsertSelectRequiresWhere() bool {
+	return false
+}
+
+func (postgreSqlDialect) ColumnType(kind ColumnKind, size int) string {
+	switch kind {
+	case BoolColumnKind:
+		// NOTE: bool values are encoded as 0 / 1, which postgresql does not
+		// implicitly cast to BOOLEAN.
+		return "SMALLINT"
+	case IntColumnKind:
+		return standardIntColumnType(size)
+	case DoubleColumnKind:
+		return "DOUBLE PRECISION"
+	case BytesColumnKind:
+		return "BYTEA"
+	case DateTimeColumnKind:
+		if size > 0 {
+			return "TIMESTAMP(" + strconv.Itoa(size) + ")"
+		}
+		return "TIMESTAMP"
+	}
+	return standardStrColumnType(size)
+}
+
+// NOTE: postgresql creates the index in the table's schema.
+func (postgreSqlDialect) WriteCreateIndex(
+	database string,
+	table string,
+	index string,
+	unique bool,
+	ifNotExists bool,
+	out *bytes.Buffer) {
+
+	writeCreateIndexPrefix(unique, ifNotExists, out)
+	quoteStandardIdentifier(index, out)
+	_, _ = out.WriteString(" ON ")
+	quoteStandardIdentifier(database, out)
+	_ = out.WriteByte('.')
+	quoteStandardIdentifier(table, out)
+}
+
+func (postgreSqlDialect) MySqlCompatible() bool {
+	return false
+}
@@ -0,0 +1,209 @@
+package sqlbuilder
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	gc "gopkg.in/check.v1"
+)
+
+// Executes the generated sql against an in-memory sqlite database.
+type SqliteDialectSuite struct {
+	db *sql.DB
+}
+
+var _ = gc.Suite(&SqliteDialectSuite{})
+
+func (s *SqliteDialectSuite) SetUpTest(c *gc.C) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	c.Assert(err, gc.IsNil)
+
+	// Each connection has its own in-memory database.
+	db.SetMaxOpenConns(1)
+
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		c.Skip("sqlite is unavailable: " + err.Error())
+	}
+	s.db = db
+}
+
+func (s *SqliteDialectSuite) TearDownTest(c *gc.C) {
+	if s.db != nil {
+		_ = s.db.Close()
+		s.db = nil
+	}
+}
+
+func (s *SqliteDialectSuite) exec(
+	c *gc.C,
+	stmt Statement,
+	args ...interface{}) {
+
+	query, err := stmt.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+
+	_, err = s.db.Exec(query, args...)
+	c.Assert(err, gc.IsNil, gc.Commentf("sql: %s", query))
+}
+
+func (s *SqliteDialectSuite) queryNames(c *gc.C, stmt Statement) []string {
+	query, err := stmt.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+
+	rows, err := s.db.Query(query)
+	c.Assert(err, gc.IsNil, gc.Commentf("sql: %s", query))
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		c.Assert(rows.Scan(&name), gc.IsNil)
+		names = append(names, name)
+	}
+	c.Assert(rows.Err(), gc.IsNil)
+	return names
+}
+
+func (s *SqliteDialectSuite) TestStatements(c *gc.C) {
+	t := newDialectIndexedTestTable()
+
+	sqls, err := t.CreateTable().
+		IfNotExists().
+		StringsForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	for _, query := range sqls {
+		_, err = s.db.Exec(query)
+		c.Assert(err, gc.IsNil, gc.Commentf("sql: %s", query))
+	}
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, name := range []string{"a", "b", "c"} {
+		s.exec(
+			c,
+			t.Insert(
+				t.C("id"),
+				t.C("name"),
+				t.C("owner"),
+				t.C("created"),
+				t.C("score"),
+				t.C("data"),
+				t.C("active")).
+				Add(
+					Literal(i+1),
+					Placeholder(),
+					Literal(1),
+					Literal(created),
+					Literal(0.5),
+					Literal([]byte{0x00, byte(i)}),
+					Literal(true)),
+			name)
+	}
+
+	// The unique index is created.
+	query, err := t.Insert(
+		t.C("id"),
+		t.C("name"),
+		t.C("created"),
+		t.C("active")).
+		Add(Literal(4), Literal("a"), Literal(created), Literal(false)).
+		StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	_, err = s.db.Exec(query)
+	c.Assert(err, gc.NotNil)
+
+	s.exec(
+		c,
+		t.Insert(t.C("id"), t.C("name"), t.C("created"), t.C("active")).
+			Add(Literal(1), Literal("z"), Literal(created), Literal(false)).
+			IgnoreDuplicates(true))
+
+	s.exec(
+		c,
+		t.Insert(t.C("id"), t.C("name"), t.C("created"), t.C("active")).
+			Add(Literal(2), Literal("bb"), Literal(created), Literal(false)).
+			AddOnDuplicateKeyUpdateValues(t.C("name")))
+
+	s.exec(
+		c,
+		t.Update().
+			Set(t.C("name"), Literal("it's")).
+			Where(EqL(t.C("data"), []byte{0x00, 0x02})))
+
+	s.exec(c, t.Delete().Where(EqL(t.C("id"), 1)))
+
+	names := s.queryNames(
+		c,
+		t.Select(t.C("name")).
+			Where(EqL(t.C("active"), true)).
+			OrderBy(Asc(t.C("id"))).
+			Limit(10).
+			Offset(0))
+	c.Assert(names, gc.DeepEquals, []string{"bb", "it's"})
+
+	s.exec(c, t.DropTable())
+}
+
+func (s *SqliteDialectSuite) TestUpsertWithoutPrimaryKey(c *gc.C) {
+	id := IntColumn("id", NotNullable)
+	name := StrColumn("name", UTF8, UTF8CaseInsensitive, Nullable)
+	t := NewTable("t", id, name).AddUniqueIndex("id_idx", id)
+
+	sqls, err := t.CreateTable().StringsForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	for _, query := range sqls {
+		_, err = s.db.Exec(query)
+		c.Assert(err, gc.IsNil, gc.Commentf("sql: %s", query))
+	}
+
+	for _, value := range []string{"a", "b"} {
+		s.exec(
+			c,
+			t.Insert(id, name).
+				Add(Literal(1), Literal(value)).
+				AddOnDuplicateKeyUpdateValues(name))
+	}
+
+	c.Assert(s.queryNames(c, t.Select(name)), gc.DeepEquals, []string{"b"})
+}
+
+func (s *SqliteDialectSuite) TestUpsertSelectWithoutWhere(c *gc.C) {
+	id := IntColumn("id", NotNullable)
+	name := StrColumn("name", UTF8, UTF8CaseInsensitive, Nullable)
+	t := NewTable("t", id, name).SetPrimaryKey(id)
+
+	srcId := IntColumn("id", NotNullable)
+	srcName := StrColumn("name", UTF8, UTF8CaseInsensitive, Nullable)
+	src := NewTable("s", srcId, srcName).SetPrimaryKey(srcId)
+
+	for _, table := range []*Table{t, src} {
+		sqls, err := table.CreateTable().StringsForDialect(
+			"main",
+			SqliteDialect)
+		c.Assert(err, gc.IsNil)
+		for _, query := range sqls {
+			_, err = s.db.Exec(query)
+			c.Assert(err, gc.IsNil, gc.Commentf("sql: %s", query))
+		}
+	}
+
+	s.exec(c, t.Insert(id, name).Add(Literal(1), Literal("a")))
+	s.exec(c, src.Insert(srcId, srcName).Add(Literal(1), Literal("b")))
+
+	stmt := t.Insert(id, name).
+		AddSelect(src.Select(srcId, srcName)).
+		AddOnDuplicateKeyUpdateValues(name)
+
+	query, err := stmt.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		query,
+		gc.Equals,
+		`INSERT INTO "main"."t" ("id","name") `+
+			`SELECT "s"."id","s"."name" FROM "main"."s" WHERE true `+
+			`ON CONFLICT("id") DO UPDATE SET "name"=excluded."name"`)
+
+	s.exec(c, stmt)
+	c.Assert(s.queryNames(c, t.Select(name)), gc.DeepEquals, []string{"b"})
+}
@@ -0,0 +1,417 @@
+package sqlbuilder
+
+import (
+	"time"
+
+	gc "gopkg.in/check.v1"
+)
+
+type DialectSuite struct {
+}
+
+var _ = gc.Suite(&DialectSuite{})
+
+func newDialectTestTable() *Table {
+	id := IntColumn("id", NotNullable)
+	t := NewTable(
+		"t",
+		id,
+		StrColumn("name", UTF8, UTF8CaseInsensitive, Nullable),
+		BytesColumn("data", Nullable))
+	return t.SetPrimaryKey(id)
+}
+
+func (s *DialectSuite) TestSelect(c *gc.C) {
+	t := newDialectTestTable()
+	q := t.Select(t.C("id"), t.C("name")).
+		Where(EqL(t.C("name"), "it's")).
+		OrderBy(Asc(t.C("id"))).
+		Limit(10).
+		Offset(20)
+
+	sql, err := q.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`SELECT "t"."id","t"."name" FROM "main"."t" `+
+			`WHERE "t"."name"='it''s' ORDER BY "t"."id" ASC LIMIT 10 OFFSET 20`)
+
+	sql, err = q.StringForDialect("db", MySqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		"SELECT `t`.`id`,`t`.`name` FROM `db`.`t` "+
+			"WHERE `t`.`name`='it\\'s' ORDER BY `t`.`id` ASC LIMIT 20, 10")
+}
+
+func (s *DialectSuite) TestBinaryLiteral(c *gc.C) {
+	t := newDialectTestTable()
+	q := t.Select(t.C("id")).Where(EqL(t.C("data"), []byte{0x01, 0xab}))
+
+	sql, err := q.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`SELECT "t"."id" FROM "main"."t" WHERE "t"."data"=X'01ab'`)
+
+	sql, err = q.StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`SELECT "t"."id" FROM "public"."t" WHERE "t"."data"='\x01ab'::bytea`)
+}
+
+func (s *DialectSuite) TestIf(c *gc.C) {
+	t := newDialectTestTable()
+	q := t.Select(t.C("id")).
+		Where(EqL(If(GtL(t.C("id"), 1), t.C("name"), Literal("none")), "x"))
+
+	sql, err := q.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`SELECT "t"."id" FROM "main"."t" WHERE `+
+			`CASE WHEN "t"."id">1 THEN "t"."name" ELSE 'none' END='x'`)
+
+	sql, err = q.StringForDialect("db", MySqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		"SELECT `t`.`id` FROM `db`.`t` WHERE "+
+			"IF(`t`.`id`>1,`t`.`name`,'none')='x'")
+}
+
+func (s *DialectSuite) TestSelectLock(c *gc.C) {
+	t := newDialectTestTable()
+
+	sql, err := t.Select(t.C("id")).
+		Where(EqL(t.C("id"), 1)).
+		WithSharedLock().
+		StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`SELECT "t"."id" FROM "public"."t" WHERE "t"."id"=1 FOR SHARE`)
+
+	sql, err = t.Select(t.C("id")).
+		Where(EqL(t.C("id"), 1)).
+		ForUpdate().
+		StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(sql, gc.Equals, `SELECT "t"."id" FROM "main"."t" WHERE "t"."id"=1`)
+}
+
+func (s *DialectSuite) TestPlaceholders(c *gc.C) {
+	t := newDialectTestTable()
+	q := Union(
+		t.Select(t.C("id")).Where(Eq(t.C("id"), Placeholder())),
+		t.Select(t.C("id")).Where(
+			And(Eq(t.C("name"), Placeholder()), In(t.C("id"), []int{1, 2}))))
+
+	sql, err := q.StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`(SELECT "t"."id" FROM "public"."t" WHERE "t"."id"=$1) UNION `+
+			`(SELECT "t"."id" FROM "public"."t" `+
+			`WHERE ("t"."name"=$2 AND "t"."id" IN (1,2)))`)
+
+	sql, err = q.String("db")
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		"(SELECT `t`.`id` FROM `db`.`t` WHERE `t`.`id`=?) UNION "+
+			"(SELECT `t`.`id` FROM `db`.`t` "+
+			"WHERE (`t`.`name`=? AND `t`.`id` IN (1,2)))")
+}
+
+func (s *DialectSuite) TestInsert(c *gc.C) {
+	t := newDialectTestTable()
+	q := t.Insert(t.C("id"), t.C("name")).
+		Add(Placeholder(), Placeholder()).
+		Add(Placeholder(), Placeholder())
+
+	sql, err := q.StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`INSERT INTO "public"."t" ("id","name") VALUES ($1,$2), ($3,$4)`)
+}
+
+func (s *DialectSuite) TestInsertIgnore(c *gc.C) {
+	t := newDialectTestTable()
+	q := t.Insert(t.C("id")).Add(Literal(1)).IgnoreDuplicates(true)
+
+	sql, err := q.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`INSERT OR IGNORE INTO "main"."t" ("id") VALUES (1)`)
+
+	sql, err = q.StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`INSERT INTO "public"."t" ("id") VALUES (1) ON CONFLICT DO NOTHING`)
+}
+
+func (s *DialectSuite) TestUpsert(c *gc.C) {
+	t := newDialectTestTable()
+	q := t.Insert(t.C("id"), t.C("name")).
+		Add(Literal(1), Literal("a")).
+		AddOnDuplicateKeyUpdateValues(t.C("name"))
+
+	sql, err := q.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`INSERT INTO "main"."t" ("id","name") VALUES (1,'a') `+
+			`ON CONFLICT("id") DO UPDATE SET "name"=excluded."name"`)
+
+	sql, err = q.StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`INSERT INTO "public"."t" ("id","name") VALUES (1,'a') `+
+			`ON CONFLICT ("id") DO UPDATE SET "name"=EXCLUDED."name"`)
+
+	sql, err = q.StringForDialect("db", MySqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		"INSERT INTO `db`.`t` (`t`.`id`,`t`.`name`) VALUES (1,'a') "+
+			"ON DUPLICATE KEY UPDATE `t`.`name`=VALUES(`t`.`name`)")
+}
+
+func (s *DialectSuite) TestUpsertWithoutPrimaryKey(c *gc.C) {
+	q := table1.Insert(table1Col1).
+		Add(Literal(1)).
+		AddOnDuplicateKeyUpdate(table1Col2, Literal(2))
+
+	_, err := q.StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.NotNil)
+
+	sql, err := q.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`INSERT INTO "main"."table1" ("col1") VALUES (1) `+
+			`ON CONFLICT DO UPDATE SET "col2"=2`)
+}
+
+func (s *DialectSuite) TestReplace(c *gc.C) {
+	t := newDialectTestTable()
+	q := t.Replace(t.C("id")).Add(Literal(1))
+
+	sql, err := q.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(sql, gc.Equals, `REPLACE INTO "main"."t" ("id") VALUES (1)`)
+
+	_, err = q.StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *DialectSuite) TestUpdate(c *gc.C) {
+	t := newDialectTestTable()
+	q := t.Update().
+		Set(t.C("name"), Placeholder()).
+		Where(Eq(t.C("id"), Placeholder())).
+		Limit(1)
+
+	sql, err := q.StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`UPDATE "main"."t" SET "name"=? WHERE "t"."id"=? LIMIT 1`)
+
+	sql, err = t.Update().
+		Set(t.C("name"), Placeholder()).
+		Where(Eq(t.C("id"), Placeholder())).
+		StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`UPDATE "public"."t" SET "name"=$1 WHERE "t"."id"=$2`)
+}
+
+func (s *DialectSuite) TestMutationLimit(c *gc.C) {
+	t := newDialectTestTable()
+
+	_, err := t.Update().
+		Set(t.C("name"), Literal("x")).
+		Where(EqL(t.C("id"), 1)).
+		Limit(1).
+		StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.NotNil)
+
+	_, err = t.Delete().
+		Where(EqL(t.C("id"), 1)).
+		OrderBy(t.C("id")).
+		StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.NotNil)
+
+	_, err = t.Delete().
+		Where(EqL(t.C("id"), 1)).
+		Limit(1).
+		StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *DialectSuite) TestDelete(c *gc.C) {
+	t := newDialectTestTable()
+
+	sql, err := t.Delete().
+		Where(EqL(t.C("name"), "x")).
+		StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`DELETE FROM "public"."t" WHERE "t"."name"='x'`)
+}
+
+func (s *DialectSuite) TestCreateTable(c *gc.C) {
+	sql, err := newDialectTestTable().
+		CreateTable().
+		StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sql,
+		gc.Equals,
+		`CREATE TABLE "main"."t" ("id" BIGINT NOT NULL, "name" TEXT, `+
+			`"data" BLOB, PRIMARY KEY ("id"))`)
+
+	_, err = newDdlTestTable().
+		CreateTable().
+		StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.NotNil)
+}
+
+func newDialectIndexedTestTable() *Table {
+	id := IntColumn("id", NotNullable)
+	name := WithColumnOptions(
+		StrColumn("name", UTF8, UTF8CaseInsensitive, NotNullable),
+		ColumnOptions{Size: 255})
+	owner := WithColumnOptions(
+		IntColumn("owner", Nullable),
+		ColumnOptions{Size: 4})
+	created := WithColumnOptions(
+		DateTimeColumn("created", NotNullable),
+		ColumnOptions{Size: 6})
+
+	t := NewTable(
+		"t",
+		id,
+		name,
+		owner,
+		created,
+		DoubleColumn("score", Nullable),
+		BytesColumn("data", Nullable),
+		BoolColumn("active", NotNullable))
+
+	return t.SetPrimaryKey(id).
+		AddUniqueIndex("name_idx", name).
+		AddIndex("owner_created_idx", owner, created)
+}
+
+func (s *DialectSuite) TestCreateTableWithIndexes(c *gc.C) {
+	t := newDialectIndexedTestTable()
+
+	sqls, err := t.CreateTable().StringsForDialect("main", SqliteDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sqls,
+		gc.DeepEquals,
+		[]string{
+			`CREATE TABLE "main"."t" ("id" BIGINT NOT NULL, ` +
+				`"name" VARCHAR(255) NOT NULL, "owner" INTEGER, ` +
+				`"created" TIMESTAMP NOT NULL, "score" REAL, ` +
+				`"data" BLOB, "active" BOOLEAN NOT NULL, ` +
+				`PRIMARY KEY ("id"))`,
+			`CREATE UNIQUE INDEX "main"."name_idx" ON "t" ("name")`,
+			`CREATE INDEX "main"."owner_created_idx" ON "t" ` +
+				`("owner","created")`,
+		})
+
+	sqls, err = t.CreateTable().
+		IfNotExists().
+		StringsForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sqls,
+		gc.DeepEquals,
+		[]string{
+			`CREATE TABLE IF NOT EXISTS "public"."t" ("id" BIGINT NOT NULL, ` +
+				`"name" VARCHAR(255) NOT NULL, "owner" INTEGER, ` +
+				`"created" TIMESTAMP(6) NOT NULL, ` +
+				`"score" DOUBLE PRECISION, "data" BYTEA, ` +
+				`"active" SMALLINT NOT NULL, PRIMARY KEY ("id"))`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS "name_idx" ON ` +
+				`"public"."t" ("name")`,
+			`CREATE INDEX IF NOT EXISTS "owner_created_idx" ON ` +
+				`"public"."t" ("owner","created")`,
+		})
+
+	// mysql declares the indexes inline.
+	sqls, err = t.CreateTable().StringsForDialect("db", MySqlDialect)
+	c.Assert(err, gc.IsNil)
+	c.Assert(
+		sqls,
+		gc.DeepEquals,
+		[]string{
+			"CREATE TABLE `db`.`t` (`id` BIGINT NOT NULL, " +
+				"`name` VARCHAR(255) CHARACTER SET utf8 " +
+				"COLLATE utf8_unicode_ci NOT NULL, `owner` INT, " +
+				"`created` DATETIME(6) NOT NULL, `score` DOUBLE, " +
+				"`data` BLOB, `active` TINYINT(1) NOT NULL, " +
+				"PRIMARY KEY (`id`), UNIQUE KEY `name_idx` (`name`), " +
+				"KEY `owner_created_idx` (`owner`,`created`))",
+		})
+}
+
+func (s *DialectSuite) TestMySqlOnlyFeatures(c *gc.C) {
+	t := newDialectTestTable()
+
+	_, err := t.ForceIndex("idx").
+		Select(t.C("id")).
+		StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.NotNil)
+
+	_, err = t.Select(t.C("id")).
+		Where(Gt(Add(t.C("id"), Interval(time.Second)), Literal(0))).
+		StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.NotNil)
+
+	_, err = NewMultiTableDelete(
+		table1.InnerJoinOn(table2, Eq(table1Col3, table2Col3)),
+		table1).
+		Where(EqL(table2Col4, 1)).
+		StringForDialect("public", PostgreSqlDialect)
+	c.Assert(err, gc.NotNil)
+
+	_, err = NewLockStatement().
+		AddReadLock(t).
+		StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.NotNil)
+
+	_, err = NewUnlockStatement().StringForDialect("main", SqliteDialect)
+	c.Assert(err, gc.NotNil)
+}
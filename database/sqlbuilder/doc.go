@@ -1,25 +1,31 @@
 // A library for generating sql programmatically.
 //
-// SQL COMPATIBILITY NOTE: sqlbuilder is designed to generate valid MySQL sql
-// statements.  The generated statements may not work for other sql variants.
-// For instances, the generated statements does not currently work for
-// PostgreSQL since column identifiers are escaped with backquotes.
-// Patches to support other sql flavors are welcome! (see
-// https://godropbox/issues/33 for additional details).
+// SQL COMPATIBILITY NOTE: By default, sqlbuilder generates MySQL sql
+// statements.  Statements may also be generated for SQLite and PostgreSQL
+// via StringForDialect (see Dialect).  The dialect controls identifier
+// quoting, literal escaping, placeholder syntax (see Placeholder), LIMIT /
+// OFFSET, row locking and upserts.  MySQL specific features (e.g., LOCK
+// TABLES, INTERVAL, FORCE INDEX, multi-table UPDATE / DELETE) return an error
+// for other dialects.
+//
+// Table definitions may also include column attributes (see ColumnOptions),
+// a primary key and secondary indexes, from which CREATE TABLE, ALTER TABLE
+// ADD/DROP COLUMN and DROP TABLE statements can be generated.
+//
+// Analyze (and the lower level Walk) reports the tables and columns referenced
+// by a statement, and the values each column is constrained to by equality /
+// IN predicates (e.g., for routing a query to the shard owning its key).
 //
 // Known limitations for SELECT queries:
 //  - does not support subqueries (since mysql is bad at it)
 //  - does not currently support join table alias (and hence self join)
 //  - does not support NATURAL joins and join USING
 //
-// Known limitation for INSERT statements:
-//  - does not support "INSERT INTO SELECT"
-//
 // Known limitation for UPDATE statements:
 //  - does not support update without a WHERE clause (since it is dangerous)
-//  - does not support multi-table update
+//  - does not support ORDER BY / LIMIT for multi-table update
 //
 // Known limitation for DELETE statements:
 //  - does not support delete without a WHERE clause (since it is dangerous)
-//  - does not support multi-table delete
+//  - does not support ORDER BY / LIMIT for multi-table delete
 package sqlbuilder
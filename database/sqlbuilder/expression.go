@@ -19,13 +19,17 @@ type orderByClause struct {
 }
 
 func (o *orderByClause) SerializeSql(out *bytes.Buffer) error {
+	return o.serializeSql(defaultSqlWriter(out))
+}
+
+func (o *orderByClause) serializeSql(out *sqlWriter) error {
 	if o.expression == nil {
 		return errors.Newf(
 			"nil order by clause.  Generated sql: %s",
 			out.String())
 	}
 
-	if err := o.expression.SerializeSql(out); err != nil {
+	if err := o.expression.serializeSql(out); err != nil {
 		return err
 	}
 
@@ -53,14 +57,18 @@ type literalExpression struct {
 }
 
 func (c literalExpression) SerializeSql(out *bytes.Buffer) error {
-	sqltypes.Value(c.value).EncodeSql(out)
+	return c.serializeSql(defaultSqlWriter(out))
+}
+
+func (c literalExpression) serializeSql(out *sqlWriter) error {
+	out.dialect.EncodeLiteral(c.value, out.Buffer)
 	return nil
 }
 
 func serializeClauses(
 	clauses []Clause,
 	separator []byte,
-	out *bytes.Buffer) (err error) {
+	out *sqlWriter) (err error) {
 
 	if clauses == nil || len(clauses) == 0 {
 		return errors.Newf("Empty clauses.  Generated sql: %s", out.String())
@@ -69,7 +77,7 @@ func serializeClauses(
 	if clauses[0] == nil {
 		return errors.Newf("nil clause.  Generated sql: %s", out.String())
 	}
-	if err = clauses[0].SerializeSql(out); err != nil {
+	if err = clauses[0].serializeSql(out); err != nil {
 		return
 	}
 
@@ -79,7 +87,7 @@ func serializeClauses(
 		if c == nil {
 			return errors.Newf("nil clause.  Generated sql: %s", out.String())
 		}
-		if err = c.SerializeSql(out); err != nil {
+		if err = c.serializeSql(out); err != nil {
 			return
 		}
 	}
@@ -95,7 +103,11 @@ type conjunctExpression struct {
 	conjunction []byte
 }
 
-func (conj *conjunctExpression) SerializeSql(out *bytes.Buffer) (err error) {
+func (conj *conjunctExpression) SerializeSql(out *bytes.Buffer) error {
+	return conj.serializeSql(defaultSqlWriter(out))
+}
+
+func (conj *conjunctExpression) serializeSql(out *sqlWriter) (err error) {
 	if len(conj.expressions) == 0 {
 		return errors.Newf(
 			"Empty conjunction.  Generated sql: %s",
@@ -130,7 +142,11 @@ type arithmeticExpression struct {
 	operator    []byte
 }
 
-func (arith *arithmeticExpression) SerializeSql(out *bytes.Buffer) (err error) {
+func (arith *arithmeticExpression) SerializeSql(out *bytes.Buffer) error {
+	return arith.serializeSql(defaultSqlWriter(out))
+}
+
+func (arith *arithmeticExpression) serializeSql(out *sqlWriter) (err error) {
 	if len(arith.expressions) == 0 {
 		return errors.Newf(
 			"Empty arithmetic expression.  Generated sql: %s",
@@ -164,10 +180,14 @@ type tupleExpression struct {
 }
 
 func (tuple *tupleExpression) SerializeSql(out *bytes.Buffer) error {
+	return tuple.serializeSql(defaultSqlWriter(out))
+}
+
+func (tuple *tupleExpression) serializeSql(out *sqlWriter) error {
 	if len(tuple.elements.clauses) < 1 {
 		return errors.Newf("Tuples must include at least one element")
 	}
-	return tuple.elements.SerializeSql(out)
+	return tuple.elements.serializeSql(out)
 }
 
 func Tuple(exprs ...Expression) Expression {
@@ -190,6 +210,10 @@ type listClause struct {
 }
 
 func (list *listClause) SerializeSql(out *bytes.Buffer) error {
+	return list.serializeSql(defaultSqlWriter(out))
+}
+
+func (list *listClause) serializeSql(out *sqlWriter) error {
 	if list.includeParentheses {
 		_ = out.WriteByte('(')
 	}
@@ -212,13 +236,17 @@ type negateExpression struct {
 	nested BoolExpression
 }
 
-func (c *negateExpression) SerializeSql(out *bytes.Buffer) (err error) {
+func (c *negateExpression) SerializeSql(out *bytes.Buffer) error {
+	return c.serializeSql(defaultSqlWriter(out))
+}
+
+func (c *negateExpression) serializeSql(out *sqlWriter) (err error) {
 	_, _ = out.WriteString("NOT (")
 
 	if c.nested == nil {
 		return errors.Newf("nil nested.  Generated sql: %s", out.String())
 	}
-	if err = c.nested.SerializeSql(out); err != nil {
+	if err = c.nested.serializeSql(out); err != nil {
 		return
 	}
 
@@ -240,11 +268,15 @@ type binaryExpression struct {
 	operator []byte
 }
 
-func (c *binaryExpression) SerializeSql(out *bytes.Buffer) (err error) {
+func (c *binaryExpression) SerializeSql(out *bytes.Buffer) error {
+	return c.serializeSql(defaultSqlWriter(out))
+}
+
+func (c *binaryExpression) serializeSql(out *sqlWriter) (err error) {
 	if c.lhs == nil {
 		return errors.Newf("nil lhs.  Generated sql: %s", out.String())
 	}
-	if err = c.lhs.SerializeSql(out); err != nil {
+	if err = c.lhs.serializeSql(out); err != nil {
 		return
 	}
 
@@ -253,7 +285,7 @@ func (c *binaryExpression) SerializeSql(out *bytes.Buffer) (err error) {
 	if c.rhs == nil {
 		return errors.Newf("nil rhs.  Generated sql: %s", out.String())
 	}
-	if err = c.rhs.SerializeSql(out); err != nil {
+	if err = c.rhs.serializeSql(out); err != nil {
 		return
 	}
 
@@ -281,7 +313,11 @@ type funcExpression struct {
 	args     *listClause
 }
 
-func (c *funcExpression) SerializeSql(out *bytes.Buffer) (err error) {
+func (c *funcExpression) SerializeSql(out *bytes.Buffer) error {
+	return c.serializeSql(defaultSqlWriter(out))
+}
+
+func (c *funcExpression) serializeSql(out *sqlWriter) (err error) {
 	if !validIdentifierName(c.funcName) {
 		return errors.Newf(
 			"Invalid function name: %s.  Generated sql: %s",
@@ -292,7 +328,7 @@ func (c *funcExpression) SerializeSql(out *bytes.Buffer) (err error) {
 	if c.args == nil {
 		_, _ = out.WriteString("()")
 	} else {
-		return c.args.SerializeSql(out)
+		return c.args.serializeSql(out)
 	}
 	return nil
 }
@@ -324,7 +360,15 @@ type intervalExpression struct {
 
 var intervalSep = ":"
 
-func (c *intervalExpression) SerializeSql(out *bytes.Buffer) (err error) {
+func (c *intervalExpression) SerializeSql(out *bytes.Buffer) error {
+	return c.serializeSql(defaultSqlWriter(out))
+}
+
+func (c *intervalExpression) serializeSql(out *sqlWriter) (err error) {
+	if err = out.requireMySql("INTERVAL"); err != nil {
+		return
+	}
+
 	hours := c.duration / time.Hour
 	minutes := (c.duration % time.Hour) / time.Minute
 	sec := (c.duration % time.Minute) / time.Second
@@ -358,6 +402,26 @@ func Interval(duration time.Duration) Expression {
 	}
 }
 
+type placeholderExpression struct {
+	isExpression
+}
+
+func (c *placeholderExpression) SerializeSql(out *bytes.Buffer) error {
+	return c.serializeSql(defaultSqlWriter(out))
+}
+
+func (c *placeholderExpression) serializeSql(out *sqlWriter) error {
+	out.writePlaceholder()
+	return nil
+}
+
+// Returns a bind parameter placeholder, i.e., "?" for mysql and sqlite, and
+// "$n" for postgresql.  Placeholders are numbered in the order they appear in
+// the generated statement.
+func Placeholder() Expression {
+	return &placeholderExpression{}
+}
+
 var likeEscaper = strings.NewReplacer("_", "\\_", "%", "\\%")
 
 func EscapeForLike(s string) string {
@@ -557,6 +621,10 @@ type inExpression struct {
 }
 
 func (c *inExpression) SerializeSql(out *bytes.Buffer) error {
+	return c.serializeSql(defaultSqlWriter(out))
+}
+
+func (c *inExpression) serializeSql(out *sqlWriter) error {
 	if c.err != nil {
 		return errors.Wrap(c.err, "Invalid IN expression")
 	}
@@ -568,9 +636,9 @@ func (c *inExpression) SerializeSql(out *bytes.Buffer) error {
 	}
 
 	// We'll serialize the lhs even if we don't need it to ensure no error
-	buf := &bytes.Buffer{}
+	buf := out.scratch()
 
-	err := c.lhs.SerializeSql(buf)
+	err := c.lhs.serializeSql(buf)
 	if err != nil {
 		return err
 	}
@@ -580,10 +648,10 @@ func (c *inExpression) SerializeSql(out *bytes.Buffer) error {
 		return nil
 	}
 
-	_, _ = out.WriteString(buf.String())
+	out.merge(buf)
 	_, _ = out.WriteString(" IN ")
 
-	err = c.rhs.SerializeSql(out)
+	err = c.rhs.serializeSql(out)
 	if err != nil {
 		return err
 	}
@@ -691,18 +759,34 @@ type ifExpression struct {
 }
 
 func (exp *ifExpression) SerializeSql(out *bytes.Buffer) error {
+	return exp.serializeSql(defaultSqlWriter(out))
+}
+
+func (exp *ifExpression) serializeSql(out *sqlWriter) error {
+	if !out.dialect.MySqlCompatible() {
+		_, _ = out.WriteString("CASE WHEN ")
+		_ = exp.conditional.serializeSql(out)
+		_, _ = out.WriteString(" THEN ")
+		_ = exp.trueExpression.serializeSql(out)
+		_, _ = out.WriteString(" ELSE ")
+		_ = exp.falseExpression.serializeSql(out)
+		_, _ = out.WriteString(" END")
+		return nil
+	}
+
 	_, _ = out.WriteString("IF(")
-	_ = exp.conditional.SerializeSql(out)
+	_ = exp.conditional.serializeSql(out)
 	_, _ = out.WriteString(",")
-	_ = exp.trueExpression.SerializeSql(out)
+	_ = exp.trueExpression.serializeSql(out)
 	_, _ = out.WriteString(",")
-	_ = exp.falseExpression.SerializeSql(out)
+	_ = exp.falseExpression.serializeSql(out)
 	_, _ = out.WriteString(")")
 	return nil
 }
 
 // Returns a representation of an if-expression, of the form:
 //   IF (BOOLEAN TEST, VALUE-IF-TRUE, VALUE-IF-FALSE)
+// Dialects without the IF function use the equivalent CASE WHEN expression.
 func If(conditional BoolExpression,
 	trueExpression Expression,
 	falseExpression Expression) Expression {
@@ -725,8 +809,17 @@ func ColumnValue(col NonAliasColumn) Expression {
 }
 
 func (cv *columnValueExpression) SerializeSql(out *bytes.Buffer) error {
+	return cv.serializeSql(defaultSqlWriter(out))
+}
+
+func (cv *columnValueExpression) serializeSql(out *sqlWriter) error {
+	if !out.dialect.MySqlCompatible() {
+		out.dialect.WriteInsertedValue(cv.column.Name(), out.Buffer)
+		return nil
+	}
+
 	_, _ = out.WriteString("VALUES(")
-	_ = cv.column.SerializeSqlForColumnList(out)
+	_ = cv.column.serializeSqlForColumnList(out)
 	_ = out.WriteByte(')')
 	return nil
 }
@@ -0,0 +1,12 @@
+// Generation of sqlbuilder table definitions from mysql schema dumps (i.e.,
+// SHOW CREATE TABLE or mysqldump --no-data output).  See
+// database/sqlbuilder/cmd/sqlbuildergen for the command line tool.
+//
+// Known limitations:
+//   - DECIMAL / FLOAT columns are generated as DoubleColumn
+//   - ENUM / SET / JSON columns are generated as StrColumn
+//   - DATE / TIMESTAMP columns are generated as DateTimeColumn (i.e., the
+//     generated tables' DDL declares them as DATETIME)
+//   - ON UPDATE clauses, column comments, prefix key lengths, fulltext
+//     indexes and foreign keys are ignored
+package schemagen
@@ -0,0 +1,308 @@
+// Generation of sqlbuilder table definitions
+
+package schemagen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dropbox/godropbox/database/sqlbuilder"
+	"github.com/dropbox/godropbox/errors"
+)
+
+const sqlbuilderImportPath = "github.com/dropbox/godropbox/database/sqlbuilder"
+
+// Names promoted from the embedded *sqlbuilder.Table, which column fields must
+// not shadow.
+var reservedFieldNames = func() map[string]bool {
+	names := map[string]bool{"Table": true}
+	t := reflect.TypeOf(&sqlbuilder.Table{})
+	for i := 0; i < t.NumMethod(); i++ {
+		names[t.Method(i).Name] = true
+	}
+	return names
+}()
+
+var knownCharsets = map[string]string{
+	string(sqlbuilder.UTF8): "sqlbuilder.UTF8",
+}
+
+var knownCollations = map[string]string{
+	string(sqlbuilder.UTF8CaseInsensitive): "sqlbuilder.UTF8CaseInsensitive",
+	string(sqlbuilder.UTF8CaseSensitive):   "sqlbuilder.UTF8CaseSensitive",
+	string(sqlbuilder.UTF8Binary):          "sqlbuilder.UTF8Binary",
+}
+
+// Converts a snake_case sql identifier to an exported CamelCase go identifier.
+func GoName(name string) string {
+	buf := &bytes.Buffer{}
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if buf.Len() == 0 && unicode.IsDigit(r) {
+			_ = buf.WriteByte('X')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		_, _ = buf.WriteRune(r)
+	}
+
+	if buf.Len() == 0 {
+		return "X"
+	}
+	return buf.String()
+}
+
+// Returns the go field name for each of the table's columns.
+func fieldNames(table *Table) map[string]string {
+	used := make(map[string]bool)
+	result := make(map[string]string)
+	for _, col := range table.Columns {
+		name := GoName(col.Name)
+		if reservedFieldNames[name] {
+			name += "Col"
+		}
+		for i := 2; used[name]; i++ {
+			name = GoName(col.Name) + strconv.Itoa(i)
+		}
+		used[name] = true
+		result[col.Name] = name
+	}
+	return result
+}
+
+// Generates gofmt-ed go source declaring a sqlbuilder table variable for each
+// of the given tables.  source is recorded in the generated file's header.
+func Generate(pkg string, source string, tables []*Table) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(
+		buf,
+		"// Code generated by sqlbuildergen from %s. DO NOT EDIT.\n\n",
+		source)
+	fmt.Fprintf(buf, "package %s\n\n", pkg)
+	fmt.Fprintf(buf, "import %q\n", sqlbuilderImportPath)
+
+	for _, table := range tables {
+		if err := generateTable(table, buf); err != nil {
+			return nil, errors.Wrapf(
+				err,
+				"Failed to generate table %s",
+				table.Name)
+		}
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to format generated code")
+	}
+	return src, nil
+}
+
+func generateTable(table *Table, buf *bytes.Buffer) error {
+	varName := GoName(table.Name)
+	typeName := varName + "Table"
+	fields := fieldNames(table)
+
+	fmt.Fprintf(
+		buf,
+		"\n// %s is the sqlbuilder definition of the `%s` table.\n",
+		typeName,
+		table.Name)
+	fmt.Fprintf(buf, "type %s struct {\n", typeName)
+	fmt.Fprintf(buf, "*sqlbuilder.Table\n\n")
+	for _, col := range table.Columns {
+		fmt.Fprintf(buf, "%s sqlbuilder.NonAliasColumn\n", fields[col.Name])
+	}
+	fmt.Fprintf(buf, "}\n\n")
+
+	fmt.Fprintf(buf, "// %s is the `%s` table.\n", varName, table.Name)
+	fmt.Fprintf(buf, "var %s = new%s()\n\n", varName, typeName)
+
+	fmt.Fprintf(buf, "func new%s() *%s {\n", typeName, typeName)
+	fmt.Fprintf(buf, "t := &%s{\n", typeName)
+	for _, col := range table.Columns {
+		expr, err := columnExpression(col)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%s: %s,\n", fields[col.Name], expr)
+	}
+	fmt.Fprintf(buf, "}\n")
+
+	fmt.Fprintf(buf, "t.Table = sqlbuilder.NewTable(\n%q", table.Name)
+	for _, col := range table.Columns {
+		fmt.Fprintf(buf, ",\nt.%s", fields[col.Name])
+	}
+	fmt.Fprintf(buf, ")\n")
+
+	keyColumns := func(names []string) (string, error) {
+		refs := make([]string, 0, len(names))
+		for _, name := range names {
+			field, ok := fields[name]
+			if !ok {
+				return "", errors.Newf("Unknown key column %s", name)
+			}
+			refs = append(refs, "t."+field)
+		}
+		return strings.Join(refs, ", "), nil
+	}
+
+	if len(table.PrimaryKey) > 0 {
+		cols, err := keyColumns(table.PrimaryKey)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "t.SetPrimaryKey(%s)\n", cols)
+	}
+
+	for _, index := range table.Indexes {
+		cols, err := keyColumns(index.Columns)
+		if err != nil {
+			return err
+		}
+		method := "AddIndex"
+		if index.Unique {
+			method = "AddUniqueIndex"
+		}
+		fmt.Fprintf(buf, "t.%s(%q, %s)\n", method, index.Name, cols)
+	}
+
+	fmt.Fprintf(buf, "return t\n}\n")
+	return nil
+}
+
+// Returns the go expression which constructs the column.
+func columnExpression(col *Column) (string, error) {
+	nullable := "sqlbuilder.Nullable"
+	if !col.Nullable {
+		nullable = "sqlbuilder.NotNullable"
+	}
+
+	var expr string
+	switch col.Kind {
+	case IntKind:
+		expr = fmt.Sprintf("sqlbuilder.IntColumn(%q, %s)", col.Name, nullable)
+	case BoolKind:
+		expr = fmt.Sprintf("sqlbuilder.BoolColumn(%q, %s)", col.Name, nullable)
+	case DoubleKind:
+		expr = fmt.Sprintf("sqlbuilder.DoubleColumn(%q, %s)", col.Name, nullable)
+	case BytesKind:
+		expr = fmt.Sprintf("sqlbuilder.BytesColumn(%q, %s)", col.Name, nullable)
+	case DateTimeKind:
+		expr = fmt.Sprintf(
+			"sqlbuilder.DateTimeColumn(%q, %s)",
+			col.Name,
+			nullable)
+	case StrKind:
+		charset, ok := knownCharsets[col.Charset]
+		if !ok {
+			charset = fmt.Sprintf("sqlbuilder.Charset(%q)", col.Charset)
+		}
+		collation, ok := knownCollations[col.Collation]
+		if !ok {
+			collation = fmt.Sprintf("sqlbuilder.Collation(%q)", col.Collation)
+		}
+		expr = fmt.Sprintf(
+			"sqlbuilder.StrColumn(%q, %s, %s, %s)",
+			col.Name,
+			charset,
+			collation,
+			nullable)
+	default:
+		return "", errors.Newf("Unknown column kind %d", col.Kind)
+	}
+
+	options := make([]string, 0)
+	// Integer sizes default to BIGINT.
+	if col.Size != 0 && !(col.Kind == IntKind && col.Size == 8) {
+		options = append(options, fmt.Sprintf("Size: %d", col.Size))
+	}
+	if col.Unsigned && col.Kind == IntKind {
+		options = append(options, "Unsigned: true")
+	}
+	if col.AutoIncrement {
+		options = append(options, "AutoIncrement: true")
+	}
+	if col.Default != nil {
+		def, err := defaultExpression(col)
+		if err != nil {
+			return "", err
+		}
+		options = append(options, "Default: "+def)
+	}
+
+	if len(options) == 0 {
+		return expr, nil
+	}
+
+	return fmt.Sprintf(
+		"sqlbuilder.WithColumnOptions(\n%s,\nsqlbuilder.ColumnOptions{%s})",
+		expr,
+		strings.Join(options, ", ")), nil
+}
+
+// Returns the go expression for the column's default value.
+func defaultExpression(col *Column) (string, error) {
+	def := col.Default
+	if def.IsFunc {
+		if def.FuncArg == "" {
+			return fmt.Sprintf("sqlbuilder.SqlFunc(%q)", def.Value), nil
+		}
+		arg, err := strconv.Atoi(def.FuncArg)
+		if err != nil {
+			return "", errors.Newf(
+				"Invalid argument for default %s: %s",
+				def.Value,
+				def.FuncArg)
+		}
+		return fmt.Sprintf(
+			"sqlbuilder.SqlFunc(%q, sqlbuilder.Literal(%d))",
+			def.Value,
+			arg), nil
+	}
+
+	switch col.Kind {
+	case IntKind:
+		if col.Unsigned {
+			v, err := strconv.ParseUint(def.Value, 10, 64)
+			if err != nil {
+				return "", errors.Wrapf(err, "Invalid default for %s", col.Name)
+			}
+			return fmt.Sprintf("sqlbuilder.Literal(uint64(%d))", v), nil
+		}
+		v, err := strconv.ParseInt(def.Value, 10, 64)
+		if err != nil {
+			return "", errors.Wrapf(err, "Invalid default for %s", col.Name)
+		}
+		return fmt.Sprintf("sqlbuilder.Literal(int64(%d))", v), nil
+	case BoolKind:
+		v, err := strconv.ParseInt(def.Value, 10, 64)
+		if err != nil {
+			return "", errors.Wrapf(err, "Invalid default for %s", col.Name)
+		}
+		return fmt.Sprintf("sqlbuilder.Literal(%t)", v != 0), nil
+	case DoubleKind:
+		v, err := strconv.ParseFloat(def.Value, 64)
+		if err != nil {
+			return "", errors.Wrapf(err, "Invalid default for %s", col.Name)
+		}
+		return fmt.Sprintf(
+			"sqlbuilder.Literal(float64(%s))",
+			strconv.FormatFloat(v, 'g', -1, 64)), nil
+	case BytesKind:
+		return fmt.Sprintf("sqlbuilder.Literal([]byte(%q))", def.Value), nil
+	default:
+		return fmt.Sprintf("sqlbuilder.Literal(%q)", def.Value), nil
+	}
+}
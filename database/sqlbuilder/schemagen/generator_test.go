@@ -0,0 +1,51 @@
+package schemagen
+
+import (
+	"io/ioutil"
+
+	gc "gopkg.in/check.v1"
+)
+
+type GeneratorSuite struct {
+}
+
+var _ = gc.Suite(&GeneratorSuite{})
+
+func (s *GeneratorSuite) TestGoName(c *gc.C) {
+	c.Assert(GoName("owner_id"), gc.Equals, "OwnerId")
+	c.Assert(GoName("shard-map"), gc.Equals, "ShardMap")
+	c.Assert(GoName("2fa"), gc.Equals, "X2fa")
+	c.Assert(GoName("_"), gc.Equals, "X")
+}
+
+// To update the golden file, run:
+//
+//	go run ../cmd/sqlbuildergen -schema testdata/schema.sql -package models \
+//	    -out testdata/tables.go.golden
+func (s *GeneratorSuite) TestGenerate(c *gc.C) {
+	schema, err := ioutil.ReadFile("testdata/schema.sql")
+	c.Assert(err, gc.IsNil)
+
+	tables, err := ParseSchema(string(schema))
+	c.Assert(err, gc.IsNil)
+
+	src, err := Generate("models", "schema.sql", tables)
+	c.Assert(err, gc.IsNil)
+
+	expected, err := ioutil.ReadFile("testdata/tables.go.golden")
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(src), gc.Equals, string(expected))
+}
+
+func (s *GeneratorSuite) TestGenerateUnknownKeyColumn(c *gc.C) {
+	tables := []*Table{
+		{
+			Name:       "t",
+			Columns:    []*Column{{Name: "a", Kind: IntKind}},
+			PrimaryKey: []string{"b"},
+		},
+	}
+
+	_, err := Generate("models", "schema.sql", tables)
+	c.Assert(err, gc.NotNil)
+}
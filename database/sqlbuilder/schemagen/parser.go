@@ -0,0 +1,629 @@
+// Parsing of SHOW CREATE TABLE / mysqldump schema output
+
+package schemagen
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+// The sqlbuilder column constructor used for a parsed column.
+type ColumnKind int
+
+const (
+	IntKind ColumnKind = iota
+	BoolKind
+	DoubleKind
+	StrKind
+	BytesKind
+	DateTimeKind
+)
+
+// A column's DEFAULT clause.
+type DefaultValue struct {
+	// The unquoted literal value, or the function name when IsFunc is true.
+	Value string
+
+	// True if the literal was quoted in the schema.
+	IsString bool
+
+	// True if the default is a function call, e.g., CURRENT_TIMESTAMP.
+	IsFunc bool
+
+	// The function's argument (e.g., the precision in CURRENT_TIMESTAMP(6)),
+	// if any.
+	FuncArg string
+}
+
+type Column struct {
+	Name string
+	Kind ColumnKind
+
+	// The mysql type name, in lower case (e.g., "varchar").
+	SqlType string
+
+	// See sqlbuilder.ColumnOptions for how Size is interpreted for each kind.
+	Size          int
+	Unsigned      bool
+	AutoIncrement bool
+	Nullable      bool
+
+	// Only set for string columns.  These default to the table's charset
+	// and collation.
+	Charset   string
+	Collation string
+
+	// nil if the column has no default, or defaults to NULL.
+	Default *DefaultValue
+}
+
+type Index struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+type Table struct {
+	Name       string
+	Columns    []*Column
+	PrimaryKey []string
+	Indexes    []Index
+
+	// The table's DEFAULT CHARSET / COLLATE options.
+	Charset   string
+	Collation string
+}
+
+type tokenKind int
+
+const (
+	wordToken   tokenKind = iota // keywords, numbers and unquoted values
+	identToken                   // `quoted identifiers`
+	stringToken                  // 'quoted strings'
+	symbolToken                  // ( ) , ; =
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+func (t token) isWord(word string) bool {
+	return t.kind == wordToken && strings.EqualFold(t.value, word)
+}
+
+func (t token) isSymbol(symbol string) bool {
+	return t.kind == symbolToken && t.value == symbol
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || c == '+' || c == '$' ||
+		c >= 0x80 ||
+		unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// Splits the schema into tokens.  Comments (including mysqldump's
+// /*!NNNNN ... */ version comments) are discarded.
+func tokenize(schema string) ([]token, error) {
+	tokens := make([]token, 0)
+
+	i := 0
+	for i < len(schema) {
+		c := schema[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(schema[i:], "--"):
+			end := strings.IndexByte(schema[i:], '\n')
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end + 1
+		case strings.HasPrefix(schema[i:], "/*"):
+			end := strings.Index(schema[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.New("Unterminated comment")
+			}
+			i += end + 4
+		case c == '`':
+			end := strings.IndexByte(schema[i+1:], '`')
+			if end < 0 {
+				return nil, errors.New("Unterminated quoted identifier")
+			}
+			tokens = append(tokens, token{identToken, schema[i+1 : i+1+end]})
+			i += end + 2
+		case c == '\'' || c == '"':
+			value, n, err := unquote(schema[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{stringToken, value})
+			i += n
+		case strings.IndexByte("(),;=", c) >= 0:
+			tokens = append(tokens, token{symbolToken, string(c)})
+			i++
+		case isWordChar(c):
+			start := i
+			for i < len(schema) && isWordChar(schema[i]) {
+				i++
+			}
+			tokens = append(tokens, token{wordToken, schema[start:i]})
+		default:
+			return nil, errors.Newf("Unexpected character %q in schema", c)
+		}
+	}
+
+	return tokens, nil
+}
+
+// Unquotes the string literal at the start of s.  Returns the unquoted value
+// and the number of bytes consumed.
+func unquote(s string) (string, int, error) {
+	quote := s[0]
+	value := make([]byte, 0, len(s))
+
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				value = append(value, '\n')
+			case 'r':
+				value = append(value, '\r')
+			case 't':
+				value = append(value, '\t')
+			case '0':
+				value = append(value, 0)
+			case 'Z':
+				value = append(value, 26)
+			default:
+				value = append(value, s[i])
+			}
+		case c == quote && i+1 < len(s) && s[i+1] == quote:
+			value = append(value, quote)
+			i++
+		case c == quote:
+			return string(value), i + 1, nil
+		default:
+			value = append(value, c)
+		}
+	}
+
+	return "", 0, errors.New("Unterminated string literal")
+}
+
+// Parses every CREATE TABLE statement in a schema dump (e.g., the output of
+// SHOW CREATE TABLE or mysqldump --no-data).  All other statements are
+// ignored.
+func ParseSchema(schema string) ([]*Table, error) {
+	tokens, err := tokenize(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]*Table, 0)
+
+	start := 0
+	for start < len(tokens) {
+		end := start
+		depth := 0
+		for ; end < len(tokens); end++ {
+			if tokens[end].isSymbol("(") {
+				depth++
+			} else if tokens[end].isSymbol(")") {
+				depth--
+			} else if depth == 0 && tokens[end].isSymbol(";") {
+				break
+			}
+		}
+
+		stmt := tokens[start:end]
+		if len(stmt) > 2 && stmt[0].isWord("CREATE") && stmt[1].isWord("TABLE") {
+			table, err := parseCreateTable(stmt[2:])
+			if err != nil {
+				return nil, err
+			}
+			tables = append(tables, table)
+		}
+
+		start = end + 1
+	}
+
+	return tables, nil
+}
+
+// Splits tokens (excluding the enclosing parentheses) at top level commas.
+func splitList(tokens []token) [][]token {
+	result := make([][]token, 0)
+
+	start := 0
+	depth := 0
+	for i, t := range tokens {
+		if t.isSymbol("(") {
+			depth++
+		} else if t.isSymbol(")") {
+			depth--
+		} else if depth == 0 && t.isSymbol(",") {
+			result = append(result, tokens[start:i])
+			start = i + 1
+		}
+	}
+
+	if start < len(tokens) {
+		result = append(result, tokens[start:])
+	}
+	return result
+}
+
+// Returns the index of the parenthesis which closes the one at tokens[open].
+func matchParen(tokens []token, open int) (int, error) {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		if tokens[i].isSymbol("(") {
+			depth++
+		} else if tokens[i].isSymbol(")") {
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, errors.New("Unbalanced parentheses")
+}
+
+func parseCreateTable(tokens []token) (*Table, error) {
+	if len(tokens) > 3 &&
+		tokens[0].isWord("IF") &&
+		tokens[1].isWord("NOT") &&
+		tokens[2].isWord("EXISTS") {
+
+		tokens = tokens[3:]
+	}
+
+	if len(tokens) == 0 || tokens[0].kind != identToken &&
+		tokens[0].kind != wordToken {
+
+		return nil, errors.New("Missing table name in CREATE TABLE")
+	}
+
+	// Strip the database name from db.table names
+	if len(tokens) > 2 && tokens[1].isWord(".") {
+		tokens = tokens[2:]
+	}
+
+	table := &Table{Name: tokens[0].value}
+	if idx := strings.LastIndexByte(table.Name, '.'); idx >= 0 &&
+		tokens[0].kind == wordToken {
+
+		table.Name = table.Name[idx+1:]
+	}
+
+	if len(tokens) < 2 || !tokens[1].isSymbol("(") {
+		return nil, errors.Newf(
+			"Expected column definitions for table %s",
+			table.Name)
+	}
+
+	end, err := matchParen(tokens, 1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid definition for table %s", table.Name)
+	}
+
+	parseTableOptions(table, tokens[end+1:])
+
+	for _, def := range splitList(tokens[2:end]) {
+		if len(def) == 0 {
+			continue
+		}
+
+		if def[0].kind == identToken {
+			col, err := parseColumn(table, def)
+			if err != nil {
+				return nil, errors.Wrapf(
+					err,
+					"Invalid column definition in table %s",
+					table.Name)
+			}
+			table.Columns = append(table.Columns, col)
+			continue
+		}
+
+		if err := parseKey(table, def); err != nil {
+			return nil, errors.Wrapf(
+				err,
+				"Invalid key definition in table %s",
+				table.Name)
+		}
+	}
+
+	if len(table.Columns) == 0 {
+		return nil, errors.Newf("Table %s has no columns", table.Name)
+	}
+
+	return table, nil
+}
+
+func parseTableOptions(table *Table, tokens []token) {
+	for i := 0; i < len(tokens); i++ {
+		var target *string
+		if tokens[i].isWord("CHARSET") {
+			target = &table.Charset
+		} else if tokens[i].isWord("CHARACTER") &&
+			i+1 < len(tokens) &&
+			tokens[i+1].isWord("SET") {
+
+			target = &table.Charset
+			i++
+		} else if tokens[i].isWord("COLLATE") {
+			target = &table.Collation
+		} else {
+			continue
+		}
+
+		if i+1 < len(tokens) && tokens[i+1].isSymbol("=") {
+			i++
+		}
+		if i+1 < len(tokens) {
+			*target = tokens[i+1].value
+			i++
+		}
+	}
+}
+
+func parseColumn(table *Table, tokens []token) (*Column, error) {
+	col := &Column{
+		Name:     tokens[0].value,
+		Nullable: true,
+	}
+
+	if len(tokens) < 2 || tokens[1].kind != wordToken {
+		return nil, errors.Newf("Missing type for column %s", col.Name)
+	}
+
+	col.SqlType = strings.ToLower(tokens[1].value)
+
+	// Type arguments, e.g., varchar(255) or decimal(10,2)
+	typeArgs := make([]string, 0)
+	i := 2
+	if i < len(tokens) && tokens[i].isSymbol("(") {
+		end, err := matchParen(tokens, i)
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range splitList(tokens[i+1 : end]) {
+			if len(arg) > 0 {
+				typeArgs = append(typeArgs, arg[0].value)
+			}
+		}
+		i = end + 1
+	}
+
+	size := 0
+	if len(typeArgs) > 0 {
+		size, _ = strconv.Atoi(typeArgs[0])
+	}
+
+	switch col.SqlType {
+	case "tinyint":
+		if size == 1 {
+			col.Kind = BoolKind
+		} else {
+			col.Kind = IntKind
+			col.Size = 1
+		}
+	case "bool", "boolean":
+		col.Kind = BoolKind
+	case "smallint":
+		col.Kind = IntKind
+		col.Size = 2
+	case "mediumint":
+		col.Kind = IntKind
+		col.Size = 3
+	case "int", "integer":
+		col.Kind = IntKind
+		col.Size = 4
+	case "bigint":
+		col.Kind = IntKind
+		col.Size = 8
+	case "float", "double", "real", "decimal", "numeric":
+		col.Kind = DoubleKind
+	case "char", "varchar":
+		col.Kind = StrKind
+		col.Size = size
+	case "tinytext", "text", "mediumtext", "longtext", "enum", "set", "json":
+		col.Kind = StrKind
+	case "binary", "varbinary":
+		col.Kind = BytesKind
+		col.Size = size
+	case "tinyblob", "blob", "mediumblob", "longblob":
+		col.Kind = BytesKind
+	case "date", "datetime", "timestamp":
+		col.Kind = DateTimeKind
+		col.Size = size
+	default:
+		return nil, errors.Newf(
+			"Unsupported type %s for column %s",
+			col.SqlType,
+			col.Name)
+	}
+
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.isWord("UNSIGNED"):
+			col.Unsigned = true
+		case t.isWord("AUTO_INCREMENT"):
+			col.AutoIncrement = true
+		case t.isWord("NOT") && i+1 < len(tokens) && tokens[i+1].isWord("NULL"):
+			col.Nullable = false
+			i++
+		case t.isWord("CHARACTER") && i+2 < len(tokens) && tokens[i+1].isWord("SET"):
+			col.Charset = tokens[i+2].value
+			i += 2
+		case (t.isWord("CHARSET") || t.isWord("COLLATE")) && i+1 < len(tokens):
+			if t.isWord("CHARSET") {
+				col.Charset = tokens[i+1].value
+			} else {
+				col.Collation = tokens[i+1].value
+			}
+			i++
+		case t.isWord("DEFAULT") && i+1 < len(tokens):
+			n, err := parseDefault(col, tokens[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			i += n
+		case t.isWord("ON") && i+2 < len(tokens) && tokens[i+1].isWord("UPDATE"):
+			// ON UPDATE CURRENT_TIMESTAMP[(n)] cannot be represented.
+			i += 2
+			if i+1 < len(tokens) && tokens[i+1].isSymbol("(") {
+				end, err := matchParen(tokens, i+1)
+				if err != nil {
+					return nil, err
+				}
+				i = end
+			}
+		case t.isWord("COMMENT") && i+1 < len(tokens):
+			i++
+		}
+	}
+
+	if col.Kind == StrKind {
+		// The table's collation only applies if the column uses the table's
+		// charset.
+		if col.Collation == "" &&
+			(col.Charset == "" || col.Charset == table.Charset) {
+
+			col.Collation = table.Collation
+		}
+		if col.Charset == "" {
+			col.Charset = table.Charset
+		}
+	} else {
+		// Binary columns may report a charset in some mysql versions.
+		col.Charset = ""
+		col.Collation = ""
+	}
+
+	return col, nil
+}
+
+// Parses the value following DEFAULT.  Returns the number of tokens consumed.
+func parseDefault(col *Column, tokens []token) (int, error) {
+	t := tokens[0]
+	switch {
+	case t.isWord("NULL"):
+		return 1, nil
+	case t.kind == stringToken:
+		col.Default = &DefaultValue{Value: t.value, IsString: true}
+		return 1, nil
+	case t.kind != wordToken:
+		return 0, errors.Newf(
+			"Unsupported default expression for column %s",
+			col.Name)
+	}
+
+	if _, err := strconv.ParseFloat(t.value, 64); err == nil {
+		col.Default = &DefaultValue{Value: t.value}
+		return 1, nil
+	}
+
+	fn := strings.ToUpper(t.value)
+	switch fn {
+	case "CURRENT_TIMESTAMP", "NOW", "LOCALTIME", "LOCALTIMESTAMP":
+	default:
+		return 0, errors.Newf(
+			"Unsupported default %s for column %s",
+			t.value,
+			col.Name)
+	}
+
+	col.Default = &DefaultValue{Value: fn, IsFunc: true}
+	if len(tokens) > 1 && tokens[1].isSymbol("(") {
+		end, err := matchParen(tokens, 1)
+		if err != nil {
+			return 0, err
+		}
+		if end == 3 {
+			col.Default.FuncArg = tokens[2].value
+		}
+		return end + 1, nil
+	}
+	return 1, nil
+}
+
+func parseKey(table *Table, tokens []token) error {
+	if tokens[0].isWord("CONSTRAINT") ||
+		tokens[0].isWord("FULLTEXT") ||
+		tokens[0].isWord("SPATIAL") ||
+		tokens[0].isWord("FOREIGN") ||
+		tokens[0].isWord("CHECK") {
+
+		// Not representable in sqlbuilder.
+		return nil
+	}
+
+	primary := false
+	unique := false
+	i := 0
+	if tokens[i].isWord("PRIMARY") {
+		primary = true
+		i++
+	} else if tokens[i].isWord("UNIQUE") {
+		unique = true
+		i++
+	}
+
+	if i >= len(tokens) || !(tokens[i].isWord("KEY") || tokens[i].isWord("INDEX")) {
+		if !unique {
+			return errors.Newf("Unexpected definition: %s", tokens[0].value)
+		}
+	} else {
+		i++
+	}
+
+	name := ""
+	if i < len(tokens) && tokens[i].kind == identToken {
+		name = tokens[i].value
+		i++
+	}
+
+	if i >= len(tokens) || !tokens[i].isSymbol("(") {
+		return errors.New("Missing key columns")
+	}
+
+	end, err := matchParen(tokens, i)
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, 0)
+	for _, part := range splitList(tokens[i+1 : end]) {
+		// Ignore prefix lengths and ASC / DESC.
+		if len(part) == 0 || part[0].kind != identToken {
+			return errors.New("Unsupported key part")
+		}
+		columns = append(columns, part[0].value)
+	}
+
+	if primary {
+		table.PrimaryKey = columns
+		return nil
+	}
+
+	if name == "" {
+		name = columns[0]
+	}
+
+	table.Indexes = append(table.Indexes, Index{
+		Name:    name,
+		Unique:  unique,
+		Columns: columns,
+	})
+	return nil
+}
@@ -0,0 +1,106 @@
+package schemagen
+
+import (
+	"io/ioutil"
+	"testing"
+
+	gc "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	gc.TestingT(t)
+}
+
+type ParserSuite struct {
+}
+
+var _ = gc.Suite(&ParserSuite{})
+
+func (s *ParserSuite) parseTestSchema(c *gc.C) []*Table {
+	schema, err := ioutil.ReadFile("testdata/schema.sql")
+	c.Assert(err, gc.IsNil)
+
+	tables, err := ParseSchema(string(schema))
+	c.Assert(err, gc.IsNil)
+	c.Assert(len(tables), gc.Equals, 2)
+	return tables
+}
+
+func (s *ParserSuite) TestParseTable(c *gc.C) {
+	users := s.parseTestSchema(c)[0]
+
+	c.Assert(users.Name, gc.Equals, "users")
+	c.Assert(users.Charset, gc.Equals, "utf8")
+	c.Assert(users.Collation, gc.Equals, "utf8_unicode_ci")
+	c.Assert(users.PrimaryKey, gc.DeepEquals, []string{"id"})
+	c.Assert(users.Indexes, gc.DeepEquals, []Index{
+		{Name: "name_idx", Unique: true, Columns: []string{"name"}},
+		{
+			Name:    "owner_created_idx",
+			Unique:  false,
+			Columns: []string{"owner_id", "created"},
+		},
+	})
+	c.Assert(len(users.Columns), gc.Equals, 8)
+}
+
+func (s *ParserSuite) TestParseColumns(c *gc.C) {
+	cols := s.parseTestSchema(c)[0].Columns
+
+	c.Assert(*cols[0], gc.DeepEquals, Column{
+		Name:          "id",
+		Kind:          IntKind,
+		SqlType:       "bigint",
+		Size:          8,
+		Unsigned:      true,
+		AutoIncrement: true,
+	})
+
+	c.Assert(*cols[1], gc.DeepEquals, Column{
+		Name:      "name",
+		Kind:      StrKind,
+		SqlType:   "varchar",
+		Size:      255,
+		Charset:   "utf8",
+		Collation: "utf8_unicode_ci",
+		Default:   &DefaultValue{Value: "", IsString: true},
+	})
+
+	c.Assert(cols[2].Nullable, gc.Equals, true)
+	c.Assert(cols[2].Default, gc.IsNil)
+
+	c.Assert(cols[4].Kind, gc.Equals, DateTimeKind)
+	c.Assert(cols[4].Size, gc.Equals, 6)
+	c.Assert(*cols[4].Default, gc.DeepEquals, DefaultValue{
+		Value:   "CURRENT_TIMESTAMP",
+		IsFunc:  true,
+		FuncArg: "6",
+	})
+
+	c.Assert(cols[5].Kind, gc.Equals, BytesKind)
+	c.Assert(cols[6].Kind, gc.Equals, BoolKind)
+
+	// Explicit charsets don't inherit the table's collation.
+	c.Assert(cols[7].Charset, gc.Equals, "latin1")
+	c.Assert(cols[7].Collation, gc.Equals, "")
+	c.Assert(cols[7].Default.Value, gc.Equals, "it's")
+}
+
+func (s *ParserSuite) TestParseQualifiedTableName(c *gc.C) {
+	tables, err := ParseSchema(
+		"CREATE TABLE IF NOT EXISTS `db`.`t` (`a` int NOT NULL DEFAULT -1)")
+	c.Assert(err, gc.IsNil)
+	c.Assert(len(tables), gc.Equals, 1)
+	c.Assert(tables[0].Name, gc.Equals, "t")
+	c.Assert(tables[0].Columns[0].Default.Value, gc.Equals, "-1")
+}
+
+func (s *ParserSuite) TestParseUnsupportedType(c *gc.C) {
+	_, err := ParseSchema("CREATE TABLE `t` (`a` geometry NOT NULL)")
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *ParserSuite) TestParseUnterminatedString(c *gc.C) {
+	_, err := ParseSchema("CREATE TABLE `t` (`a` text DEFAULT 'foo)")
+	c.Assert(err, gc.NotNil)
+}
@@ -0,0 +1,22 @@
+-- MySQL dump
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+DROP TABLE IF EXISTS `users`;
+CREATE TABLE `users` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) COLLATE utf8_unicode_ci NOT NULL DEFAULT '',
+  `owner_id` int(11) DEFAULT NULL,
+  `score` double NOT NULL DEFAULT '1.5',
+  `created` datetime(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+  `data` blob,
+  `active` tinyint(1) NOT NULL DEFAULT '1',
+  `table` varchar(10) CHARACTER SET latin1 DEFAULT 'it''s',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name_idx` (`name`(10)),
+  KEY `owner_created_idx` (`owner_id`,`created`)
+) ENGINE=InnoDB AUTO_INCREMENT=5 DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+
+CREATE TABLE `shard_map` (
+  `shard` smallint(6) NOT NULL,
+  `host` varbinary(64) NOT NULL,
+  PRIMARY KEY (`shard`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -11,6 +11,10 @@ import (
 type Statement interface {
 	// String returns generated SQL as string.
 	String(database string) (sql string, err error)
+
+	// StringForDialect returns generated SQL as string, using the dialect's
+	// quoting, escaping and placeholder syntax.
+	StringForDialect(database string, dialect Dialect) (sql string, err error)
 }
 
 type SelectStatement interface {
@@ -34,7 +38,15 @@ type InsertStatement interface {
 
 	// Add a row of values to the insert statement.
 	Add(row ...Expression) InsertStatement
+	// Insert the rows returned by the select query (i.e., INSERT ... SELECT)
+	// instead of a VALUES list.  The query must select the same number of
+	// columns as the insert statement's column list.
+	AddSelect(query SelectStatement) InsertStatement
 	AddOnDuplicateKeyUpdate(col NonAliasColumn, expr Expression) InsertStatement
+	// Shorthand for AddOnDuplicateKeyUpdate(col, ColumnValue(col)) for each of
+	// the columns, i.e., update the columns to the values which would have
+	// been inserted.
+	AddOnDuplicateKeyUpdateValues(cols ...NonAliasColumn) InsertStatement
 	Comment(comment string) InsertStatement
 	IgnoreDuplicates(ignore bool) InsertStatement
 }
@@ -78,6 +90,26 @@ type DeleteStatement interface {
 	Comment(comment string) DeleteStatement
 }
 
+// Returns a multi-table UPDATE statement over a join table expression.  The
+// updated columns may belong to any of the joined tables.  NOTE: mysql does
+// not support ORDER BY and LIMIT for multi-table updates.
+func NewMultiTableUpdate(table ReadableTable) UpdateStatement {
+	return newUpdateStatement(table)
+}
+
+// Returns a multi-table DELETE statement over a join table expression, which
+// deletes the matching rows from each of the deleteFrom tables.  NOTE: mysql
+// does not support ORDER BY and LIMIT for multi-table deletes.
+func NewMultiTableDelete(
+	table ReadableTable,
+	deleteFrom ...*Table) DeleteStatement {
+
+	d := newDeleteStatement(table).(*deleteStatementImpl)
+	d.deleteFrom = deleteFrom
+	d.multiTable = true
+	return d
+}
+
 // LockStatement is used to take Read/Write lock on tables.
 // See http://dev.mysql.com/doc/refman/5.0/en/lock-tables.html
 type LockStatement interface {
@@ -179,16 +211,24 @@ func (us *unionStatementImpl) Offset(offset int64) UnionStatement {
 }
 
 func (us *unionStatementImpl) String(database string) (sql string, err error) {
+	return us.StringForDialect(database, MySqlDialect)
+}
+
+func (us *unionStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
 	if len(us.selects) == 0 {
 		return "", errors.Newf("Union statement must have at least one SELECT")
 	}
 
 	if len(us.selects) == 1 {
-		return us.selects[0].String(database)
+		return us.selects[0].StringForDialect(database, dialect)
 	}
 
 	// Union statements in MySQL require that the same number of columns in each subquery
 	var projections []Projection
+	statements := make([]*selectStatementImpl, 0, len(us.selects))
 
 	for _, statement := range us.selects {
 		// do a type assertion to get at the underlying struct
@@ -198,6 +238,7 @@ func (us *unionStatementImpl) String(database string) (sql string, err error) {
 				"Expected inner select statement to be of type " +
 					"selectStatementImpl")
 		}
+		statements = append(statements, statementImpl)
 
 		// check that for limit for statements with order by clauses
 		if statementImpl.order != nil && statementImpl.limit < 0 {
@@ -221,8 +262,8 @@ func (us *unionStatementImpl) String(database string) (sql string, err error) {
 		}
 	}
 
-	buf := new(bytes.Buffer)
-	for i, statement := range us.selects {
+	buf := newSqlWriter(dialect)
+	for i, statement := range statements {
 		if i != 0 {
 			if us.unique {
 				_, _ = buf.WriteString(" UNION ")
@@ -231,42 +272,35 @@ func (us *unionStatementImpl) String(database string) (sql string, err error) {
 			}
 		}
 		_, _ = buf.WriteString("(")
-		selectSql, err := statement.String(database)
-		if err != nil {
+		if err = statement.serialize(database, buf); err != nil {
 			return "", err
 		}
-		_, _ = buf.WriteString(selectSql)
 		_, _ = buf.WriteString(")")
 	}
 
 	if us.where != nil {
 		_, _ = buf.WriteString(" WHERE ")
-		if err = us.where.SerializeSql(buf); err != nil {
+		if err = us.where.serializeSql(buf); err != nil {
 			return
 		}
 	}
 
 	if us.group != nil {
 		_, _ = buf.WriteString(" GROUP BY ")
-		if err = us.group.SerializeSql(buf); err != nil {
+		if err = us.group.serializeSql(buf); err != nil {
 			return
 		}
 	}
 
 	if us.order != nil {
 		_, _ = buf.WriteString(" ORDER BY ")
-		if err = us.order.SerializeSql(buf); err != nil {
+		if err = us.order.serializeSql(buf); err != nil {
 			return
 		}
 	}
 
 	if us.limit >= 0 {
-		if us.offset >= 0 {
-			_, _ = buf.WriteString(
-				fmt.Sprintf(" LIMIT %d, %d", us.offset, us.limit))
-		} else {
-			_, _ = buf.WriteString(fmt.Sprintf(" LIMIT %d", us.limit))
-		}
+		dialect.WriteLimit(us.limit, us.offset, buf.Buffer)
 	}
 	return buf.String(), nil
 }
@@ -384,14 +418,33 @@ func (q *selectStatementImpl) Comment(comment string) SelectStatement {
 
 // Return the properly escaped SQL statement, against the specified database
 func (q *selectStatementImpl) String(database string) (sql string, err error) {
+	return q.StringForDialect(database, MySqlDialect)
+}
+
+func (q *selectStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
+	buf := newSqlWriter(dialect)
+	if err = q.serialize(database, buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Writes the statement to out.  This is also used for serializing inner
+// select statements, which share the outer statement's placeholder numbering.
+func (q *selectStatementImpl) serialize(
+	database string,
+	buf *sqlWriter) (err error) {
+
 	if !validIdentifierName(database) {
-		return "", errors.New("Invalid database name specified")
+		return errors.New("Invalid database name specified")
 	}
 
-	buf := new(bytes.Buffer)
 	_, _ = buf.WriteString("SELECT ")
 
-	if err = writeComment(q.comment, buf); err != nil {
+	if err = writeComment(q.comment, buf.Buffer); err != nil {
 		return
 	}
 
@@ -400,7 +453,7 @@ func (q *selectStatementImpl) String(database string) (sql string, err error) {
 	}
 
 	if q.projections == nil || len(q.projections) == 0 {
-		return "", errors.Newf(
+		return errors.Newf(
 			"No column selected.  Generated sql: %s",
 			buf.String())
 	}
@@ -410,59 +463,49 @@ func (q *selectStatementImpl) String(database string) (sql string, err error) {
 			_ = buf.WriteByte(',')
 		}
 		if col == nil {
-			return "", errors.Newf(
+			return errors.Newf(
 				"nil column selected.  Generated sql: %s",
 				buf.String())
 		}
-		if err = col.SerializeSqlForColumnList(buf); err != nil {
+		if err = col.serializeSqlForColumnList(buf); err != nil {
 			return
 		}
 	}
 
 	_, _ = buf.WriteString(" FROM ")
 	if q.table == nil {
-		return "", errors.Newf("nil table.  Generated sql: %s", buf.String())
+		return errors.Newf("nil table.  Generated sql: %s", buf.String())
 	}
-	if err = q.table.SerializeSql(database, buf); err != nil {
+	if err = serializeTable(q.table, database, buf); err != nil {
 		return
 	}
 
 	if q.where != nil {
 		_, _ = buf.WriteString(" WHERE ")
-		if err = q.where.SerializeSql(buf); err != nil {
+		if err = q.where.serializeSql(buf); err != nil {
 			return
 		}
 	}
 
 	if q.group != nil {
 		_, _ = buf.WriteString(" GROUP BY ")
-		if err = q.group.SerializeSql(buf); err != nil {
+		if err = q.group.serializeSql(buf); err != nil {
 			return
 		}
 	}
 
 	if q.order != nil {
 		_, _ = buf.WriteString(" ORDER BY ")
-		if err = q.order.SerializeSql(buf); err != nil {
+		if err = q.order.serializeSql(buf); err != nil {
 			return
 		}
 	}
 
 	if q.limit >= 0 {
-		if q.offset >= 0 {
-			_, _ = buf.WriteString(fmt.Sprintf(" LIMIT %d, %d", q.offset, q.limit))
-		} else {
-			_, _ = buf.WriteString(fmt.Sprintf(" LIMIT %d", q.limit))
-		}
-	}
-
-	if q.forUpdate {
-		_, _ = buf.WriteString(" FOR UPDATE")
-	} else if q.withSharedLock {
-		_, _ = buf.WriteString(" LOCK IN SHARE MODE")
+		buf.dialect.WriteLimit(q.limit, q.offset, buf.Buffer)
 	}
 
-	return buf.String(), nil
+	return buf.dialect.WriteSelectLock(q.forUpdate, q.withSharedLock, buf.Buffer)
 }
 
 //
@@ -474,13 +517,22 @@ func newInsertStatement(
 	columns ...NonAliasColumn) InsertStatement {
 
 	return &insertStatementImpl{
-		table:   t,
-		columns: columns,
-		rows:    make([][]Expression, 0, 1),
+		table:                 t,
+		columns:               columns,
+		rows:                  make([][]Expression, 0, 1),
 		onDuplicateKeyUpdates: make([]columnAssignment, 0, 0),
 	}
 }
 
+func newReplaceStatement(
+	t WritableTable,
+	columns ...NonAliasColumn) InsertStatement {
+
+	s := newInsertStatement(t, columns...).(*insertStatementImpl)
+	s.replace = true
+	return s
+}
+
 type columnAssignment struct {
 	col  NonAliasColumn
 	expr Expression
@@ -490,9 +542,12 @@ type insertStatementImpl struct {
 	table                 WritableTable
 	columns               []NonAliasColumn
 	rows                  [][]Expression
+	query                 SelectStatement
 	onDuplicateKeyUpdates []columnAssignment
 	comment               string
 	ignore                bool
+	// True for REPLACE INTO statements
+	replace bool
 }
 
 func (s *insertStatementImpl) Add(
@@ -502,6 +557,13 @@ func (s *insertStatementImpl) Add(
 	return s
 }
 
+func (s *insertStatementImpl) AddSelect(
+	query SelectStatement) InsertStatement {
+
+	s.query = query
+	return s
+}
+
 func (s *insertStatementImpl) AddOnDuplicateKeyUpdate(
 	col NonAliasColumn,
 	expr Expression) InsertStatement {
@@ -513,6 +575,15 @@ func (s *insertStatementImpl) AddOnDuplicateKeyUpdate(
 	return s
 }
 
+func (s *insertStatementImpl) AddOnDuplicateKeyUpdateValues(
+	cols ...NonAliasColumn) InsertStatement {
+
+	for _, col := range cols {
+		s.AddOnDuplicateKeyUpdate(col, ColumnValue(col))
+	}
+	return s
+}
+
 func (s *insertStatementImpl) IgnoreDuplicates(ignore bool) InsertStatement {
 	s.ignore = ignore
 	return s
@@ -524,18 +595,27 @@ func (s *insertStatementImpl) Comment(comment string) InsertStatement {
 }
 
 func (s *insertStatementImpl) String(database string) (sql string, err error) {
+	return s.StringForDialect(database, MySqlDialect)
+}
+
+func (s *insertStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
 	if !validIdentifierName(database) {
 		return "", errors.New("Invalid database name specified")
 	}
 
-	buf := new(bytes.Buffer)
-	_, _ = buf.WriteString("INSERT ")
-	if s.ignore {
-		_, _ = buf.WriteString("IGNORE ")
+	buf := newSqlWriter(dialect)
+	if s.replace && (s.ignore || len(s.onDuplicateKeyUpdates) > 0) {
+		return "", errors.New(
+			"REPLACE does not support IGNORE or ON DUPLICATE KEY UPDATE")
+	}
+	if err = dialect.WriteInsertInto(s.replace, s.ignore, buf.Buffer); err != nil {
+		return
 	}
-	_, _ = buf.WriteString("INTO ")
 
-	if err = writeComment(s.comment, buf); err != nil {
+	if err = writeComment(s.comment, buf.Buffer); err != nil {
 		return
 	}
 
@@ -543,7 +623,7 @@ func (s *insertStatementImpl) String(database string) (sql string, err error) {
 		return "", errors.Newf("nil table.  Generated sql: %s", buf.String())
 	}
 
-	if err = s.table.SerializeSql(database, buf); err != nil {
+	if err = serializeTable(s.table, database, buf); err != nil {
 		return
 	}
 
@@ -565,51 +645,42 @@ func (s *insertStatementImpl) String(database string) (sql string, err error) {
 				buf.String())
 		}
 
-		if err = col.SerializeSqlForColumnList(buf); err != nil {
+		if err = writeAssignedColumn(col, buf); err != nil {
 			return
 		}
 	}
 
-	if len(s.rows) == 0 {
-		return "", errors.Newf(
-			"No row specified.  Generated sql: %s",
-			buf.String())
-	}
-
-	_, _ = buf.WriteString(") VALUES (")
-	for row_i, row := range s.rows {
-		if row_i > 0 {
-			_, _ = buf.WriteString(", (")
-		}
-
-		if len(row) != len(s.columns) {
+	if s.query != nil {
+		if len(s.rows) > 0 {
 			return "", errors.Newf(
-				"# of values does not match # of columns.  Generated sql: %s",
+				"Cannot insert both rows and a select query.  "+
+					"Generated sql: %s",
 				buf.String())
 		}
 
-		for col_i, value := range row {
-			if col_i > 0 {
-				_ = buf.WriteByte(',')
-			}
-
-			if value == nil {
-				return "", errors.Newf(
-					"nil value in row %d col %d.  Generated sql: %s",
-					row_i,
-					col_i,
-					buf.String())
-			}
+		if err = s.writeSelect(database, buf); err != nil {
+			return
+		}
+	} else if err = s.writeRows(buf); err != nil {
+		return
+	}
 
-			if err = value.SerializeSql(buf); err != nil {
-				return
-			}
+	var key []string
+	if t, ok := s.table.(*Table); ok {
+		for _, col := range t.primaryKey {
+			key = append(key, col.Name())
 		}
-		_ = buf.WriteByte(')')
+	}
+	err = dialect.WriteOnConflict(
+		s.ignore,
+		len(s.onDuplicateKeyUpdates) > 0,
+		key,
+		buf.Buffer)
+	if err != nil {
+		return
 	}
 
 	if len(s.onDuplicateKeyUpdates) > 0 {
-		_, _ = buf.WriteString(" ON DUPLICATE KEY UPDATE ")
 		for i, colExpr := range s.onDuplicateKeyUpdates {
 			if i > 0 {
 				_, _ = buf.WriteString(", ")
@@ -622,7 +693,7 @@ func (s *insertStatementImpl) String(database string) (sql string, err error) {
 					buf.String())
 			}
 
-			if err = colExpr.col.SerializeSqlForColumnList(buf); err != nil {
+			if err = writeAssignedColumn(colExpr.col, buf); err != nil {
 				return
 			}
 
@@ -635,7 +706,7 @@ func (s *insertStatementImpl) String(database string) (sql string, err error) {
 					buf.String())
 			}
 
-			if err = colExpr.expr.SerializeSql(buf); err != nil {
+			if err = colExpr.expr.serializeSql(buf); err != nil {
 				return
 			}
 		}
@@ -644,11 +715,132 @@ func (s *insertStatementImpl) String(database string) (sql string, err error) {
 	return buf.String(), nil
 }
 
+func (s *insertStatementImpl) writeSelect(
+	database string,
+	buf *sqlWriter) error {
+
+	if impl, ok := s.query.(*selectStatementImpl); ok &&
+		len(impl.projections) != len(s.columns) {
+
+		return errors.Newf(
+			"# of selected columns does not match # of columns.  "+
+				"Generated sql: %s",
+			buf.String())
+	}
+
+	_, _ = buf.WriteString(") ")
+
+	if impl, ok := s.query.(*selectStatementImpl); ok {
+		if impl.where == nil &&
+			len(s.onDuplicateKeyUpdates) > 0 &&
+			buf.dialect.UpsertSelectRequiresWhere() {
+
+			withWhere := *impl
+			withWhere.where = &alwaysTrueExpression{}
+			impl = &withWhere
+		}
+		return impl.serialize(database, buf)
+	}
+
+	selectSql, err := s.query.StringForDialect(database, buf.dialect)
+	if err != nil {
+		return err
+	}
+	_, _ = buf.WriteString(selectSql)
+	return nil
+}
+
+// A boolean expression which is always true (i.e., "WHERE true").
+type alwaysTrueExpression struct {
+	isExpression
+	isBoolExpression
+}
+
+func (e *alwaysTrueExpression) SerializeSql(out *bytes.Buffer) error {
+	return e.serializeSql(defaultSqlWriter(out))
+}
+
+func (e *alwaysTrueExpression) serializeSql(out *sqlWriter) error {
+	_, _ = out.WriteString("true")
+	return nil
+}
+
+func (s *insertStatementImpl) writeRows(buf *sqlWriter) (err error) {
+	if len(s.rows) == 0 {
+		return errors.Newf(
+			"No row specified.  Generated sql: %s",
+			buf.String())
+	}
+
+	_, _ = buf.WriteString(") VALUES (")
+	for row_i, row := range s.rows {
+		if row_i > 0 {
+			_, _ = buf.WriteString(", (")
+		}
+
+		if len(row) != len(s.columns) {
+			return errors.Newf(
+				"# of values does not match # of columns.  Generated sql: %s",
+				buf.String())
+		}
+
+		for col_i, value := range row {
+			if col_i > 0 {
+				_ = buf.WriteByte(',')
+			}
+
+			if value == nil {
+				return errors.Newf(
+					"nil value in row %d col %d.  Generated sql: %s",
+					row_i,
+					col_i,
+					buf.String())
+			}
+
+			if err = value.serializeSql(buf); err != nil {
+				return
+			}
+		}
+		_ = buf.WriteByte(')')
+	}
+
+	return nil
+}
+
+// Writes the column which is the target of an assignment.  The column is
+// qualified by table name only if the dialect allows it.
+func writeAssignedColumn(col NonAliasColumn, buf *sqlWriter) error {
+	if buf.dialect.QualifiesAssignedColumns() {
+		return col.serializeSqlForColumnList(buf)
+	}
+
+	if !validIdentifierName(col.Name()) {
+		return errors.Newf(
+			"Invalid column name in assignment.  Generated sql: %s",
+			buf.String())
+	}
+	buf.writeIdentifier(col.Name())
+	return nil
+}
+
 //
 // UPDATE statement ===========================================================
 //
 
-func newUpdateStatement(table WritableTable) UpdateStatement {
+// The table expression targeted by UPDATE and DELETE statements.  This is
+// either a single (writable) table, or a join table for multi-table
+// statements.
+type mutableTable interface {
+	Columns() []NonAliasColumn
+	SerializeSql(database string, out *bytes.Buffer) error
+}
+
+func isMultiTable(table mutableTable) bool {
+	_, ok := table.(*joinTable)
+	return ok
+}
+
+func newUpdateStatement(table mutableTable) UpdateStatement {
 	return &updateStatementImpl{
 		table:        table,
 		updateValues: make(map[NonAliasColumn]Expression),
@@ -657,7 +849,7 @@ func newUpdateStatement(table WritableTable) UpdateStatement {
 }
 
 type updateStatementImpl struct {
-	table        WritableTable
+	table        mutableTable
 	updateValues map[NonAliasColumn]Expression
 	where        BoolExpression
 	order        *listClause
@@ -696,14 +888,21 @@ func (u *updateStatementImpl) Comment(comment string) UpdateStatement {
 }
 
 func (u *updateStatementImpl) String(database string) (sql string, err error) {
+	return u.StringForDialect(database, MySqlDialect)
+}
+
+func (u *updateStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
 	if !validIdentifierName(database) {
 		return "", errors.New("Invalid database name specified")
 	}
 
-	buf := new(bytes.Buffer)
+	buf := newSqlWriter(dialect)
 	_, _ = buf.WriteString("UPDATE ")
 
-	if err = writeComment(u.comment, buf); err != nil {
+	if err = writeComment(u.comment, buf.Buffer); err != nil {
 		return
 	}
 
@@ -711,7 +910,14 @@ func (u *updateStatementImpl) String(database string) (sql string, err error) {
 		return "", errors.Newf("nil table.  Generated sql: %s", buf.String())
 	}
 
-	if err = u.table.SerializeSql(database, buf); err != nil {
+	multiTable := isMultiTable(u.table)
+	if multiTable {
+		if err = buf.requireMySql("Multi-table update"); err != nil {
+			return
+		}
+	}
+
+	if err = serializeTable(u.table, database, buf); err != nil {
 		return
 	}
 
@@ -721,6 +927,25 @@ func (u *updateStatementImpl) String(database string) (sql string, err error) {
 			buf.String())
 	}
 
+	if multiTable && (u.order != nil || u.limit >= 0) {
+		return "", errors.Newf(
+			"Multi-table update does not support ORDER BY or LIMIT.  "+
+				"Generated sql: %s",
+			buf.String())
+	}
+
+	// Columns are identified by name for single table updates, and by
+	// qualified name for multi-table updates (since the joined tables may
+	// have columns with the same name).
+	columnKey := func(col NonAliasColumn) (string, error) {
+		if !multiTable {
+			return col.Name(), nil
+		}
+		keyBuf := &bytes.Buffer{}
+		err := col.SerializeSqlForColumnList(keyBuf)
+		return keyBuf.String(), err
+	}
+
 	_, _ = buf.WriteString(" SET ")
 	addComma := false
 
@@ -733,11 +958,19 @@ func (u *updateStatementImpl) String(database string) (sql string, err error) {
 				buf.String())
 		}
 
-		updateValues[col.Name()] = expr
+		key, keyErr := columnKey(col)
+		if keyErr != nil {
+			return "", keyErr
+		}
+		updateValues[key] = expr
 	}
 
 	for _, col := range u.table.Columns() {
-		val, inMap := updateValues[col.Name()]
+		key, keyErr := columnKey(col)
+		if keyErr != nil {
+			return "", keyErr
+		}
+		val, inMap := updateValues[key]
 		if !inMap {
 			continue
 		}
@@ -752,12 +985,12 @@ func (u *updateStatementImpl) String(database string) (sql string, err error) {
 				buf.String())
 		}
 
-		if err = col.SerializeSql(buf); err != nil {
+		if err = writeAssignedColumn(col, buf); err != nil {
 			return
 		}
 
 		_ = buf.WriteByte('=')
-		if err = val.SerializeSql(buf); err != nil {
+		if err = val.serializeSql(buf); err != nil {
 			return
 		}
 
@@ -771,19 +1004,26 @@ func (u *updateStatementImpl) String(database string) (sql string, err error) {
 	}
 
 	_, _ = buf.WriteString(" WHERE ")
-	if err = u.where.SerializeSql(buf); err != nil {
+	if err = u.where.serializeSql(buf); err != nil {
 		return
 	}
 
+	if (u.order != nil || u.limit >= 0) && !dialect.LimitsMutations() {
+		return "", errors.Newf(
+			"ORDER BY / LIMIT is not supported by the sql dialect.  "+
+				"Generated sql: %s",
+			buf.String())
+	}
+
 	if u.order != nil {
 		_, _ = buf.WriteString(" ORDER BY ")
-		if err = u.order.SerializeSql(buf); err != nil {
+		if err = u.order.serializeSql(buf); err != nil {
 			return
 		}
 	}
 
 	if u.limit >= 0 {
-		_, _ = buf.WriteString(fmt.Sprintf(" LIMIT %d", u.limit))
+		dialect.WriteLimit(u.limit, -1, buf.Buffer)
 	}
 
 	return buf.String(), nil
@@ -793,7 +1033,7 @@ func (u *updateStatementImpl) String(database string) (sql string, err error) {
 // DELETE statement ===========================================================
 //
 
-func newDeleteStatement(table WritableTable) DeleteStatement {
+func newDeleteStatement(table mutableTable) DeleteStatement {
 	return &deleteStatementImpl{
 		table: table,
 		limit: -1,
@@ -801,11 +1041,14 @@ func newDeleteStatement(table WritableTable) DeleteStatement {
 }
 
 type deleteStatementImpl struct {
-	table   WritableTable
-	where   BoolExpression
-	order   *listClause
-	limit   int64
-	comment string
+	table mutableTable
+	// The tables to delete from, for multi-table deletes
+	deleteFrom []*Table
+	multiTable bool
+	where      BoolExpression
+	order      *listClause
+	limit      int64
+	comment    string
 }
 
 func (d *deleteStatementImpl) Where(expression BoolExpression) DeleteStatement {
@@ -831,22 +1074,78 @@ func (d *deleteStatementImpl) Comment(comment string) DeleteStatement {
 }
 
 func (d *deleteStatementImpl) String(database string) (sql string, err error) {
+	return d.StringForDialect(database, MySqlDialect)
+}
+
+func (d *deleteStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
 	if !validIdentifierName(database) {
 		return "", errors.New("Invalid database name specified")
 	}
 
-	buf := new(bytes.Buffer)
-	_, _ = buf.WriteString("DELETE FROM ")
+	buf := newSqlWriter(dialect)
+	if d.multiTable {
+		_, _ = buf.WriteString("DELETE ")
+	} else {
+		_, _ = buf.WriteString("DELETE FROM ")
+	}
 
-	if err = writeComment(d.comment, buf); err != nil {
+	if err = writeComment(d.comment, buf.Buffer); err != nil {
 		return
 	}
 
+	if d.multiTable {
+		if err = buf.requireMySql("Multi-table delete"); err != nil {
+			return
+		}
+
+		if len(d.deleteFrom) == 0 {
+			return "", errors.Newf(
+				"No table to delete from.  Generated sql: %s",
+				buf.String())
+		}
+
+		if d.order != nil || d.limit >= 0 {
+			return "", errors.Newf(
+				"Multi-table delete does not support ORDER BY or LIMIT.  "+
+					"Generated sql: %s",
+				buf.String())
+		}
+
+		joined := make(map[string]bool)
+		addTableNames(d.table, joined)
+
+		for i, t := range d.deleteFrom {
+			if t == nil {
+				return "", errors.Newf(
+					"nil table to delete from.  Generated sql: %s",
+					buf.String())
+			}
+			if !joined[t.Name()] {
+				return "", errors.Newf(
+					"Table %s is not in the delete's table expression.  "+
+						"Generated sql: %s",
+					t.Name(),
+					buf.String())
+			}
+			if i > 0 {
+				_, _ = buf.WriteString(", ")
+			}
+			if err = writeTableName(database, t, buf); err != nil {
+				return
+			}
+		}
+
+		_, _ = buf.WriteString(" FROM ")
+	}
+
 	if d.table == nil {
 		return "", errors.Newf("nil table.  Generated sql: %s", buf.String())
 	}
 
-	if err = d.table.SerializeSql(database, buf); err != nil {
+	if err = serializeTable(d.table, database, buf); err != nil {
 		return
 	}
 
@@ -857,19 +1156,26 @@ func (d *deleteStatementImpl) String(database string) (sql string, err error) {
 	}
 
 	_, _ = buf.WriteString(" WHERE ")
-	if err = d.where.SerializeSql(buf); err != nil {
+	if err = d.where.serializeSql(buf); err != nil {
 		return
 	}
 
+	if (d.order != nil || d.limit >= 0) && !dialect.LimitsMutations() {
+		return "", errors.Newf(
+			"ORDER BY / LIMIT is not supported by the sql dialect.  "+
+				"Generated sql: %s",
+			buf.String())
+	}
+
 	if d.order != nil {
 		_, _ = buf.WriteString(" ORDER BY ")
-		if err = d.order.SerializeSql(buf); err != nil {
+		if err = d.order.serializeSql(buf); err != nil {
 			return
 		}
 	}
 
 	if d.limit >= 0 {
-		_, _ = buf.WriteString(fmt.Sprintf(" LIMIT %d", d.limit))
+		dialect.WriteLimit(d.limit, -1, buf.Buffer)
 	}
 
 	return buf.String(), nil
@@ -908,6 +1214,13 @@ func (s *lockStatementImpl) AddWriteLock(t *Table) LockStatement {
 }
 
 func (s *lockStatementImpl) String(database string) (sql string, err error) {
+	return s.StringForDialect(database, MySqlDialect)
+}
+
+func (s *lockStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
 	if !validIdentifierName(database) {
 		return "", errors.New("Invalid database name specified")
 	}
@@ -916,7 +1229,10 @@ func (s *lockStatementImpl) String(database string) (sql string, err error) {
 		return "", errors.New("No locks added")
 	}
 
-	buf := new(bytes.Buffer)
+	buf := newSqlWriter(dialect)
+	if err = buf.requireMySql("LOCK TABLES"); err != nil {
+		return
+	}
 	_, _ = buf.WriteString("LOCK TABLES ")
 
 	for idx, lock := range s.locks {
@@ -924,7 +1240,7 @@ func (s *lockStatementImpl) String(database string) (sql string, err error) {
 			return "", errors.Newf("nil table.  Generated sql: %s", buf.String())
 		}
 
-		if err = lock.t.SerializeSql(database, buf); err != nil {
+		if err = lock.t.serializeSql(database, buf); err != nil {
 			return
 		}
 
@@ -952,6 +1268,16 @@ type unlockStatementImpl struct {
 }
 
 func (s *unlockStatementImpl) String(database string) (sql string, err error) {
+	return s.StringForDialect(database, MySqlDialect)
+}
+
+func (s *unlockStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
+	if err = newSqlWriter(dialect).requireMySql("UNLOCK TABLES"); err != nil {
+		return
+	}
 	return "UNLOCK TABLES", nil
 }
 
@@ -969,6 +1295,13 @@ type gtidNextStatementImpl struct {
 }
 
 func (s *gtidNextStatementImpl) String(database string) (sql string, err error) {
+	return s.StringForDialect(database, MySqlDialect)
+}
+
+func (s *gtidNextStatementImpl) StringForDialect(
+	database string,
+	dialect Dialect) (sql string, err error) {
+
 	// This statement sets a session local variable defining what the next transaction ID is.  It
 	// does not interact with other MySQL sessions. It is neither a DDL nor DML statement, so we
 	// don't have to worry about data corruption.
@@ -976,7 +1309,10 @@ func (s *gtidNextStatementImpl) String(database string) (sql string, err error)
 	// See: https://dev.mysql.com/doc/refman/5.7/en/replication-options-gtids.html
 	const gtidFormatString = "SET GTID_NEXT=\"%x-%x-%x-%x-%x:%d\""
 
-	buf := new(bytes.Buffer)
+	buf := newSqlWriter(dialect)
+	if err = buf.requireMySql("SET GTID_NEXT"); err != nil {
+		return
+	}
 	_, _ = buf.WriteString(fmt.Sprintf(gtidFormatString,
 		s.sid[:4], s.sid[4:6], s.sid[6:8], s.sid[8:10], s.sid[10:], s.gno))
 	return buf.String(), nil
@@ -383,6 +383,85 @@ func (s *StmtSuite) TestOnDuplicateKeyUpdateMulti(c *gc.C) {
 			"ON DUPLICATE KEY UPDATE `table1`.`col3`=3, `table1`.`col2`=4")
 }
 
+func (s *StmtSuite) TestOnDuplicateKeyUpdateValues(c *gc.C) {
+	stmt := table1.Insert(table1Col1, table1Col2, table1Col3)
+	stmt.Add(Literal(1), Literal(2), Literal(3))
+	stmt.AddOnDuplicateKeyUpdateValues(table1Col2, table1Col3)
+
+	sql, err := stmt.String("db")
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(
+		sql,
+		gc.Equals,
+		"INSERT INTO `db`.`table1` "+
+			"(`table1`.`col1`,`table1`.`col2`,`table1`.`col3`) "+
+			"VALUES (1,2,3) "+
+			"ON DUPLICATE KEY UPDATE "+
+			"`table1`.`col2`=VALUES(`table1`.`col2`), "+
+			"`table1`.`col3`=VALUES(`table1`.`col3`)")
+}
+
+func (s *StmtSuite) TestInsertSelect(c *gc.C) {
+	stmt := table1.Insert(table1Col1, table1Col2)
+	stmt.AddSelect(
+		table3.Select(table3Col1, table3Col2).Where(GtL(table3Col1, 5)))
+	stmt.AddOnDuplicateKeyUpdateValues(table1Col2)
+
+	sql, err := stmt.String("db")
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(
+		sql,
+		gc.Equals,
+		"INSERT INTO `db`.`table1` "+
+			"(`table1`.`col1`,`table1`.`col2`) "+
+			"SELECT `table3`.`col1`,`table3`.`col2` FROM `db`.`table3` "+
+			"WHERE `table3`.`col1`>5 "+
+			"ON DUPLICATE KEY UPDATE `table1`.`col2`=VALUES(`table1`.`col2`)")
+}
+
+func (s *StmtSuite) TestInsertSelectColumnLengthMismatch(c *gc.C) {
+	stmt := table1.Insert(table1Col1, table1Col2)
+	stmt.AddSelect(table3.Select(table3Col1))
+
+	_, err := stmt.String("db")
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *StmtSuite) TestInsertSelectWithRows(c *gc.C) {
+	stmt := table1.Insert(table1Col1)
+	stmt.Add(Literal(1))
+	stmt.AddSelect(table3.Select(table3Col1))
+
+	_, err := stmt.String("db")
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *StmtSuite) TestReplace(c *gc.C) {
+	stmt := table1.Replace(table1Col1, table1Col2)
+	stmt.Add(Literal(1), Literal(2))
+
+	sql, err := stmt.String("db")
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(
+		sql,
+		gc.Equals,
+		"REPLACE INTO `db`.`table1` "+
+			"(`table1`.`col1`,`table1`.`col2`) "+
+			"VALUES (1,2)")
+}
+
+func (s *StmtSuite) TestReplaceOnDuplicateKeyUpdate(c *gc.C) {
+	stmt := table1.Replace(table1Col1, table1Col2)
+	stmt.Add(Literal(1), Literal(2))
+	stmt.AddOnDuplicateKeyUpdateValues(table1Col2)
+
+	_, err := stmt.String("db")
+	c.Assert(err, gc.NotNil)
+}
+
 //
 // UPDATE statement tests =====================================================
 //
@@ -477,6 +556,34 @@ func (s *StmtSuite) TestUpdateWithLimit(c *gc.C) {
 			"LIMIT 5")
 }
 
+func (s *StmtSuite) TestMultiTableUpdate(c *gc.C) {
+	join := table1.InnerJoinOn(table2, Eq(table1Col3, table2Col3))
+	stmt := NewMultiTableUpdate(join)
+	stmt.Set(table2Col3, Literal(2))
+	stmt.Set(table1Col3, Literal(1))
+	stmt.Where(EqL(table2Col4, 3))
+
+	sql, err := stmt.String("db")
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(
+		sql,
+		gc.Equals,
+		"UPDATE `db`.`table1` JOIN `db`.`table2` "+
+			"ON `table1`.`col3`=`table2`.`col3` "+
+			"SET `table1`.`col3`=1, `table2`.`col3`=2 "+
+			"WHERE `table2`.`col4`=3")
+}
+
+func (s *StmtSuite) TestMultiTableUpdateWithLimit(c *gc.C) {
+	join := table1.InnerJoinOn(table2, Eq(table1Col3, table2Col3))
+	stmt := NewMultiTableUpdate(join).Set(table1Col1, Literal(1))
+	stmt.Where(EqL(table2Col4, 3)).Limit(1)
+
+	_, err := stmt.String("db")
+	c.Assert(err, gc.NotNil)
+}
+
 //
 // DELETE statement tests =====================================================
 //
@@ -520,6 +627,53 @@ func (s *StmtSuite) TestDeleteWithLimit(c *gc.C) {
 		"DELETE FROM `db`.`table1` WHERE `table1`.`col1`=1 LIMIT 5")
 }
 
+func (s *StmtSuite) TestMultiTableDelete(c *gc.C) {
+	join := table1.LeftJoinOn(table2, Eq(table1Col3, table2Col3))
+	stmt := NewMultiTableDelete(join, table1, table2)
+	stmt.Where(EqL(table1Col1, 1))
+
+	sql, err := stmt.String("db")
+	c.Assert(err, gc.IsNil)
+
+	c.Assert(
+		sql,
+		gc.Equals,
+		"DELETE `db`.`table1`, `db`.`table2` "+
+			"FROM `db`.`table1` LEFT JOIN `db`.`table2` "+
+			"ON `table1`.`col3`=`table2`.`col3` "+
+			"WHERE `table1`.`col1`=1")
+}
+
+func (s *StmtSuite) TestMultiTableDeleteNoTargets(c *gc.C) {
+	join := table1.LeftJoinOn(table2, Eq(table1Col3, table2Col3))
+	stmt := NewMultiTableDelete(join).Where(EqL(table1Col1, 1))
+
+	_, err := stmt.String("db")
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *StmtSuite) TestMultiTableDeleteNotJoined(c *gc.C) {
+	join := table1.LeftJoinOn(table2, Eq(table1Col3, table2Col3))
+	stmt := NewMultiTableDelete(join, table1, table3).
+		Where(EqL(table1Col1, 1))
+
+	_, err := stmt.String("db")
+	c.Assert(err, gc.NotNil)
+
+	stmt = NewMultiTableDelete(join, nil).Where(EqL(table1Col1, 1))
+	_, err = stmt.String("db")
+	c.Assert(err, gc.NotNil)
+}
+
+func (s *StmtSuite) TestMultiTableDeleteWithOrderBy(c *gc.C) {
+	join := table1.LeftJoinOn(table2, Eq(table1Col3, table2Col3))
+	stmt := NewMultiTableDelete(join, table1).Where(EqL(table1Col1, 1))
+	stmt.OrderBy(table1Col1)
+
+	_, err := stmt.String("db")
+	c.Assert(err, gc.NotNil)
+}
+
 //
 // LOCK/UNLOCK statement tests ================================================
 //
@@ -81,6 +81,17 @@ type Table struct {
 	columnLookup map[string]NonAliasColumn
 	// If not empty, the name of the index to force
 	forcedIndex string
+
+	// Key definitions.  These are only used for generating DDL statements.
+	primaryKey []NonAliasColumn
+	indexes    []Index
+}
+
+// Definition of a secondary index on a table.
+type Index struct {
+	Name    string
+	Unique  bool
+	Columns []NonAliasColumn
 }
 
 // Returns the specified column, or errors if it doesn't exist in the table
@@ -121,6 +132,65 @@ func (t *Table) Columns() []NonAliasColumn {
 	return t.columns
 }
 
+// Sets the table's primary key and returns the table.  This function will panic
+// if any of the columns is not in the table.
+func (t *Table) SetPrimaryKey(columns ...NonAliasColumn) *Table {
+	t.checkKeyColumns(columns)
+	t.primaryKey = columns
+	return t
+}
+
+// Adds a non-unique index to the table definition and returns the table.  This
+// function will panic if name is not valid or if any of the columns is not in
+// the table.
+func (t *Table) AddIndex(name string, columns ...NonAliasColumn) *Table {
+	return t.addIndex(Index{Name: name, Columns: columns})
+}
+
+// Adds a unique index to the table definition and returns the table.  This
+// function will panic if name is not valid or if any of the columns is not in
+// the table.
+func (t *Table) AddUniqueIndex(name string, columns ...NonAliasColumn) *Table {
+	return t.addIndex(Index{Name: name, Unique: true, Columns: columns})
+}
+
+func (t *Table) addIndex(index Index) *Table {
+	if !validIdentifierName(index.Name) {
+		panic("Invalid index name")
+	}
+	t.checkKeyColumns(index.Columns)
+	t.indexes = append(t.indexes, index)
+	return t
+}
+
+func (t *Table) checkKeyColumns(columns []NonAliasColumn) {
+	if len(columns) == 0 {
+		panic(fmt.Sprintf("Empty key in table %s", t.name))
+	}
+	for _, c := range columns {
+		if c == nil {
+			panic(fmt.Sprintf("nil key column in table %s", t.name))
+		}
+		if _, ok := t.columnLookup[c.Name()]; !ok {
+			panic(fmt.Sprintf(
+				"Key column %s is not in table %s",
+				c.Name(),
+				t.name))
+		}
+	}
+}
+
+// Returns the table's primary key columns (empty if no primary key is
+// defined).
+func (t *Table) PrimaryKey() []NonAliasColumn {
+	return t.primaryKey
+}
+
+// Returns the table's secondary indexes.
+func (t *Table) Indexes() []Index {
+	return t.indexes
+}
+
 // Returns a copy of this table, but with the specified index forced.
 func (t *Table) ForceIndex(index string) *Table {
 	newTable := *t
@@ -131,24 +201,50 @@ func (t *Table) ForceIndex(index string) *Table {
 // Generates the sql string for the current table expression.  Note: the
 // generated string may not be a valid/executable sql statement.
 func (t *Table) SerializeSql(database string, out *bytes.Buffer) error {
-	_, _ = out.WriteString("`")
-	_, _ = out.WriteString(database)
-	_, _ = out.WriteString("`.`")
-	_, _ = out.WriteString(t.Name())
-	_, _ = out.WriteString("`")
+	return t.serializeSql(database, defaultSqlWriter(out))
+}
+
+func (t *Table) serializeSql(database string, out *sqlWriter) error {
+	out.writeIdentifier(database)
+	_ = out.WriteByte('.')
+	out.writeIdentifier(t.Name())
 
 	if t.forcedIndex != "" {
 		if !validIdentifierName(t.forcedIndex) {
 			return errors.Newf("'%s' is not a valid identifier for an index", t.forcedIndex)
 		}
-		_, _ = out.WriteString(" FORCE INDEX (`")
-		_, _ = out.WriteString(t.forcedIndex)
-		_, _ = out.WriteString("`)")
+		if err := out.requireMySql("FORCE INDEX"); err != nil {
+			return err
+		}
+		_, _ = out.WriteString(" FORCE INDEX (")
+		out.writeIdentifier(t.forcedIndex)
+		_ = out.WriteByte(')')
 	}
 
 	return nil
 }
 
+// Table expressions which support dialect aware serialization.
+type dialectTable interface {
+	serializeSql(database string, out *sqlWriter) error
+}
+
+// Serializes the table expression using the writer's dialect.  Table
+// expressions defined outside of this package are serialized using their
+// SerializeSql method.
+func serializeTable(
+	table interface {
+		SerializeSql(database string, out *bytes.Buffer) error
+	},
+	database string,
+	out *sqlWriter) error {
+
+	if t, ok := table.(dialectTable); ok {
+		return t.serializeSql(database, out)
+	}
+	return table.SerializeSql(database, out.Buffer)
+}
+
 // Generates a select query on the current table.
 func (t *Table) Select(projections ...Projection) SelectStatement {
 	return newSelectStatement(t, projections)
@@ -182,6 +278,11 @@ func (t *Table) Insert(columns ...NonAliasColumn) InsertStatement {
 	return newInsertStatement(t, columns...)
 }
 
+// Generates a REPLACE INTO statement on the table.
+func (t *Table) Replace(columns ...NonAliasColumn) InsertStatement {
+	return newReplaceStatement(t, columns...)
+}
+
 func (t *Table) Update() UpdateStatement {
 	return newUpdateStatement(t)
 }
@@ -190,6 +291,21 @@ func (t *Table) Delete() DeleteStatement {
 	return newDeleteStatement(t)
 }
 
+// Generates a CREATE TABLE statement for the table definition.
+func (t *Table) CreateTable() CreateTableStatement {
+	return newCreateTableStatement(t)
+}
+
+// Generates an ALTER TABLE statement on the table.
+func (t *Table) AlterTable() AlterTableStatement {
+	return newAlterTableStatement(t)
+}
+
+// Generates a DROP TABLE statement for the table.
+func (t *Table) DropTable() DropTableStatement {
+	return newDropTableStatement(t)
+}
+
 type joinType int
 
 const (
@@ -244,6 +360,17 @@ func RightJoinOn(
 	return newJoinTable(lhs, rhs, RIGHT_JOIN, onCondition)
 }
 
+// Adds the names of the physical tables in the table expression to names.
+func addTableNames(table mutableTable, names map[string]bool) {
+	switch t := table.(type) {
+	case *Table:
+		names[t.Name()] = true
+	case *joinTable:
+		addTableNames(t.lhs, names)
+		addTableNames(t.rhs, names)
+	}
+}
+
 func (t *joinTable) Columns() []NonAliasColumn {
 	columns := make([]NonAliasColumn, 0)
 	columns = append(columns, t.lhs.Columns()...)
@@ -256,6 +383,13 @@ func (t *joinTable) SerializeSql(
 	database string,
 	out *bytes.Buffer) (err error) {
 
+	return t.serializeSql(database, defaultSqlWriter(out))
+}
+
+func (t *joinTable) serializeSql(
+	database string,
+	out *sqlWriter) (err error) {
+
 	if t.lhs == nil {
 		return errors.Newf("nil lhs.  Generated sql: %s", out.String())
 	}
@@ -266,7 +400,7 @@ func (t *joinTable) SerializeSql(
 		return errors.Newf("nil onCondition.  Generated sql: %s", out.String())
 	}
 
-	if err = t.lhs.SerializeSql(database, out); err != nil {
+	if err = serializeTable(t.lhs, database, out); err != nil {
 		return
 	}
 
@@ -279,12 +413,12 @@ func (t *joinTable) SerializeSql(
 		_, _ = out.WriteString(" RIGHT JOIN ")
 	}
 
-	if err = t.rhs.SerializeSql(database, out); err != nil {
+	if err = serializeTable(t.rhs, database, out); err != nil {
 		return
 	}
 
 	_, _ = out.WriteString(" ON ")
-	if err = t.onCondition.SerializeSql(out); err != nil {
+	if err = t.onCondition.serializeSql(out); err != nil {
 		return
 	}
 
@@ -5,7 +5,11 @@ import (
 )
 
 type Clause interface {
+	// Serializes the clause using the default (mysql) dialect
 	SerializeSql(out *bytes.Buffer) error
+
+	// Serializes the clause using the writer's dialect
+	serializeSql(out *sqlWriter) error
 }
 
 // A clause that can be used in order by
@@ -30,6 +34,7 @@ type Projection interface {
 	Clause
 	isProjectionInterface
 	SerializeSqlForColumnList(out *bytes.Buffer) error
+	serializeSqlForColumnList(out *sqlWriter) error
 }
 
 //
@@ -0,0 +1,711 @@
+package sqltypes
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/dropbox/godropbox/errors"
+	mysql_proto "github.com/dropbox/godropbox/proto/mysql"
+)
+
+// This contains encoders / decoders between Value and the MySQL binary
+// protocol, as used by COM_STMT_EXECUTE parameters and binary result set rows.
+// See https://dev.mysql.com/doc/internals/en/binary-protocol-value.html
+
+const (
+	// The first byte of a length encoded NULL (only valid in text rows).
+	lengthEncodedNull = 0xfb
+
+	// The parameter type flag which marks an integer as unsigned.
+	unsignedFlag = 0x80
+)
+
+// ColumnType describes how a value is represented in the binary protocol.
+type ColumnType struct {
+	Type mysql_proto.FieldType_Type
+
+	// Only meaningful for integer types.
+	Unsigned bool
+}
+
+// TypedValue is a Value tagged with its MySQL column type.
+type TypedValue struct {
+	Val Value
+	ColumnType
+}
+
+// Value implements the driver.Valuer interface.
+func (v TypedValue) Value() (driver.Value, error) {
+	return v.Val.Value()
+}
+
+// AppendLengthEncodedInt appends n in the length encoded integer format.
+func AppendLengthEncodedInt(b []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(b, byte(n))
+	case n < 1<<16:
+		return append(b, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(b, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	}
+	b = append(b, 0xfe)
+	return appendUint64(b, n)
+}
+
+// ReadLengthEncodedInt decodes a length encoded integer from the beginning of
+// b.  isNull is true if the encoded value is the NULL marker (0xfb).  Returns
+// the number of bytes consumed.
+func ReadLengthEncodedInt(b []byte) (n uint64, isNull bool, size int, err error) {
+	if len(b) == 0 {
+		return 0, false, 0, errors.Newf("Empty length encoded integer")
+	}
+
+	switch b[0] {
+	case lengthEncodedNull:
+		return 0, true, 1, nil
+	case 0xfc:
+		size = 3
+	case 0xfd:
+		size = 4
+	case 0xfe:
+		size = 9
+	case 0xff:
+		return 0, false, 0, errors.Newf("Invalid length encoded integer prefix")
+	default:
+		return uint64(b[0]), false, 1, nil
+	}
+
+	if len(b) < size {
+		return 0, false, 0, errors.Newf(
+			"Truncated length encoded integer (%d < %d)",
+			len(b),
+			size)
+	}
+	for i := size - 1; i > 0; i-- {
+		n = n<<8 | uint64(b[i])
+	}
+	return n, false, size, nil
+}
+
+// AppendLengthEncodedString appends s prefixed by its length encoded length.
+func AppendLengthEncodedString(b []byte, s []byte) []byte {
+	b = AppendLengthEncodedInt(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// ReadLengthEncodedString decodes a length encoded string from the beginning
+// of b.  The returned slice references b.  Returns the number of bytes
+// consumed.
+func ReadLengthEncodedString(b []byte) (s []byte, isNull bool, size int, err error) {
+	n, isNull, size, err := ReadLengthEncodedInt(b)
+	if err != nil || isNull {
+		return nil, isNull, size, err
+	}
+
+	if uint64(len(b)-size) < n {
+		return nil, false, 0, errors.Newf(
+			"Truncated length encoded string (%d < %d)",
+			len(b)-size,
+			n)
+	}
+	end := size + int(n)
+	return b[size:end], false, end, nil
+}
+
+// AppendBinary appends the binary protocol encoding of the value.  NULL values
+// are not encoded (they are marked in the NULL bitmap instead), so b is
+// returned unchanged.
+func (v TypedValue) AppendBinary(b []byte) ([]byte, error) {
+	if v.Val.IsNull() || v.Type == mysql_proto.FieldType_NULL {
+		return b, nil
+	}
+
+	switch v.Type {
+	case mysql_proto.FieldType_TINY:
+		u64, err := v.integer(8)
+		return append(b, byte(u64)), err
+	case mysql_proto.FieldType_SHORT, mysql_proto.FieldType_YEAR:
+		u64, err := v.integer(16)
+		return append(b, byte(u64), byte(u64>>8)), err
+	case mysql_proto.FieldType_INT24, mysql_proto.FieldType_LONG:
+		u64, err := v.integer(32)
+		return appendUint32(b, uint32(u64)), err
+	case mysql_proto.FieldType_LONGLONG:
+		u64, err := v.integer(64)
+		return appendUint64(b, u64), err
+	case mysql_proto.FieldType_FLOAT:
+		f64, err := v.float(32)
+		return appendUint32(b, math.Float32bits(float32(f64))), err
+	case mysql_proto.FieldType_DOUBLE:
+		f64, err := v.float(64)
+		return appendUint64(b, math.Float64bits(f64)), err
+	case mysql_proto.FieldType_DATE,
+		mysql_proto.FieldType_NEWDATE,
+		mysql_proto.FieldType_DATETIME,
+		mysql_proto.FieldType_TIMESTAMP:
+
+		t, err := parseDateTime(v.Val.Raw())
+		if err != nil {
+			return b, err
+		}
+		return t.appendBinary(b), nil
+	case mysql_proto.FieldType_TIME:
+		d, err := parseDuration(v.Val.Raw())
+		if err != nil {
+			return b, err
+		}
+		return d.appendBinary(b), nil
+	case mysql_proto.FieldType_DATETIME2,
+		mysql_proto.FieldType_TIMESTAMP2,
+		mysql_proto.FieldType_TIME2:
+
+		return b, binlogOnlyTypeError(v.Type)
+	}
+
+	if !isStringType(v.Type) {
+		return b, errors.Newf("Unsupported column type %v", v.Type)
+	}
+	return AppendLengthEncodedString(b, v.Val.Raw()), nil
+}
+
+// Returns the value's integer bits, checking that the value fits in bitSize
+// bits.
+func (v TypedValue) integer(bitSize int) (uint64, error) {
+	if v.Unsigned {
+		return decodeUint(v.Val, bitSize)
+	}
+	i64, err := decodeInt(v.Val, bitSize)
+	return uint64(i64), err
+}
+
+func (v TypedValue) float(bitSize int) (float64, error) {
+	if v.Val.IsNumeric() {
+		return strconv.ParseFloat(string(v.Val.Raw()), bitSize)
+	}
+	return decodeFloat(v.Val, bitSize)
+}
+
+// DecodeBinary decodes a non-NULL binary protocol value of the given column
+// type from the beginning of b.  Returns the number of bytes consumed.
+//
+// Integers and YEAR are decoded as Numeric, FLOAT, DOUBLE and DECIMAL as
+// Fractional, temporal types as utf8 strings in MySQL's text format, VARCHAR,
+// ENUM and SET as utf8 strings, and all other string types as binary strings.
+func DecodeBinary(b []byte, colType ColumnType) (TypedValue, int, error) {
+	value, size, err := decodeBinary(b, colType)
+	if err != nil {
+		return TypedValue{}, 0, err
+	}
+	return TypedValue{Val: value, ColumnType: colType}, size, nil
+}
+
+func decodeBinary(b []byte, colType ColumnType) (Value, int, error) {
+	switch colType.Type {
+	case mysql_proto.FieldType_NULL:
+		return NULL, 0, nil
+	case mysql_proto.FieldType_TINY:
+		if len(b) < 1 {
+			return NULL, 0, truncatedError(colType, 1, len(b))
+		}
+		return integerValue(uint64(b[0]), 8, colType.Unsigned), 1, nil
+	case mysql_proto.FieldType_SHORT, mysql_proto.FieldType_YEAR:
+		if len(b) < 2 {
+			return NULL, 0, truncatedError(colType, 2, len(b))
+		}
+		u64 := uint64(binary.LittleEndian.Uint16(b))
+		return integerValue(u64, 16, colType.Unsigned), 2, nil
+	case mysql_proto.FieldType_INT24, mysql_proto.FieldType_LONG:
+		if len(b) < 4 {
+			return NULL, 0, truncatedError(colType, 4, len(b))
+		}
+		u64 := uint64(binary.LittleEndian.Uint32(b))
+		return integerValue(u64, 32, colType.Unsigned), 4, nil
+	case mysql_proto.FieldType_LONGLONG:
+		if len(b) < 8 {
+			return NULL, 0, truncatedError(colType, 8, len(b))
+		}
+		u64 := binary.LittleEndian.Uint64(b)
+		return integerValue(u64, 64, colType.Unsigned), 8, nil
+	case mysql_proto.FieldType_FLOAT:
+		if len(b) < 4 {
+			return NULL, 0, truncatedError(colType, 4, len(b))
+		}
+		f32 := math.Float32frombits(binary.LittleEndian.Uint32(b))
+		return MakeFractional(
+			strconv.AppendFloat(nil, float64(f32), 'f', -1, 32)), 4, nil
+	case mysql_proto.FieldType_DOUBLE:
+		if len(b) < 8 {
+			return NULL, 0, truncatedError(colType, 8, len(b))
+		}
+		f64 := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		return MakeFractional(
+			strconv.AppendFloat(nil, f64, 'f', -1, 64)), 8, nil
+	case mysql_proto.FieldType_DATE, mysql_proto.FieldType_NEWDATE:
+		t, size, err := readDateTime(b)
+		if err != nil {
+			return NULL, 0, err
+		}
+		return Value{String{t.appendDate(nil), true}}, size, nil
+	case mysql_proto.FieldType_DATETIME, mysql_proto.FieldType_TIMESTAMP:
+		t, size, err := readDateTime(b)
+		if err != nil {
+			return NULL, 0, err
+		}
+		return Value{String{t.appendDateTime(nil), true}}, size, nil
+	case mysql_proto.FieldType_TIME:
+		d, size, err := readDuration(b)
+		if err != nil {
+			return NULL, 0, err
+		}
+		return Value{String{d.appendText(nil), true}}, size, nil
+	case mysql_proto.FieldType_DATETIME2,
+		mysql_proto.FieldType_TIMESTAMP2,
+		mysql_proto.FieldType_TIME2:
+
+		return NULL, 0, binlogOnlyTypeError(colType.Type)
+	}
+
+	if !isStringType(colType.Type) {
+		return NULL, 0, errors.Newf("Unsupported column type %v", colType.Type)
+	}
+
+	s, isNull, size, err := ReadLengthEncodedString(b)
+	if err != nil {
+		return NULL, 0, err
+	}
+	if isNull {
+		return NULL, 0, errors.Newf("Unexpected NULL marker in binary value")
+	}
+	// Copy the data since b is typically a reused network buffer.
+	data := append([]byte{}, s...)
+
+	switch colType.Type {
+	case mysql_proto.FieldType_DECIMAL, mysql_proto.FieldType_NEWDECIMAL:
+		return MakeFractional(data), size, nil
+	case mysql_proto.FieldType_VARCHAR,
+		mysql_proto.FieldType_VAR_STRING,
+		mysql_proto.FieldType_ENUM,
+		mysql_proto.FieldType_SET:
+
+		return Value{String{data, true}}, size, nil
+	}
+	return MakeString(data), size, nil
+}
+
+func isStringType(t mysql_proto.FieldType_Type) bool {
+	switch t {
+	case mysql_proto.FieldType_DECIMAL,
+		mysql_proto.FieldType_NEWDECIMAL,
+		mysql_proto.FieldType_VARCHAR,
+		mysql_proto.FieldType_BIT,
+		mysql_proto.FieldType_ENUM,
+		mysql_proto.FieldType_SET,
+		mysql_proto.FieldType_TINY_BLOB,
+		mysql_proto.FieldType_MEDIUM_BLOB,
+		mysql_proto.FieldType_LONG_BLOB,
+		mysql_proto.FieldType_BLOB,
+		mysql_proto.FieldType_VAR_STRING,
+		mysql_proto.FieldType_STRING,
+		mysql_proto.FieldType_GEOMETRY:
+
+		return true
+	}
+	return false
+}
+
+// The packed temporal types are only used by the binlog; the protocol sends
+// their columns as DATETIME, TIMESTAMP and TIME.
+func binlogOnlyTypeError(t mysql_proto.FieldType_Type) error {
+	return errors.Newf("Binlog only column type %v is not supported", t)
+}
+
+func truncatedError(colType ColumnType, expected int, actual int) error {
+	return errors.Newf(
+		"Truncated %v value (%d < %d)",
+		colType.Type,
+		actual,
+		expected)
+}
+
+// Converts the low bitSize bits of u64 into a Numeric value, sign extending
+// if the column is signed.
+func integerValue(u64 uint64, bitSize int, unsigned bool) Value {
+	if unsigned {
+		return MakeNumeric(strconv.AppendUint(nil, u64, 10))
+	}
+	shift := uint(64 - bitSize)
+	i64 := int64(u64<<shift) >> shift
+	return MakeNumeric(strconv.AppendInt(nil, i64, 10))
+}
+
+// AppendStmtExecuteParams appends the parameter section of a COM_STMT_EXECUTE
+// packet (NULL bitmap, new-params-bound flag, parameter types and values).
+func AppendStmtExecuteParams(b []byte, params []TypedValue) ([]byte, error) {
+	if len(params) == 0 {
+		return b, nil
+	}
+
+	bitmapStart := len(b)
+	b = append(b, make([]byte, (len(params)+7)/8)...)
+	for i, param := range params {
+		if param.Val.IsNull() {
+			b[bitmapStart+i/8] |= 1 << uint(i%8)
+		}
+	}
+
+	b = append(b, 1) // new-params-bound
+	for _, param := range params {
+		var flags byte
+		if param.Unsigned {
+			flags = unsignedFlag
+		}
+		b = append(b, byte(param.Type), flags)
+	}
+
+	var err error
+	for i, param := range params {
+		b, err = param.AppendBinary(b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to encode parameter %d", i)
+		}
+	}
+	return b, nil
+}
+
+// AppendBinaryRow appends a binary result set row packet payload (header,
+// NULL bitmap and values).
+func AppendBinaryRow(b []byte, row []TypedValue) ([]byte, error) {
+	b = append(b, 0x00)
+
+	// The result set NULL bitmap is offset by 2 bits.
+	bitmapStart := len(b)
+	b = append(b, make([]byte, (len(row)+7+2)/8)...)
+	for i, v := range row {
+		if v.Val.IsNull() {
+			b[bitmapStart+(i+2)/8] |= 1 << uint((i+2)%8)
+		}
+	}
+
+	var err error
+	for i, v := range row {
+		b, err = v.AppendBinary(b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to encode column %d", i)
+		}
+	}
+	return b, nil
+}
+
+// DecodeBinaryRow decodes a binary result set row packet payload.
+func DecodeBinaryRow(b []byte, columns []ColumnType) ([]TypedValue, error) {
+	bitmapLen := (len(columns) + 7 + 2) / 8
+	if len(b) < 1+bitmapLen {
+		return nil, errors.Newf("Truncated binary row header")
+	}
+	if b[0] != 0x00 {
+		return nil, errors.Newf("Invalid binary row header: %d", b[0])
+	}
+
+	bitmap := b[1 : 1+bitmapLen]
+	b = b[1+bitmapLen:]
+
+	row := make([]TypedValue, len(columns))
+	for i, colType := range columns {
+		if bitmap[(i+2)/8]&(1<<uint((i+2)%8)) != 0 {
+			row[i] = TypedValue{Val: NULL, ColumnType: colType}
+			continue
+		}
+
+		v, size, err := DecodeBinary(b, colType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to decode column %d", i)
+		}
+		row[i] = v
+		b = b[size:]
+	}
+
+	if len(b) != 0 {
+		return nil, errors.Newf("%d trailing bytes in binary row", len(b))
+	}
+	return row, nil
+}
+
+func appendUint32(b []byte, n uint32) []byte {
+	return append(b, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+}
+
+func appendUint64(b []byte, n uint64) []byte {
+	return append(
+		b,
+		byte(n), byte(n>>8), byte(n>>16), byte(n>>24),
+		byte(n>>32), byte(n>>40), byte(n>>48), byte(n>>56))
+}
+
+// mysqlDateTime is the decoded form of a MYSQL_TIME date / datetime.  Unlike
+// time.Time, it can represent zero dates (e.g., 0000-00-00).
+type mysqlDateTime struct {
+	year, month, day, hour, minute, second, micro int
+}
+
+func (t mysqlDateTime) appendBinary(b []byte) []byte {
+	var length byte
+	switch {
+	case t.micro != 0:
+		length = 11
+	case t.hour != 0 || t.minute != 0 || t.second != 0:
+		length = 7
+	case t.year != 0 || t.month != 0 || t.day != 0:
+		length = 4
+	}
+
+	b = append(b, length)
+	if length == 0 {
+		return b
+	}
+	b = append(b, byte(t.year), byte(t.year>>8), byte(t.month), byte(t.day))
+	if length == 4 {
+		return b
+	}
+	b = append(b, byte(t.hour), byte(t.minute), byte(t.second))
+	if length == 7 {
+		return b
+	}
+	return appendUint32(b, uint32(t.micro))
+}
+
+func (t mysqlDateTime) appendDate(b []byte) []byte {
+	return append(b, fmt.Sprintf("%04d-%02d-%02d", t.year, t.month, t.day)...)
+}
+
+func (t mysqlDateTime) appendDateTime(b []byte) []byte {
+	b = t.appendDate(b)
+	b = append(
+		b,
+		fmt.Sprintf(" %02d:%02d:%02d", t.hour, t.minute, t.second)...)
+	if t.micro != 0 {
+		b = append(b, fmt.Sprintf(".%06d", t.micro)...)
+	}
+	return b
+}
+
+func readDateTime(b []byte) (mysqlDateTime, int, error) {
+	var t mysqlDateTime
+	if len(b) == 0 {
+		return t, 0, errors.Newf("Truncated date time value")
+	}
+
+	n := int(b[0])
+	switch n {
+	case 0, 4, 7, 11:
+	default:
+		return t, 0, errors.Newf("Invalid date time length: %d", n)
+	}
+	if len(b) < 1+n {
+		return t, 0, errors.Newf(
+			"Truncated date time value (%d < %d)",
+			len(b)-1,
+			n)
+	}
+
+	data := b[1 : 1+n]
+	if n >= 4 {
+		t.year = int(binary.LittleEndian.Uint16(data))
+		t.month = int(data[2])
+		t.day = int(data[3])
+	}
+	if n >= 7 {
+		t.hour = int(data[4])
+		t.minute = int(data[5])
+		t.second = int(data[6])
+	}
+	if n == 11 {
+		t.micro = int(binary.LittleEndian.Uint32(data[7:]))
+	}
+	return t, 1 + n, nil
+}
+
+// Parses "YYYY-MM-DD[( |T)HH:MM:SS[.ffffff]]".
+func parseDateTime(s []byte) (mysqlDateTime, error) {
+	var t mysqlDateTime
+	invalid := errors.Newf("Invalid date time value: %q", s)
+
+	if len(s) < 10 || s[4] != '-' || s[7] != '-' {
+		return t, invalid
+	}
+
+	var ok bool
+	if t.year, ok = parseDigits(s[0:4]); !ok {
+		return t, invalid
+	}
+	if t.month, ok = parseDigits(s[5:7]); !ok {
+		return t, invalid
+	}
+	if t.day, ok = parseDigits(s[8:10]); !ok {
+		return t, invalid
+	}
+
+	s = s[10:]
+	if len(s) == 0 {
+		return t, nil
+	}
+	if s[0] != ' ' && s[0] != 'T' {
+		return t, invalid
+	}
+
+	if t.hour, t.minute, t.second, t.micro, ok = parseClock(s[1:]); !ok ||
+		t.hour > 23 {
+
+		return t, invalid
+	}
+	return t, nil
+}
+
+// mysqlDuration is the decoded form of a MYSQL_TIME time value.
+type mysqlDuration struct {
+	negative                bool
+	hours, minutes, seconds int
+	micro                   int
+}
+
+func (d mysqlDuration) appendBinary(b []byte) []byte {
+	var length byte
+	switch {
+	case d.micro != 0:
+		length = 12
+	case d.hours != 0 || d.minutes != 0 || d.seconds != 0:
+		length = 8
+	}
+
+	b = append(b, length)
+	if length == 0 {
+		return b
+	}
+	negative := byte(0)
+	if d.negative {
+		negative = 1
+	}
+	b = append(b, negative)
+	b = appendUint32(b, uint32(d.hours/24))
+	b = append(b, byte(d.hours%24), byte(d.minutes), byte(d.seconds))
+	if length == 8 {
+		return b
+	}
+	return appendUint32(b, uint32(d.micro))
+}
+
+func (d mysqlDuration) appendText(b []byte) []byte {
+	if d.negative {
+		b = append(b, '-')
+	}
+	b = append(
+		b,
+		fmt.Sprintf("%02d:%02d:%02d", d.hours, d.minutes, d.seconds)...)
+	if d.micro != 0 {
+		b = append(b, fmt.Sprintf(".%06d", d.micro)...)
+	}
+	return b
+}
+
+func readDuration(b []byte) (mysqlDuration, int, error) {
+	var d mysqlDuration
+	if len(b) == 0 {
+		return d, 0, errors.Newf("Truncated time value")
+	}
+
+	n := int(b[0])
+	switch n {
+	case 0, 8, 12:
+	default:
+		return d, 0, errors.Newf("Invalid time length: %d", n)
+	}
+	if len(b) < 1+n {
+		return d, 0, errors.Newf("Truncated time value (%d < %d)", len(b)-1, n)
+	}
+
+	data := b[1 : 1+n]
+	if n >= 8 {
+		d.negative = data[0] == 1
+		d.hours = int(binary.LittleEndian.Uint32(data[1:]))*24 + int(data[5])
+		d.minutes = int(data[6])
+		d.seconds = int(data[7])
+	}
+	if n == 12 {
+		d.micro = int(binary.LittleEndian.Uint32(data[8:]))
+	}
+	return d, 1 + n, nil
+}
+
+// Parses "[-]H+:MM:SS[.ffffff]".
+func parseDuration(s []byte) (mysqlDuration, error) {
+	var d mysqlDuration
+	invalid := errors.Newf("Invalid time value: %q", s)
+
+	if len(s) > 0 && s[0] == '-' {
+		d.negative = true
+		s = s[1:]
+	}
+
+	var ok bool
+	if d.hours, d.minutes, d.seconds, d.micro, ok = parseClock(s); !ok {
+		return d, invalid
+	}
+	return d, nil
+}
+
+// Parses "H+:MM:SS[.ffffff]".  The fraction is truncated to microseconds.
+func parseClock(s []byte) (hour, minute, second, micro int, ok bool) {
+	colon := 0
+	for colon < len(s) && s[colon] != ':' {
+		colon++
+	}
+	if colon == 0 || len(s) < colon+6 || s[colon+3] != ':' {
+		return 0, 0, 0, 0, false
+	}
+
+	if hour, ok = parseDigits(s[:colon]); !ok {
+		return 0, 0, 0, 0, false
+	}
+	if minute, ok = parseDigits(s[colon+1 : colon+3]); !ok || minute > 59 {
+		return 0, 0, 0, 0, false
+	}
+	if second, ok = parseDigits(s[colon+4 : colon+6]); !ok || second > 59 {
+		return 0, 0, 0, 0, false
+	}
+
+	frac := s[colon+6:]
+	if len(frac) == 0 {
+		return hour, minute, second, 0, true
+	}
+	if frac[0] != '.' || len(frac) == 1 {
+		return 0, 0, 0, 0, false
+	}
+	frac = frac[1:]
+	if len(frac) > 6 {
+		frac = frac[:6]
+	}
+	if micro, ok = parseDigits(frac); !ok {
+		return 0, 0, 0, 0, false
+	}
+	for i := len(frac); i < 6; i++ {
+		micro *= 10
+	}
+	return hour, minute, second, micro, true
+}
+
+func parseDigits(s []byte) (int, bool) {
+	if len(s) == 0 || len(s) > 9 {
+		return 0, false
+	}
+	n := 0
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
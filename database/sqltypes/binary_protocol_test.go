@@ -0,0 +1,363 @@
+package sqltypes
+
+import (
+	"database/sql/driver"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	mysql_proto "github.com/dropbox/godropbox/proto/mysql"
+)
+
+type BinaryProtocolSuite struct {
+}
+
+var _ = Suite(&BinaryProtocolSuite{})
+
+func typed(
+	v Value,
+	t mysql_proto.FieldType_Type,
+	unsigned bool) TypedValue {
+
+	return TypedValue{Val: v, ColumnType: ColumnType{t, unsigned}}
+}
+
+func (s *BinaryProtocolSuite) TestLengthEncodedInt(c *C) {
+	for _, test := range []struct {
+		n       uint64
+		encoded []byte
+	}{
+		{0, []byte{0}},
+		{250, []byte{250}},
+		{251, []byte{0xfc, 251, 0}},
+		{0xffff, []byte{0xfc, 0xff, 0xff}},
+		{0x10000, []byte{0xfd, 0, 0, 1}},
+		{0x1000000, []byte{0xfe, 0, 0, 0, 1, 0, 0, 0, 0}},
+	} {
+		encoded := AppendLengthEncodedInt(nil, test.n)
+		c.Assert(encoded, DeepEquals, test.encoded)
+
+		n, isNull, size, err := ReadLengthEncodedInt(append(encoded, 'x'))
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(size, Equals, len(encoded))
+		c.Assert(n, Equals, test.n)
+	}
+
+	_, isNull, size, err := ReadLengthEncodedInt([]byte{0xfb})
+	c.Assert(err, IsNil)
+	c.Assert(isNull, IsTrue)
+	c.Assert(size, Equals, 1)
+
+	_, _, _, err = ReadLengthEncodedInt([]byte{0xfd, 0})
+	c.Assert(err, NotNil)
+	_, _, _, err = ReadLengthEncodedInt(nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *BinaryProtocolSuite) TestLengthEncodedString(c *C) {
+	encoded := AppendLengthEncodedString(nil, []byte("abc"))
+	c.Assert(encoded, DeepEquals, []byte{3, 'a', 'b', 'c'})
+
+	str, isNull, size, err := ReadLengthEncodedString(encoded)
+	c.Assert(err, IsNil)
+	c.Assert(isNull, IsFalse)
+	c.Assert(size, Equals, 4)
+	c.Assert(string(str), Equals, "abc")
+
+	_, _, _, err = ReadLengthEncodedString(encoded[:3])
+	c.Assert(err, NotNil)
+}
+
+func (s *BinaryProtocolSuite) TestIntegers(c *C) {
+	for _, test := range []struct {
+		value    string
+		t        mysql_proto.FieldType_Type
+		unsigned bool
+		encoded  []byte
+	}{
+		{"-1", mysql_proto.FieldType_TINY, false, []byte{0xff}},
+		{"255", mysql_proto.FieldType_TINY, true, []byte{0xff}},
+		{"-2", mysql_proto.FieldType_SHORT, false, []byte{0xfe, 0xff}},
+		{"2012", mysql_proto.FieldType_YEAR, false, []byte{0xdc, 0x07}},
+		{"-3", mysql_proto.FieldType_INT24, false, []byte{0xfd, 0xff, 0xff, 0xff}},
+		{"4294967295", mysql_proto.FieldType_LONG, true, []byte{0xff, 0xff, 0xff, 0xff}},
+		{
+			"-9223372036854775808",
+			mysql_proto.FieldType_LONGLONG,
+			false,
+			[]byte{0, 0, 0, 0, 0, 0, 0, 0x80},
+		},
+		{
+			"18446744073709551615",
+			mysql_proto.FieldType_LONGLONG,
+			true,
+			[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		},
+	} {
+		v := typed(MakeNumeric([]byte(test.value)), test.t, test.unsigned)
+		encoded, err := v.AppendBinary(nil)
+		c.Assert(err, IsNil)
+		c.Assert(encoded, DeepEquals, test.encoded, Commentf(test.value))
+
+		decoded, size, err := DecodeBinary(encoded, v.ColumnType)
+		c.Assert(err, IsNil)
+		c.Assert(size, Equals, len(encoded))
+		c.Assert(decoded.Val.IsNumeric(), IsTrue)
+		c.Assert(decoded.Val.String(), Equals, test.value)
+		c.Assert(decoded.ColumnType, Equals, v.ColumnType)
+	}
+
+	v := typed(MakeNumeric([]byte("128")), mysql_proto.FieldType_TINY, false)
+	_, err := v.AppendBinary(nil)
+	c.Assert(err, NotNil)
+
+	v = typed(MakeNumeric([]byte("-1")), mysql_proto.FieldType_LONG, true)
+	_, err = v.AppendBinary(nil)
+	c.Assert(err, NotNil)
+
+	_, _, err = DecodeBinary(
+		[]byte{1, 2, 3},
+		ColumnType{Type: mysql_proto.FieldType_LONG})
+	c.Assert(err, NotNil)
+}
+
+func (s *BinaryProtocolSuite) TestFloats(c *C) {
+	v := typed(MakeFractional([]byte("1.5")), mysql_proto.FieldType_DOUBLE, false)
+	encoded, err := v.AppendBinary(nil)
+	c.Assert(err, IsNil)
+	c.Assert(encoded, DeepEquals, []byte{0, 0, 0, 0, 0, 0, 0xf8, 0x3f})
+
+	decoded, size, err := DecodeBinary(encoded, v.ColumnType)
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, 8)
+	c.Assert(decoded.Val.IsFractional(), IsTrue)
+	c.Assert(decoded.Val.String(), Equals, "1.5")
+
+	v = typed(MakeNumeric([]byte("2")), mysql_proto.FieldType_FLOAT, false)
+	encoded, err = v.AppendBinary(nil)
+	c.Assert(err, IsNil)
+	c.Assert(encoded, DeepEquals, []byte{0, 0, 0, 0x40})
+
+	decoded, _, err = DecodeBinary(encoded, v.ColumnType)
+	c.Assert(err, IsNil)
+	c.Assert(decoded.Val.String(), Equals, "2")
+
+	v = typed(MakeUtf8String("x"), mysql_proto.FieldType_DOUBLE, false)
+	_, err = v.AppendBinary(nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *BinaryProtocolSuite) TestDateTime(c *C) {
+	for _, test := range []struct {
+		text    string
+		t       mysql_proto.FieldType_Type
+		encoded []byte
+	}{
+		{"0000-00-00", mysql_proto.FieldType_DATE, []byte{0}},
+		{"2012-02-24", mysql_proto.FieldType_DATE, []byte{4, 0xdc, 0x07, 2, 24}},
+		{
+			"0000-00-00 00:00:00",
+			mysql_proto.FieldType_DATETIME,
+			[]byte{0},
+		},
+		{
+			"2012-02-24 00:00:00",
+			mysql_proto.FieldType_DATETIME,
+			[]byte{4, 0xdc, 0x07, 2, 24},
+		},
+		{
+			"2012-02-24 23:19:43",
+			mysql_proto.FieldType_TIMESTAMP,
+			[]byte{7, 0xdc, 0x07, 2, 24, 23, 19, 43},
+		},
+		{
+			"2012-02-24 23:19:43.000010",
+			mysql_proto.FieldType_DATETIME,
+			[]byte{11, 0xdc, 0x07, 2, 24, 23, 19, 43, 10, 0, 0, 0},
+		},
+	} {
+		v := typed(MakeUtf8String(test.text), test.t, false)
+		encoded, err := v.AppendBinary(nil)
+		c.Assert(err, IsNil)
+		c.Assert(encoded, DeepEquals, test.encoded, Commentf(test.text))
+
+		decoded, size, err := DecodeBinary(encoded, v.ColumnType)
+		c.Assert(err, IsNil)
+		c.Assert(size, Equals, len(encoded))
+		c.Assert(decoded.Val.IsUtf8String(), IsTrue)
+		c.Assert(decoded.Val.String(), Equals, test.text)
+	}
+
+	// Values produced by BuildValue(time.Time) round trip.
+	v := typed(
+		MakeUtf8String("2012-02-24 23:19:43.000000"),
+		mysql_proto.FieldType_DATETIME,
+		false)
+	encoded, err := v.AppendBinary(nil)
+	c.Assert(err, IsNil)
+	c.Assert(encoded, DeepEquals, []byte{7, 0xdc, 0x07, 2, 24, 23, 19, 43})
+
+	for _, invalid := range []string{
+		"2012-02-24 24:00:00",
+		"2012/02/24",
+		"2012-02-24 23:19",
+		"2012-02-24 23:19:43.",
+	} {
+		v := typed(MakeUtf8String(invalid), mysql_proto.FieldType_DATETIME, false)
+		_, err := v.AppendBinary(nil)
+		c.Assert(err, NotNil, Commentf(invalid))
+	}
+
+	_, _, err = DecodeBinary(
+		[]byte{5, 0, 0, 0, 0, 0},
+		ColumnType{Type: mysql_proto.FieldType_DATETIME})
+	c.Assert(err, NotNil)
+}
+
+func (s *BinaryProtocolSuite) TestTime(c *C) {
+	for _, test := range []struct {
+		text    string
+		encoded []byte
+	}{
+		{"00:00:00", []byte{0}},
+		{"-01:02:03", []byte{8, 1, 0, 0, 0, 0, 1, 2, 3}},
+		{"838:59:59", []byte{8, 0, 34, 0, 0, 0, 22, 59, 59}},
+		{
+			"10:00:00.500000",
+			[]byte{12, 0, 0, 0, 0, 0, 10, 0, 0, 0x20, 0xa1, 0x07, 0},
+		},
+	} {
+		v := typed(MakeUtf8String(test.text), mysql_proto.FieldType_TIME, false)
+		encoded, err := v.AppendBinary(nil)
+		c.Assert(err, IsNil)
+		c.Assert(encoded, DeepEquals, test.encoded, Commentf(test.text))
+
+		decoded, size, err := DecodeBinary(encoded, v.ColumnType)
+		c.Assert(err, IsNil)
+		c.Assert(size, Equals, len(encoded))
+		c.Assert(decoded.Val.String(), Equals, test.text)
+	}
+}
+
+func (s *BinaryProtocolSuite) TestBinlogOnlyTypes(c *C) {
+	for _, t := range []mysql_proto.FieldType_Type{
+		mysql_proto.FieldType_DATETIME2,
+		mysql_proto.FieldType_TIMESTAMP2,
+		mysql_proto.FieldType_TIME2,
+	} {
+		v := typed(MakeUtf8String("2012-02-24 23:19:43"), t, false)
+		_, err := v.AppendBinary(nil)
+		c.Assert(err, NotNil)
+
+		_, _, err = DecodeBinary([]byte{0}, v.ColumnType)
+		c.Assert(err, NotNil)
+	}
+}
+
+func (s *BinaryProtocolSuite) TestDriverValuer(c *C) {
+	var valuer driver.Valuer = typed(
+		MakeNumeric([]byte("-12")),
+		mysql_proto.FieldType_LONGLONG,
+		false)
+
+	value, err := valuer.Value()
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, int64(-12))
+}
+
+func (s *BinaryProtocolSuite) TestStrings(c *C) {
+	v := typed(MakeUtf8String("abc"), mysql_proto.FieldType_VAR_STRING, false)
+	encoded, err := v.AppendBinary(nil)
+	c.Assert(err, IsNil)
+	c.Assert(encoded, DeepEquals, []byte{3, 'a', 'b', 'c'})
+
+	decoded, size, err := DecodeBinary(encoded, v.ColumnType)
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, 4)
+	c.Assert(decoded.Val.IsUtf8String(), IsTrue)
+	c.Assert(decoded.Val.String(), Equals, "abc")
+
+	// Decoded values do not alias the input.
+	encoded[1] = 'x'
+	c.Assert(decoded.Val.String(), Equals, "abc")
+
+	decoded, _, err = DecodeBinary(
+		encoded,
+		ColumnType{Type: mysql_proto.FieldType_BLOB})
+	c.Assert(err, IsNil)
+	c.Assert(decoded.Val.IsString(), IsTrue)
+	c.Assert(decoded.Val.IsUtf8String(), IsFalse)
+
+	decoded, _, err = DecodeBinary(
+		[]byte{4, '1', '.', '2', '5'},
+		ColumnType{Type: mysql_proto.FieldType_NEWDECIMAL})
+	c.Assert(err, IsNil)
+	c.Assert(decoded.Val.IsFractional(), IsTrue)
+	c.Assert(decoded.Val.String(), Equals, "1.25")
+}
+
+func (s *BinaryProtocolSuite) TestStmtExecuteParams(c *C) {
+	params := []TypedValue{
+		typed(MakeNumeric([]byte("1")), mysql_proto.FieldType_LONGLONG, true),
+		typed(NULL, mysql_proto.FieldType_NULL, false),
+		typed(MakeUtf8String("ab"), mysql_proto.FieldType_VAR_STRING, false),
+	}
+
+	encoded, err := AppendStmtExecuteParams(nil, params)
+	c.Assert(err, IsNil)
+	c.Assert(
+		encoded,
+		DeepEquals,
+		[]byte{
+			0x02, // NULL bitmap
+			1,    // new-params-bound
+			byte(mysql_proto.FieldType_LONGLONG), 0x80,
+			byte(mysql_proto.FieldType_NULL), 0,
+			byte(mysql_proto.FieldType_VAR_STRING), 0,
+			1, 0, 0, 0, 0, 0, 0, 0,
+			2, 'a', 'b',
+		})
+
+	encoded, err = AppendStmtExecuteParams(nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(encoded, HasLen, 0)
+
+	_, err = AppendStmtExecuteParams(
+		nil,
+		[]TypedValue{
+			typed(MakeUtf8String("x"), mysql_proto.FieldType_LONG, false),
+		})
+	c.Assert(err, NotNil)
+}
+
+func (s *BinaryProtocolSuite) TestBinaryRow(c *C) {
+	row := []TypedValue{
+		typed(MakeNumeric([]byte("-7")), mysql_proto.FieldType_LONG, false),
+		typed(NULL, mysql_proto.FieldType_VAR_STRING, false),
+		typed(MakeUtf8String("ab"), mysql_proto.FieldType_VAR_STRING, false),
+		typed(MakeUtf8String("2012-02-24"), mysql_proto.FieldType_DATE, false),
+	}
+	columns := make([]ColumnType, len(row))
+	for i, v := range row {
+		columns[i] = v.ColumnType
+	}
+
+	encoded, err := AppendBinaryRow(nil, row)
+	c.Assert(err, IsNil)
+	c.Assert(encoded[:2], DeepEquals, []byte{0x00, 0x08})
+
+	decoded, err := DecodeBinaryRow(encoded, columns)
+	c.Assert(err, IsNil)
+	c.Assert(decoded, HasLen, len(row))
+	for i, v := range decoded {
+		c.Assert(v.ColumnType, Equals, row[i].ColumnType)
+		c.Assert(v.Val.IsNull(), Equals, row[i].Val.IsNull())
+		c.Assert(v.Val.String(), Equals, row[i].Val.String())
+	}
+
+	_, err = DecodeBinaryRow(append(encoded, 0), columns)
+	c.Assert(err, NotNil)
+	_, err = DecodeBinaryRow(encoded[:len(encoded)-1], columns)
+	c.Assert(err, NotNil)
+}
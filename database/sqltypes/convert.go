@@ -0,0 +1,279 @@
+package sqltypes
+
+import (
+	"strconv"
+
+	"github.com/dropbox/godropbox/errors"
+	dbstrings "github.com/dropbox/godropbox/strings"
+)
+
+// Type specialized decoders used by ConvertAssign.  Numbers are parsed
+// directly from the value's raw bytes, so decoding into numeric (and *[]byte)
+// destinations does not allocate.  Errors fall back to strconv to produce the
+// same messages as the reflection based path.
+
+// Returns false if dest is not one of the specialized destination types, or if
+// dest is a nil pointer (which is rejected by the reflection based path).
+func convertAssignFast(src Value, dest interface{}) (bool, error) {
+	switch d := dest.(type) {
+	case *int64:
+		if d == nil {
+			break
+		}
+		i64, err := decodeInt(src, 64)
+		if err == nil {
+			*d = i64
+		}
+		return true, err
+	case *int:
+		if d == nil {
+			break
+		}
+		i64, err := decodeInt(src, strconv.IntSize)
+		if err == nil {
+			*d = int(i64)
+		}
+		return true, err
+	case *int32:
+		if d == nil {
+			break
+		}
+		i64, err := decodeInt(src, 32)
+		if err == nil {
+			*d = int32(i64)
+		}
+		return true, err
+	case *int16:
+		if d == nil {
+			break
+		}
+		i64, err := decodeInt(src, 16)
+		if err == nil {
+			*d = int16(i64)
+		}
+		return true, err
+	case *int8:
+		if d == nil {
+			break
+		}
+		i64, err := decodeInt(src, 8)
+		if err == nil {
+			*d = int8(i64)
+		}
+		return true, err
+	case *uint64:
+		if d == nil {
+			break
+		}
+		u64, err := decodeUint(src, 64)
+		if err == nil {
+			*d = u64
+		}
+		return true, err
+	case *uint:
+		if d == nil {
+			break
+		}
+		u64, err := decodeUint(src, strconv.IntSize)
+		if err == nil {
+			*d = uint(u64)
+		}
+		return true, err
+	case *uint32:
+		if d == nil {
+			break
+		}
+		u64, err := decodeUint(src, 32)
+		if err == nil {
+			*d = uint32(u64)
+		}
+		return true, err
+	case *uint16:
+		if d == nil {
+			break
+		}
+		u64, err := decodeUint(src, 16)
+		if err == nil {
+			*d = uint16(u64)
+		}
+		return true, err
+	case *uint8:
+		if d == nil {
+			break
+		}
+		u64, err := decodeUint(src, 8)
+		if err == nil {
+			*d = uint8(u64)
+		}
+		return true, err
+	case *float64:
+		if d == nil {
+			break
+		}
+		f64, err := decodeFloat(src, 64)
+		if err == nil {
+			*d = f64
+		}
+		return true, err
+	case *float32:
+		if d == nil {
+			break
+		}
+		f64, err := decodeFloat(src, 32)
+		if err == nil {
+			*d = float32(f64)
+		}
+		return true, err
+	case *bool:
+		if d == nil {
+			break
+		}
+		// treat bool as true if non-zero integer
+		i64, err := decodeInt(src, 64)
+		if err == nil {
+			*d = i64 != 0
+		}
+		return true, err
+	case *string:
+		if d == nil {
+			break
+		}
+		s, err := decodeString(src)
+		if err == nil {
+			*d = string(s.raw())
+		}
+		return true, err
+	case *[]byte:
+		if d == nil {
+			break
+		}
+		s, err := decodeString(src)
+		if err == nil {
+			*d = s.raw()
+		}
+		return true, err
+	}
+	return false, nil
+}
+
+func decodeString(src Value) (String, error) {
+	if src.Inner == nil {
+		return String{}, errors.Newf("source is null")
+	}
+	s, ok := src.Inner.(String)
+	if !ok {
+		return String{}, errors.Newf("source: '%v' is not String", src)
+	}
+	return s, nil
+}
+
+func decodeNumeric(src Value) (Numeric, error) {
+	if src.Inner == nil {
+		return nil, errors.Newf("source is null")
+	}
+	n, ok := src.Inner.(Numeric)
+	if !ok {
+		return nil, errors.Newf("source: '%v' is not Numeric", src)
+	}
+	return n, nil
+}
+
+func decodeInt(src Value, bitSize int) (int64, error) {
+	n, err := decodeNumeric(src)
+	if err != nil {
+		return 0, err
+	}
+	if i64, ok := parseInt(n, bitSize); ok {
+		return i64, nil
+	}
+	return strconv.ParseInt(string(n), 10, bitSize)
+}
+
+func decodeUint(src Value, bitSize int) (uint64, error) {
+	n, err := decodeNumeric(src)
+	if err != nil {
+		return 0, err
+	}
+	if u64, ok := parseUint(n, bitSize); ok {
+		return u64, nil
+	}
+	return strconv.ParseUint(string(n), 10, bitSize)
+}
+
+func decodeFloat(src Value, bitSize int) (float64, error) {
+	if src.Inner == nil {
+		return 0, errors.Newf("source is null")
+	}
+	f, ok := src.Inner.(Fractional)
+	if !ok {
+		return 0, errors.Newf("source: '%v' is not Fractional", src)
+	}
+
+	// The shallow string must not escape, so the error is regenerated from a
+	// copy.
+	f64, err := strconv.ParseFloat(dbstrings.ShallowString(f), bitSize)
+	if err != nil {
+		return strconv.ParseFloat(string(f), bitSize)
+	}
+	return f64, nil
+}
+
+// Parses a base 10 unsigned integer.  Returns false if the input is not a
+// valid number, or if it does not fit in bitSize bits.
+func parseUint(b []byte, bitSize int) (uint64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+
+	max := uint64(1)<<uint(bitSize) - 1
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		d := uint64(c - '0')
+		if n > max/10 {
+			return 0, false
+		}
+		n *= 10
+		if n > max-d {
+			return 0, false
+		}
+		n += d
+	}
+	return n, true
+}
+
+// Parses a base 10 signed integer.  Returns false if the input is not a valid
+// number, or if it does not fit in bitSize bits.
+func parseInt(b []byte, bitSize int) (int64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+
+	negative := false
+	if b[0] == '-' || b[0] == '+' {
+		negative = b[0] == '-'
+		b = b[1:]
+		if len(b) == 0 {
+			return 0, false
+		}
+	}
+
+	u64, ok := parseUint(b, 64)
+	if !ok {
+		return 0, false
+	}
+
+	cutoff := uint64(1) << uint(bitSize-1)
+	if negative {
+		if u64 > cutoff {
+			return 0, false
+		}
+		return -int64(u64), true
+	}
+	if u64 >= cutoff {
+		return 0, false
+	}
+	return int64(u64), true
+}
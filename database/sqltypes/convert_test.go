@@ -0,0 +1,231 @@
+package sqltypes
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	. "gopkg.in/check.v1"
+)
+
+type ConvertSuite struct {
+}
+
+var _ = Suite(&ConvertSuite{})
+
+func (s *ConvertSuite) TestParseIntMatchesStrconv(c *C) {
+	inputs := []string{
+		"", "+", "-", "0", "-0", "+1", "12a", " 1", "1_0", "++1", "-+1",
+		"127", "128", "-128", "-129",
+		"32767", "32768", "-32768", "-32769",
+		"2147483647", "2147483648", "-2147483648", "-2147483649",
+		"9223372036854775807", "9223372036854775808",
+		"-9223372036854775808", "-9223372036854775809",
+		"18446744073709551615", "18446744073709551616",
+		"99999999999999999999999",
+	}
+
+	for _, input := range inputs {
+		for _, bits := range []int{8, 16, 32, 64} {
+			expected, err := strconv.ParseInt(input, 10, bits)
+			i64, ok := parseInt([]byte(input), bits)
+			c.Assert(ok, Equals, err == nil, Commentf("%q %d", input, bits))
+			if ok {
+				c.Assert(i64, Equals, expected)
+			}
+
+			expectedU, err := strconv.ParseUint(input, 10, bits)
+			u64, ok := parseUint([]byte(input), bits)
+			c.Assert(ok, Equals, err == nil, Commentf("%q %d", input, bits))
+			if ok {
+				c.Assert(u64, Equals, expectedU)
+			}
+		}
+	}
+}
+
+func TestParseIntQuick(t *testing.T) {
+	signed := func(i int64) bool {
+		v, ok := parseInt([]byte(strconv.FormatInt(i, 10)), 64)
+		return ok && v == i
+	}
+	if err := quick.Check(signed, nil); err != nil {
+		t.Error(err)
+	}
+
+	unsigned := func(u uint64) bool {
+		v, ok := parseUint([]byte(strconv.FormatUint(u, 10)), 64)
+		return ok && v == u
+	}
+	if err := quick.Check(unsigned, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func (s *ConvertSuite) TestConvertAssignSizedTypes(c *C) {
+	var i8 int8
+	c.Assert(ConvertAssign(MakeNumeric([]byte("-128")), &i8), IsNil)
+	c.Assert(i8, Equals, int8(math.MinInt8))
+	c.Assert(ConvertAssign(MakeNumeric([]byte("128")), &i8), NotNil)
+
+	var u16 uint16
+	c.Assert(ConvertAssign(MakeNumeric([]byte("65535")), &u16), IsNil)
+	c.Assert(u16, Equals, uint16(math.MaxUint16))
+	c.Assert(ConvertAssign(MakeNumeric([]byte("-1")), &u16), NotNil)
+
+	var u64 uint64
+	c.Assert(
+		ConvertAssign(MakeNumeric([]byte("18446744073709551615")), &u64),
+		IsNil)
+	c.Assert(u64, Equals, uint64(math.MaxUint64))
+
+	var f32 float32
+	c.Assert(ConvertAssign(MakeFractional([]byte("1.5")), &f32), IsNil)
+	c.Assert(f32, Equals, float32(1.5))
+	c.Assert(ConvertAssign(MakeFractional([]byte("1.5x")), &f32), NotNil)
+	c.Assert(ConvertAssign(MakeNumeric([]byte("1")), &f32), NotNil)
+
+	var b bool
+	c.Assert(ConvertAssign(MakeNumeric([]byte("2")), &b), IsNil)
+	c.Assert(b, Equals, true)
+
+	var i int
+	c.Assert(ConvertAssign(NULL, &i), ErrorMatches, "source is null(.|\n)*")
+	c.Assert(ConvertAssign(MakeString([]byte("1")), &i), NotNil)
+}
+
+func (s *ConvertSuite) TestConvertAssignNilDestination(c *C) {
+	numeric := MakeNumeric([]byte("1"))
+	str := MakeString([]byte("1"))
+
+	c.Assert(
+		ConvertAssign(numeric, (*int64)(nil)),
+		ErrorMatches,
+		"destination pointer is Nil(.|\n)*")
+	c.Assert(
+		ConvertAssign(numeric, (*uint8)(nil)),
+		ErrorMatches,
+		"destination pointer is Nil(.|\n)*")
+	c.Assert(
+		ConvertAssign(MakeFractional([]byte("1.5")), (*float64)(nil)),
+		ErrorMatches,
+		"destination pointer is Nil(.|\n)*")
+	c.Assert(
+		ConvertAssign(numeric, (*bool)(nil)),
+		ErrorMatches,
+		"destination pointer is Nil(.|\n)*")
+	c.Assert(
+		ConvertAssign(str, (*string)(nil)),
+		ErrorMatches,
+		"destination pointer is Nil(.|\n)*")
+	c.Assert(
+		ConvertAssign(str, (*[]byte)(nil)),
+		ErrorMatches,
+		"destination pointer is Nil(.|\n)*")
+}
+
+type namedInt64 int64
+
+func (s *ConvertSuite) TestConvertAssignNamedType(c *C) {
+	// Named types use the reflection based path.
+	var n namedInt64
+	c.Assert(ConvertAssign(MakeNumeric([]byte("-5")), &n), IsNil)
+	c.Assert(n, Equals, namedInt64(-5))
+}
+
+func (s *ConvertSuite) TestConvertAssignAllocations(c *C) {
+	numeric := MakeNumeric([]byte("-1234567890"))
+	unsigned := MakeNumeric([]byte("1234567890"))
+	fractional := MakeFractional([]byte("12345.6789"))
+	str := MakeString([]byte("abc"))
+
+	var i64 int64
+	var i32 int32
+	var u64 uint64
+	var f64 float64
+	var b bool
+	var bs []byte
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = ConvertAssign(numeric, &i64)
+		_ = ConvertAssign(numeric, &i32)
+		_ = ConvertAssign(unsigned, &u64)
+		_ = ConvertAssign(fractional, &f64)
+		_ = ConvertAssign(unsigned, &b)
+		_ = ConvertAssign(str, &bs)
+	})
+	c.Assert(allocs, Equals, float64(0))
+}
+
+func BenchmarkConvertAssignInt64(b *testing.B) {
+	v := MakeNumeric([]byte("-1234567890"))
+	var dest int64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = ConvertAssign(v, &dest)
+	}
+}
+
+func BenchmarkConvertAssignUint64(b *testing.B) {
+	v := MakeNumeric([]byte("18446744073709551615"))
+	var dest uint64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = ConvertAssign(v, &dest)
+	}
+}
+
+func BenchmarkConvertAssignFloat64(b *testing.B) {
+	v := MakeFractional([]byte("12345.6789"))
+	var dest float64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = ConvertAssign(v, &dest)
+	}
+}
+
+func BenchmarkConvertAssignBytes(b *testing.B) {
+	v := MakeString([]byte("abcdefghijklmnopqrstuvwxyz"))
+	var dest []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = ConvertAssign(v, &dest)
+	}
+}
+
+func BenchmarkConvertAssignString(b *testing.B) {
+	v := MakeString([]byte("abcdefghijklmnopqrstuvwxyz"))
+	var dest string
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = ConvertAssign(v, &dest)
+	}
+}
+
+// Baseline for the reflection based path.
+func BenchmarkConvertAssignNamedInt64(b *testing.B) {
+	v := MakeNumeric([]byte("-1234567890"))
+	var dest namedInt64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = ConvertAssign(v, &dest)
+	}
+}
+
+func BenchmarkConvertAssignRow(b *testing.B) {
+	row := []Value{
+		MakeNumeric([]byte("1234567890")),
+		MakeNumeric([]byte("-42")),
+		MakeFractional([]byte("1.5")),
+		MakeString([]byte("data")),
+	}
+	var id uint64
+	var delta int32
+	var ratio float64
+	var data []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = ConvertAssignRow(row, &id, &delta, &ratio, &data)
+	}
+}
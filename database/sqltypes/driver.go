@@ -0,0 +1,108 @@
+package sqltypes
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+// Layouts accepted when converting string values to time.Time.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+func buildValuerValue(valuer driver.Valuer) (Value, error) {
+	// Follow database/sql's convention of treating nil pointers as NULL.
+	if isNilPointer(valuer) {
+		return NULL, nil
+	}
+
+	val, err := valuer.Value()
+	if err != nil {
+		return Value{}, errors.Wrapf(err, "Failed to get value of %T", valuer)
+	}
+	if !driver.IsValue(val) {
+		return Value{}, errors.Newf(
+			"%T returned non-driver value of type %T",
+			valuer,
+			val)
+	}
+	return BuildValue(val)
+}
+
+// Value implements the driver.Valuer interface.  Numeric values are returned
+// as int64 (or as a decimal string if the value overflows int64), fractional
+// values as float64, utf8 strings as string, and binary strings as []byte.
+func (v Value) Value() (driver.Value, error) {
+	switch inner := v.Inner.(type) {
+	case nil:
+		return nil, nil
+	case Numeric:
+		if i64, err := strconv.ParseInt(string(inner), 10, 64); err == nil {
+			return i64, nil
+		}
+		return string(inner), nil
+	case Fractional:
+		f64, err := strconv.ParseFloat(string(inner), 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid fractional value %s", inner)
+		}
+		return f64, nil
+	case String:
+		if inner.isUtf8 {
+			return string(inner.data), nil
+		}
+		return inner.data, nil
+	}
+	return nil, errors.Newf("Unsupported value type %T", v.Inner)
+}
+
+// Scan implements the sql.Scanner interface.  []byte sources are copied,
+// since the driver may reuse the underlying array.
+func (v *Value) Scan(src interface{}) error {
+	if b, ok := src.([]byte); ok {
+		*v = MakeString(append([]byte{}, b...))
+		return nil
+	}
+
+	val, err := BuildValue(src)
+	if err != nil {
+		return err
+	}
+	*v = val
+	return nil
+}
+
+// sql.NullTime's Scan only accepts time.Time sources, whereas time values are
+// stored as strings (see BuildValue).  The string is interpreted as UTC.
+func scanNullTime(src Value, dest *sql.NullTime) error {
+	if src.IsNull() {
+		*dest = sql.NullTime{}
+		return nil
+	}
+
+	s, ok := src.Inner.(String)
+	if !ok {
+		return errors.Newf("source: '%v' is not String", src)
+	}
+
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, string(s.raw()), time.UTC)
+		if err == nil {
+			*dest = sql.NullTime{Time: t, Valid: true}
+			return nil
+		}
+	}
+	return errors.Newf("source: '%v' is not a valid time", src)
+}
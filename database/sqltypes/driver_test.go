@@ -0,0 +1,182 @@
+package sqltypes
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+)
+
+type DriverSuite struct {
+}
+
+var _ = Suite(&DriverSuite{})
+
+type userId int64
+
+type testValuer struct {
+	value driver.Value
+}
+
+func (v *testValuer) Value() (driver.Value, error) {
+	return v.value, nil
+}
+
+type testScanner struct {
+	src interface{}
+}
+
+func (s *testScanner) Scan(src interface{}) error {
+	s.src = src
+	return nil
+}
+
+func (s *DriverSuite) TestBuildValueSizedNumerics(c *C) {
+	for _, val := range []interface{}{
+		int8(-8),
+		int16(-16),
+		uint16(16),
+		userId(42),
+	} {
+		v, err := BuildValue(val)
+		c.Assert(err, IsNil)
+		c.Assert(v.IsNumeric(), IsTrue)
+	}
+
+	v, err := BuildValue(int16(-16))
+	c.Assert(err, IsNil)
+	c.Assert(v.String(), Equals, "-16")
+
+	v, err = BuildValue(userId(42))
+	c.Assert(err, IsNil)
+	c.Assert(v.String(), Equals, "42")
+}
+
+func (s *DriverSuite) TestBuildValueValuer(c *C) {
+	v, err := BuildValue(sql.NullInt64{Int64: 5, Valid: true})
+	c.Assert(err, IsNil)
+	c.Assert(v.IsNumeric(), IsTrue)
+	c.Assert(v.String(), Equals, "5")
+
+	v, err = BuildValue(sql.NullString{})
+	c.Assert(err, IsNil)
+	c.Assert(v.IsNull(), IsTrue)
+
+	v, err = BuildValue(&testValuer{value: "abc"})
+	c.Assert(err, IsNil)
+	c.Assert(v.IsUtf8String(), IsTrue)
+	c.Assert(v.String(), Equals, "abc")
+
+	var nilValuer *testValuer
+	v, err = BuildValue(nilValuer)
+	c.Assert(err, IsNil)
+	c.Assert(v.IsNull(), IsTrue)
+
+	_, err = BuildValue(&testValuer{value: int32(1)})
+	c.Assert(err, NotNil)
+}
+
+func (s *DriverSuite) TestBuildValueTextMarshaler(c *C) {
+	v, err := BuildValue(net.IPv4(10, 0, 0, 1))
+	c.Assert(err, IsNil)
+	c.Assert(v.IsUtf8String(), IsTrue)
+	c.Assert(v.String(), Equals, "10.0.0.1")
+}
+
+func (s *DriverSuite) TestValue(c *C) {
+	val, err := NULL.Value()
+	c.Assert(err, IsNil)
+	c.Assert(val, IsNil)
+
+	val, err = MakeNumeric([]byte("-12")).Value()
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, int64(-12))
+
+	val, err = MakeNumeric([]byte("18446744073709551615")).Value()
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "18446744073709551615")
+
+	val, err = MakeFractional([]byte("1.5")).Value()
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, float64(1.5))
+
+	val, err = MakeUtf8String("abc").Value()
+	c.Assert(err, IsNil)
+	c.Assert(val, Equals, "abc")
+
+	val, err = MakeString([]byte("abc")).Value()
+	c.Assert(err, IsNil)
+	c.Assert(val, DeepEquals, []byte("abc"))
+}
+
+func (s *DriverSuite) TestScan(c *C) {
+	var v Value
+
+	src := []byte("abc")
+	c.Assert(v.Scan(src), IsNil)
+	src[0] = 'x'
+	c.Assert(v.IsString(), IsTrue)
+	c.Assert(v.String(), Equals, "abc")
+
+	c.Assert(v.Scan(int64(7)), IsNil)
+	c.Assert(v.IsNumeric(), IsTrue)
+	c.Assert(v.String(), Equals, "7")
+
+	c.Assert(v.Scan("abc"), IsNil)
+	c.Assert(v.IsUtf8String(), IsTrue)
+
+	c.Assert(v.Scan(nil), IsNil)
+	c.Assert(v.IsNull(), IsTrue)
+
+	var _ sql.Scanner = &v
+	var _ driver.Valuer = v
+}
+
+func (s *DriverSuite) TestConvertAssignNullTypes(c *C) {
+	var i sql.NullInt64
+	c.Assert(ConvertAssign(MakeNumeric([]byte("12")), &i), IsNil)
+	c.Assert(i, Equals, sql.NullInt64{Int64: 12, Valid: true})
+	c.Assert(ConvertAssign(NULL, &i), IsNil)
+	c.Assert(i.Valid, IsFalse)
+
+	var f sql.NullFloat64
+	c.Assert(ConvertAssign(MakeFractional([]byte("1.5")), &f), IsNil)
+	c.Assert(f, Equals, sql.NullFloat64{Float64: 1.5, Valid: true})
+
+	var b sql.NullBool
+	c.Assert(ConvertAssign(MakeNumeric([]byte("1")), &b), IsNil)
+	c.Assert(b, Equals, sql.NullBool{Bool: true, Valid: true})
+
+	var str sql.NullString
+	c.Assert(ConvertAssign(MakeString([]byte("abc")), &str), IsNil)
+	c.Assert(str, Equals, sql.NullString{String: "abc", Valid: true})
+
+	var t sql.NullTime
+	ts := time.Date(2012, time.February, 24, 23, 19, 43, 10000, time.UTC)
+	v, err := BuildValue(ts)
+	c.Assert(err, IsNil)
+	c.Assert(ConvertAssign(v, &t), IsNil)
+	c.Assert(t.Valid, IsTrue)
+	c.Assert(t.Time.Equal(ts), IsTrue)
+	c.Assert(ConvertAssign(NULL, &t), IsNil)
+	c.Assert(t.Valid, IsFalse)
+	c.Assert(ConvertAssign(MakeNumeric([]byte("1")), &t), NotNil)
+}
+
+func (s *DriverSuite) TestConvertAssignScanner(c *C) {
+	scanner := &testScanner{}
+	c.Assert(ConvertAssign(MakeNumeric([]byte("3")), scanner), IsNil)
+	c.Assert(scanner.src, Equals, int64(3))
+
+	c.Assert(ConvertAssign(NULL, scanner), IsNil)
+	c.Assert(scanner.src, IsNil)
+
+	var v Value
+	c.Assert(ConvertAssign(MakeFractional([]byte("1.50")), &v), IsNil)
+	c.Assert(v.IsFractional(), IsTrue)
+	c.Assert(v.String(), Equals, "1.50")
+}
@@ -10,6 +10,9 @@ package sqltypes
 
 import (
 	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
 	"encoding/base64"
 	"encoding/binary"
 	"reflect"
@@ -198,7 +201,8 @@ func (v Value) IsString() (ok bool) {
 func (v Value) IsUtf8String() (ok bool) {
 	_ = String{} // compiler bug work-around
 	if v.Inner != nil {
-		s, ok := v.Inner.(String)
+		var s String
+		s, ok = v.Inner.(String)
 		ok = ok && s.isUtf8
 	}
 	return ok
@@ -224,6 +228,10 @@ func BuildValue(goval interface{}) (v Value, err error) {
 		v = Value{Numeric(strconv.AppendInt(nil, int64(val), 10))}
 	case int:
 		v = Value{Numeric(strconv.AppendInt(nil, int64(bindVal), 10))}
+	case int8:
+		v = Value{Numeric(strconv.AppendInt(nil, int64(bindVal), 10))}
+	case int16:
+		v = Value{Numeric(strconv.AppendInt(nil, int64(bindVal), 10))}
 	case int32:
 		v = Value{Numeric(strconv.AppendInt(nil, int64(bindVal), 10))}
 	case int64:
@@ -232,10 +240,14 @@ func BuildValue(goval interface{}) (v Value, err error) {
 		v = Value{Numeric(strconv.AppendUint(nil, uint64(bindVal), 10))}
 	case uint8:
 		v = Value{Numeric(strconv.AppendUint(nil, uint64(bindVal), 10))}
+	case uint16:
+		v = Value{Numeric(strconv.AppendUint(nil, uint64(bindVal), 10))}
 	case uint32:
 		v = Value{Numeric(strconv.AppendUint(nil, uint64(bindVal), 10))}
 	case uint64:
 		v = Value{Numeric(strconv.AppendUint(nil, uint64(bindVal), 10))}
+	case float32:
+		v = Value{Fractional(strconv.AppendFloat(nil, float64(bindVal), 'f', -1, 32))}
 	case float64:
 		v = Value{Fractional(strconv.AppendFloat(nil, bindVal, 'f', -1, 64))}
 	case string:
@@ -244,10 +256,30 @@ func BuildValue(goval interface{}) (v Value, err error) {
 		v = Value{String{bindVal, false}}
 	case time.Time:
 		v = Value{String{[]byte(bindVal.Format("2006-01-02 15:04:05.000000")), true}}
+	case *time.Time:
+		// Handled explicitly since *time.Time is a TextMarshaler.
+		if bindVal != nil {
+			return BuildValue(*bindVal)
+		}
 	case Numeric, Fractional, String:
 		v = Value{bindVal.(InnerValue)}
 	case Value:
 		v = bindVal
+	case *Value:
+		if bindVal != nil {
+			v = *bindVal
+		}
+	case driver.Valuer:
+		return buildValuerValue(bindVal)
+	case encoding.TextMarshaler:
+		if isNilPointer(bindVal) {
+			return NULL, nil
+		}
+		text, err := bindVal.MarshalText()
+		if err != nil {
+			return Value{}, errors.Wrapf(err, "Failed to marshal %T", goval)
+		}
+		v = Value{String{text, true}}
 	default:
 		// Check if v is a pointer.
 		rv := reflect.ValueOf(goval)
@@ -257,6 +289,27 @@ func BuildValue(goval interface{}) (v Value, err error) {
 			}
 			return BuildValue(reflect.Indirect(rv).Interface())
 		}
+
+		// Named types (e.g., type UserId int64) are built from their
+		// underlying kind.
+		switch rv.Kind() {
+		case reflect.Bool:
+			return BuildValue(rv.Bool())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return BuildValue(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return BuildValue(rv.Uint())
+		case reflect.Float32:
+			return BuildValue(float32(rv.Float()))
+		case reflect.Float64:
+			return BuildValue(rv.Float())
+		case reflect.String:
+			return BuildValue(rv.String())
+		case reflect.Slice:
+			if rv.Type().Elem().Kind() == reflect.Uint8 {
+				return BuildValue(rv.Bytes())
+			}
+		}
 		return Value{}, errors.Newf("Unsupported bind variable type %T: %v", goval, goval)
 	}
 	return v, nil
@@ -321,43 +374,35 @@ func ConvertAssignRow(row []Value, dest ...interface{}) error {
 // a pointer type.
 // Note that for anything else than *[]byte the value is copied, however if 'dest'
 // is of type *[]byte it will point to same []byte array as 'src.Raw()' (no copying).
+// sql.Scanner destinations (including the sql.Null* types) are populated via
+// Scan, and may accept null sources.
 func ConvertAssign(src Value, dest interface{}) error {
-	// TODO(zviad): reflecting might be too slow so common cases
-	// can probably be handled without reflections
-	var s String
+	// Common destination types are decoded without reflection.
+	if handled, err := convertAssignFast(src, dest); handled {
+		return err
+	}
+
 	var n Numeric
 	var f Fractional
 	var ok bool
 	var err error
 
-	if src.Inner == nil {
-		return errors.Newf("source is null")
-	}
-
 	switch d := dest.(type) {
-	case *string:
-		if s, ok = src.Inner.(String); !ok {
-			return errors.Newf("source: '%v' is not String", src)
-		}
-		*d = string(s.raw())
+	case *Value:
+		*d = src
 		return nil
-	case *[]byte:
-		if s, ok = src.Inner.(String); !ok {
-			return errors.Newf("source: '%v' is not String", src)
+	case *sql.NullTime:
+		return scanNullTime(src, d)
+	case sql.Scanner:
+		driverValue, err := src.Value()
+		if err != nil {
+			return err
 		}
-		*d = s.raw()
-		return nil
-		// TODO(zviad): figure out how to do this without reflections
-		// because I think reflections are slow?
-		//case *int, *int8, *int16, *int32, *int64:
-		//    if n, ok := src.Inner.(Numeric); !ok {
-		//        return errors.Newf("source: %v is not Numeric", src)
-		//    }
-		//	if i64, err := strconv.ParseInt(string(n.raw()), 10, 64); err != nil {
-		//		return err
-		//	}
-		//    *d = i64
-		//	return nil
+		return d.Scan(driverValue)
+	}
+
+	if src.Inner == nil {
+		return errors.Newf("source is null")
 	}
 
 	dpv := reflect.ValueOf(dest)
@@ -229,11 +229,13 @@ func (s *SqlTypesSuite) TestBuildValue(c *C) {
 	v, err = BuildValue("abcd")
 	c.Assert(err, IsNil)
 	c.Assert(v.IsString(), IsTrue)
+	c.Assert(v.IsUtf8String(), IsTrue)
 	c.Assert(v.String(), Equals, "abcd")
 
 	v, err = BuildValue([]byte("abcd"))
 	c.Assert(err, IsNil)
 	c.Assert(v.IsString(), IsTrue)
+	c.Assert(v.IsUtf8String(), IsFalse)
 	c.Assert(v.String(), Equals, "abcd")
 
 	err = ConvertAssign(v, &n64)
@@ -260,7 +262,9 @@ func (s *SqlTypesSuite) TestBuildValue(c *C) {
 	c.Assert(v.String(), Equals, "abc")
 
 	v, err = BuildValue(float32(1.23))
-	c.Assert(err, NotNil)
+	c.Assert(err, IsNil)
+	c.Assert(v.IsFractional(), IsTrue)
+	c.Assert(v.String(), Equals, "1.23")
 
 	v1 := MakeString([]byte("ab"))
 	v, err = BuildValue(v1)
@@ -268,7 +272,7 @@ func (s *SqlTypesSuite) TestBuildValue(c *C) {
 	c.Assert(v.IsString(), IsTrue)
 	c.Assert(v.String(), Equals, "ab")
 
-	v, err = BuildValue(float32(1.23))
+	v, err = BuildValue(complex(1, 2))
 	c.Assert(err, NotNil)
 }
 
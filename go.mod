@@ -1,13 +1,20 @@
 module github.com/dropbox/godropbox
 
-go 1.13
+go 1.18
 
 require (
 	github.com/davecgh/go-spew v1.1.1
 	github.com/gogo/protobuf v1.3.1
-	github.com/kr/pretty v0.2.0 // indirect
+	github.com/mattn/go-sqlite3 v1.14.17
 	github.com/pmezard/go-difflib v1.0.0
 	github.com/stretchr/testify v1.8.0
 	golang.org/x/net v0.0.0-20200202094626-16171245cfb2
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15
 )
+
+require (
+	github.com/kr/pretty v0.2.0 // indirect
+	github.com/kr/text v0.1.0 // indirect
+	golang.org/x/text v0.3.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,215 @@
+package hash2
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/dropbox/godropbox/murmur3"
+)
+
+const (
+	// The number of ring points per unit of weight used by libketama (and
+	// most ketama compatible clients).
+	DefaultKetamaPointsPerWeight = 160
+)
+
+// This returns the ketama hash of the key, i.e., the first 4 bytes of the
+// key's MD5 checksum, in little endian.
+func KetamaHash(key []byte) uint32 {
+	sum := md5.Sum(key)
+	return binary.LittleEndian.Uint32(sum[0:4])
+}
+
+// This returns the 32-bit murmur3 hash of the key (with seed 0).
+func Murmur3Hash(key []byte) uint32 {
+	return murmur3.Hash32(key, 0)
+}
+
+// A node (e.g., a server address) in the ketama ring.
+type KetamaNode struct {
+	// The node's name, which determines the node's ring points.
+	Name string
+
+	// The node's relative weight.  Non-positive weights are treated as 1.
+	Weight int
+}
+
+type ketamaPoint struct {
+	hash uint32
+	node int
+}
+
+// KetamaRing is a ring-based consistent hash (see
+// https://www.last.fm/user/RJ/journal/2007/04/10/rz_libketama_-_a_consistent_hashing_algo_for_memcache_clients
+// for details).  Each node is assigned a number of pseudorandom points on the
+// ring proportional to its weight, and keys are mapped to the node owning the
+// first point at or after the key's hash (wrapping around).  Adding or
+// removing a node only moves the keys adjacent to that node's points.
+//
+// When the hash function is nil, the ring is compatible with libketama: each
+// node has floor(weight / totalWeight * pointsPerWeight / 4 * numNodes) * 4
+// points (i.e., pointsPerWeight * numNodes points in total, split by weight),
+// which are generated from the MD5 checksum of "<name>-<i>" (4 points per
+// checksum), and keys are hashed using KetamaHash.  Otherwise, each node has
+// weight * pointsPerWeight points, and each point is generated by hashing
+// "<name>-<i>" with the hash function (the same as libmemcached's non-MD5
+// ketama mode).
+//
+// KetamaRing is immutable, and is safe for concurrent use.
+type KetamaRing struct {
+	points   []ketamaPoint
+	numNodes int
+	hashFunc func(key []byte) uint32
+}
+
+// This creates a ketama ring for the given nodes.  pointsPerWeight
+// defaults to DefaultKetamaPointsPerWeight when non-positive.
+func NewKetamaRing(
+	nodes []KetamaNode,
+	pointsPerWeight int,
+	hashFunc func(key []byte) uint32) *KetamaRing {
+
+	if pointsPerWeight <= 0 {
+		pointsPerWeight = DefaultKetamaPointsPerWeight
+	}
+
+	ring := &KetamaRing{
+		numNodes: len(nodes),
+		hashFunc: hashFunc,
+	}
+	if ring.hashFunc == nil {
+		ring.hashFunc = KetamaHash
+	}
+
+	totalWeight := 0
+	for _, node := range nodes {
+		totalWeight += ketamaWeight(node)
+	}
+
+	for i, node := range nodes {
+		weight := ketamaWeight(node)
+		numPoints := weight * pointsPerWeight
+
+		if hashFunc == nil {
+			numPoints = libketamaNumPoints(
+				weight,
+				totalWeight,
+				pointsPerWeight,
+				len(nodes))
+
+			for j := 0; j*4 < numPoints; j++ {
+				sum := md5.Sum([]byte(node.Name + "-" + strconv.Itoa(j)))
+				for k := 0; k < 4 && j*4+k < numPoints; k++ {
+					ring.points = append(
+						ring.points,
+						ketamaPoint{
+							hash: binary.LittleEndian.Uint32(sum[k*4 : k*4+4]),
+							node: i,
+						})
+				}
+			}
+		} else {
+			for j := 0; j < numPoints; j++ {
+				ring.points = append(
+					ring.points,
+					ketamaPoint{
+						hash: hashFunc([]byte(node.Name + "-" + strconv.Itoa(j))),
+						node: i,
+					})
+			}
+		}
+	}
+
+	// Ties are broken by node name (instead of node index) so that the
+	// mapping does not depend on the nodes' ordering.
+	sort.Slice(ring.points, func(a, b int) bool {
+		pa, pb := ring.points[a], ring.points[b]
+		if pa.hash != pb.hash {
+			return pa.hash < pb.hash
+		}
+		return nodes[pa.node].Name < nodes[pb.node].Name
+	})
+
+	return ring
+}
+
+func ketamaWeight(node KetamaNode) int {
+	if node.Weight <= 0 {
+		return 1
+	}
+	return node.Weight
+}
+
+// Returns the node's number of points, computed the same way as libketama
+// (including its float precision).
+func libketamaNumPoints(
+	weight int,
+	totalWeight int,
+	pointsPerWeight int,
+	numNodes int) int {
+
+	pct := float32(weight) / float32(totalWeight)
+	checksums := float32(
+		float64(pct) * (float64(pointsPerWeight) / 4) * float64(numNodes))
+	return int(math.Floor(float64(checksums))) * 4
+}
+
+// Returns the position of the first point owning the key.
+func (r *KetamaRing) search(key []byte) int {
+	hash := r.hashFunc(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return i
+}
+
+// This returns the index (into the nodes used to construct the ring) of the
+// node which owns the key.  Returns -1 if the ring is empty.
+func (r *KetamaRing) Get(key []byte) int {
+	if len(r.points) == 0 {
+		return -1
+	}
+	return r.points[r.search(key)].node
+}
+
+// This returns the indices of up to n distinct nodes for the key, in ring
+// order (i.e., the first index is the same as Get's result).  The nodes
+// following the owner on the ring are the natural replica locations, since
+// they are the nodes which take over the key when the owner is removed.
+func (r *KetamaRing) GetReplicas(key []byte, n int) []int {
+	if len(r.points) == 0 || n <= 0 {
+		return nil
+	}
+	if n > r.numNodes {
+		n = r.numNodes
+	}
+
+	result := make([]int, 0, n)
+	start := r.search(key)
+	for i := 0; i < len(r.points) && len(result) < n; i++ {
+		node := r.points[(start+i)%len(r.points)].node
+
+		seen := false
+		for _, existing := range result {
+			if existing == node {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// This returns the total number of points on the ring.
+func (r *KetamaRing) NumPoints() int {
+	return len(r.points)
+}
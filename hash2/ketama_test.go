@@ -0,0 +1,135 @@
+package hash2
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"strconv"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+)
+
+type KetamaSuite struct {
+}
+
+var _ = Suite(&KetamaSuite{})
+
+func ketamaNodes(names ...string) []KetamaNode {
+	nodes := make([]KetamaNode, len(names))
+	for i, name := range names {
+		nodes[i] = KetamaNode{Name: name, Weight: 1}
+	}
+	return nodes
+}
+
+func (s *KetamaSuite) TestKetamaHash(c *C) {
+	sum := md5.Sum([]byte("foo"))
+	c.Assert(KetamaHash([]byte("foo")), Equals, binary.LittleEndian.Uint32(sum[:4]))
+}
+
+func (s *KetamaSuite) TestEmptyRing(c *C) {
+	ring := NewKetamaRing(nil, 0, nil)
+	c.Assert(ring.NumPoints(), Equals, 0)
+	c.Assert(ring.Get([]byte("foo")), Equals, -1)
+}
+
+func (s *KetamaSuite) TestNumPoints(c *C) {
+	nodes := ketamaNodes("a:11211", "b:11211")
+	nodes[1].Weight = 3
+
+	// libketama splits 160 points per node by weight, i.e., 80 and 240.
+	ring := NewKetamaRing(nodes, 0, nil)
+	c.Assert(ring.NumPoints(), Equals, 2*DefaultKetamaPointsPerWeight)
+
+	// floor(1/4 * 40 * 3) = 30 and floor(2/4 * 40 * 3) = 60 checksums.
+	nodes = ketamaNodes("a:11211", "b:11211", "c:11211")
+	nodes[2].Weight = 2
+	ring = NewKetamaRing(nodes, 0, nil)
+	c.Assert(ring.NumPoints(), Equals, (30+30+60)*4)
+
+	ring = NewKetamaRing(nodes, 10, Murmur3Hash)
+	c.Assert(ring.NumPoints(), Equals, 40)
+}
+
+func (s *KetamaSuite) TestDistribution(c *C) {
+	for _, hashFunc := range []func([]byte) uint32{nil, Murmur3Hash} {
+		nodes := ketamaNodes("a:11211", "b:11211", "c:11211", "d:11211")
+		nodes[3].Weight = 2
+
+		ring := NewKetamaRing(nodes, 0, hashFunc)
+
+		counts := make([]int, len(nodes))
+		for i := 0; i < 50000; i++ {
+			counts[ring.Get([]byte("key"+strconv.Itoa(i)))]++
+		}
+
+		// Each unit of weight should receive roughly 1/5 of the keys.
+		for i, count := range counts {
+			expected := 10000 * nodes[i].Weight
+			c.Assert(count > expected*8/10, IsTrue, Commentf("%v", counts))
+			c.Assert(count < expected*12/10, IsTrue, Commentf("%v", counts))
+		}
+	}
+}
+
+func (s *KetamaSuite) TestMinimalMovement(c *C) {
+	before := NewKetamaRing(
+		ketamaNodes("a:11211", "b:11211", "c:11211", "d:11211"),
+		0,
+		nil)
+	// Same nodes in a different order, with one node removed.
+	after := NewKetamaRing(
+		ketamaNodes("d:11211", "b:11211", "a:11211"),
+		0,
+		nil)
+
+	beforeNames := []string{"a:11211", "b:11211", "c:11211", "d:11211"}
+	afterNames := []string{"d:11211", "b:11211", "a:11211"}
+
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := []byte("key" + strconv.Itoa(i))
+		oldName := beforeNames[before.Get(key)]
+		newName := afterNames[after.Get(key)]
+		if oldName != "c:11211" {
+			// Only keys owned by the removed node should move.
+			c.Assert(newName, Equals, oldName)
+		} else {
+			moved++
+		}
+	}
+
+	c.Assert(moved > 1500 && moved < 3500, IsTrue)
+}
+
+func (s *KetamaSuite) TestGetReplicas(c *C) {
+	nodes := ketamaNodes("a:11211", "b:11211", "c:11211")
+	ring := NewKetamaRing(nodes, 0, nil)
+
+	c.Assert(NewKetamaRing(nil, 0, nil).GetReplicas([]byte("foo"), 2), IsNil)
+
+	for i := 0; i < 100; i++ {
+		key := []byte("key" + strconv.Itoa(i))
+
+		replicas := ring.GetReplicas(key, 2)
+		c.Assert(replicas, HasLen, 2)
+		c.Assert(replicas[0], Equals, ring.Get(key))
+		c.Assert(replicas[1], Not(Equals), replicas[0])
+
+		c.Assert(ring.GetReplicas(key, 5), HasLen, 3)
+	}
+
+	// When the owner is removed, the key moves to its second replica.
+	smaller := NewKetamaRing(
+		ketamaNodes("a:11211", "b:11211"),
+		0,
+		nil)
+	for i := 0; i < 100; i++ {
+		key := []byte("key" + strconv.Itoa(i))
+		replicas := ring.GetReplicas(key, 2)
+		if replicas[0] == 2 {
+			c.Assert(smaller.Get(key), Equals, replicas[1])
+		}
+	}
+}
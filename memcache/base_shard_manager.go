@@ -3,9 +3,12 @@ package memcache
 import (
 	"expvar"
 	"sync"
+	"time"
 
 	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
 	"github.com/dropbox/godropbox/net2"
+	"github.com/dropbox/godropbox/stats"
 )
 
 type MemcachedState int
@@ -35,14 +38,22 @@ type BaseShardManager struct {
 	getShardId (func(key string, numShard int) (shard int))
 	pool       net2.ConnectionPool
 
+	// Optional.  When nil, replicas are placed on the shards following the
+	// key's shard (wrapping around).
+	getReplicaShardIds func(key string, numShard int, numReplicas int) []int
+
 	rwMutex     sync.RWMutex
-	shardStates []ShardState // guarded by rwMutex
+	shardStates []ShardState    // guarded by rwMutex
+	migration   *shardMigration // guarded by rwMutex; nil when not migrating
 
 	logError func(err error)
 	logInfo  func(v ...interface{})
+
+	stats *statsCache // nil when stats are disabled
 }
 
-var _ ShardManager = (*BaseShardManager)(nil)
+var _ ReplicaShardManager = (*BaseShardManager)(nil)
+var _ MigratingShardManager = (*BaseShardManager)(nil)
 
 // Initializes the BaseShardManager.
 func (m *BaseShardManager) Init(
@@ -72,20 +83,56 @@ func (m *BaseShardManager) InitWithPool(
 	m.logInfo = logInfo
 }
 
+// This sets the stats factory used for recording per address connection pool
+// wait time summaries and connection error counters.  This must be called
+// before the shard manager is used.
+func (m *BaseShardManager) SetStatsFactory(factory stats.StatsFactory) {
+	m.stats = newStatsCache(factory)
+}
+
 // This updates the shard manager to use new shard states.
 func (m *BaseShardManager) UpdateShardStates(shardStates []ShardState) {
-	newAddrs := set.NewSet()
-	for _, state := range shardStates {
-		newAddrs.Add(state.Address)
-	}
+	m.updateShardStates(shardStates, nil)
+}
+
+// Same as UpdateShardStates, but also invokes onUpdate (if non-nil) while
+// holding the write lock, which allows derived shard managers to update their
+// shard function's state atomically with the shard states.
+func (m *BaseShardManager) updateShardStates(
+	shardStates []ShardState,
+	onUpdate func()) {
 
 	m.rwMutex.Lock()
 	defer m.rwMutex.Unlock()
 
-	oldAddrs := set.NewSet()
+	oldAddrs := m.registeredAddrsLocked()
+	m.shardStates = shardStates
+	m.updateRegisteredAddrsLocked(oldAddrs)
+
+	if onUpdate != nil {
+		onUpdate()
+	}
+}
+
+// Returns the addresses used by the shard states and the migration target's
+// shard states.  This method assumes that m.rwMutex is locked.
+func (m *BaseShardManager) registeredAddrsLocked() set.Set {
+	addrs := set.NewSet()
 	for _, state := range m.shardStates {
-		oldAddrs.Add(state.Address)
+		addrs.Add(state.Address)
 	}
+	if m.migration != nil {
+		for _, state := range m.migration.target.shardStates {
+			addrs.Add(state.Address)
+		}
+	}
+	return addrs
+}
+
+// (Un)registers the addresses added to / removed from the pool since
+// oldAddrs.  This method assumes that m.rwMutex is locked.
+func (m *BaseShardManager) updateRegisteredAddrsLocked(oldAddrs set.Set) {
+	newAddrs := m.registeredAddrsLocked()
 
 	for address := range set.Subtract(newAddrs, oldAddrs).Iter() {
 		if err := m.pool.Register("tcp", address.(string)); err != nil {
@@ -98,8 +145,6 @@ func (m *BaseShardManager) UpdateShardStates(shardStates []ShardState) {
 			m.logError(err)
 		}
 	}
-
-	m.shardStates = shardStates
 }
 
 // See ShardManager interface for documentation.
@@ -131,6 +176,59 @@ func (m *BaseShardManager) GetShard(
 	return
 }
 
+// See ReplicaShardManager interface for documentation.
+func (m *BaseShardManager) GetReplicaShardIds(
+	key string,
+	numReplicas int) []int {
+
+	m.rwMutex.RLock()
+	defer m.rwMutex.RUnlock()
+
+	numShards := len(m.shardStates)
+	if m.getReplicaShardIds != nil {
+		return m.getReplicaShardIds(key, numShards, numReplicas)
+	}
+
+	shardId := m.getShardId(key, numShards)
+	if shardId == -1 {
+		return nil
+	}
+
+	if numReplicas > numShards {
+		numReplicas = numShards
+	}
+	shardIds := make([]int, 0, numReplicas)
+	for i := 0; i < numReplicas; i++ {
+		shardIds = append(shardIds, (shardId+i)%numShards)
+	}
+	return shardIds
+}
+
+// See ReplicaShardManager interface for documentation.
+func (m *BaseShardManager) GetShardConnection(
+	shardId int) (
+	conn net2.ManagedConn,
+	err error) {
+
+	m.rwMutex.RLock()
+	defer m.rwMutex.RUnlock()
+
+	if shardId < 0 || shardId >= len(m.shardStates) {
+		return nil, errors.Newf("Invalid memcache shard id: %d", shardId)
+	}
+
+	state := m.shardStates[shardId]
+	if state.State != ActiveServer {
+		m.logInfo("Memcache shard ", shardId, " is not in active state.")
+		connSkippedByAddr.Add(state.Address, 1)
+		return nil, nil
+	}
+
+	entry := &ShardMapping{}
+	m.fillEntryWithConnection(state.Address, entry)
+	return entry.Connection, entry.ConnErr
+}
+
 // See ShardManager interface for documentation.
 func (m *BaseShardManager) GetShardsForKeys(
 	keys []string) map[int]*ShardMapping {
@@ -287,7 +385,7 @@ func (m *BaseShardManager) GetAllShards() map[int]net2.ManagedConn {
 	defer m.rwMutex.RUnlock()
 
 	for i, state := range m.shardStates {
-		conn, err := m.pool.Get("tcp", state.Address)
+		conn, err := m.getConnection(state.Address)
 		if err != nil {
 			m.logError(err)
 			conn = nil
@@ -298,8 +396,23 @@ func (m *BaseShardManager) GetAllShards() map[int]net2.ManagedConn {
 	return results
 }
 
-func (m *BaseShardManager) fillEntryWithConnection(address string, entry *ShardMapping) {
+// Gets a connection from the pool, and records the pool wait time.
+func (m *BaseShardManager) getConnection(
+	address string) (
+	net2.ManagedConn,
+	error) {
+
+	start := time.Now()
 	conn, err := m.pool.Get("tcp", address)
+	m.stats.observeLatency(poolWaitMsMetric, address, "", start)
+	if err != nil {
+		m.stats.counter(connErrorsMetric, address, "").Inc()
+	}
+	return conn, err
+}
+
+func (m *BaseShardManager) fillEntryWithConnection(address string, entry *ShardMapping) {
+	conn, err := m.getConnection(address)
 	if err != nil {
 		m.logError(err)
 		connErrByAddr.Add(address, 1)
@@ -0,0 +1,140 @@
+package memcache
+
+import (
+	"log"
+
+	"github.com/dropbox/godropbox/hash2"
+	"github.com/dropbox/godropbox/net2"
+)
+
+// A memcache shard's state, along with its relative weight in the
+// consistent hash ring.
+type WeightedShardState struct {
+	ShardState
+
+	// The shard's relative weight.  Non-positive weights are treated as 1.
+	Weight int
+}
+
+// Options for configuring the consistent hash ring.
+type ConsistentHashOptions struct {
+	// The number of ring points (aka virtual nodes) per unit of weight.  When
+	// HashFunc is nil, this is the average number of points per shard
+	// instead, which are split by weight (see hash2.KetamaRing).  Defaults to
+	// hash2.DefaultKetamaPointsPerWeight.
+	PointsPerWeight int
+
+	// The key hash function.  When nil, the ring is libketama compatible
+	// (MD5 based, including libketama's weighting).  Use hash2.Murmur3Hash for a faster, non-cryptographic
+	// hash (the ring is then libmemcached compatible in its non-MD5 ketama
+	// mode).
+	HashFunc func(key []byte) uint32
+}
+
+// A shard manager which maps keys to shards using a ketama consistent hash
+// ring, built from the shards' addresses and weights.  Unlike the
+// StaticShardManager's modulo sharding, adding or removing a shard only
+// moves the keys owned by that shard.  Shard ids are indices into the most
+// recently provided shard states.
+type ConsistentHashShardManager struct {
+	BaseShardManager
+
+	hashOptions ConsistentHashOptions
+	ring        *hash2.KetamaRing // guarded by BaseShardManager.rwMutex
+}
+
+var _ ReplicaShardManager = (*ConsistentHashShardManager)(nil)
+
+// This creates a ConsistentHashShardManager, which returns connections from
+// the given memcache shards.
+func NewConsistentHashShardManager(
+	shardStates []WeightedShardState,
+	hashOptions ConsistentHashOptions,
+	options net2.ConnectionOptions) *ConsistentHashShardManager {
+
+	manager := &ConsistentHashShardManager{}
+	manager.Init(
+		hashOptions,
+		func(err error) { log.Print(err) },
+		log.Print,
+		net2.NewMultiConnectionPool(options))
+
+	manager.UpdateWeightedShardStates(shardStates)
+
+	return manager
+}
+
+// Initializes the ConsistentHashShardManager.
+func (m *ConsistentHashShardManager) Init(
+	hashOptions ConsistentHashOptions,
+	logError func(err error),
+	logInfo func(v ...interface{}),
+	pool net2.ConnectionPool) {
+
+	m.hashOptions = hashOptions
+	m.ring = hash2.NewKetamaRing(
+		nil,
+		hashOptions.PointsPerWeight,
+		hashOptions.HashFunc)
+
+	m.BaseShardManager.InitWithPool(m.getShardId, logError, logInfo, pool)
+	m.BaseShardManager.getReplicaShardIds = m.getReplicaShardIds
+}
+
+// NOTE: This is only called by BaseShardManager while holding rwMutex.
+func (m *ConsistentHashShardManager) getShardId(key string, numShard int) int {
+	if numShard == 0 {
+		return -1
+	}
+	return m.ring.Get([]byte(key))
+}
+
+// Replicas are placed on the next distinct shards on the ring.  NOTE: This is
+// only called by BaseShardManager while holding rwMutex.
+func (m *ConsistentHashShardManager) getReplicaShardIds(
+	key string,
+	numShard int,
+	numReplicas int) []int {
+
+	if numShard == 0 {
+		return nil
+	}
+	return m.ring.GetReplicas([]byte(key), numReplicas)
+}
+
+// This updates the shard manager to use new shard states, and rebuilds the
+// hash ring.  A shard's ring position is determined by its address (and not
+// by its index), hence reordering shard states does not move any keys.
+func (m *ConsistentHashShardManager) UpdateWeightedShardStates(
+	shardStates []WeightedShardState) {
+
+	states := make([]ShardState, len(shardStates))
+	nodes := make([]hash2.KetamaNode, len(shardStates))
+	for i, state := range shardStates {
+		states[i] = state.ShardState
+		nodes[i] = hash2.KetamaNode{
+			Name:   state.Address,
+			Weight: state.Weight,
+		}
+	}
+
+	ring := hash2.NewKetamaRing(
+		nodes,
+		m.hashOptions.PointsPerWeight,
+		m.hashOptions.HashFunc)
+
+	m.updateShardStates(states, func() { m.ring = ring })
+}
+
+// This updates the shard manager to use new shard states (each shard has a
+// weight of 1), and rebuilds the hash ring.
+func (m *ConsistentHashShardManager) UpdateShardStates(
+	shardStates []ShardState) {
+
+	weighted := make([]WeightedShardState, len(shardStates))
+	for i, state := range shardStates {
+		weighted[i].ShardState = state
+	}
+
+	m.UpdateWeightedShardStates(weighted)
+}
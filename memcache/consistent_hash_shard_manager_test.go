@@ -0,0 +1,143 @@
+package memcache
+
+import (
+	"strconv"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/dropbox/godropbox/container/set"
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/hash2"
+	"github.com/dropbox/godropbox/net2"
+)
+
+type ConsistentHashManagerSuite struct {
+	manager *ConsistentHashShardManager
+	pool    *nilConnPool
+}
+
+var _ = Suite(&ConsistentHashManagerSuite{})
+
+// A mock pool which returns nil connections.
+type nilConnPool struct {
+	*mockPool
+}
+
+func (p *nilConnPool) Get(_, _ string) (net2.ManagedConn, error) {
+	return nil, nil
+}
+
+func (s *ConsistentHashManagerSuite) SetUpTest(c *C) {
+	s.pool = &nilConnPool{newMockPool()}
+	s.manager = &ConsistentHashShardManager{}
+	s.manager.Init(
+		ConsistentHashOptions{},
+		func(err error) { c.Log(err) },
+		c.Log,
+		s.pool)
+}
+
+func weightedStates(addrs ...string) []WeightedShardState {
+	states := make([]WeightedShardState, len(addrs))
+	for i, addr := range addrs {
+		states[i].Address = addr
+		states[i].State = ActiveServer
+		states[i].Weight = 1
+	}
+	return states
+}
+
+func (s *ConsistentHashManagerSuite) shardAddrs(
+	keys []string) map[string]string {
+
+	result := make(map[string]string)
+	for shardId, mapping := range s.manager.GetShardsForKeys(keys) {
+		for _, key := range mapping.Keys {
+			result[key] = s.manager.shardStates[shardId].Address
+		}
+	}
+	return result
+}
+
+func testKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func (s *ConsistentHashManagerSuite) TestEmpty(c *C) {
+	shardId, conn, err := s.manager.GetShard("foo")
+	c.Assert(shardId, Equals, -1)
+	c.Assert(conn, IsNil)
+	c.Assert(err, IsNil)
+}
+
+func (s *ConsistentHashManagerSuite) TestRegister(c *C) {
+	s.manager.UpdateWeightedShardStates(weightedStates("a", "b", "c"))
+	c.Assert(set.NewSet("a", "b", "c").IsEqual(s.pool.registered), IsTrue)
+
+	s.manager.UpdateShardStates([]ShardState{{Address: "b"}})
+	c.Assert(set.NewSet("b").IsEqual(s.pool.registered), IsTrue)
+}
+
+func (s *ConsistentHashManagerSuite) TestMatchesRing(c *C) {
+	states := weightedStates("a:11211", "b:11211", "c:11211")
+	states[2].Weight = 2
+	s.manager.UpdateWeightedShardStates(states)
+
+	ring := hash2.NewKetamaRing(
+		[]hash2.KetamaNode{
+			{Name: "a:11211", Weight: 1},
+			{Name: "b:11211", Weight: 1},
+			{Name: "c:11211", Weight: 2},
+		},
+		0,
+		nil)
+
+	for _, key := range testKeys(100) {
+		shardId, _, err := s.manager.GetShard(key)
+		c.Assert(err, IsNil)
+		c.Assert(shardId, Equals, ring.Get([]byte(key)))
+	}
+}
+
+func (s *ConsistentHashManagerSuite) TestMinimalMovement(c *C) {
+	keys := testKeys(5000)
+
+	s.manager.UpdateWeightedShardStates(
+		weightedStates("a:11211", "b:11211", "c:11211"))
+	before := s.shardAddrs(keys)
+
+	// Add a shard, and reorder the existing shards.
+	s.manager.UpdateWeightedShardStates(
+		weightedStates("c:11211", "d:11211", "a:11211", "b:11211"))
+	after := s.shardAddrs(keys)
+
+	moved := 0
+	for _, key := range keys {
+		if before[key] != after[key] {
+			c.Assert(after[key], Equals, "d:11211")
+			moved++
+		}
+	}
+	c.Assert(moved > 0 && moved < len(keys)/2, IsTrue)
+}
+
+func (s *ConsistentHashManagerSuite) TestInactiveShard(c *C) {
+	states := weightedStates("a:11211", "b:11211")
+	states[1].State = DownServer
+	s.manager.UpdateWeightedShardStates(states)
+
+	for _, key := range testKeys(100) {
+		shardId, conn, err := s.manager.GetShard(key)
+		c.Assert(err, IsNil)
+		c.Assert(conn, IsNil)
+		c.Assert(shardId == 0 || shardId == 1, IsTrue)
+	}
+
+	mappings := s.manager.GetShardsForKeys(testKeys(100))
+	c.Assert(mappings, HasLen, 2)
+	c.Assert(mappings[1].Connection, IsNil)
+}
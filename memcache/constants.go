@@ -24,8 +24,16 @@ const (
 	StatusItemNotStored
 	StatusIncrDecrOnNonNumericValue
 	StatusVbucketBelongsToAnotherServer // Not used
-	StatusAuthenticationError           // Not used
-	StatusAuthenticationContinue        // Not used
+	StatusAuthenticationError
+	StatusAuthenticationContinue
+)
+
+// The SASL statuses returned by memcached (which differ from the protocol
+// spec's values above).  The client translates them to
+// StatusAuthenticationError / StatusAuthenticationContinue.
+const (
+	statusSASLAuthError    ResponseStatus = 0x20
+	statusSASLAuthContinue ResponseStatus = 0x21
 )
 
 const (
@@ -72,15 +80,18 @@ const (
 	opAppendQ    // Unsupported
 	opPrependQ   // Unsupported
 	opVerbosity
-	opTouch // Unsupported
-	opGAT   // Unsupported
-	opGATQ  // Unsupported
+	opTouch
+	opGAT
+	opGATQ // Unsupported
+)
+
+const (
+	opSASLListMechs opCode = 0x20 + iota
+	opSASLAuth
+	opSASLStep // Unsupported
 )
 
 // More unsupported opcodes:
-//   0x20     SASL list mechs
-//   0x21     SASL Auth
-//   0x22     SASL Step
 //   0x30     RGet
 //   0x31     RSet
 //   0x32     RSetQ
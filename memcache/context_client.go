@@ -0,0 +1,513 @@
+package memcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/dropbox/godropbox/net2"
+)
+
+// A Client which also provides context-aware variants of the key / value
+// operations (admin operations, i.e., Flush, Stat, Version and Verbosity,
+// do not take a context).  The context's deadline is applied to the
+// operation's socket I/O, and in-flight operations are interrupted when the
+// context is done.  An interrupted operation leaves its connection in invalid
+// state (i.e., ClientShard.IsValidState returns false, and the sharded client
+// discards the connection), since the connection may still have a partially
+// read response.
+//
+// See Client interface for the operations' documentation.
+type ContextClient interface {
+	Client
+
+	GetCtx(ctx context.Context, key string) GetResponse
+
+	GetMultiCtx(ctx context.Context, keys []string) map[string]GetResponse
+
+	GetSentinelsCtx(ctx context.Context, keys []string) map[string]GetResponse
+
+	GetAndTouchCtx(
+		ctx context.Context,
+		key string,
+		expiration uint32) GetResponse
+
+	GetAndTouchMultiCtx(
+		ctx context.Context,
+		keys []string,
+		expiration uint32) map[string]GetResponse
+
+	TouchCtx(ctx context.Context, key string, expiration uint32) MutateResponse
+
+	SetCtx(ctx context.Context, item *Item) MutateResponse
+
+	SetMultiCtx(ctx context.Context, items []*Item) []MutateResponse
+
+	SetSentinelsCtx(ctx context.Context, items []*Item) []MutateResponse
+
+	CasMultiCtx(ctx context.Context, items []*Item) []MutateResponse
+
+	CasSentinelsCtx(ctx context.Context, items []*Item) []MutateResponse
+
+	AddCtx(ctx context.Context, item *Item) MutateResponse
+
+	AddMultiCtx(ctx context.Context, items []*Item) []MutateResponse
+
+	ReplaceCtx(ctx context.Context, item *Item) MutateResponse
+
+	DeleteCtx(ctx context.Context, key string) MutateResponse
+
+	DeleteMultiCtx(ctx context.Context, keys []string) []MutateResponse
+
+	AppendCtx(ctx context.Context, key string, value []byte) MutateResponse
+
+	PrependCtx(ctx context.Context, key string, value []byte) MutateResponse
+
+	IncrementCtx(
+		ctx context.Context,
+		key string,
+		delta uint64,
+		initValue uint64,
+		expiration uint32) CountResponse
+
+	DecrementCtx(
+		ctx context.Context,
+		key string,
+		delta uint64,
+		initValue uint64,
+		expiration uint32) CountResponse
+}
+
+// Implemented by net.Conn.
+type deadlineSetter interface {
+	SetDeadline(t time.Time) error
+}
+
+// Returns the function used for setting the channel's deadline, or nil if
+// the channel does not support deadlines.  NOTE: net2.ManagedConn disables
+// SetDeadline, hence its deadline limit is used instead.
+func channelDeadlineFunc(channel interface{}) func(t time.Time) error {
+	if limiter, ok := channel.(net2.DeadlineLimiter); ok {
+		return limiter.SetDeadlineLimit
+	}
+	if conn, ok := channel.(deadlineSetter); ok {
+		return conn.SetDeadline
+	}
+	return nil
+}
+
+// A sync.Locker which does nothing.  The raw clients' context-aware
+// operations run on copies of the clients with this locker, since the
+// operations already hold the clients' mutexes.
+type noOpLocker struct{}
+
+func (noOpLocker) Lock()   {}
+func (noOpLocker) Unlock() {}
+
+// Returns an error if the context is already done.
+func checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "Memcache request not sent")
+	}
+	return nil
+}
+
+// Runs op with the context's deadline applied to the channel (when the
+// channel supports deadlines), and interrupts op's socket I/O when the
+// context is done.  If op was interrupted, or the channel's deadline could
+// not be reset afterward, invalidate is called.  An error is returned if op
+// was interrupted or not run.
+func runWithContext(
+	ctx context.Context,
+	channel interface{},
+	op func(),
+	invalidate func()) error {
+
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	setDeadline := channelDeadlineFunc(channel)
+	deadline, hasDeadline := ctx.Deadline()
+	if setDeadline == nil || (!hasDeadline && ctx.Done() == nil) {
+		op()
+		return nil
+	}
+
+	if hasDeadline {
+		if err := setDeadline(deadline); err != nil {
+			return errors.Wrap(err, "Failed to set memcache request deadline")
+		}
+	}
+
+	stop := make(chan struct{})
+	watcherDone := make(chan struct{})
+	interrupted := false
+	var interruptErr error
+	go func() {
+		defer close(watcherDone)
+
+		select {
+		case <-ctx.Done():
+			interrupted = true
+			// Unblock any pending read / write.
+			interruptErr = setDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	op()
+
+	close(stop)
+	<-watcherDone
+
+	if interrupted {
+		invalidate()
+		if interruptErr != nil {
+			return errors.Wrapf(
+				interruptErr,
+				"Memcache request not interrupted (%s)",
+				ctx.Err())
+		}
+		return errors.Wrap(ctx.Err(), "Memcache request interrupted")
+	}
+
+	if err := setDeadline(time.Time{}); err != nil {
+		// The operation succeeded, but the connection should not be reused
+		// with a stale deadline.
+		invalidate()
+	}
+	return nil
+}
+
+func itemKeys(items []*Item) []string {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		if item != nil {
+			keys[i] = item.Key
+		}
+	}
+	return keys
+}
+
+func getErrorResponses(keys []string, err error) map[string]GetResponse {
+	responses := make(map[string]GetResponse, len(keys))
+	for _, key := range keys {
+		responses[key] = NewGetErrorResponse(key, err)
+	}
+	return responses
+}
+
+func mutateErrorResponses(keys []string, err error) []MutateResponse {
+	responses := make([]MutateResponse, len(keys))
+	for i, key := range keys {
+		responses[i] = NewMutateErrorResponse(key, err)
+	}
+	return responses
+}
+
+// Implements ContextClient's context-aware operations on top of the client's
+// plain operations.  Each operation is executed through run, which is
+// responsible for applying the context to the operation.
+type contextOps struct {
+	client Client
+	run    func(ctx context.Context, op func(client Client)) error
+}
+
+// Returns contextOps which only check the context before running each
+// operation (i.e., operations are not interrupted).
+func newCheckOnlyContextOps(client Client) contextOps {
+	return contextOps{
+		client: client,
+		run: func(ctx context.Context, op func(client Client)) error {
+			if err := checkContext(ctx); err != nil {
+				return err
+			}
+			op(client)
+			return nil
+		},
+	}
+}
+
+// Returns the client as a ContextClient.  Clients which do not implement
+// ContextClient only check the context before running each operation.
+func asContextClient(client Client) ContextClient {
+	if ctxClient, ok := client.(ContextClient); ok {
+		return ctxClient
+	}
+
+	return &checkOnlyContextClient{
+		Client:     client,
+		contextOps: newCheckOnlyContextOps(client),
+	}
+}
+
+type checkOnlyContextClient struct {
+	Client
+	contextOps
+}
+
+func (c contextOps) GetCtx(ctx context.Context, key string) GetResponse {
+	var resp GetResponse
+	err := c.run(ctx, func(client Client) { resp = client.Get(key) })
+	if err != nil {
+		return NewGetErrorResponse(key, err)
+	}
+	return resp
+}
+
+func (c contextOps) GetMultiCtx(
+	ctx context.Context,
+	keys []string) map[string]GetResponse {
+
+	var resp map[string]GetResponse
+	err := c.run(ctx, func(client Client) { resp = client.GetMulti(keys) })
+	if err != nil {
+		return getErrorResponses(keys, err)
+	}
+	return resp
+}
+
+func (c contextOps) GetSentinelsCtx(
+	ctx context.Context,
+	keys []string) map[string]GetResponse {
+
+	var resp map[string]GetResponse
+	err := c.run(ctx, func(client Client) { resp = client.GetSentinels(keys) })
+	if err != nil {
+		return getErrorResponses(keys, err)
+	}
+	return resp
+}
+
+func (c contextOps) GetAndTouchCtx(
+	ctx context.Context,
+	key string,
+	expiration uint32) GetResponse {
+
+	var resp GetResponse
+	err := c.run(
+		ctx,
+		func(client Client) { resp = client.GetAndTouch(key, expiration) })
+	if err != nil {
+		return NewGetErrorResponse(key, err)
+	}
+	return resp
+}
+
+func (c contextOps) GetAndTouchMultiCtx(
+	ctx context.Context,
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	var resp map[string]GetResponse
+	err := c.run(
+		ctx,
+		func(client Client) {
+			resp = client.GetAndTouchMulti(keys, expiration)
+		})
+	if err != nil {
+		return getErrorResponses(keys, err)
+	}
+	return resp
+}
+
+func (c contextOps) ctxMutate(
+	ctx context.Context,
+	key string,
+	mutateFunc func(Client) MutateResponse) MutateResponse {
+
+	var resp MutateResponse
+	err := c.run(ctx, func(client Client) { resp = mutateFunc(client) })
+	if err != nil {
+		return NewMutateErrorResponse(key, err)
+	}
+	return resp
+}
+
+func (c contextOps) ctxMutateMulti(
+	ctx context.Context,
+	keys []string,
+	mutateMultiFunc func(Client) []MutateResponse) []MutateResponse {
+
+	var resp []MutateResponse
+	err := c.run(ctx, func(client Client) { resp = mutateMultiFunc(client) })
+	if err != nil {
+		return mutateErrorResponses(keys, err)
+	}
+	return resp
+}
+
+func (c contextOps) TouchCtx(
+	ctx context.Context,
+	key string,
+	expiration uint32) MutateResponse {
+
+	return c.ctxMutate(
+		ctx,
+		key,
+		func(client Client) MutateResponse {
+			return client.Touch(key, expiration)
+		})
+}
+
+func (c contextOps) SetCtx(ctx context.Context, item *Item) MutateResponse {
+	return c.ctxMutate(
+		ctx,
+		itemKeys([]*Item{item})[0],
+		func(client Client) MutateResponse { return client.Set(item) })
+}
+
+func (c contextOps) SetMultiCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.ctxMutateMulti(
+		ctx,
+		itemKeys(items),
+		func(client Client) []MutateResponse { return client.SetMulti(items) })
+}
+
+func (c contextOps) SetSentinelsCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.ctxMutateMulti(
+		ctx,
+		itemKeys(items),
+		func(client Client) []MutateResponse {
+			return client.SetSentinels(items)
+		})
+}
+
+func (c contextOps) CasMultiCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.ctxMutateMulti(
+		ctx,
+		itemKeys(items),
+		func(client Client) []MutateResponse { return client.CasMulti(items) })
+}
+
+func (c contextOps) CasSentinelsCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.ctxMutateMulti(
+		ctx,
+		itemKeys(items),
+		func(client Client) []MutateResponse {
+			return client.CasSentinels(items)
+		})
+}
+
+func (c contextOps) AddCtx(ctx context.Context, item *Item) MutateResponse {
+	return c.ctxMutate(
+		ctx,
+		itemKeys([]*Item{item})[0],
+		func(client Client) MutateResponse { return client.Add(item) })
+}
+
+func (c contextOps) AddMultiCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.ctxMutateMulti(
+		ctx,
+		itemKeys(items),
+		func(client Client) []MutateResponse { return client.AddMulti(items) })
+}
+
+func (c contextOps) ReplaceCtx(ctx context.Context, item *Item) MutateResponse {
+	return c.ctxMutate(
+		ctx,
+		itemKeys([]*Item{item})[0],
+		func(client Client) MutateResponse { return client.Replace(item) })
+}
+
+func (c contextOps) DeleteCtx(ctx context.Context, key string) MutateResponse {
+	return c.ctxMutate(
+		ctx,
+		key,
+		func(client Client) MutateResponse { return client.Delete(key) })
+}
+
+func (c contextOps) DeleteMultiCtx(
+	ctx context.Context,
+	keys []string) []MutateResponse {
+
+	return c.ctxMutateMulti(
+		ctx,
+		keys,
+		func(client Client) []MutateResponse {
+			return client.DeleteMulti(keys)
+		})
+}
+
+func (c contextOps) AppendCtx(
+	ctx context.Context,
+	key string,
+	value []byte) MutateResponse {
+
+	return c.ctxMutate(
+		ctx,
+		key,
+		func(client Client) MutateResponse { return client.Append(key, value) })
+}
+
+func (c contextOps) PrependCtx(
+	ctx context.Context,
+	key string,
+	value []byte) MutateResponse {
+
+	return c.ctxMutate(
+		ctx,
+		key,
+		func(client Client) MutateResponse {
+			return client.Prepend(key, value)
+		})
+}
+
+func (c contextOps) ctxCount(
+	ctx context.Context,
+	key string,
+	countFunc func(Client) CountResponse) CountResponse {
+
+	var resp CountResponse
+	err := c.run(ctx, func(client Client) { resp = countFunc(client) })
+	if err != nil {
+		return NewCountErrorResponse(key, err)
+	}
+	return resp
+}
+
+func (c contextOps) IncrementCtx(
+	ctx context.Context,
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.ctxCount(
+		ctx,
+		key,
+		func(client Client) CountResponse {
+			return client.Increment(key, delta, initValue, expiration)
+		})
+}
+
+func (c contextOps) DecrementCtx(
+	ctx context.Context,
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.ctxCount(
+		ctx,
+		key,
+		func(client Client) CountResponse {
+			return client.Decrement(key, delta, initValue, expiration)
+		})
+}
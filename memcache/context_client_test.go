@@ -0,0 +1,278 @@
+package memcache_test
+
+import (
+	"context"
+	"net"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/memcache/memcachetest"
+	"github.com/dropbox/godropbox/net2"
+)
+
+type ContextClientSuite struct {
+	servers []*memcachetest.Server
+}
+
+var _ = Suite(&ContextClientSuite{})
+
+func (s *ContextClientSuite) SetUpTest(c *C) {
+	s.servers = nil
+	for i := 0; i < 2; i++ {
+		server, err := memcachetest.NewServer(memcachetest.Options{})
+		c.Assert(err, IsNil)
+		s.servers = append(s.servers, server)
+	}
+}
+
+func (s *ContextClientSuite) TearDownTest(c *C) {
+	for _, server := range s.servers {
+		_ = server.Close()
+	}
+}
+
+func (s *ContextClientSuite) newRawClients(c *C) []memcache.ContextClient {
+	// NOTE: memcachetest does not support the meta protocol.
+	builders := []memcache.ClientShardBuilder{
+		memcache.NewRawBinaryClient,
+		memcache.NewRawAsciiClient,
+	}
+
+	clients := []memcache.ContextClient{}
+	for _, builder := range builders {
+		conn, err := net.Dial("tcp", s.servers[0].Addr())
+		c.Assert(err, IsNil)
+		clients = append(clients, builder(0, conn).(memcache.ContextClient))
+	}
+	return clients
+}
+
+func (s *ContextClientSuite) TestRawClients(c *C) {
+	for _, client := range s.newRawClients(c) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+
+		resp := client.SetCtx(
+			ctx,
+			&memcache.Item{Key: "key", Value: []byte("value")})
+		c.Assert(resp.Error(), IsNil)
+
+		gresp := client.GetCtx(ctx, "key")
+		c.Assert(gresp.Error(), IsNil)
+		c.Assert(string(gresp.Value()), Equals, "value")
+
+		gresps := client.GetMultiCtx(ctx, []string{"key", "missing"})
+		c.Assert(gresps, HasLen, 2)
+		c.Assert(string(gresps["key"].Value()), Equals, "value")
+
+		resp = client.DeleteCtx(ctx, "missing")
+		c.Assert(resp.Status(), Equals, memcache.StatusKeyNotFound)
+
+		cancel()
+
+		// The deadline is cleared once the operation completes.
+		c.Assert(client.Get("key").Error(), IsNil)
+		c.Assert(client.(memcache.ClientShard).IsValidState(), IsTrue)
+	}
+}
+
+func (s *ContextClientSuite) TestDoneContext(c *C) {
+	for _, client := range s.newRawClients(c) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		c.Assert(client.GetCtx(ctx, "key").Error(), NotNil)
+
+		responses := client.SetMultiCtx(
+			ctx,
+			[]*memcache.Item{{Key: "key", Value: []byte("value")}})
+		c.Assert(responses, HasLen, 1)
+		c.Assert(responses[0].Error(), NotNil)
+		c.Assert(responses[0].Key(), Equals, "key")
+
+		// Nothing was sent, hence the connection is still usable.
+		c.Assert(client.(memcache.ClientShard).IsValidState(), IsTrue)
+	}
+	c.Assert(s.servers[0].Stat("cmd_set"), Equals, uint64(0))
+}
+
+func (s *ContextClientSuite) TestDeadline(c *C) {
+	// NOTE: The ascii client uses "gets" for get requests.
+	s.servers[0].InjectFault(memcachetest.Fault{Delay: time.Second})
+
+	for _, client := range s.newRawClients(c) {
+		ctx, cancel := context.WithTimeout(
+			context.Background(),
+			20*time.Millisecond)
+
+		start := time.Now()
+		gresp := client.GetCtx(ctx, "key")
+		cancel()
+
+		c.Assert(gresp.Error(), NotNil)
+		c.Assert(time.Since(start) < 500*time.Millisecond, IsTrue)
+
+		// The connection may have a partially read response.
+		c.Assert(client.(memcache.ClientShard).IsValidState(), IsFalse)
+	}
+}
+
+func (s *ContextClientSuite) TestCancel(c *C) {
+	s.servers[0].InjectFault(
+		memcachetest.Fault{Command: "get", Delay: time.Second})
+
+	client := s.newRawClients(c)[0]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	gresp := client.GetCtx(ctx, "key")
+	c.Assert(gresp.Error(), NotNil)
+	c.Assert(time.Since(start) < 500*time.Millisecond, IsTrue)
+	c.Assert(client.(memcache.ClientShard).IsValidState(), IsFalse)
+}
+
+func (s *ContextClientSuite) TestSharedRawClients(c *C) {
+	for _, client := range s.newRawClients(c) {
+		c.Assert(
+			client.Set(&memcache.Item{Key: "key", Value: []byte("value")}).Error(),
+			IsNil)
+
+		s.servers[0].InjectFault(
+			memcachetest.Fault{Delay: 200 * time.Millisecond, Count: 1})
+
+		done := make(chan memcache.GetResponse, 1)
+		go func() {
+			done <- client.Get("key")
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		// The context's deadline must not interrupt the in-flight request
+		// from the other goroutine.
+		ctx, cancel := context.WithTimeout(
+			context.Background(),
+			20*time.Millisecond)
+		c.Assert(client.GetCtx(ctx, "key").Error(), NotNil)
+		cancel()
+
+		gresp := <-done
+		c.Assert(gresp.Error(), IsNil)
+		c.Assert(string(gresp.Value()), Equals, "value")
+		c.Assert(client.(memcache.ClientShard).IsValidState(), IsTrue)
+	}
+}
+
+func (s *ContextClientSuite) TestShardedClient(c *C) {
+	addrs := []string{s.servers[0].Addr(), s.servers[1].Addr()}
+	manager := memcache.NewStaticShardManager(
+		addrs,
+		func(key string, numShard int) int {
+			if key == "slow" {
+				return 1
+			}
+			return 0
+		},
+		net2.ConnectionOptions{MaxActiveConnections: 10})
+
+	client := memcache.NewShardedClient(
+		manager,
+		memcache.NewRawBinaryClient).(memcache.ContextClient)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	responses := client.SetMultiCtx(
+		ctx,
+		[]*memcache.Item{
+			{Key: "fast", Value: []byte("fast")},
+			{Key: "slow", Value: []byte("slow")},
+		})
+	cancel()
+	c.Assert(responses, HasLen, 2)
+	for _, resp := range responses {
+		c.Assert(resp.Error(), IsNil)
+	}
+
+	s.servers[1].InjectFault(
+		memcachetest.Fault{Command: "get", Delay: time.Second})
+
+	ctx, cancel = context.WithTimeout(
+		context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	gresps := client.GetMultiCtx(ctx, []string{"fast", "slow"})
+	c.Assert(time.Since(start) < 500*time.Millisecond, IsTrue)
+	c.Assert(gresps, HasLen, 2)
+	c.Assert(gresps["fast"].Error(), IsNil)
+	c.Assert(string(gresps["fast"].Value()), Equals, "fast")
+	c.Assert(gresps["slow"].Error(), NotNil)
+}
+
+func (s *ContextClientSuite) TestPooledShardedClientDeadline(c *C) {
+	s.testPooledShardedClientDeadline(c, memcache.ShardedClientOptions{})
+}
+
+func (s *ContextClientSuite) TestPooledShardedClientDeadlineWithStats(
+	c *C) {
+
+	s.testPooledShardedClientDeadline(
+		c,
+		memcache.ShardedClientOptions{
+			StatsFactory: newRecordingStatsFactory(),
+		})
+}
+
+func (s *ContextClientSuite) testPooledShardedClientDeadline(
+	c *C,
+	options memcache.ShardedClientOptions) {
+
+	manager := memcache.NewStaticShardManager(
+		[]string{s.servers[0].Addr()},
+		func(key string, numShard int) int { return 0 },
+		net2.ConnectionOptions{
+			MaxActiveConnections: 10,
+			MaxIdleConnections:   10,
+		})
+
+	client := memcache.NewShardedClientWithOptions(
+		manager,
+		memcache.NewRawBinaryClient,
+		options).(memcache.ContextClient)
+
+	resp := client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.servers[0].Stat("total_connections"), Equals, uint64(1))
+
+	s.servers[0].InjectFault(
+		memcachetest.Fault{Command: "get", Delay: 2 * time.Second, Count: 1})
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	gresp := client.GetCtx(ctx, "key")
+	c.Assert(gresp.Error(), NotNil)
+	c.Assert(time.Since(start) < 500*time.Millisecond, IsTrue)
+
+	// The interrupted connection is discarded, hence a new connection is
+	// used, and the new connection has no deadline.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	gresp = client.GetCtx(ctx, "key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(string(gresp.Value()), Equals, "value")
+	c.Assert(s.servers[0].Stat("total_connections"), Equals, uint64(2))
+
+	// Completed requests release their connections back to the pool.
+	c.Assert(client.Get("key").Error(), IsNil)
+	c.Assert(s.servers[0].Stat("total_connections"), Equals, uint64(2))
+}
@@ -3,5 +3,6 @@
 //
 // Implementation note: this client uses memcached's binary protocol.  See
 // https://code.google.com/p/memcached/wiki/BinaryProtocolRevamped for
-// additional details.
+// additional details.  RawAsciiClient and RawMetaClient provide ascii and
+// meta protocol (mg/ms/md/ma) alternatives.
 package memcache
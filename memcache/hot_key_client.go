@@ -0,0 +1,576 @@
+package memcache
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dropbox/godropbox/container/lrucache"
+	"github.com/dropbox/godropbox/murmur3"
+	"github.com/dropbox/godropbox/time2"
+)
+
+const (
+	defaultHotKeyThreshold = 100
+	defaultHotKeyWindow    = 10 * time.Second
+	defaultMaxHotKeys      = 1000
+	defaultHotKeyTTL       = time.Second
+	defaultSketchWidth     = 2048
+	defaultSketchDepth     = 4
+
+	hotKeyLockShards    = 32
+	hotKeyLockShardSeed = 0x5eed
+)
+
+// Options for configuring HotKeyClient.
+type HotKeyOptions struct {
+	// Only one of every SampleRate get accesses (chosen at random) is
+	// counted.  Defaults to 1 (i.e., every access is counted).
+	SampleRate int
+
+	// A key is hot when its estimated (sampled) access count reaches this
+	// threshold.  Defaults to 100.
+	HotKeyThreshold int
+
+	// The access counts are halved every Window (hence a key is no longer hot
+	// once its access rate drops).  Defaults to 10 seconds.
+	Window time.Duration
+
+	// The maximum number of hot keys (and locally cached entries).  Defaults
+	// to 1000.
+	MaxHotKeys int
+
+	// The maximum staleness of locally cached entries.  Defaults to 1 second.
+	TTL time.Duration
+
+	// The count-min sketch's dimensions.  Default to 2048 (width) and 4
+	// (depth).
+	SketchWidth int
+	SketchDepth int
+
+	// Defaults to time2.DefaultClock.
+	Clock time2.Clock
+}
+
+// A count-min sketch, which estimates (over-estimates) key frequencies in
+// constant space.  The counts are updated atomically (i.e., the sketch is
+// lock free).
+type countMinSketch struct {
+	counts [][]uint32
+}
+
+func newCountMinSketch(width int, depth int) *countMinSketch {
+	counts := make([][]uint32, depth)
+	for i := range counts {
+		counts[i] = make([]uint32, width)
+	}
+	return &countMinSketch{counts: counts}
+}
+
+// Increments the key's count, and returns the key's estimated count.
+func (s *countMinSketch) add(key string) uint32 {
+	var estimate uint32
+	for i, row := range s.counts {
+		j := murmur3.Hash32([]byte(key), uint32(i)) % uint32(len(row))
+		count := atomic.AddUint32(&row[j], 1)
+		if i == 0 || count < estimate {
+			estimate = count
+		}
+	}
+	return estimate
+}
+
+func (s *countMinSketch) halve() {
+	for _, row := range s.counts {
+		for j := range row {
+			for {
+				count := atomic.LoadUint32(&row[j])
+				if atomic.CompareAndSwapUint32(&row[j], count, count/2) {
+					break
+				}
+			}
+		}
+	}
+}
+
+type nearCacheEntry struct {
+	resp      GetResponse
+	expiresAt time.Time
+}
+
+// A lock shard of the hot keys and the near cache.  Keys are assigned to
+// shards by hash, so that concurrent gets of different keys rarely contend.
+type hotKeyShard struct {
+	mutex   sync.Mutex
+	random  *rand.Rand
+	hotKeys map[string]uint64 // hot key -> the window the key is hot in
+	cache   *lrucache.LRUCache
+
+	// Bumped whenever the shard's entries are invalidated.  A get response
+	// is only cached if the shard's generation did not change while the get
+	// was in flight (otherwise, the response may predate a write).
+	generation uint64
+}
+
+// This must be called while holding the shard's mutex.
+func (s *hotKeyShard) isHotLocked(key string, window uint64) bool {
+	hotWindow, ok := s.hotKeys[key]
+	return ok && hotWindow == window
+}
+
+// Marks the key as hot for the current window, unless the shard is full.
+// Returns true if the key is hot.  This must be called while holding the
+// shard's mutex.
+func (s *hotKeyShard) markHotLocked(
+	key string,
+	window uint64,
+	maxHotKeys int) bool {
+
+	if s.isHotLocked(key, window) {
+		return true
+	}
+
+	if len(s.hotKeys) >= maxHotKeys {
+		// Hot keys are re-detected every window.
+		for hotKey, hotWindow := range s.hotKeys {
+			if hotWindow != window {
+				delete(s.hotKeys, hotKey)
+			}
+		}
+		if len(s.hotKeys) >= maxHotKeys {
+			return false
+		}
+	}
+
+	s.hotKeys[key] = window
+	return true
+}
+
+// A Client wrapper which detects hot keys, and serves them from a short-TTL
+// in-process near cache, which shields the hot keys' shards from most of the
+// keys' read traffic.
+//
+// Get accesses are sampled into a count-min sketch (whose counts decay every
+// window).  Once a key's estimated access count reaches the threshold, the
+// key is hot, and its get responses (including misses) are cached locally
+// for up to TTL.  Writes through this client invalidate the locally cached
+// entries (both before and after the write is sent, and responses of gets
+// which raced with the write are not cached), but writes from other clients
+// are only observed once the cached entries expire (i.e., the staleness is
+// bounded by TTL).
+//
+// The hot keys and the near cache are split into lock shards (the sketch is
+// lock free), hence near cache hits do not contend on a global lock.  NOTE:
+// MaxHotKeys is split evenly between the lock shards.
+//
+// NOTE: GetSentinels and GetAndTouch (and their batch variants) always read
+// from memcache.
+type HotKeyClient struct {
+	Client
+
+	options         HotKeyOptions
+	shardMaxHotKeys int
+
+	sketch *countMinSketch
+
+	windowMutex sync.Mutex // serializes window rotations
+	windowStart int64      // atomic; unix nanos
+	window      uint64     // atomic
+
+	shards []*hotKeyShard
+}
+
+// This creates a new HotKeyClient which wraps the given client.
+func NewHotKeyClient(client Client, options HotKeyOptions) *HotKeyClient {
+	if options.SampleRate <= 0 {
+		options.SampleRate = 1
+	}
+	if options.HotKeyThreshold <= 0 {
+		options.HotKeyThreshold = defaultHotKeyThreshold
+	}
+	if options.Window <= 0 {
+		options.Window = defaultHotKeyWindow
+	}
+	if options.MaxHotKeys <= 0 {
+		options.MaxHotKeys = defaultMaxHotKeys
+	}
+	if options.TTL <= 0 {
+		options.TTL = defaultHotKeyTTL
+	}
+	if options.SketchWidth <= 0 {
+		options.SketchWidth = defaultSketchWidth
+	}
+	if options.SketchDepth <= 0 {
+		options.SketchDepth = defaultSketchDepth
+	}
+	if options.Clock == nil {
+		options.Clock = time2.DefaultClock
+	}
+
+	numShards := hotKeyLockShards
+	if options.MaxHotKeys < numShards {
+		numShards = options.MaxHotKeys
+	}
+	shardMaxHotKeys := options.MaxHotKeys / numShards
+
+	seed := time.Now().UnixNano()
+	shards := make([]*hotKeyShard, numShards)
+	for i := range shards {
+		shards[i] = &hotKeyShard{
+			random:  rand.New(rand.NewSource(seed + int64(i))),
+			hotKeys: make(map[string]uint64),
+			cache:   lrucache.New(shardMaxHotKeys),
+		}
+	}
+
+	return &HotKeyClient{
+		Client:          client,
+		options:         options,
+		shardMaxHotKeys: shardMaxHotKeys,
+		sketch:          newCountMinSketch(options.SketchWidth, options.SketchDepth),
+		windowStart:     options.Clock.Now().UnixNano(),
+		shards:          shards,
+	}
+}
+
+func (c *HotKeyClient) shard(key string) *hotKeyShard {
+	hash := murmur3.Hash32([]byte(key), hotKeyLockShardSeed)
+	return c.shards[hash%uint32(len(c.shards))]
+}
+
+// Returns the current window, and rotates the window (i.e., decays the
+// counts) if needed.
+func (c *HotKeyClient) currentWindow(now time.Time) uint64 {
+	if now.UnixNano()-atomic.LoadInt64(&c.windowStart) <
+		int64(c.options.Window) {
+
+		return atomic.LoadUint64(&c.window)
+	}
+
+	c.windowMutex.Lock()
+	defer c.windowMutex.Unlock()
+
+	if now.UnixNano()-atomic.LoadInt64(&c.windowStart) >=
+		int64(c.options.Window) {
+
+		c.sketch.halve()
+		atomic.StoreInt64(&c.windowStart, now.UnixNano())
+
+		// Hot keys are re-detected using the decayed counts.
+		atomic.AddUint64(&c.window, 1)
+	}
+	return atomic.LoadUint64(&c.window)
+}
+
+// This returns the current hot keys (sorted), for debugging.
+func (c *HotKeyClient) HotKeys() []string {
+	window := c.currentWindow(c.options.Clock.Now())
+
+	keys := []string{}
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		for key := range shard.hotKeys {
+			if shard.isHotLocked(key, window) {
+				keys = append(keys, key)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Records the key access, and returns the key's locally cached response (if
+// any).  isHot is true if the key is hot.  generation is the key's shard
+// generation, which must be passed to store.
+func (c *HotKeyClient) access(
+	key string,
+	now time.Time) (
+	cached GetResponse,
+	isHot bool,
+	generation uint64) {
+
+	window := c.currentWindow(now)
+	shard := c.shard(key)
+
+	shard.mutex.Lock()
+	sampled := shard.random.Intn(c.options.SampleRate) == 0
+	isHot = shard.isHotLocked(key, window)
+	if isHot {
+		if value, ok := shard.cache.Get(key); ok {
+			entry := value.(*nearCacheEntry)
+			if now.Before(entry.expiresAt) {
+				cached = entry.resp
+			} else {
+				shard.cache.Delete(key)
+			}
+		}
+	}
+	generation = shard.generation
+	shard.mutex.Unlock()
+
+	if !sampled {
+		return cached, isHot, generation
+	}
+
+	// NOTE: The sketch is updated outside of the shard's lock.
+	estimate := c.sketch.add(key)
+	if !isHot && estimate >= uint32(c.options.HotKeyThreshold) {
+		shard.mutex.Lock()
+		isHot = shard.markHotLocked(key, window, c.shardMaxHotKeys)
+		generation = shard.generation
+		shard.mutex.Unlock()
+	}
+	return cached, isHot, generation
+}
+
+// Caches the response of a hot key, unless the key was invalidated since
+// the key's access.
+func (c *HotKeyClient) store(
+	resp GetResponse,
+	generation uint64,
+	now time.Time) {
+
+	if resp.Error() != nil {
+		return
+	}
+	if resp.Status() != StatusNoError && resp.Status() != StatusKeyNotFound {
+		return
+	}
+
+	window := c.currentWindow(now)
+	shard := c.shard(resp.Key())
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if shard.generation != generation ||
+		!shard.isHotLocked(resp.Key(), window) {
+
+		return
+	}
+
+	shard.cache.Set(
+		resp.Key(),
+		&nearCacheEntry{
+			resp:      resp,
+			expiresAt: now.Add(c.options.TTL),
+		})
+}
+
+func (c *HotKeyClient) invalidate(keys ...string) {
+	for _, key := range keys {
+		shard := c.shard(key)
+
+		shard.mutex.Lock()
+		shard.cache.Delete(key)
+		shard.generation++
+		shard.mutex.Unlock()
+	}
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Get(key string) GetResponse {
+	cached, isHot, generation := c.access(key, c.options.Clock.Now())
+	if cached != nil {
+		return cached
+	}
+
+	resp := c.Client.Get(key)
+	if isHot {
+		c.store(resp, generation, c.options.Clock.Now())
+	}
+	return resp
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) GetMulti(keys []string) map[string]GetResponse {
+	if keys == nil {
+		return c.Client.GetMulti(keys)
+	}
+
+	results := make(map[string]GetResponse)
+	generations := make(map[string]uint64)
+	missing := []string{}
+
+	now := c.options.Clock.Now()
+	for _, key := range keys {
+		if _, ok := results[key]; ok {
+			continue
+		}
+		if _, ok := generations[key]; ok {
+			continue
+		}
+
+		cached, _, generation := c.access(key, now)
+		if cached != nil {
+			results[key] = cached
+		} else {
+			generations[key] = generation
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) == 0 {
+		return results
+	}
+
+	responses := c.Client.GetMulti(missing)
+
+	now = c.options.Clock.Now()
+	for key, resp := range responses {
+		results[key] = resp
+		c.store(resp, generations[key], now)
+	}
+
+	return results
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Touch(key string, expiration uint32) MutateResponse {
+	c.invalidate(key)
+	defer c.invalidate(key)
+	return c.Client.Touch(key, expiration)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Set(item *Item) MutateResponse {
+	if item != nil {
+		c.invalidate(item.Key)
+		defer c.invalidate(item.Key)
+	}
+	return c.Client.Set(item)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) SetMulti(items []*Item) []MutateResponse {
+	c.invalidateItems(items)
+	defer c.invalidateItems(items)
+	return c.Client.SetMulti(items)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) SetSentinels(items []*Item) []MutateResponse {
+	c.invalidateItems(items)
+	defer c.invalidateItems(items)
+	return c.Client.SetSentinels(items)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) CasMulti(items []*Item) []MutateResponse {
+	c.invalidateItems(items)
+	defer c.invalidateItems(items)
+	return c.Client.CasMulti(items)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) CasSentinels(items []*Item) []MutateResponse {
+	c.invalidateItems(items)
+	defer c.invalidateItems(items)
+	return c.Client.CasSentinels(items)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Add(item *Item) MutateResponse {
+	if item != nil {
+		c.invalidate(item.Key)
+		defer c.invalidate(item.Key)
+	}
+	return c.Client.Add(item)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) AddMulti(items []*Item) []MutateResponse {
+	c.invalidateItems(items)
+	defer c.invalidateItems(items)
+	return c.Client.AddMulti(items)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Replace(item *Item) MutateResponse {
+	if item != nil {
+		c.invalidate(item.Key)
+		defer c.invalidate(item.Key)
+	}
+	return c.Client.Replace(item)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Delete(key string) MutateResponse {
+	c.invalidate(key)
+	defer c.invalidate(key)
+	return c.Client.Delete(key)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) DeleteMulti(keys []string) []MutateResponse {
+	c.invalidate(keys...)
+	defer c.invalidate(keys...)
+	return c.Client.DeleteMulti(keys)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Append(key string, value []byte) MutateResponse {
+	c.invalidate(key)
+	defer c.invalidate(key)
+	return c.Client.Append(key, value)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Prepend(key string, value []byte) MutateResponse {
+	c.invalidate(key)
+	defer c.invalidate(key)
+	return c.Client.Prepend(key, value)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Increment(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	c.invalidate(key)
+	defer c.invalidate(key)
+	return c.Client.Increment(key, delta, initValue, expiration)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Decrement(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	c.invalidate(key)
+	defer c.invalidate(key)
+	return c.Client.Decrement(key, delta, initValue, expiration)
+}
+
+// See Client interface for documentation.
+func (c *HotKeyClient) Flush(expiration uint32) Response {
+	c.invalidateAll()
+	defer c.invalidateAll()
+	return c.Client.Flush(expiration)
+}
+
+func (c *HotKeyClient) invalidateAll() {
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		shard.cache = lrucache.New(c.shardMaxHotKeys)
+		shard.generation++
+		shard.mutex.Unlock()
+	}
+}
+
+func (c *HotKeyClient) invalidateItems(items []*Item) {
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			keys = append(keys, item.Key)
+		}
+	}
+	c.invalidate(keys...)
+}
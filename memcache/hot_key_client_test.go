@@ -0,0 +1,193 @@
+package memcache_test
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/memcache/memcachetest"
+	"github.com/dropbox/godropbox/time2"
+)
+
+type HotKeyClientSuite struct {
+	server *memcachetest.Server
+	raw    memcache.Client
+	clock  *time2.MockClock
+	client *memcache.HotKeyClient
+}
+
+var _ = Suite(&HotKeyClientSuite{})
+
+func (s *HotKeyClientSuite) SetUpTest(c *C) {
+	server, err := memcachetest.NewServer(memcachetest.Options{})
+	c.Assert(err, IsNil)
+	s.server = server
+
+	conn, err := net.Dial("tcp", server.Addr())
+	c.Assert(err, IsNil)
+	s.raw = memcache.NewRawBinaryClient(0, conn)
+
+	s.clock = time2.NewMockClock(time.Unix(1000, 0))
+	s.client = memcache.NewHotKeyClient(
+		s.raw,
+		memcache.HotKeyOptions{
+			HotKeyThreshold: 3,
+			Window:          10 * time.Second,
+			TTL:             time.Second,
+			Clock:           s.clock,
+		})
+
+	resp := s.raw.Set(&memcache.Item{Key: "hot", Value: []byte("v1")})
+	c.Assert(resp.Error(), IsNil)
+}
+
+func (s *HotKeyClientSuite) TearDownTest(c *C) {
+	_ = s.server.Close()
+}
+
+func (s *HotKeyClientSuite) getValue(c *C, key string) string {
+	resp := s.client.Get(key)
+	c.Assert(resp.Error(), IsNil)
+	return string(resp.Value())
+}
+
+func (s *HotKeyClientSuite) makeHot(c *C) {
+	for i := 0; i < 3; i++ {
+		c.Assert(s.getValue(c, "hot"), Equals, "v1")
+	}
+	c.Assert(s.client.HotKeys(), DeepEquals, []string{"hot"})
+}
+
+func (s *HotKeyClientSuite) TestDetection(c *C) {
+	c.Assert(s.client.HotKeys(), HasLen, 0)
+
+	s.getValue(c, "hot")
+	s.getValue(c, "cold")
+	s.getValue(c, "hot")
+	c.Assert(s.client.HotKeys(), HasLen, 0)
+
+	s.getValue(c, "hot")
+	c.Assert(s.client.HotKeys(), DeepEquals, []string{"hot"})
+}
+
+func (s *HotKeyClientSuite) TestServeFromCache(c *C) {
+	s.makeHot(c)
+	numGets := s.server.Stat("cmd_get")
+
+	c.Assert(s.getValue(c, "hot"), Equals, "v1")
+	responses := s.client.GetMulti([]string{"hot"})
+	c.Assert(string(responses["hot"].Value()), Equals, "v1")
+	c.Assert(s.server.Stat("cmd_get"), Equals, numGets)
+
+	// Writes from other clients are observed once the entry expires.
+	resp := s.raw.Set(&memcache.Item{Key: "hot", Value: []byte("v2")})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.getValue(c, "hot"), Equals, "v1")
+
+	s.clock.Advance(time.Second)
+	c.Assert(s.getValue(c, "hot"), Equals, "v2")
+	c.Assert(s.server.Stat("cmd_get"), Equals, numGets+1)
+}
+
+func (s *HotKeyClientSuite) TestInvalidateOnWrite(c *C) {
+	s.makeHot(c)
+
+	resp := s.client.Set(&memcache.Item{Key: "hot", Value: []byte("v2")})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.getValue(c, "hot"), Equals, "v2")
+
+	resp = s.client.Delete("hot")
+	c.Assert(resp.Error(), IsNil)
+	gresp := s.client.Get("hot")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(gresp.Status(), Equals, memcache.StatusKeyNotFound)
+}
+
+func (s *HotKeyClientSuite) TestWindowDecay(c *C) {
+	s.makeHot(c)
+
+	// The counts are halved, and the key must be re-detected.
+	s.clock.Advance(10 * time.Second)
+	s.getValue(c, "hot")
+	c.Assert(s.client.HotKeys(), HasLen, 0)
+
+	s.getValue(c, "hot")
+	c.Assert(s.client.HotKeys(), DeepEquals, []string{"hot"})
+}
+
+// A client whose gets block (after reading from memcache) until released.
+type blockingGetClient struct {
+	memcache.Client
+
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingGetClient) Get(key string) memcache.GetResponse {
+	resp := c.Client.Get(key)
+	c.read <- struct{}{}
+	<-c.release
+	return resp
+}
+
+func (s *HotKeyClientSuite) TestWriteDuringGet(c *C) {
+	s.makeHot(c)
+	s.clock.Advance(time.Second)
+
+	blocking := &blockingGetClient{
+		Client:  s.raw,
+		read:    make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	client := memcache.NewHotKeyClient(
+		blocking,
+		memcache.HotKeyOptions{
+			HotKeyThreshold: 1,
+			Clock:           s.clock,
+		})
+
+	done := make(chan string)
+	go func() {
+		done <- string(client.Get("hot").Value())
+	}()
+	<-blocking.read
+
+	// The in-flight get read the old value before the write.
+	resp := client.Set(&memcache.Item{Key: "hot", Value: []byte("v2")})
+	c.Assert(resp.Error(), IsNil)
+	close(blocking.release)
+	c.Assert(<-done, Equals, "v1")
+
+	// The stale response is not cached.
+	go func() {
+		<-blocking.read
+	}()
+	c.Assert(string(client.Get("hot").Value()), Equals, "v2")
+}
+
+func (s *HotKeyClientSuite) TestConcurrentHits(c *C) {
+	s.makeHot(c)
+	numGets := s.server.Stat("cmd_get")
+
+	wg := sync.WaitGroup{}
+	values := make(chan string, 100)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				values <- string(s.client.Get("hot").Value())
+			}
+		}()
+	}
+	wg.Wait()
+	close(values)
+
+	for value := range values {
+		c.Assert(value, Equals, "v1")
+	}
+	c.Assert(s.server.Stat("cmd_get"), Equals, numGets)
+}
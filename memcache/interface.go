@@ -138,6 +138,16 @@ type Client interface {
 	// both ACTIVE and WRITE_ONLY memcache shards.
 	GetSentinels(keys []string) map[string]GetResponse
 
+	// This retrieves a single entry from memcache, and updates the entry's
+	// expiration time (see Item.Expiration for the expiration format).
+	GetAndTouch(key string, expiration uint32) GetResponse
+
+	// Batch version of the GetAndTouch method.
+	GetAndTouchMulti(keys []string, expiration uint32) map[string]GetResponse
+
+	// This updates a single entry's expiration time without retrieving it.
+	Touch(key string, expiration uint32) MutateResponse
+
 	// This sets a single entry into memcache.  If the item's data version id
 	// (aka CAS) is nonzero, the set operation can only succeed if the item
 	// exists in memcache and has a same data version id; otherwise, this will
@@ -306,3 +316,39 @@ type ShardManager interface {
 	// This return a (shard id -> connection) mapping for all shards.
 	GetAllShards() map[int]net2.ManagedConn
 }
+
+// A ShardManager which can place each key on multiple (replica) shards.
+type ReplicaShardManager interface {
+	ShardManager
+
+	// This returns the ids of up to numReplicas distinct shards for the key,
+	// in preference order (the first id is the same as GetShard's shard id).
+	// This returns nil if the key does not belong to any shard.
+	GetReplicaShardIds(key string, numReplicas int) []int
+
+	// This returns a connection to the shard.  Similar to GetShard, the
+	// connection may be nil if the shard is not in active state.
+	GetShardConnection(shardId int) (conn net2.ManagedConn, err error)
+}
+
+// A ShardManager which can migrate keys to a new set of shards (e.g., during
+// a resharding).  See BaseShardManager.StartMigration.
+type MigratingShardManager interface {
+	ShardManager
+
+	// This returns the migration target's shard manager, or nil when no
+	// migration is in progress.
+	GetMigrationTarget() ShardManager
+
+	// This returns whether the key's reads should be dual-read (i.e., read
+	// from the target shard first, and from the current shard on target
+	// misses), and whether the key's writes should be shadowed to the target
+	// shard.  Both are false for keys whose current and target shards are on
+	// the same server, and when no migration is in progress.
+	GetMigrationRoute(key string) (dualRead bool, shadowWrite bool)
+
+	// These record the dual-read / shadow-write outcomes, which are included
+	// in the migration's progress report.
+	RecordDualReads(hits int, misses int)
+	RecordShadowWrites(successes int, failures int)
+}
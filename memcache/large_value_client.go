@@ -0,0 +1,620 @@
+package memcache
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+const (
+	// The Item.Flags bits reserved by LargeValueClient.  Items stored through
+	// LargeValueClient must not set these bits.
+	LargeValueReservedFlags uint32 = 0xff000000
+
+	// Values longer than this many bytes are compressed by default.
+	DefaultCompressionThreshold = 1024
+
+	// The default maximum chunk size.  This leaves room for the item's
+	// key and the server's per-item overhead within memcached's default 1MB
+	// item size limit.
+	DefaultMaxChunkSize = 1000 * 1024
+
+	flagChunked    uint32 = 0x80000000
+	flagCodecMask  uint32 = 0x0f000000
+	flagCodecShift        = 24
+
+	chunkKeyPrefix = "chunk:"
+
+	manifestVersion = 1
+	manifestLength  = 21 // version + generation + num chunks + length + crc
+)
+
+// A value compression codec.
+type Codec interface {
+	// This returns the codec's id, which is recorded in the stored item's
+	// flags.  The id must be in the range [1, 15].
+	Id() uint8
+
+	// This returns the compressed value.
+	Encode(value []byte) ([]byte, error)
+
+	// This returns the decompressed value.
+	Decode(value []byte) ([]byte, error)
+}
+
+// A Codec which compresses values using zlib.
+type ZlibCodec struct{}
+
+// See Codec interface for documentation.
+func (ZlibCodec) Id() uint8 {
+	return 1
+}
+
+// See Codec interface for documentation.
+func (ZlibCodec) Encode(value []byte) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	writer := zlib.NewWriter(buffer)
+	if _, err := writer.Write(value); err != nil {
+		return nil, errors.Wrap(err, "Failed to compress value")
+	}
+	if err := writer.Close(); err != nil {
+		return nil, errors.Wrap(err, "Failed to compress value")
+	}
+	return buffer.Bytes(), nil
+}
+
+// See Codec interface for documentation.
+func (ZlibCodec) Decode(value []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to decompress value")
+	}
+	defer func() { _ = reader.Close() }()
+
+	result, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to decompress value")
+	}
+	return result, nil
+}
+
+// Options for configuring LargeValueClient.
+type LargeValueOptions struct {
+	// Values longer than this many bytes are compressed (the compressed value
+	// is only stored when it is smaller than the original value).  Defaults
+	// to DefaultCompressionThreshold.  Negative values disable compression.
+	CompressionThreshold int
+
+	// The compression codec.  Defaults to ZlibCodec.
+	Codec Codec
+
+	// Values (after compression) longer than this many bytes are split into
+	// chunks of at most MaxChunkSize bytes.  Defaults to DefaultMaxChunkSize.
+	MaxChunkSize int
+}
+
+// The manifest is stored in place of a chunked value.
+type manifest struct {
+	generation uint64
+	numChunks  uint32
+	length     uint32
+	checksum   uint32
+}
+
+func (m *manifest) serialize() []byte {
+	buffer := make([]byte, manifestLength)
+	buffer[0] = manifestVersion
+	binary.BigEndian.PutUint64(buffer[1:9], m.generation)
+	binary.BigEndian.PutUint32(buffer[9:13], m.numChunks)
+	binary.BigEndian.PutUint32(buffer[13:17], m.length)
+	binary.BigEndian.PutUint32(buffer[17:21], m.checksum)
+	return buffer
+}
+
+func parseManifest(value []byte) (*manifest, error) {
+	if len(value) != manifestLength || value[0] != manifestVersion {
+		return nil, errors.New("Invalid chunk manifest")
+	}
+
+	return &manifest{
+		generation: binary.BigEndian.Uint64(value[1:9]),
+		numChunks:  binary.BigEndian.Uint32(value[9:13]),
+		length:     binary.BigEndian.Uint32(value[13:17]),
+		checksum:   binary.BigEndian.Uint32(value[17:21]),
+	}, nil
+}
+
+// The chunk keys are derived from the item's key (hashed, to bound the
+// chunk key length) and the manifest's generation.
+func (m *manifest) chunkKeys(key string) []string {
+	sum := md5.Sum([]byte(key))
+	prefix := chunkKeyPrefix +
+		hex.EncodeToString(sum[:]) + ":" +
+		strconv.FormatUint(m.generation, 16) + ":"
+
+	keys := make([]string, m.numChunks)
+	for i := range keys {
+		keys[i] = prefix + strconv.Itoa(i)
+	}
+	return keys
+}
+
+// A Client wrapper which transparently compresses large values, and splits
+// values larger than the server's item size limit into multiple chunk items.
+//
+// Compressed values are tagged with the codec's id in the item's flags (see
+// LargeValueReservedFlags).  A chunked value is stored as a manifest under
+// the item's key; the chunks are stored under keys derived from the item's
+// key and a random per-write generation.  Chunks are always written before
+// the manifest, hence readers never observe partially written values, and
+// replacing the manifest (which honors the item's DataVersionId, aka CAS)
+// atomically invalidates the previous value's chunks.  Unreachable chunks are
+// not deleted; they expire with the item, or are evicted by the server.
+//
+// NOTE: Append and Prepend are passed through as is, and should only be used
+// on values which are stored uncompressed and unchunked.
+type LargeValueClient struct {
+	client  Client
+	options LargeValueOptions
+}
+
+var _ Client = (*LargeValueClient)(nil)
+
+// This creates a new LargeValueClient which wraps the given client.
+func NewLargeValueClient(client Client, options LargeValueOptions) Client {
+	if options.CompressionThreshold == 0 {
+		options.CompressionThreshold = DefaultCompressionThreshold
+	}
+	if options.Codec == nil {
+		options.Codec = ZlibCodec{}
+	}
+	if options.MaxChunkSize <= 0 {
+		options.MaxChunkSize = DefaultMaxChunkSize
+	}
+
+	return &LargeValueClient{
+		client:  client,
+		options: options,
+	}
+}
+
+// Returns the item to store under the original key, along with the item's
+// chunks (if any).
+func (c *LargeValueClient) encode(item *Item) (*Item, []*Item, error) {
+	if item.Flags&LargeValueReservedFlags != 0 {
+		return nil, nil, errors.Newf(
+			"Invalid flags: %x overlaps with reserved flags",
+			item.Flags)
+	}
+
+	value := item.Value
+	flags := item.Flags
+
+	threshold := c.options.CompressionThreshold
+	if threshold >= 0 && len(value) > threshold {
+		compressed, err := c.options.Codec.Encode(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(compressed) < len(value) {
+			value = compressed
+			flags |= uint32(c.options.Codec.Id()) << flagCodecShift
+		}
+	}
+
+	encoded := *item
+	encoded.Flags = flags
+	encoded.Value = value
+
+	chunkSize := c.options.MaxChunkSize
+	if len(value) <= chunkSize {
+		return &encoded, nil, nil
+	}
+
+	generation := make([]byte, 8)
+	if _, err := rand.Read(generation); err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to generate chunk generation")
+	}
+
+	m := &manifest{
+		generation: binary.BigEndian.Uint64(generation),
+		numChunks:  uint32((len(value) + chunkSize - 1) / chunkSize),
+		length:     uint32(len(value)),
+		checksum:   crc32.ChecksumIEEE(value),
+	}
+
+	chunks := make([]*Item, m.numChunks)
+	for i, key := range m.chunkKeys(item.Key) {
+		end := (i + 1) * chunkSize
+		if end > len(value) {
+			end = len(value)
+		}
+
+		chunks[i] = &Item{
+			Key:        key,
+			Value:      value[i*chunkSize : end],
+			Expiration: item.Expiration,
+		}
+	}
+
+	encoded.Flags |= flagChunked
+	encoded.Value = m.serialize()
+
+	return &encoded, chunks, nil
+}
+
+// Returns the decoded response.  chunks must include the chunks of all
+// chunked responses.
+func (c *LargeValueClient) decode(
+	resp GetResponse,
+	chunks map[string]GetResponse) GetResponse {
+
+	if resp.Error() != nil || resp.Status() != StatusNoError {
+		return resp
+	}
+
+	flags := resp.Flags()
+	if flags&LargeValueReservedFlags == 0 {
+		return resp
+	}
+
+	key := resp.Key()
+	value := resp.Value()
+
+	if flags&flagChunked != 0 {
+		m, err := parseManifest(value)
+		if err != nil {
+			return NewGetErrorResponse(key, err)
+		}
+
+		assembled := make([]byte, 0, m.length)
+		for _, chunkKey := range m.chunkKeys(key) {
+			chunk, ok := chunks[chunkKey]
+			if !ok {
+				return NewGetErrorResponse(
+					key,
+					errors.Newf("Missing chunk response: %s", chunkKey))
+			}
+			if chunk.Error() != nil {
+				return NewGetErrorResponse(key, chunk.Error())
+			}
+			if chunk.Status() != StatusNoError {
+				// The chunk was evicted (or has expired).  Treat the entire
+				// value as missing.
+				return NewGetResponse(key, StatusKeyNotFound, 0, nil, 0)
+			}
+			assembled = append(assembled, chunk.Value()...)
+		}
+
+		if uint32(len(assembled)) != m.length ||
+			crc32.ChecksumIEEE(assembled) != m.checksum {
+
+			return NewGetErrorResponse(
+				key,
+				errors.Newf("Corrupted chunked value: %s", key))
+		}
+
+		value = assembled
+	}
+
+	codecId := uint8((flags & flagCodecMask) >> flagCodecShift)
+	if codecId != 0 {
+		if codecId != c.options.Codec.Id() {
+			return NewGetErrorResponse(
+				key,
+				errors.Newf("Unknown codec id: %d", codecId))
+		}
+
+		var err error
+		value, err = c.options.Codec.Decode(value)
+		if err != nil {
+			return NewGetErrorResponse(key, err)
+		}
+	}
+
+	return NewGetResponse(
+		key,
+		StatusNoError,
+		flags&^LargeValueReservedFlags,
+		value,
+		resp.DataVersionId())
+}
+
+// Decodes the responses, fetching the chunks of chunked values using
+// getChunks.
+func (c *LargeValueClient) decodeMulti(
+	responses map[string]GetResponse,
+	getChunks func(keys []string) map[string]GetResponse) map[string]GetResponse {
+
+	if responses == nil {
+		return nil
+	}
+
+	chunkKeys := []string{}
+	for key, resp := range responses {
+		if resp.Error() != nil ||
+			resp.Status() != StatusNoError ||
+			resp.Flags()&flagChunked == 0 {
+
+			continue
+		}
+
+		m, err := parseManifest(resp.Value())
+		if err != nil {
+			continue // decode will return the error.
+		}
+		chunkKeys = append(chunkKeys, m.chunkKeys(key)...)
+	}
+
+	var chunks map[string]GetResponse
+	if len(chunkKeys) > 0 {
+		chunks = getChunks(chunkKeys)
+	}
+
+	results := make(map[string]GetResponse, len(responses))
+	for key, resp := range responses {
+		results[key] = c.decode(resp, chunks)
+	}
+	return results
+}
+
+// Encodes the items, and writes the items' chunks using setChunks.  For each
+// item, either the encoded item or the error response is populated.
+func (c *LargeValueClient) prepare(
+	items []*Item,
+	setChunks func(items []*Item) []MutateResponse) (
+	[]*Item,
+	[]MutateResponse) {
+
+	encoded := make([]*Item, len(items))
+	responses := make([]MutateResponse, len(items))
+
+	chunks := []*Item{}
+	owners := make(map[string]int)
+	for i, item := range items {
+		if item == nil {
+			responses[i] = NewMutateErrorResponse("", errors.New("item is nil"))
+			continue
+		}
+
+		encodedItem, itemChunks, err := c.encode(item)
+		if err != nil {
+			responses[i] = NewMutateErrorResponse(item.Key, err)
+			continue
+		}
+
+		encoded[i] = encodedItem
+		for _, chunk := range itemChunks {
+			chunks = append(chunks, chunk)
+			owners[chunk.Key] = i
+		}
+	}
+
+	if len(chunks) == 0 {
+		return encoded, responses
+	}
+
+	// NOTE: The response ordering is undefined; match responses by key.
+	for _, resp := range setChunks(chunks) {
+		if resp.Error() == nil {
+			continue
+		}
+
+		i, ok := owners[resp.Key()]
+		if !ok || responses[i] != nil {
+			continue
+		}
+
+		encoded[i] = nil
+		responses[i] = NewMutateErrorResponse(
+			items[i].Key,
+			errors.Wrap(resp.Error(), "Failed to store value chunk"))
+	}
+
+	return encoded, responses
+}
+
+func (c *LargeValueClient) mutate(
+	item *Item,
+	op func(item *Item) MutateResponse) MutateResponse {
+
+	encoded, responses := c.prepare([]*Item{item}, c.client.SetMulti)
+	if responses[0] != nil {
+		return responses[0]
+	}
+	return op(encoded[0])
+}
+
+func (c *LargeValueClient) mutateMulti(
+	items []*Item,
+	setChunks func(items []*Item) []MutateResponse,
+	op func(items []*Item) []MutateResponse) []MutateResponse {
+
+	if items == nil {
+		return nil
+	}
+
+	encoded, responses := c.prepare(items, setChunks)
+
+	toWrite := []*Item{}
+	for _, item := range encoded {
+		if item != nil {
+			toWrite = append(toWrite, item)
+		}
+	}
+
+	results := make([]MutateResponse, 0, len(items))
+	for _, resp := range responses {
+		if resp != nil {
+			results = append(results, resp)
+		}
+	}
+	if len(toWrite) > 0 {
+		results = append(results, op(toWrite)...)
+	}
+	return results
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Get(key string) GetResponse {
+	responses := map[string]GetResponse{key: c.client.Get(key)}
+	return c.decodeMulti(responses, c.client.GetMulti)[key]
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) GetMulti(keys []string) map[string]GetResponse {
+	return c.decodeMulti(c.client.GetMulti(keys), c.client.GetMulti)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) GetSentinels(keys []string) map[string]GetResponse {
+	return c.decodeMulti(c.client.GetSentinels(keys), c.client.GetSentinels)
+}
+
+// See Client interface for documentation.  The chunks' expiration times are
+// also updated.
+func (c *LargeValueClient) GetAndTouch(
+	key string,
+	expiration uint32) GetResponse {
+
+	responses := map[string]GetResponse{
+		key: c.client.GetAndTouch(key, expiration),
+	}
+	return c.decodeMulti(
+		responses,
+		func(keys []string) map[string]GetResponse {
+			return c.client.GetAndTouchMulti(keys, expiration)
+		})[key]
+}
+
+// See Client interface for documentation.  The chunks' expiration times are
+// also updated.
+func (c *LargeValueClient) GetAndTouchMulti(
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	return c.decodeMulti(
+		c.client.GetAndTouchMulti(keys, expiration),
+		func(keys []string) map[string]GetResponse {
+			return c.client.GetAndTouchMulti(keys, expiration)
+		})
+}
+
+// See Client interface for documentation.  NOTE: Since the chunks' keys are
+// stored in the value's manifest, this is implemented using GetAndTouch.
+func (c *LargeValueClient) Touch(key string, expiration uint32) MutateResponse {
+	resp := c.GetAndTouch(key, expiration)
+	if resp.Error() != nil {
+		return NewMutateErrorResponse(key, resp.Error())
+	}
+	return NewMutateResponse(key, resp.Status(), resp.DataVersionId())
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Set(item *Item) MutateResponse {
+	return c.mutate(item, c.client.Set)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) SetMulti(items []*Item) []MutateResponse {
+	return c.mutateMulti(items, c.client.SetMulti, c.client.SetMulti)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) SetSentinels(items []*Item) []MutateResponse {
+	return c.mutateMulti(items, c.client.SetSentinels, c.client.SetSentinels)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) CasMulti(items []*Item) []MutateResponse {
+	return c.mutateMulti(items, c.client.SetMulti, c.client.CasMulti)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) CasSentinels(items []*Item) []MutateResponse {
+	return c.mutateMulti(items, c.client.SetSentinels, c.client.CasSentinels)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Add(item *Item) MutateResponse {
+	return c.mutate(item, c.client.Add)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) AddMulti(items []*Item) []MutateResponse {
+	return c.mutateMulti(items, c.client.SetMulti, c.client.AddMulti)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Replace(item *Item) MutateResponse {
+	return c.mutate(item, c.client.Replace)
+}
+
+// See Client interface for documentation.  NOTE: Only the manifest is
+// deleted; the (unreachable) chunks are left to expire.
+func (c *LargeValueClient) Delete(key string) MutateResponse {
+	return c.client.Delete(key)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) DeleteMulti(keys []string) []MutateResponse {
+	return c.client.DeleteMulti(keys)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Append(key string, value []byte) MutateResponse {
+	return c.client.Append(key, value)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Prepend(key string, value []byte) MutateResponse {
+	return c.client.Prepend(key, value)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Increment(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.client.Increment(key, delta, initValue, expiration)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Decrement(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.client.Decrement(key, delta, initValue, expiration)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Flush(expiration uint32) Response {
+	return c.client.Flush(expiration)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Stat(statsKey string) StatResponse {
+	return c.client.Stat(statsKey)
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Version() VersionResponse {
+	return c.client.Version()
+}
+
+// See Client interface for documentation.
+func (c *LargeValueClient) Verbosity(verbosity uint32) Response {
+	return c.client.Verbosity(verbosity)
+}
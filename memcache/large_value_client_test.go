@@ -0,0 +1,187 @@
+package memcache_test
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/memcache/memcachetest"
+)
+
+type LargeValueClientSuite struct {
+	server *memcachetest.Server
+	conn   net.Conn
+	client memcache.Client
+}
+
+var _ = Suite(&LargeValueClientSuite{})
+
+func (s *LargeValueClientSuite) SetUpTest(c *C) {
+	server, err := memcachetest.NewServer(
+		memcachetest.Options{MaxValueLength: 1024})
+	c.Assert(err, IsNil)
+	s.server = server
+
+	s.conn, err = net.Dial("tcp", server.Addr())
+	c.Assert(err, IsNil)
+
+	s.client = memcache.NewLargeValueClient(
+		memcache.NewRawBinaryClient(0, s.conn),
+		memcache.LargeValueOptions{
+			CompressionThreshold: 100,
+			MaxChunkSize:         1000,
+		})
+}
+
+func (s *LargeValueClientSuite) TearDownTest(c *C) {
+	_ = s.conn.Close()
+	_ = s.server.Close()
+}
+
+func randomBytes(n int) []byte {
+	value := make([]byte, n)
+	_, _ = rand.Read(value)
+	return value
+}
+
+func (s *LargeValueClientSuite) TestSmallValue(c *C) {
+	resp := s.client.Set(
+		&memcache.Item{Key: "key", Value: []byte("value"), Flags: 123})
+	c.Assert(resp.Error(), IsNil)
+
+	item, ok := s.server.Item("key")
+	c.Assert(ok, IsTrue)
+	c.Assert(string(item.Value), Equals, "value")
+	c.Assert(item.Flags, Equals, uint32(123))
+
+	gresp := s.client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(string(gresp.Value()), Equals, "value")
+	c.Assert(gresp.Flags(), Equals, uint32(123))
+}
+
+func (s *LargeValueClientSuite) TestCompression(c *C) {
+	value := []byte(strings.Repeat("compressible ", 1000))
+
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: value, Flags: 7})
+	c.Assert(resp.Error(), IsNil)
+
+	item, ok := s.server.Item("key")
+	c.Assert(ok, IsTrue)
+	c.Assert(len(item.Value) < 1000, IsTrue)
+	c.Assert(item.Flags&memcache.LargeValueReservedFlags, Not(Equals), uint32(0))
+
+	gresp := s.client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(bytes.Equal(gresp.Value(), value), IsTrue)
+	c.Assert(gresp.Flags(), Equals, uint32(7))
+
+	// Incompressible values are stored as is.
+	value = randomBytes(500)
+	resp = s.client.Set(&memcache.Item{Key: "key", Value: value})
+	c.Assert(resp.Error(), IsNil)
+
+	item, ok = s.server.Item("key")
+	c.Assert(ok, IsTrue)
+	c.Assert(bytes.Equal(item.Value, value), IsTrue)
+	c.Assert(item.Flags, Equals, uint32(0))
+}
+
+func (s *LargeValueClientSuite) TestChunking(c *C) {
+	value := randomBytes(3500)
+
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: value, Flags: 1})
+	c.Assert(resp.Error(), IsNil)
+
+	// The manifest and 4 chunks.
+	c.Assert(s.server.NumItems(), Equals, 5)
+
+	gresp := s.client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(bytes.Equal(gresp.Value(), value), IsTrue)
+	c.Assert(gresp.Flags(), Equals, uint32(1))
+
+	// CAS overwrites invalidate the previous value's chunks.
+	newValue := randomBytes(2500)
+	resp = s.client.Set(&memcache.Item{
+		Key:           "key",
+		Value:         newValue,
+		DataVersionId: gresp.DataVersionId(),
+	})
+	c.Assert(resp.Error(), IsNil)
+
+	resp = s.client.Set(&memcache.Item{
+		Key:           "key",
+		Value:         value,
+		DataVersionId: gresp.DataVersionId(),
+	})
+	c.Assert(resp.Status(), Equals, memcache.StatusKeyExists)
+
+	gresp = s.client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(bytes.Equal(gresp.Value(), newValue), IsTrue)
+}
+
+func (s *LargeValueClientSuite) TestMissingChunk(c *C) {
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: randomBytes(2500)})
+	c.Assert(resp.Error(), IsNil)
+
+	// Evict all chunks, but keep the manifest.
+	item, ok := s.server.Item("key")
+	c.Assert(ok, IsTrue)
+	c.Assert(s.client.Flush(0).Error(), IsNil)
+	item.DataVersionId = 0
+	resp = memcache.NewRawBinaryClient(0, s.conn).Set(&item)
+	c.Assert(resp.Error(), IsNil)
+
+	gresp := s.client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(gresp.Status(), Equals, memcache.StatusKeyNotFound)
+}
+
+func (s *LargeValueClientSuite) TestMultiOps(c *C) {
+	values := map[string][]byte{
+		"small":        []byte("small"),
+		"compressible": []byte(strings.Repeat("a", 5000)),
+		"chunked":      randomBytes(2500),
+	}
+
+	items := []*memcache.Item{}
+	keys := []string{}
+	for key, value := range values {
+		keys = append(keys, key)
+		items = append(items, &memcache.Item{Key: key, Value: value})
+	}
+	items = append(items, &memcache.Item{
+		Key:   "reserved",
+		Value: []byte("value"),
+		Flags: 0x10000000,
+	})
+
+	responses := s.client.SetMulti(items)
+	c.Assert(responses, HasLen, len(items))
+	for _, resp := range responses {
+		if resp.Key() == "reserved" {
+			c.Assert(resp.Error(), NotNil)
+		} else {
+			c.Assert(resp.Error(), IsNil)
+		}
+	}
+
+	gresps := s.client.GetMulti(append(keys, "missing"))
+	c.Assert(gresps, HasLen, len(keys)+1)
+	for key, value := range values {
+		c.Assert(gresps[key].Error(), IsNil)
+		c.Assert(bytes.Equal(gresps[key].Value(), value), IsTrue)
+	}
+	c.Assert(gresps["missing"].Status(), Equals, memcache.StatusKeyNotFound)
+
+	responses = s.client.AddMulti(items[:1])
+	c.Assert(responses, HasLen, 1)
+	c.Assert(responses[0].Status(), Equals, memcache.StatusKeyExists)
+}
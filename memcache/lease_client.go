@@ -0,0 +1,306 @@
+package memcache
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/dropbox/godropbox/time2"
+)
+
+const (
+	// The Item.Flags value of lease sentinels.  Items stored through
+	// LeaseClient must not use this exact flags value.
+	LeaseSentinelFlags uint32 = 0x00800000
+
+	defaultLeaseTTL          = 10 // seconds
+	defaultStaleTTL          = 60 // seconds
+	defaultLeaseWaitTimeout  = time.Second
+	defaultLeasePollInterval = 20 * time.Millisecond
+
+	staleKeyPrefix = "stale:"
+)
+
+var leaseSentinelValue = []byte("lease")
+
+// Options for configuring LeaseClient.
+type LeaseOptions struct {
+	// The lease's expiration (in seconds), i.e., the maximum amount of time
+	// other callers defer to a lease holder which never completes.  Defaults
+	// to 10 seconds.
+	LeaseTTL uint32
+
+	// The expiration (in seconds) of the stale copies created by Invalidate.
+	// Defaults to 60 seconds.
+	StaleTTL uint32
+
+	// The maximum amount of time a caller waits for another caller's lease
+	// to complete (when there is no stale copy) before recomputing the value
+	// itself.  Defaults to 1 second.
+	WaitTimeout time.Duration
+
+	// How often waiting callers poll for the lease's completion.  Defaults to
+	// 20 milliseconds.
+	PollInterval time.Duration
+
+	// Defaults to time2.DefaultClock.
+	Clock time2.Clock
+}
+
+// Computes a missing item's value.  The returned item's key is ignored.
+type ComputeFunc func() (*Item, error)
+
+// A Client wrapper which protects the backing store from thundering herds
+// on cache misses.
+//
+// On a miss, GetOrCompute tries to acquire the key's lease by adding a lease
+// sentinel item.  Only the lease holder computes the value, which is then
+// stored via CAS against the sentinel (hence the computed value never
+// overwrites a concurrent write / invalidation).  Other callers return the
+// key's stale copy (if any), or poll until the lease holder stores the
+// value.  Callers which time out compute the value themselves, but do not
+// store it.
+//
+// Invalidate should be used in place of Delete for keys read via
+// GetOrCompute.  It keeps a stale copy of the current value (for StaleTTL),
+// which is served to callers while the new value is being computed.
+//
+// NOTE: Get and GetMulti treat lease sentinels as misses.  Stale copies are
+// not kept for keys which are too long to be prefixed.
+type LeaseClient struct {
+	Client
+
+	options LeaseOptions
+}
+
+// This creates a new LeaseClient which wraps the given client.
+func NewLeaseClient(client Client, options LeaseOptions) *LeaseClient {
+	if options.LeaseTTL == 0 {
+		options.LeaseTTL = defaultLeaseTTL
+	}
+	if options.StaleTTL == 0 {
+		options.StaleTTL = defaultStaleTTL
+	}
+	if options.WaitTimeout <= 0 {
+		options.WaitTimeout = defaultLeaseWaitTimeout
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = defaultLeasePollInterval
+	}
+	if options.Clock == nil {
+		options.Clock = time2.DefaultClock
+	}
+
+	return &LeaseClient{
+		Client:  client,
+		options: options,
+	}
+}
+
+func isLeaseSentinel(resp GetResponse) bool {
+	return resp.Status() == StatusNoError &&
+		resp.Flags() == LeaseSentinelFlags &&
+		bytes.Equal(resp.Value(), leaseSentinelValue)
+}
+
+func staleKey(key string) string {
+	return staleKeyPrefix + key
+}
+
+func responseItem(resp GetResponse) *Item {
+	return &Item{
+		Key:           resp.Key(),
+		Value:         resp.Value(),
+		Flags:         resp.Flags(),
+		DataVersionId: resp.DataVersionId(),
+	}
+}
+
+// Converts lease sentinels into misses.
+func hideLeaseSentinel(resp GetResponse) GetResponse {
+	if resp.Error() == nil && isLeaseSentinel(resp) {
+		return NewGetResponse(resp.Key(), StatusKeyNotFound, 0, nil, 0)
+	}
+	return resp
+}
+
+// See Client interface for documentation.
+func (c *LeaseClient) Get(key string) GetResponse {
+	return hideLeaseSentinel(c.Client.Get(key))
+}
+
+// See Client interface for documentation.
+func (c *LeaseClient) GetMulti(keys []string) map[string]GetResponse {
+	responses := c.Client.GetMulti(keys)
+	for key, resp := range responses {
+		responses[key] = hideLeaseSentinel(resp)
+	}
+	return responses
+}
+
+// This returns the key's item, computing (and storing) the item on cache
+// miss.  isStale is true when the returned item is the key's stale copy
+// (i.e., another caller is computing the key's new value).
+func (c *LeaseClient) GetOrCompute(
+	key string,
+	compute ComputeFunc) (
+	item *Item,
+	isStale bool,
+	err error) {
+
+	deadline := c.options.Clock.Now().Add(c.options.WaitTimeout)
+	checkedStale := false
+
+	for {
+		resp := c.Client.Get(key)
+		if resp.Error() != nil {
+			return nil, false, resp.Error()
+		}
+
+		if resp.Status() == StatusKeyNotFound {
+			leaseId, acquired, err := c.acquireLease(key)
+			if err != nil {
+				return nil, false, err
+			}
+			if acquired {
+				item, err := c.computeWithLease(key, leaseId, compute)
+				return item, false, err
+			}
+		} else if !isLeaseSentinel(resp) {
+			return responseItem(resp), false, nil
+		}
+
+		// Another caller holds the lease.
+		if !checkedStale {
+			checkedStale = true
+
+			staleResp := c.Client.Get(staleKey(key))
+			if staleResp.Error() == nil &&
+				staleResp.Status() == StatusNoError {
+
+				item := responseItem(staleResp)
+				item.Key = key
+				item.DataVersionId = 0
+				return item, true, nil
+			}
+		}
+
+		if !c.options.Clock.Now().Before(deadline) {
+			// Give up on the lease holder.  The lease holder is responsible
+			// for storing the value.
+			item, err := c.compute(key, compute)
+			return item, false, err
+		}
+
+		c.options.Clock.Sleep(c.options.PollInterval)
+	}
+}
+
+// Returns the lease's CAS id when the lease is acquired.
+func (c *LeaseClient) acquireLease(key string) (uint64, bool, error) {
+	resp := c.Client.Add(&Item{
+		Key:        key,
+		Value:      leaseSentinelValue,
+		Flags:      LeaseSentinelFlags,
+		Expiration: c.options.LeaseTTL,
+	})
+	if resp.Status() == StatusKeyExists ||
+		resp.Status() == StatusItemNotStored {
+
+		return 0, false, nil
+	}
+	if resp.Error() != nil {
+		return 0, false, resp.Error()
+	}
+
+	if resp.DataVersionId() != 0 {
+		return resp.DataVersionId(), true, nil
+	}
+
+	// Some protocols (e.g., ascii) do not return the CAS id on add.
+	getResp := c.Client.Get(key)
+	if getResp.Error() != nil {
+		return 0, false, getResp.Error()
+	}
+	if !isLeaseSentinel(getResp) {
+		return 0, false, nil
+	}
+	return getResp.DataVersionId(), true, nil
+}
+
+func (c *LeaseClient) compute(key string, compute ComputeFunc) (*Item, error) {
+	item, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, errors.Newf("Computed nil item for %s", key)
+	}
+
+	result := *item
+	result.Key = key
+	result.DataVersionId = 0
+	return &result, nil
+}
+
+func (c *LeaseClient) computeWithLease(
+	key string,
+	leaseId uint64,
+	compute ComputeFunc) (*Item, error) {
+
+	item, err := c.compute(key, compute)
+	if err != nil {
+		c.releaseLease(key, leaseId)
+		return nil, err
+	}
+
+	toStore := *item
+	toStore.DataVersionId = leaseId
+
+	// A failed CAS means the lease was invalidated (or expired) while the
+	// value was being computed, in which case the computed value may already
+	// be stale, and is not stored.
+	resp := c.Client.Set(&toStore)
+	switch resp.Status() {
+	case StatusKeyExists, StatusKeyNotFound:
+		return item, nil
+	}
+	if resp.Error() != nil {
+		return nil, resp.Error()
+	}
+
+	item.DataVersionId = resp.DataVersionId()
+	return item, nil
+}
+
+// Best effort release, which lets other callers retry without waiting for
+// the lease to expire.
+func (c *LeaseClient) releaseLease(key string, leaseId uint64) {
+	resp := c.Client.Get(key)
+	if resp.Error() == nil &&
+		isLeaseSentinel(resp) &&
+		resp.DataVersionId() == leaseId {
+
+		_ = c.Client.Delete(key)
+	}
+}
+
+// This deletes the key, while keeping a stale copy of the key's current
+// value, which GetOrCompute serves while the new value is being computed.
+func (c *LeaseClient) Invalidate(key string) MutateResponse {
+	resp := c.Client.Get(key)
+	if resp.Error() == nil &&
+		resp.Status() == StatusNoError &&
+		!isLeaseSentinel(resp) &&
+		isValidKeyString(staleKey(key)) {
+
+		_ = c.Client.Set(&Item{
+			Key:        staleKey(key),
+			Value:      resp.Value(),
+			Flags:      resp.Flags(),
+			Expiration: c.options.StaleTTL,
+		})
+	}
+
+	return c.Client.Delete(key)
+}
@@ -0,0 +1,204 @@
+package memcache_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/memcache/memcachetest"
+	"github.com/dropbox/godropbox/net2"
+)
+
+type LeaseClientSuite struct {
+	server *memcachetest.Server
+	raw    memcache.Client
+}
+
+var _ = Suite(&LeaseClientSuite{})
+
+func (s *LeaseClientSuite) SetUpTest(c *C) {
+	server, err := memcachetest.NewServer(memcachetest.Options{})
+	c.Assert(err, IsNil)
+	s.server = server
+
+	manager := memcache.NewStaticShardManager(
+		[]string{server.Addr()},
+		func(key string, numShard int) int { return 0 },
+		net2.ConnectionOptions{MaxActiveConnections: 20})
+	s.raw = memcache.NewShardedClient(manager, memcache.NewRawBinaryClient)
+}
+
+func (s *LeaseClientSuite) TearDownTest(c *C) {
+	_ = s.server.Close()
+}
+
+func (s *LeaseClientSuite) newClient(waitTimeout time.Duration) *memcache.LeaseClient {
+	return memcache.NewLeaseClient(
+		s.raw,
+		memcache.LeaseOptions{
+			WaitTimeout:  waitTimeout,
+			PollInterval: 5 * time.Millisecond,
+		})
+}
+
+func valueFunc(value string) memcache.ComputeFunc {
+	return func() (*memcache.Item, error) {
+		return &memcache.Item{Value: []byte(value)}, nil
+	}
+}
+
+func (s *LeaseClientSuite) TestComputeOnMiss(c *C) {
+	client := s.newClient(time.Second)
+
+	numComputes := 0
+	compute := func() (*memcache.Item, error) {
+		numComputes++
+		return &memcache.Item{Value: []byte("value"), Flags: 3}, nil
+	}
+
+	item, isStale, err := client.GetOrCompute("key", compute)
+	c.Assert(err, IsNil)
+	c.Assert(isStale, IsFalse)
+	c.Assert(item.Key, Equals, "key")
+	c.Assert(string(item.Value), Equals, "value")
+
+	item, isStale, err = client.GetOrCompute("key", compute)
+	c.Assert(err, IsNil)
+	c.Assert(isStale, IsFalse)
+	c.Assert(string(item.Value), Equals, "value")
+	c.Assert(item.Flags, Equals, uint32(3))
+	c.Assert(numComputes, Equals, 1)
+
+	stored, ok := s.server.Item("key")
+	c.Assert(ok, IsTrue)
+	c.Assert(string(stored.Value), Equals, "value")
+}
+
+func (s *LeaseClientSuite) TestSingleComputation(c *C) {
+	client := s.newClient(5 * time.Second)
+
+	var numComputes int32
+	compute := func() (*memcache.Item, error) {
+		atomic.AddInt32(&numComputes, 1)
+		time.Sleep(50 * time.Millisecond)
+		return &memcache.Item{Value: []byte("value")}, nil
+	}
+
+	wg := sync.WaitGroup{}
+	values := make([]string, 10)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			item, _, err := client.GetOrCompute("key", compute)
+			if err == nil {
+				values[i] = string(item.Value)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	c.Assert(atomic.LoadInt32(&numComputes), Equals, int32(1))
+	for _, value := range values {
+		c.Assert(value, Equals, "value")
+	}
+}
+
+// Returns after the compute func (which blocks until release is closed)
+// acquired the key's lease.
+func (s *LeaseClientSuite) holdLease(
+	c *C,
+	client *memcache.LeaseClient,
+	value string) (
+	release chan struct{},
+	done chan struct{}) {
+
+	release = make(chan struct{})
+	done = make(chan struct{})
+	acquired := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		_, _, _ = client.GetOrCompute(
+			"key",
+			func() (*memcache.Item, error) {
+				close(acquired)
+				<-release
+				return &memcache.Item{Value: []byte(value)}, nil
+			})
+	}()
+	<-acquired
+
+	// Lease sentinels are hidden from plain reads.
+	resp := client.Get("key")
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(resp.Status(), Equals, memcache.StatusKeyNotFound)
+
+	return release, done
+}
+
+func (s *LeaseClientSuite) TestStaleValue(c *C) {
+	client := s.newClient(time.Second)
+
+	_, _, err := client.GetOrCompute("key", valueFunc("old"))
+	c.Assert(err, IsNil)
+
+	resp := client.Invalidate("key")
+	c.Assert(resp.Error(), IsNil)
+
+	release, done := s.holdLease(c, client, "new")
+
+	item, isStale, err := client.GetOrCompute("key", valueFunc("other"))
+	c.Assert(err, IsNil)
+	c.Assert(isStale, IsTrue)
+	c.Assert(item.Key, Equals, "key")
+	c.Assert(string(item.Value), Equals, "old")
+
+	close(release)
+	<-done
+
+	item, isStale, err = client.GetOrCompute("key", valueFunc("other"))
+	c.Assert(err, IsNil)
+	c.Assert(isStale, IsFalse)
+	c.Assert(string(item.Value), Equals, "new")
+}
+
+func (s *LeaseClientSuite) TestWaitTimeout(c *C) {
+	client := s.newClient(20 * time.Millisecond)
+
+	release, done := s.holdLease(c, client, "slow")
+
+	item, isStale, err := client.GetOrCompute("key", valueFunc("fast"))
+	c.Assert(err, IsNil)
+	c.Assert(isStale, IsFalse)
+	c.Assert(string(item.Value), Equals, "fast")
+
+	// Callers which time out do not store their values.
+	c.Assert(client.Get("key").Status(), Equals, memcache.StatusKeyNotFound)
+
+	close(release)
+	<-done
+
+	c.Assert(string(client.Get("key").Value()), Equals, "slow")
+}
+
+func (s *LeaseClientSuite) TestInvalidatedLease(c *C) {
+	client := s.newClient(time.Second)
+
+	release, done := s.holdLease(c, client, "stale")
+
+	// The lease holder's value is computed before the invalidation, hence it
+	// must not be stored.
+	resp := client.Invalidate("key")
+	c.Assert(resp.Error(), IsNil)
+
+	close(release)
+	<-done
+
+	c.Assert(client.Get("key").Status(), Equals, memcache.StatusKeyNotFound)
+}
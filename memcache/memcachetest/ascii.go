@@ -0,0 +1,494 @@
+package memcachetest
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/memcache"
+)
+
+const maxKeyLength = 250
+
+func formatUint(value uint64) string {
+	return strconv.FormatUint(value, 10)
+}
+
+func parseUint(value []byte) (uint64, bool) {
+	result, err := strconv.ParseUint(string(value), 10, 64)
+	return result, err == nil
+}
+
+func parseUint32(value string) (uint32, bool) {
+	result, err := strconv.ParseUint(value, 10, 32)
+	return uint32(result), err == nil
+}
+
+func isValidKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// The response line sent in place of the real response when a fault with a
+// status is injected.
+func asciiFaultLine(status memcache.ResponseStatus) string {
+	return "SERVER_ERROR " + statusMessage(status)
+}
+
+type asciiConn struct {
+	server *Server
+	reader *bufio.Reader
+	writer *bufio.Writer
+	closed <-chan struct{}
+}
+
+func (s *Server) serveAscii(
+	reader *bufio.Reader,
+	writer *bufio.Writer,
+	closed <-chan struct{}) {
+
+	conn := &asciiConn{
+		server: s,
+		reader: reader,
+		writer: writer,
+		closed: closed,
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if !conn.handle(strings.Fields(line)) {
+			return
+		}
+
+		if writer.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (c *asciiConn) writeLine(strs ...string) {
+	for _, str := range strs {
+		_, _ = c.writer.WriteString(str)
+	}
+	_, _ = c.writer.WriteString("\r\n")
+}
+
+// Handles a single request.  Returns false if the connection should be
+// closed.
+func (c *asciiConn) handle(args []string) bool {
+	if len(args) == 0 {
+		c.writeLine("ERROR")
+		return true
+	}
+
+	cmd := args[0]
+	switch cmd {
+	case "get", "gets":
+		return c.handleGet(cmd, args[1:], nil)
+	case "gat", "gats":
+		if len(args) < 3 {
+			c.writeLine("ERROR")
+			return true
+		}
+		expiration, ok := parseUint32(args[1])
+		if !ok {
+			c.writeLine("CLIENT_ERROR invalid exptime argument")
+			return true
+		}
+		return c.handleGet(cmd, args[2:], &expiration)
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return c.handleStore(cmd, args[1:])
+	case "delete":
+		return c.handleDelete(args[1:])
+	case "incr", "decr":
+		return c.handleArithmetic(cmd, args[1:])
+	case "touch":
+		return c.handleTouch(args[1:])
+	case "flush_all":
+		return c.handleFlush(args[1:])
+	case "stats":
+		return c.handleStats(args[1:])
+	case "version":
+		if process, keepOpen := c.checkFault(cmd, false); !process {
+			return keepOpen
+		}
+		c.writeLine("VERSION ", Version)
+		return true
+	case "verbosity":
+		return c.handleVerbosity(args[1:])
+	case "quit":
+		return false
+	}
+
+	c.writeLine("ERROR")
+	return true
+}
+
+// Applies the fault matching the command.  Returns true if the request
+// should be processed normally, and false otherwise (keepOpen indicates
+// whether the connection should remain open).
+func (c *asciiConn) checkFault(
+	cmd string,
+	noReply bool) (process bool, keepOpen bool) {
+
+	fault := c.server.applyFault(cmd, c.closed)
+	if fault == nil {
+		return true, true
+	}
+	if fault.Disconnect {
+		return false, false
+	}
+	if fault.Status != memcache.StatusNoError {
+		if !noReply {
+			c.writeLine(asciiFaultLine(fault.Status))
+		}
+		return false, true
+	}
+	return true, true
+}
+
+func (c *asciiConn) handleGet(
+	cmd string,
+	keys []string,
+	expiration *uint32) bool {
+
+	if len(keys) == 0 {
+		c.writeLine("ERROR")
+		return true
+	}
+	for _, key := range keys {
+		if !isValidKey(key) {
+			c.writeLine("CLIENT_ERROR bad command line format")
+			return true
+		}
+	}
+
+	if process, keepOpen := c.checkFault(cmd, false); !process {
+		return keepOpen
+	}
+
+	withCas := cmd == "gets" || cmd == "gats"
+
+	s := c.server
+	s.mutex.Lock()
+	for _, key := range keys {
+		var i *item
+		if expiration == nil {
+			i = s.get(key)
+		} else {
+			i = s.touch(key, *expiration)
+			if i == nil {
+				s.stats["get_misses"]++
+			} else {
+				s.stats["get_hits"]++
+			}
+		}
+
+		if i == nil {
+			continue
+		}
+
+		line := []string{
+			"VALUE ", i.key,
+			" ", formatUint(uint64(i.flags)),
+			" ", strconv.Itoa(len(i.value)),
+		}
+		if withCas {
+			line = append(line, " ", formatUint(i.cas))
+		}
+		c.writeLine(line...)
+		_, _ = c.writer.Write(i.value)
+		c.writeLine()
+	}
+	s.mutex.Unlock()
+
+	c.writeLine("END")
+	return true
+}
+
+func isNoReply(args []string, index int) bool {
+	return len(args) > index && args[index] == "noreply"
+}
+
+func (c *asciiConn) handleStore(cmd string, args []string) bool {
+	// <key> <flags> <exptime> <bytes> [<cas unique>] [noreply]
+	numArgs := 4
+	if cmd == "cas" {
+		numArgs = 5
+	}
+	if len(args) < numArgs || len(args) > numArgs+1 {
+		c.writeLine("ERROR")
+		return true
+	}
+
+	key := args[0]
+	flags, flagsOk := parseUint32(args[1])
+	expiration, expirationOk := parseUint32(args[2])
+	size, sizeErr := strconv.Atoi(args[3])
+	var cas uint64
+	casOk := true
+	if cmd == "cas" {
+		cas, casOk = parseUint([]byte(args[4]))
+	}
+	noReply := isNoReply(args, numArgs)
+
+	if !isValidKey(key) || !flagsOk || !expirationOk || sizeErr != nil ||
+		size < 0 || !casOk {
+
+		c.writeLine("CLIENT_ERROR bad command line format")
+		return true
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return false
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		c.writeLine("CLIENT_ERROR bad data chunk")
+		return true
+	}
+	value := data[:size]
+
+	if process, keepOpen := c.checkFault(cmd, noReply); !process {
+		return keepOpen
+	}
+
+	mode := storeSet
+	switch cmd {
+	case "add":
+		mode = storeAdd
+	case "replace":
+		mode = storeReplace
+	case "append":
+		mode = storeAppend
+	case "prepend":
+		mode = storePrepend
+	}
+
+	c.server.mutex.Lock()
+	status, _ := c.server.store(mode, key, value, flags, expiration, cas)
+	c.server.mutex.Unlock()
+
+	if noReply {
+		return true
+	}
+
+	switch status {
+	case memcache.StatusNoError:
+		c.writeLine("STORED")
+	case memcache.StatusKeyExists:
+		if cmd == "cas" {
+			c.writeLine("EXISTS")
+		} else {
+			c.writeLine("NOT_STORED")
+		}
+	case memcache.StatusKeyNotFound:
+		if cmd == "cas" {
+			c.writeLine("NOT_FOUND")
+		} else {
+			c.writeLine("NOT_STORED")
+		}
+	case memcache.StatusValueTooLarge:
+		c.writeLine("SERVER_ERROR object too large for cache")
+	default:
+		c.writeLine("NOT_STORED")
+	}
+	return true
+}
+
+func (c *asciiConn) handleDelete(args []string) bool {
+	// <key> [noreply]
+	if len(args) < 1 || len(args) > 2 {
+		c.writeLine("ERROR")
+		return true
+	}
+	if !isValidKey(args[0]) {
+		c.writeLine("CLIENT_ERROR bad command line format")
+		return true
+	}
+	noReply := isNoReply(args, 1)
+
+	if process, keepOpen := c.checkFault("delete", noReply); !process {
+		return keepOpen
+	}
+
+	c.server.mutex.Lock()
+	status := c.server.remove(args[0], 0)
+	c.server.mutex.Unlock()
+
+	if noReply {
+		return true
+	}
+
+	if status == memcache.StatusNoError {
+		c.writeLine("DELETED")
+	} else {
+		c.writeLine("NOT_FOUND")
+	}
+	return true
+}
+
+func (c *asciiConn) handleArithmetic(cmd string, args []string) bool {
+	// <key> <value> [noreply]
+	if len(args) < 2 || len(args) > 3 {
+		c.writeLine("ERROR")
+		return true
+	}
+	delta, ok := parseUint([]byte(args[1]))
+	if !isValidKey(args[0]) || !ok {
+		c.writeLine("CLIENT_ERROR invalid numeric delta argument")
+		return true
+	}
+	noReply := isNoReply(args, 2)
+
+	if process, keepOpen := c.checkFault(cmd, noReply); !process {
+		return keepOpen
+	}
+
+	c.server.mutex.Lock()
+	status, count, _ := c.server.arithmetic(
+		args[0],
+		cmd == "incr",
+		delta,
+		false,
+		0,
+		0)
+	c.server.mutex.Unlock()
+
+	if noReply {
+		return true
+	}
+
+	switch status {
+	case memcache.StatusNoError:
+		c.writeLine(formatUint(count))
+	case memcache.StatusKeyNotFound:
+		c.writeLine("NOT_FOUND")
+	default:
+		c.writeLine(
+			"CLIENT_ERROR cannot increment or decrement non-numeric value")
+	}
+	return true
+}
+
+func (c *asciiConn) handleTouch(args []string) bool {
+	// <key> <exptime> [noreply]
+	if len(args) < 2 || len(args) > 3 {
+		c.writeLine("ERROR")
+		return true
+	}
+	expiration, ok := parseUint32(args[1])
+	if !isValidKey(args[0]) || !ok {
+		c.writeLine("CLIENT_ERROR bad command line format")
+		return true
+	}
+	noReply := isNoReply(args, 2)
+
+	if process, keepOpen := c.checkFault("touch", noReply); !process {
+		return keepOpen
+	}
+
+	c.server.mutex.Lock()
+	i := c.server.touch(args[0], expiration)
+	c.server.mutex.Unlock()
+
+	if noReply {
+		return true
+	}
+
+	if i != nil {
+		c.writeLine("TOUCHED")
+	} else {
+		c.writeLine("NOT_FOUND")
+	}
+	return true
+}
+
+func (c *asciiConn) handleFlush(args []string) bool {
+	// [delay] [noreply]
+	noReply := false
+	var delay uint32
+	for _, arg := range args {
+		if arg == "noreply" {
+			noReply = true
+			continue
+		}
+
+		var ok bool
+		delay, ok = parseUint32(arg)
+		if !ok {
+			c.writeLine("CLIENT_ERROR bad command line format")
+			return true
+		}
+	}
+
+	if process, keepOpen := c.checkFault("flush_all", noReply); !process {
+		return keepOpen
+	}
+
+	c.server.mutex.Lock()
+	c.server.flush(delay)
+	c.server.mutex.Unlock()
+
+	if !noReply {
+		c.writeLine("OK")
+	}
+	return true
+}
+
+func (c *asciiConn) handleStats(args []string) bool {
+	if len(args) != 0 {
+		// Stats sub-groups (e.g., "stats items") are not supported.
+		c.writeLine("END")
+		return true
+	}
+
+	if process, keepOpen := c.checkFault("stats", false); !process {
+		return keepOpen
+	}
+
+	c.server.mutex.Lock()
+	entries := c.server.statEntries()
+	c.server.mutex.Unlock()
+
+	for _, entry := range entries {
+		c.writeLine("STAT ", entry[0], " ", entry[1])
+	}
+	c.writeLine("END")
+	return true
+}
+
+func (c *asciiConn) handleVerbosity(args []string) bool {
+	// <level> [noreply]
+	if len(args) < 1 || len(args) > 2 {
+		c.writeLine("ERROR")
+		return true
+	}
+	if _, ok := parseUint32(args[0]); !ok {
+		c.writeLine("CLIENT_ERROR bad command line format")
+		return true
+	}
+	noReply := isNoReply(args, 1)
+
+	if process, keepOpen := c.checkFault("verbosity", noReply); !process {
+		return keepOpen
+	}
+
+	if !noReply {
+		c.writeLine("OK")
+	}
+	return true
+}
@@ -0,0 +1,468 @@
+package memcachetest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/dropbox/godropbox/memcache"
+)
+
+const (
+	reqMagicByte  uint8 = 0x80
+	respMagicByte uint8 = 0x81
+
+	headerLength = 24
+
+	// The maximum request body accepted by the server.
+	maxBodyLength = 16 * 1024 * 1024
+)
+
+// Binary protocol opcodes (see memcache/constants.go).
+const (
+	opGet       uint8 = 0x00
+	opSet       uint8 = 0x01
+	opAdd       uint8 = 0x02
+	opReplace   uint8 = 0x03
+	opDelete    uint8 = 0x04
+	opIncrement uint8 = 0x05
+	opDecrement uint8 = 0x06
+	opQuit      uint8 = 0x07
+	opFlush     uint8 = 0x08
+	opNoOp      uint8 = 0x0a
+	opVersion   uint8 = 0x0b
+	opGetK      uint8 = 0x0c
+	opAppend    uint8 = 0x0e
+	opPrepend   uint8 = 0x0f
+	opStat      uint8 = 0x10
+	opVerbosity uint8 = 0x1b
+	opTouch     uint8 = 0x1c
+	opGAT       uint8 = 0x1d
+
+	opSASLListMechs uint8 = 0x20
+	opSASLAuth      uint8 = 0x21
+)
+
+// The SASL statuses returned by memcached.
+const (
+	statusSASLAuthError memcache.ResponseStatus = 0x20
+)
+
+const saslMechanismPlain = "PLAIN"
+
+// The ascii command names used for matching faults against binary requests.
+var binaryCommandNames = map[uint8]string{
+	opGet:       "get",
+	opGetK:      "get",
+	opSet:       "set",
+	opAdd:       "add",
+	opReplace:   "replace",
+	opDelete:    "delete",
+	opIncrement: "incr",
+	opDecrement: "decr",
+	opQuit:      "quit",
+	opFlush:     "flush_all",
+	opNoOp:      "noop",
+	opVersion:   "version",
+	opAppend:    "append",
+	opPrepend:   "prepend",
+	opStat:      "stats",
+	opVerbosity: "verbosity",
+	opTouch:     "touch",
+	opGAT:       "gat",
+
+	opSASLListMechs: "sasl_list_mechs",
+	opSASLAuth:      "sasl_auth",
+}
+
+func statusMessage(status memcache.ResponseStatus) string {
+	switch status {
+	case memcache.StatusNoError:
+		return "No error"
+	case memcache.StatusKeyNotFound:
+		return "Not found"
+	case memcache.StatusKeyExists:
+		return "Data exists for key."
+	case memcache.StatusValueTooLarge:
+		return "Too large."
+	case memcache.StatusInvalidArguments:
+		return "Invalid arguments"
+	case memcache.StatusItemNotStored:
+		return "Not stored."
+	case memcache.StatusIncrDecrOnNonNumericValue:
+		return "Non-numeric server-side value for incr or decr"
+	case memcache.StatusUnknownCommand:
+		return "Unknown command"
+	case memcache.StatusOutOfMemory:
+		return "Out of memory"
+	case memcache.StatusNotSupported:
+		return "Not supported"
+	case memcache.StatusInternalError:
+		return "Internal error"
+	case memcache.StatusBusy:
+		return "Busy"
+	case memcache.StatusTempFailure:
+		return "Temporary failure"
+	case statusSASLAuthError:
+		return "Auth failure."
+	}
+	return "Unknown error"
+}
+
+type binaryRequest struct {
+	opCode uint8
+	opaque uint32
+	cas    uint64
+	extras []byte
+	key    []byte
+	value  []byte
+}
+
+type binaryResponse struct {
+	status memcache.ResponseStatus
+	cas    uint64
+	extras []byte
+	key    []byte
+	value  []byte
+}
+
+func readBinaryRequest(reader *bufio.Reader) (*binaryRequest, error) {
+	hdr := make([]byte, headerLength)
+	if _, err := io.ReadFull(reader, hdr); err != nil {
+		return nil, err
+	}
+	if hdr[0] != reqMagicByte {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	keyLength := int(binary.BigEndian.Uint16(hdr[2:4]))
+	extrasLength := int(hdr[4])
+	bodyLength := int(binary.BigEndian.Uint32(hdr[8:12]))
+	if bodyLength > maxBodyLength || keyLength+extrasLength > bodyLength {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	body := make([]byte, bodyLength)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+
+	return &binaryRequest{
+		opCode: hdr[1],
+		opaque: binary.BigEndian.Uint32(hdr[12:16]),
+		cas:    binary.BigEndian.Uint64(hdr[16:24]),
+		extras: body[:extrasLength],
+		key:    body[extrasLength : extrasLength+keyLength],
+		value:  body[extrasLength+keyLength:],
+	}, nil
+}
+
+func writeBinaryResponse(
+	writer *bufio.Writer,
+	req *binaryRequest,
+	resp *binaryResponse) {
+
+	hdr := make([]byte, headerLength)
+	hdr[0] = respMagicByte
+	hdr[1] = req.opCode
+	binary.BigEndian.PutUint16(hdr[2:4], uint16(len(resp.key)))
+	hdr[4] = uint8(len(resp.extras))
+	binary.BigEndian.PutUint16(hdr[6:8], uint16(resp.status))
+	binary.BigEndian.PutUint32(
+		hdr[8:12],
+		uint32(len(resp.extras)+len(resp.key)+len(resp.value)))
+	binary.BigEndian.PutUint32(hdr[12:16], req.opaque)
+	binary.BigEndian.PutUint64(hdr[16:24], resp.cas)
+
+	_, _ = writer.Write(hdr)
+	_, _ = writer.Write(resp.extras)
+	_, _ = writer.Write(resp.key)
+	_, _ = writer.Write(resp.value)
+}
+
+// Returns a status-only response.  Similar to memcached, non-get error
+// responses carry a human readable message as the value.
+func statusResponse(status memcache.ResponseStatus) *binaryResponse {
+	resp := &binaryResponse{status: status}
+	if status != memcache.StatusNoError {
+		resp.value = []byte(statusMessage(status))
+	}
+	return resp
+}
+
+func (s *Server) serveBinary(
+	reader *bufio.Reader,
+	writer *bufio.Writer,
+	closed <-chan struct{}) {
+
+	authenticated := s.options.SASLUsername == ""
+	for {
+		req, err := readBinaryRequest(reader)
+		if err != nil {
+			return
+		}
+
+		if req.opCode == opQuit {
+			writeBinaryResponse(writer, req, statusResponse(0))
+			_ = writer.Flush()
+			return
+		}
+
+		if fault := s.applyFault(binaryCommandNames[req.opCode], closed); fault != nil {
+			if fault.Disconnect {
+				return
+			}
+			if fault.Status != memcache.StatusNoError {
+				writeBinaryResponse(writer, req, statusResponse(fault.Status))
+				if writer.Flush() != nil {
+					return
+				}
+				continue
+			}
+		}
+
+		if req.opCode == opSASLListMechs || req.opCode == opSASLAuth {
+			resp := s.handleBinarySASL(req)
+			if req.opCode == opSASLAuth {
+				authenticated = resp.status == memcache.StatusNoError
+			}
+			writeBinaryResponse(writer, req, resp)
+		} else if !authenticated {
+			writeBinaryResponse(writer, req, statusResponse(statusSASLAuthError))
+		} else if req.opCode == opStat {
+			s.handleBinaryStat(writer, req)
+		} else {
+			writeBinaryResponse(writer, req, s.handleBinary(req))
+		}
+
+		if writer.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handleBinarySASL(req *binaryRequest) *binaryResponse {
+	if s.options.SASLUsername == "" {
+		return statusResponse(memcache.StatusUnknownCommand)
+	}
+
+	if req.opCode == opSASLListMechs {
+		return &binaryResponse{value: []byte(saslMechanismPlain)}
+	}
+
+	if string(req.key) != saslMechanismPlain {
+		return statusResponse(statusSASLAuthError)
+	}
+
+	// authzid NUL authcid NUL passwd
+	fields := bytes.Split(req.value, []byte{0})
+	if len(fields) != 3 ||
+		string(fields[1]) != s.options.SASLUsername ||
+		string(fields[2]) != s.options.SASLPassword {
+
+		return statusResponse(statusSASLAuthError)
+	}
+	return &binaryResponse{value: []byte("Authenticated")}
+}
+
+func (s *Server) handleBinary(req *binaryRequest) *binaryResponse {
+	key := string(req.key)
+
+	switch req.opCode {
+	case opGet, opGetK, opGAT:
+		if !isValidKey(key) {
+			return statusResponse(memcache.StatusInvalidArguments)
+		}
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		var i *item
+		if req.opCode == opGAT {
+			if len(req.extras) != 4 {
+				return statusResponse(memcache.StatusInvalidArguments)
+			}
+			i = s.touch(key, binary.BigEndian.Uint32(req.extras))
+			if i == nil {
+				s.stats["get_misses"]++
+			} else {
+				s.stats["get_hits"]++
+			}
+		} else {
+			i = s.get(key)
+		}
+
+		if i == nil {
+			resp := &binaryResponse{status: memcache.StatusKeyNotFound}
+			if req.opCode == opGetK {
+				resp.key = req.key
+			}
+			return resp
+		}
+
+		resp := &binaryResponse{
+			cas:    i.cas,
+			extras: make([]byte, 4),
+			value:  append([]byte{}, i.value...),
+		}
+		binary.BigEndian.PutUint32(resp.extras, i.flags)
+		if req.opCode == opGetK {
+			resp.key = req.key
+		}
+		return resp
+
+	case opSet, opAdd, opReplace, opAppend, opPrepend:
+		if !isValidKey(key) {
+			return statusResponse(memcache.StatusInvalidArguments)
+		}
+
+		var flags, expiration uint32
+		mode := storeSet
+		switch req.opCode {
+		case opAdd:
+			mode = storeAdd
+		case opReplace:
+			mode = storeReplace
+		case opAppend:
+			mode = storeAppend
+		case opPrepend:
+			mode = storePrepend
+		}
+
+		if mode == storeAppend || mode == storePrepend {
+			if len(req.extras) != 0 {
+				return statusResponse(memcache.StatusInvalidArguments)
+			}
+		} else {
+			if len(req.extras) != 8 {
+				return statusResponse(memcache.StatusInvalidArguments)
+			}
+			flags = binary.BigEndian.Uint32(req.extras[0:4])
+			expiration = binary.BigEndian.Uint32(req.extras[4:8])
+		}
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		status, cas := s.store(
+			mode,
+			key,
+			append([]byte{}, req.value...),
+			flags,
+			expiration,
+			req.cas)
+		resp := statusResponse(status)
+		resp.cas = cas
+		return resp
+
+	case opDelete:
+		if !isValidKey(key) {
+			return statusResponse(memcache.StatusInvalidArguments)
+		}
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		return statusResponse(s.remove(key, req.cas))
+
+	case opIncrement, opDecrement:
+		if !isValidKey(key) || len(req.extras) != 20 {
+			return statusResponse(memcache.StatusInvalidArguments)
+		}
+
+		delta := binary.BigEndian.Uint64(req.extras[0:8])
+		initValue := binary.BigEndian.Uint64(req.extras[8:16])
+		expiration := binary.BigEndian.Uint32(req.extras[16:20])
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		status, count, cas := s.arithmetic(
+			key,
+			req.opCode == opIncrement,
+			delta,
+			expiration != 0xffffffff,
+			initValue,
+			expiration)
+		if status != memcache.StatusNoError {
+			return statusResponse(status)
+		}
+
+		resp := &binaryResponse{cas: cas, value: make([]byte, 8)}
+		binary.BigEndian.PutUint64(resp.value, count)
+		return resp
+
+	case opTouch:
+		if !isValidKey(key) || len(req.extras) != 4 {
+			return statusResponse(memcache.StatusInvalidArguments)
+		}
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		i := s.touch(key, binary.BigEndian.Uint32(req.extras))
+		if i == nil {
+			return statusResponse(memcache.StatusKeyNotFound)
+		}
+		return &binaryResponse{cas: i.cas}
+
+	case opFlush:
+		var delay uint32
+		switch len(req.extras) {
+		case 0:
+		case 4:
+			delay = binary.BigEndian.Uint32(req.extras)
+		default:
+			return statusResponse(memcache.StatusInvalidArguments)
+		}
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		s.flush(delay)
+		return statusResponse(memcache.StatusNoError)
+
+	case opVersion:
+		return &binaryResponse{value: []byte(Version)}
+
+	case opVerbosity:
+		if len(req.extras) != 4 {
+			return statusResponse(memcache.StatusInvalidArguments)
+		}
+		return statusResponse(memcache.StatusNoError)
+
+	case opNoOp:
+		return statusResponse(memcache.StatusNoError)
+	}
+
+	return statusResponse(memcache.StatusUnknownCommand)
+}
+
+// Stat responses are streamed as one packet per entry, followed by an empty
+// packet.
+func (s *Server) handleBinaryStat(writer *bufio.Writer, req *binaryRequest) {
+	if len(req.key) != 0 {
+		// Stats sub-groups are not supported.
+		writeBinaryResponse(
+			writer,
+			req,
+			statusResponse(memcache.StatusKeyNotFound))
+		return
+	}
+
+	s.mutex.Lock()
+	entries := s.statEntries()
+	s.mutex.Unlock()
+
+	for _, entry := range entries {
+		writeBinaryResponse(
+			writer,
+			req,
+			&binaryResponse{
+				key:   []byte(entry[0]),
+				value: []byte(entry[1]),
+			})
+	}
+	writeBinaryResponse(writer, req, &binaryResponse{})
+}
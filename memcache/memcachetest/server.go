@@ -0,0 +1,598 @@
+// Package memcachetest provides an in-process memcached compatible server for
+// testing memcache clients against the real wire protocols.  DO NOT USE IN
+// PRODUCTION.
+package memcachetest
+
+import (
+	"bufio"
+	"container/list"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/time2"
+)
+
+const (
+	defaultMaxValueLength = 1024 * 1024
+
+	// The version string reported by the server.
+	Version = "1.6.0-memcachetest"
+)
+
+// Options for configuring the test server.
+type Options struct {
+	// The maximum total size (key + value bytes) of the stored items.  Least
+	// recently used items are evicted once the limit is exceeded.  Zero means
+	// unlimited.
+	MaxBytes int
+
+	// The maximum number of stored items.  Least recently used items are
+	// evicted once the limit is exceeded.  Zero means unlimited.
+	MaxItems int
+
+	// The maximum value length.  Zero means 1MB.
+	MaxValueLength int
+
+	// The clock used for item expiration.  Defaults to time2.DefaultClock.
+	// Use a time2.MockClock to control expiration deterministically.
+	Clock time2.Clock
+
+	// When SASLUsername is non-empty, binary protocol connections must
+	// authenticate (using the SASL PLAIN mechanism) with these credentials
+	// before issuing other commands, and ascii protocol connections are
+	// rejected (similar to memcached's -S option).
+	SASLUsername string
+	SASLPassword string
+
+	// When TLSConfig is non-nil, the server only accepts TLS connections.
+	TLSConfig *tls.Config
+}
+
+// A Fault describes how the server misbehaves for matching requests.  Faults
+// are applied after the request is fully read, and before it is processed.
+type Fault struct {
+	// The command affected by the fault (e.g., "get", "set", "delete").
+	// Binary protocol requests use the equivalent ascii command names (e.g.,
+	// GetK maps to "get").  Empty matches every command.
+	Command string
+
+	// The response is delayed by this duration (in real time, regardless of
+	// the server's clock).
+	Delay time.Duration
+
+	// When true, the connection is closed without responding.
+	Disconnect bool
+
+	// When not StatusNoError, the request is not processed, and the server
+	// responds with this status instead.  Ascii requests receive a
+	// SERVER_ERROR line.
+	Status memcache.ResponseStatus
+
+	// The number of requests affected by the fault.  Zero means unlimited.
+	Count int
+}
+
+type item struct {
+	key      string
+	value    []byte
+	flags    uint32
+	cas      uint64
+	expireAt time.Time // zero if the item does not expire
+	storedAt time.Time
+}
+
+func (i *item) size() int {
+	return len(i.key) + len(i.value)
+}
+
+// Server is an in-process memcached compatible server which speaks both the
+// ascii and binary protocols (the protocol is detected per connection).
+type Server struct {
+	options  Options
+	listener net.Listener
+
+	mutex    sync.Mutex
+	items    map[string]*list.Element
+	lru      *list.List // front is the most recently used
+	numBytes int
+	lastCas  uint64
+	flushAt  time.Time // items stored before flushAt are invalid after flushAt
+	faults   []*Fault
+	conns    map[net.Conn]chan struct{} // closed when the conn is closed
+	closed   bool
+	stats    map[string]uint64
+
+	wg sync.WaitGroup
+}
+
+// This creates a test server listening on a random local tcp port, and starts
+// serving requests.
+func NewServer(options Options) (*Server, error) {
+	if options.Clock == nil {
+		options.Clock = time2.DefaultClock
+	}
+	if options.MaxValueLength == 0 {
+		options.MaxValueLength = defaultMaxValueLength
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to listen")
+	}
+	if options.TLSConfig != nil {
+		listener = tls.NewListener(listener, options.TLSConfig)
+	}
+
+	s := &Server{
+		options:  options,
+		listener: listener,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+		conns:    make(map[net.Conn]chan struct{}),
+		stats:    make(map[string]uint64),
+	}
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	return s, nil
+}
+
+// This returns the server's listening address (host:port).
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// This stops the server and closes all open connections.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		s.closeConnLocked(conn)
+	}
+	s.mutex.Unlock()
+
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// This closes all open connections without stopping the server.
+func (s *Server) CloseConnections() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for conn := range s.conns {
+		s.closeConnLocked(conn)
+	}
+}
+
+// Closes the connection, which also aborts the connection's fault delay (if
+// any).  This must be called while holding the mutex.
+func (s *Server) closeConnLocked(conn net.Conn) {
+	closed, ok := s.conns[conn]
+	if !ok {
+		return
+	}
+
+	close(closed)
+	delete(s.conns, conn)
+	_ = conn.Close()
+}
+
+// This adds a fault to the server.  When multiple faults match a request,
+// the earliest added fault is used.
+func (s *Server) InjectFault(fault Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = append(s.faults, &fault)
+}
+
+// This removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = nil
+}
+
+// This returns a copy of the stored item (without bumping its lru position).
+func (s *Server) Item(key string) (memcache.Item, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok || s.isExpired(elem.Value.(*item)) {
+		return memcache.Item{}, false
+	}
+
+	i := elem.Value.(*item)
+	return memcache.Item{
+		Key:           i.key,
+		Value:         append([]byte{}, i.value...),
+		Flags:         i.flags,
+		DataVersionId: i.cas,
+	}, true
+}
+
+// This returns the number of stored items (including expired items which
+// have not been reclaimed yet).
+func (s *Server) NumItems() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.lru.Len()
+}
+
+// This returns the value of a server statistic (e.g., "evictions",
+// "get_hits").
+func (s *Server) Stat(name string) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.stats[name]
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			_ = conn.Close()
+			return
+		}
+		closed := make(chan struct{})
+		s.conns[conn] = closed
+		s.stats["total_connections"]++
+		s.mutex.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(conn, closed)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn, closed <-chan struct{}) {
+	defer s.wg.Done()
+	defer func() {
+		s.mutex.Lock()
+		s.closeConnLocked(conn)
+		s.mutex.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	magic, err := reader.Peek(1)
+	if err != nil {
+		return
+	}
+
+	if magic[0] == reqMagicByte {
+		s.serveBinary(reader, writer, closed)
+	} else if s.options.SASLUsername == "" {
+		s.serveAscii(reader, writer, closed)
+	}
+}
+
+// Finds the fault matching the command, and applies its delay.  Returns nil
+// if no fault matches.  The delay is aborted once the connection is closed
+// (e.g., when the server is closed), in which case a disconnect fault is
+// returned.
+func (s *Server) applyFault(command string, closed <-chan struct{}) *Fault {
+	s.mutex.Lock()
+	var fault *Fault
+	for i, f := range s.faults {
+		if f.Command != "" && f.Command != command {
+			continue
+		}
+
+		fault = f
+		if f.Count > 0 {
+			f.Count--
+			if f.Count == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		break
+	}
+	s.mutex.Unlock()
+
+	if fault != nil && fault.Delay > 0 {
+		timer := time.NewTimer(fault.Delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-closed:
+			return &Fault{Disconnect: true}
+		}
+	}
+	return fault
+}
+
+//
+// Storage.  All methods below must be called while holding the mutex.
+//
+
+func (s *Server) expirationTime(expiration uint32) time.Time {
+	if expiration == 0 {
+		return time.Time{}
+	}
+
+	// Same as memcached: expirations up to 30 days are relative.
+	if expiration <= 60*60*24*30 {
+		return s.options.Clock.Now().Add(
+			time.Duration(expiration) * time.Second)
+	}
+	return time.Unix(int64(expiration), 0)
+}
+
+func (s *Server) isExpired(i *item) bool {
+	now := s.options.Clock.Now()
+	if !i.expireAt.IsZero() && !now.Before(i.expireAt) {
+		return true
+	}
+	return !s.flushAt.IsZero() &&
+		!now.Before(s.flushAt) &&
+		!i.storedAt.After(s.flushAt)
+}
+
+func (s *Server) removeElement(elem *list.Element) {
+	i := elem.Value.(*item)
+	s.lru.Remove(elem)
+	delete(s.items, i.key)
+	s.numBytes -= i.size()
+}
+
+// Returns the live item for the key (bumping its lru position), or nil.
+func (s *Server) lookup(key string) *item {
+	elem, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+
+	i := elem.Value.(*item)
+	if s.isExpired(i) {
+		s.removeElement(elem)
+		return nil
+	}
+
+	s.lru.MoveToFront(elem)
+	return i
+}
+
+// Inserts (or replaces) the item, and evicts least recently used items as
+// needed.
+func (s *Server) insert(i *item) {
+	if elem, ok := s.items[i.key]; ok {
+		s.removeElement(elem)
+	}
+
+	s.lastCas++
+	i.cas = s.lastCas
+	i.storedAt = s.options.Clock.Now()
+
+	s.items[i.key] = s.lru.PushFront(i)
+	s.numBytes += i.size()
+	s.stats["total_items"]++
+
+	for s.lru.Len() > 1 &&
+		((s.options.MaxItems > 0 && s.lru.Len() > s.options.MaxItems) ||
+			(s.options.MaxBytes > 0 && s.numBytes > s.options.MaxBytes)) {
+
+		s.removeElement(s.lru.Back())
+		s.stats["evictions"]++
+	}
+}
+
+type storeMode int
+
+const (
+	storeSet storeMode = iota
+	storeAdd
+	storeReplace
+	storeAppend
+	storePrepend
+)
+
+// Stores the value.  A non-zero cas turns the request into a compare and set.
+// Statuses follow the binary protocol's conventions.
+func (s *Server) store(
+	mode storeMode,
+	key string,
+	value []byte,
+	flags uint32,
+	expiration uint32,
+	cas uint64) (memcache.ResponseStatus, uint64) {
+
+	s.stats["cmd_set"]++
+
+	if len(value) > s.options.MaxValueLength ||
+		(s.options.MaxBytes > 0 && len(key)+len(value) > s.options.MaxBytes) {
+
+		return memcache.StatusValueTooLarge, 0
+	}
+
+	existing := s.lookup(key)
+	if cas != 0 {
+		if existing == nil {
+			return memcache.StatusKeyNotFound, 0
+		}
+		if existing.cas != cas {
+			return memcache.StatusKeyExists, 0
+		}
+	}
+
+	newItem := &item{
+		key:      key,
+		value:    value,
+		flags:    flags,
+		expireAt: s.expirationTime(expiration),
+	}
+
+	switch mode {
+	case storeAdd:
+		if existing != nil {
+			return memcache.StatusKeyExists, 0
+		}
+	case storeReplace:
+		if existing == nil {
+			return memcache.StatusKeyNotFound, 0
+		}
+	case storeAppend, storePrepend:
+		if existing == nil {
+			return memcache.StatusItemNotStored, 0
+		}
+
+		// Append / prepend keep the existing flags and expiration.
+		newItem.flags = existing.flags
+		newItem.expireAt = existing.expireAt
+		if mode == storeAppend {
+			newItem.value = append(
+				append([]byte{}, existing.value...),
+				value...)
+		} else {
+			newItem.value = append(
+				append([]byte{}, value...),
+				existing.value...)
+		}
+
+		if len(newItem.value) > s.options.MaxValueLength {
+			return memcache.StatusValueTooLarge, 0
+		}
+	}
+
+	s.insert(newItem)
+	return memcache.StatusNoError, newItem.cas
+}
+
+func (s *Server) get(key string) *item {
+	s.stats["cmd_get"]++
+
+	i := s.lookup(key)
+	if i == nil {
+		s.stats["get_misses"]++
+	} else {
+		s.stats["get_hits"]++
+	}
+	return i
+}
+
+func (s *Server) touch(key string, expiration uint32) *item {
+	s.stats["cmd_touch"]++
+
+	i := s.lookup(key)
+	if i != nil {
+		i.expireAt = s.expirationTime(expiration)
+	}
+	return i
+}
+
+func (s *Server) remove(key string, cas uint64) memcache.ResponseStatus {
+	i := s.lookup(key)
+	if i == nil {
+		return memcache.StatusKeyNotFound
+	}
+	if cas != 0 && i.cas != cas {
+		return memcache.StatusKeyExists
+	}
+
+	s.removeElement(s.items[key])
+	return memcache.StatusNoError
+}
+
+// Increments / decrements the counter.  When the counter does not exist, it
+// is created with initValue if create is true.
+func (s *Server) arithmetic(
+	key string,
+	increment bool,
+	delta uint64,
+	create bool,
+	initValue uint64,
+	expiration uint32) (memcache.ResponseStatus, uint64, uint64) {
+
+	i := s.lookup(key)
+	if i == nil {
+		if !create {
+			return memcache.StatusKeyNotFound, 0, 0
+		}
+
+		newItem := &item{
+			key:      key,
+			value:    []byte(formatUint(initValue)),
+			expireAt: s.expirationTime(expiration),
+		}
+		s.insert(newItem)
+		return memcache.StatusNoError, initValue, newItem.cas
+	}
+
+	count, ok := parseUint(i.value)
+	if !ok {
+		return memcache.StatusIncrDecrOnNonNumericValue, 0, 0
+	}
+
+	if increment {
+		count += delta // wraps, same as memcached
+	} else if delta > count {
+		count = 0
+	} else {
+		count -= delta
+	}
+
+	s.insert(&item{
+		key:      key,
+		value:    []byte(formatUint(count)),
+		flags:    i.flags,
+		expireAt: i.expireAt,
+	})
+	return memcache.StatusNoError, count, s.lastCas
+}
+
+func (s *Server) flush(delay uint32) {
+	s.stats["cmd_flush"]++
+
+	if delay == 0 {
+		s.items = make(map[string]*list.Element)
+		s.lru.Init()
+		s.numBytes = 0
+		s.flushAt = time.Time{}
+		return
+	}
+	s.flushAt = s.expirationTime(delay)
+}
+
+func (s *Server) statEntries() [][2]string {
+	entries := [][2]string{
+		{"version", Version},
+		{"curr_items", formatUint(uint64(s.lru.Len()))},
+		{"bytes", formatUint(uint64(s.numBytes))},
+		{"curr_connections", formatUint(uint64(len(s.conns)))},
+		{"limit_maxbytes", formatUint(uint64(s.options.MaxBytes))},
+	}
+	for _, name := range []string{
+		"total_items",
+		"total_connections",
+		"cmd_get",
+		"cmd_set",
+		"cmd_touch",
+		"cmd_flush",
+		"get_hits",
+		"get_misses",
+		"evictions",
+	} {
+		entries = append(entries, [2]string{name, formatUint(s.stats[name])})
+	}
+	return entries
+}
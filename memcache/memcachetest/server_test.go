@@ -0,0 +1,402 @@
+package memcachetest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/time2"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type ServerSuite struct {
+	clock  *time2.MockClock
+	server *Server
+	conns  []net.Conn
+}
+
+var _ = Suite(&ServerSuite{})
+
+func (s *ServerSuite) SetUpTest(c *C) {
+	s.clock = time2.NewMockClock(time.Unix(1500000000, 0))
+	s.startServer(c, Options{})
+}
+
+func (s *ServerSuite) TearDownTest(c *C) {
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+	_ = s.server.Close()
+}
+
+func (s *ServerSuite) startServer(c *C, options Options) {
+	if s.server != nil {
+		_ = s.server.Close()
+	}
+
+	options.Clock = s.clock
+	server, err := NewServer(options)
+	c.Assert(err, IsNil)
+	s.server = server
+}
+
+func (s *ServerSuite) dial(c *C) net.Conn {
+	conn, err := net.Dial("tcp", s.server.Addr())
+	c.Assert(err, IsNil)
+	s.conns = append(s.conns, conn)
+	return conn
+}
+
+func (s *ServerSuite) clients(c *C) map[string]memcache.ClientShard {
+	return map[string]memcache.ClientShard{
+		"ascii":  memcache.NewRawAsciiClient(0, s.dial(c)),
+		"binary": memcache.NewRawBinaryClient(0, s.dial(c)),
+	}
+}
+
+func (s *ServerSuite) TestGetSetDelete(c *C) {
+	for name, client := range s.clients(c) {
+		key := "key-" + name
+
+		gresp := client.Get(key)
+		c.Assert(gresp.Error(), IsNil)
+		c.Assert(gresp.Status(), Equals, memcache.StatusKeyNotFound)
+
+		resp := client.Set(&memcache.Item{
+			Key:   key,
+			Value: []byte("value"),
+			Flags: 123,
+		})
+		c.Assert(resp.Error(), IsNil)
+
+		gresp = client.Get(key)
+		c.Assert(gresp.Error(), IsNil)
+		c.Assert(string(gresp.Value()), Equals, "value")
+		c.Assert(gresp.Flags(), Equals, uint32(123))
+		c.Assert(gresp.DataVersionId(), Not(Equals), uint64(0))
+
+		resp = client.Append(key, []byte("-appended"))
+		c.Assert(resp.Error(), IsNil)
+		resp = client.Prepend(key, []byte("prepended-"))
+		c.Assert(resp.Error(), IsNil)
+
+		item, ok := s.server.Item(key)
+		c.Assert(ok, IsTrue)
+		c.Assert(string(item.Value), Equals, "prepended-value-appended")
+		c.Assert(item.Flags, Equals, uint32(123))
+
+		resp = client.Delete(key)
+		c.Assert(resp.Error(), IsNil)
+
+		resp = client.Delete(key)
+		c.Assert(resp.Status(), Equals, memcache.StatusKeyNotFound)
+
+		c.Assert(client.IsValidState(), IsTrue)
+	}
+}
+
+func (s *ServerSuite) TestAddReplace(c *C) {
+	for name, client := range s.clients(c) {
+		item := &memcache.Item{Key: "key-" + name, Value: []byte("1")}
+
+		resp := client.Replace(item)
+		c.Assert(resp.Error(), NotNil)
+
+		resp = client.Add(item)
+		c.Assert(resp.Error(), IsNil)
+
+		resp = client.Add(item)
+		c.Assert(resp.Error(), NotNil)
+
+		item.Value = []byte("2")
+		resp = client.Replace(item)
+		c.Assert(resp.Error(), IsNil)
+
+		c.Assert(string(client.Get(item.Key).Value()), Equals, "2")
+		c.Assert(client.IsValidState(), IsTrue)
+	}
+}
+
+func (s *ServerSuite) TestCas(c *C) {
+	for name, client := range s.clients(c) {
+		key := "key-" + name
+
+		resp := client.Set(&memcache.Item{Key: key, Value: []byte("v1")})
+		c.Assert(resp.Error(), IsNil)
+
+		cas := client.Get(key).DataVersionId()
+
+		resp = client.Set(
+			&memcache.Item{Key: key, Value: []byte("v2"), DataVersionId: cas})
+		c.Assert(resp.Error(), IsNil)
+
+		// Stale cas.
+		resp = client.Set(
+			&memcache.Item{Key: key, Value: []byte("v3"), DataVersionId: cas})
+		c.Assert(resp.Status(), Equals, memcache.StatusKeyExists)
+
+		c.Assert(string(client.Get(key).Value()), Equals, "v2")
+
+		// Missing item.
+		resp = client.Set(
+			&memcache.Item{Key: "missing", Value: []byte("v"), DataVersionId: cas})
+		c.Assert(resp.Status(), Equals, memcache.StatusKeyNotFound)
+
+		c.Assert(client.IsValidState(), IsTrue)
+	}
+}
+
+func (s *ServerSuite) TestIncrementDecrement(c *C) {
+	clients := s.clients(c)
+
+	binaryClient := clients["binary"]
+	cresp := binaryClient.Increment("counter", 5, 10, 0)
+	c.Assert(cresp.Error(), IsNil)
+	c.Assert(cresp.Count(), Equals, uint64(10))
+
+	cresp = binaryClient.Increment("counter", 5, 10, 0)
+	c.Assert(cresp.Error(), IsNil)
+	c.Assert(cresp.Count(), Equals, uint64(15))
+
+	cresp = binaryClient.Decrement("missing", 5, 10, 0xffffffff)
+	c.Assert(cresp.Status(), Equals, memcache.StatusKeyNotFound)
+
+	asciiClient := clients["ascii"]
+	cresp = asciiClient.Decrement("counter", 20, 0, 0xffffffff)
+	c.Assert(cresp.Error(), IsNil)
+	c.Assert(cresp.Count(), Equals, uint64(0))
+
+	cresp = asciiClient.Increment("counter", 7, 0, 0xffffffff)
+	c.Assert(cresp.Error(), IsNil)
+	c.Assert(cresp.Count(), Equals, uint64(7))
+
+	asciiClient.Set(&memcache.Item{Key: "text", Value: []byte("abc")})
+	cresp = binaryClient.Increment("text", 1, 0, 0xffffffff)
+	c.Assert(cresp.Status(), Equals, memcache.StatusIncrDecrOnNonNumericValue)
+	c.Assert(binaryClient.IsValidState(), IsTrue)
+}
+
+func (s *ServerSuite) TestExpiration(c *C) {
+	for name, client := range s.clients(c) {
+		key := "key-" + name
+
+		resp := client.Set(
+			&memcache.Item{Key: key, Value: []byte("v"), Expiration: 10})
+		c.Assert(resp.Error(), IsNil)
+
+		s.clock.Advance(9 * time.Second)
+		c.Assert(client.Get(key).Status(), Equals, memcache.StatusNoError)
+
+		resp = client.Touch(key, 10)
+		c.Assert(resp.Error(), IsNil)
+
+		s.clock.Advance(9 * time.Second)
+		gresp := client.GetAndTouch(key, 100)
+		c.Assert(gresp.Error(), IsNil)
+		c.Assert(string(gresp.Value()), Equals, "v")
+
+		s.clock.Advance(99 * time.Second)
+		c.Assert(client.Get(key).Status(), Equals, memcache.StatusNoError)
+
+		s.clock.Advance(time.Second)
+		c.Assert(client.Get(key).Status(), Equals, memcache.StatusKeyNotFound)
+
+		// Absolute expiration.
+		expiration := uint32(s.clock.Now().Unix() + 5)
+		resp = client.Set(
+			&memcache.Item{Key: key, Value: []byte("v"), Expiration: expiration})
+		c.Assert(resp.Error(), IsNil)
+
+		s.clock.Advance(5 * time.Second)
+		c.Assert(client.Get(key).Status(), Equals, memcache.StatusKeyNotFound)
+	}
+}
+
+func (s *ServerSuite) TestFlush(c *C) {
+	for name, client := range s.clients(c) {
+		key := "key-" + name
+
+		client.Set(&memcache.Item{Key: key, Value: []byte("v")})
+		c.Assert(client.Flush(0).Error(), IsNil)
+		c.Assert(client.Get(key).Status(), Equals, memcache.StatusKeyNotFound)
+
+		client.Set(&memcache.Item{Key: key, Value: []byte("v")})
+		c.Assert(client.Flush(10).Error(), IsNil)
+		c.Assert(client.Get(key).Status(), Equals, memcache.StatusNoError)
+
+		s.clock.Advance(10 * time.Second)
+		c.Assert(client.Get(key).Status(), Equals, memcache.StatusKeyNotFound)
+
+		// Items stored after the delayed flush are not affected.
+		s.clock.Advance(time.Second)
+		client.Set(&memcache.Item{Key: key, Value: []byte("v")})
+		c.Assert(client.Get(key).Status(), Equals, memcache.StatusNoError)
+	}
+}
+
+func (s *ServerSuite) TestLRUEviction(c *C) {
+	s.startServer(c, Options{MaxItems: 2})
+
+	for _, client := range s.clients(c) {
+		c.Assert(client.Flush(0).Error(), IsNil)
+
+		client.Set(&memcache.Item{Key: "a", Value: []byte("1")})
+		client.Set(&memcache.Item{Key: "b", Value: []byte("2")})
+
+		// Bump "a" so that "b" is the least recently used item.
+		c.Assert(client.Get("a").Status(), Equals, memcache.StatusNoError)
+
+		client.Set(&memcache.Item{Key: "c", Value: []byte("3")})
+
+		c.Assert(client.Get("a").Status(), Equals, memcache.StatusNoError)
+		c.Assert(client.Get("b").Status(), Equals, memcache.StatusKeyNotFound)
+		c.Assert(client.Get("c").Status(), Equals, memcache.StatusNoError)
+		c.Assert(s.server.NumItems(), Equals, 2)
+	}
+
+	c.Assert(s.server.Stat("evictions"), Equals, uint64(2))
+}
+
+func (s *ServerSuite) TestMaxBytes(c *C) {
+	s.startServer(c, Options{MaxBytes: 10})
+
+	for _, client := range s.clients(c) {
+		c.Assert(client.Flush(0).Error(), IsNil)
+
+		resp := client.Set(&memcache.Item{Key: "a", Value: []byte("12345")})
+		c.Assert(resp.Error(), IsNil)
+		resp = client.Set(&memcache.Item{Key: "b", Value: []byte("12345")})
+		c.Assert(resp.Error(), IsNil)
+
+		c.Assert(client.Get("a").Status(), Equals, memcache.StatusKeyNotFound)
+		c.Assert(client.Get("b").Status(), Equals, memcache.StatusNoError)
+
+		resp = client.Set(&memcache.Item{Key: "c", Value: []byte("1234567890")})
+		c.Assert(resp.Error(), NotNil)
+	}
+}
+
+func (s *ServerSuite) TestGetMulti(c *C) {
+	for name, client := range s.clients(c) {
+		key1 := "key1-" + name
+		key2 := "key2-" + name
+
+		client.Set(&memcache.Item{Key: key1, Value: []byte("1")})
+		client.Set(&memcache.Item{Key: key2, Value: []byte("2")})
+
+		gresps := client.GetMulti([]string{key1, key2, "missing"})
+		c.Assert(gresps, HasLen, 3)
+		c.Assert(string(gresps[key1].Value()), Equals, "1")
+		c.Assert(string(gresps[key2].Value()), Equals, "2")
+		c.Assert(gresps["missing"].Status(), Equals, memcache.StatusKeyNotFound)
+	}
+}
+
+func (s *ServerSuite) TestStatAndVersion(c *C) {
+	for _, client := range s.clients(c) {
+		vresp := client.Version()
+		c.Assert(vresp.Error(), IsNil)
+		c.Assert(vresp.Versions()[0], Equals, Version)
+
+		c.Assert(client.Verbosity(1).Error(), IsNil)
+	}
+
+	client := s.clients(c)["binary"]
+	sresp := client.Stat("")
+	c.Assert(sresp.Error(), IsNil)
+	c.Assert(sresp.Entries()[0]["version"], Equals, Version)
+	c.Assert(client.IsValidState(), IsTrue)
+}
+
+func (s *ServerSuite) TestFaultStatus(c *C) {
+	s.server.InjectFault(Fault{
+		Command: "get",
+		Status:  memcache.StatusBusy,
+		Count:   1,
+	})
+
+	client := memcache.NewRawBinaryClient(0, s.dial(c))
+	gresp := client.Get("key")
+	c.Assert(gresp.Status(), Equals, memcache.StatusBusy)
+
+	// The fault is only applied once.
+	gresp = client.Get("key")
+	c.Assert(gresp.Status(), Equals, memcache.StatusKeyNotFound)
+
+	s.server.InjectFault(Fault{Command: "set", Status: memcache.StatusBusy})
+
+	asciiClient := memcache.NewRawAsciiClient(0, s.dial(c))
+	resp := asciiClient.Set(&memcache.Item{Key: "key", Value: []byte("v")})
+	c.Assert(resp.Error(), NotNil)
+
+	s.server.ClearFaults()
+
+	asciiClient = memcache.NewRawAsciiClient(0, s.dial(c))
+	resp = asciiClient.Set(&memcache.Item{Key: "key", Value: []byte("v")})
+	c.Assert(resp.Error(), IsNil)
+}
+
+func (s *ServerSuite) TestFaultDisconnect(c *C) {
+	s.server.InjectFault(Fault{Disconnect: true, Count: 1})
+
+	client := memcache.NewRawBinaryClient(0, s.dial(c))
+	gresp := client.Get("key")
+	c.Assert(gresp.Error(), NotNil)
+	c.Assert(client.IsValidState(), IsFalse)
+
+	client = memcache.NewRawBinaryClient(0, s.dial(c))
+	gresp = client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+}
+
+func (s *ServerSuite) TestFaultDelay(c *C) {
+	s.server.InjectFault(
+		Fault{Command: "version", Delay: 50 * time.Millisecond})
+
+	client := memcache.NewRawAsciiClient(0, s.dial(c))
+
+	start := time.Now()
+	c.Assert(client.Version().Error(), IsNil)
+	c.Assert(time.Since(start) >= 50*time.Millisecond, IsTrue)
+}
+
+func (s *ServerSuite) TestFaultDelayAbortedOnClose(c *C) {
+	s.server.InjectFault(Fault{Command: "get", Delay: time.Hour})
+
+	for _, closeFunc := range []func(){
+		s.server.CloseConnections,
+		func() { _ = s.server.Close() },
+	} {
+		client := memcache.NewRawBinaryClient(0, s.dial(c))
+
+		done := make(chan memcache.GetResponse)
+		go func() {
+			done <- client.Get("key")
+		}()
+
+		// Wait for the get to reach the server.
+		time.Sleep(50 * time.Millisecond)
+
+		start := time.Now()
+		closeFunc()
+		c.Assert((<-done).Error(), NotNil)
+		c.Assert(time.Since(start) < time.Minute, IsTrue)
+	}
+}
+
+func (s *ServerSuite) TestCloseConnections(c *C) {
+	client := memcache.NewRawBinaryClient(0, s.dial(c))
+	c.Assert(client.Get("key").Error(), IsNil)
+
+	s.server.CloseConnections()
+
+	c.Assert(client.Get("key").Error(), NotNil)
+}
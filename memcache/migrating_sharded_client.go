@@ -0,0 +1,379 @@
+package memcache
+
+// A sharded memcache client which supports migrating keys between shard
+// servers (e.g., during a resharding) without cold cache dips, as configured
+// by the MigratingShardManager's migration policy.
+//
+// Get and GetMulti dual-read the selected migrating keys, i.e., the keys are
+// read from their target shards first, and target misses are read from their
+// current shards.  Target hits are returned with zero DataVersionId, since
+// the target shard's version is meaningless to the current shard (i.e., a Cas
+// based on a target hit is treated as an add by the current shard).  Writes to the selected migrating keys are shadowed to their
+// target shards once the writes are sent to their current shards:
+//   - Successful Set, Add, Replace and Cas (including their batch and
+//     sentinel variants) are shadowed as adds of the same item.  When the
+//     target shard already has the key, the key is deleted instead (i.e.,
+//     the shadow write never overwrites a target value).
+//   - Delete, Append, Prepend, Increment, Decrement and Touch are shadowed
+//     as deletes (i.e., the target shard re-populates the key on the next
+//     set).
+//
+// Consistency: since shadow writes never overwrite, sequential writes to a
+// key leave the target shard either without the key or with the current
+// shard's latest value.  Concurrent writes to the same key may still leave
+// the target shard with an older value (e.g., when a shadow add is delayed
+// past a later write's shadow delete), until the key is written again or
+// expires.  Failed shadow writes may also leave older values in place.
+//
+// The current shards' responses are returned.  Shadow write failures are
+// ignored, but are included in the manager's migration progress.
+//
+// NOTE: GetSentinels and GetAndTouch (and its batch variant) are not
+// dual-read.  Flush is sent to both the current and the target shards.  The
+// context-aware operations are not supported (i.e., the client does not
+// implement ContextClient).
+type MigratingShardedClient struct {
+	Client
+
+	manager MigratingShardManager
+	builder ClientShardBuilder
+}
+
+// This creates a new MigratingShardedClient.
+func NewMigratingShardedClient(
+	manager MigratingShardManager,
+	builder ClientShardBuilder) Client {
+
+	return &MigratingShardedClient{
+		Client:  NewShardedClient(manager, builder),
+		manager: manager,
+		builder: builder,
+	}
+}
+
+// Returns a client for the migration target's shards, or nil when no
+// migration is in progress.
+func (c *MigratingShardedClient) targetClient() Client {
+	target := c.manager.GetMigrationTarget()
+	if target == nil {
+		return nil
+	}
+	return NewShardedClient(target, c.builder)
+}
+
+// Returns a copy of a target shard's get hit with zero DataVersionId.
+func withoutDataVersionId(resp GetResponse) GetResponse {
+	return NewGetResponse(
+		resp.Key(),
+		resp.Status(),
+		resp.Flags(),
+		resp.Value(),
+		0)
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Get(key string) GetResponse {
+	target := c.targetClient()
+	if target == nil {
+		return c.Client.Get(key)
+	}
+	if dualRead, _ := c.manager.GetMigrationRoute(key); !dualRead {
+		return c.Client.Get(key)
+	}
+
+	resp := target.Get(key)
+	if resp.Error() == nil && resp.Status() == StatusNoError {
+		c.manager.RecordDualReads(1, 0)
+		return withoutDataVersionId(resp)
+	}
+	c.manager.RecordDualReads(0, 1)
+	return c.Client.Get(key)
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) GetMulti(
+	keys []string) map[string]GetResponse {
+
+	target := c.targetClient()
+	if target == nil {
+		return c.Client.GetMulti(keys)
+	}
+
+	dualReadKeys := []string{}
+	for _, key := range keys {
+		if dualRead, _ := c.manager.GetMigrationRoute(key); dualRead {
+			dualReadKeys = append(dualReadKeys, key)
+		}
+	}
+	if len(dualReadKeys) == 0 {
+		return c.Client.GetMulti(keys)
+	}
+
+	results := make(map[string]GetResponse, len(keys))
+	for key, resp := range target.GetMulti(dualReadKeys) {
+		if resp.Error() == nil && resp.Status() == StatusNoError {
+			results[key] = withoutDataVersionId(resp)
+		}
+	}
+	c.manager.RecordDualReads(len(results), len(dualReadKeys)-len(results))
+
+	remaining := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := results[key]; !ok {
+			remaining = append(remaining, key)
+		}
+	}
+	if len(remaining) > 0 {
+		for key, resp := range c.Client.GetMulti(remaining) {
+			results[key] = resp
+		}
+	}
+	return results
+}
+
+// Returns the items which are selected for shadow writes and were written
+// successfully.  Responses are matched to items by key, since batch
+// responses are not ordered.
+func (c *MigratingShardedClient) shadowItems(
+	items []*Item,
+	responses []MutateResponse) []*Item {
+
+	succeeded := make(map[string]int)
+	for _, resp := range responses {
+		if resp.Error() == nil {
+			succeeded[resp.Key()]++
+		}
+	}
+
+	shadow := []*Item{}
+	for _, item := range items {
+		if item == nil || succeeded[item.Key] == 0 {
+			continue
+		}
+		succeeded[item.Key]--
+
+		if _, shadowWrite := c.manager.GetMigrationRoute(item.Key); shadowWrite {
+			// Data version ids are per shard.
+			copied := *item
+			copied.DataVersionId = 0
+			shadow = append(shadow, &copied)
+		}
+	}
+	return shadow
+}
+
+func (c *MigratingShardedClient) recordShadowWrites(
+	responses []MutateResponse) {
+
+	failures := 0
+	for _, resp := range responses {
+		if resp.Error() != nil {
+			failures++
+		}
+	}
+	c.manager.RecordShadowWrites(len(responses)-failures, failures)
+}
+
+// Shadows successful writes as adds, and deletes the keys which already
+// exist on the target shards.  See MigratingShardedClient for the
+// consistency guarantees.
+func (c *MigratingShardedClient) shadowSets(
+	items []*Item,
+	responses []MutateResponse) {
+
+	target := c.targetClient()
+	if target == nil {
+		return
+	}
+
+	shadow := c.shadowItems(items, responses)
+	if len(shadow) == 0 {
+		return
+	}
+
+	results := []MutateResponse{}
+	existing := []string{}
+	for _, resp := range target.AddMulti(shadow) {
+		if resp.Status() == StatusKeyExists {
+			existing = append(existing, resp.Key())
+		} else {
+			results = append(results, resp)
+		}
+	}
+	if len(existing) > 0 {
+		results = append(results, deleteShadowKeys(target, existing)...)
+	}
+	c.recordShadowWrites(results)
+}
+
+// Deletes the keys from the target shards.  Deleting a missing key is not a
+// failure.
+func deleteShadowKeys(target Client, keys []string) []MutateResponse {
+	responses := target.DeleteMulti(keys)
+	for i, resp := range responses {
+		if resp.Status() == StatusKeyNotFound {
+			responses[i] = NewMutateResponse(resp.Key(), StatusNoError, 0)
+		}
+	}
+	return responses
+}
+
+// Shadows writes as deletes.
+func (c *MigratingShardedClient) shadowDeletes(keys []string) {
+	target := c.targetClient()
+	if target == nil {
+		return
+	}
+
+	shadow := []string{}
+	for _, key := range keys {
+		if _, shadowWrite := c.manager.GetMigrationRoute(key); shadowWrite {
+			shadow = append(shadow, key)
+		}
+	}
+	if len(shadow) == 0 {
+		return
+	}
+
+	c.recordShadowWrites(deleteShadowKeys(target, shadow))
+}
+
+func (c *MigratingShardedClient) shadowSet(
+	item *Item,
+	resp MutateResponse) MutateResponse {
+
+	c.shadowSets([]*Item{item}, []MutateResponse{resp})
+	return resp
+}
+
+func (c *MigratingShardedClient) shadowSetMulti(
+	items []*Item,
+	responses []MutateResponse) []MutateResponse {
+
+	c.shadowSets(items, responses)
+	return responses
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Set(item *Item) MutateResponse {
+	return c.shadowSet(item, c.Client.Set(item))
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) SetMulti(items []*Item) []MutateResponse {
+	return c.shadowSetMulti(items, c.Client.SetMulti(items))
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) SetSentinels(
+	items []*Item) []MutateResponse {
+
+	return c.shadowSetMulti(items, c.Client.SetSentinels(items))
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) CasMulti(items []*Item) []MutateResponse {
+	return c.shadowSetMulti(items, c.Client.CasMulti(items))
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) CasSentinels(
+	items []*Item) []MutateResponse {
+
+	return c.shadowSetMulti(items, c.Client.CasSentinels(items))
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Add(item *Item) MutateResponse {
+	return c.shadowSet(item, c.Client.Add(item))
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) AddMulti(items []*Item) []MutateResponse {
+	return c.shadowSetMulti(items, c.Client.AddMulti(items))
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Replace(item *Item) MutateResponse {
+	return c.shadowSet(item, c.Client.Replace(item))
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Delete(key string) MutateResponse {
+	resp := c.Client.Delete(key)
+	c.shadowDeletes([]string{key})
+	return resp
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) DeleteMulti(keys []string) []MutateResponse {
+	responses := c.Client.DeleteMulti(keys)
+	c.shadowDeletes(keys)
+	return responses
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Append(
+	key string,
+	value []byte) MutateResponse {
+
+	resp := c.Client.Append(key, value)
+	c.shadowDeletes([]string{key})
+	return resp
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Prepend(
+	key string,
+	value []byte) MutateResponse {
+
+	resp := c.Client.Prepend(key, value)
+	c.shadowDeletes([]string{key})
+	return resp
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Touch(
+	key string,
+	expiration uint32) MutateResponse {
+
+	resp := c.Client.Touch(key, expiration)
+	c.shadowDeletes([]string{key})
+	return resp
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Increment(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	resp := c.Client.Increment(key, delta, initValue, expiration)
+	c.shadowDeletes([]string{key})
+	return resp
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Decrement(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	resp := c.Client.Decrement(key, delta, initValue, expiration)
+	c.shadowDeletes([]string{key})
+	return resp
+}
+
+// See Client interface for documentation.
+func (c *MigratingShardedClient) Flush(expiration uint32) Response {
+	resp := c.Client.Flush(expiration)
+
+	if target := c.targetClient(); target != nil {
+		if targetResp := target.Flush(expiration); resp.Error() == nil {
+			return targetResp
+		}
+	}
+	return resp
+}
@@ -0,0 +1,215 @@
+package memcache_test
+
+import (
+	"log"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/memcache/memcachetest"
+	"github.com/dropbox/godropbox/net2"
+)
+
+type MigratingShardedClientSuite struct {
+	source  *memcachetest.Server
+	target  *memcachetest.Server
+	manager *memcache.StaticShardManager
+	client  memcache.Client
+}
+
+var _ = Suite(&MigratingShardedClientSuite{})
+
+func (s *MigratingShardedClientSuite) SetUpTest(c *C) {
+	var err error
+	s.source, err = memcachetest.NewServer(memcachetest.Options{})
+	c.Assert(err, IsNil)
+	s.target, err = memcachetest.NewServer(memcachetest.Options{})
+	c.Assert(err, IsNil)
+
+	s.manager = &memcache.StaticShardManager{}
+	s.manager.Init(
+		func(key string, numShard int) int { return 0 },
+		func(err error) { log.Print(err) },
+		log.Print,
+		net2.ConnectionOptions{MaxActiveConnections: 10})
+	s.manager.UpdateShardStates([]memcache.ShardState{
+		{Address: s.source.Addr(), State: memcache.ActiveServer},
+	})
+
+	s.client = memcache.NewMigratingShardedClient(
+		s.manager,
+		memcache.NewRawBinaryClient)
+}
+
+func (s *MigratingShardedClientSuite) TearDownTest(c *C) {
+	_ = s.source.Close()
+	_ = s.target.Close()
+}
+
+func (s *MigratingShardedClientSuite) startMigration(
+	c *C,
+	policy memcache.PercentageMigrationPolicy) {
+
+	err := s.manager.StartMigration(
+		[]memcache.ShardState{
+			{Address: s.target.Addr(), State: memcache.ActiveServer},
+		},
+		nil,
+		policy)
+	c.Assert(err, IsNil)
+}
+
+func (s *MigratingShardedClientSuite) set(c *C, key string, value string) {
+	resp := s.client.Set(&memcache.Item{Key: key, Value: []byte(value)})
+	c.Assert(resp.Error(), IsNil)
+}
+
+func (s *MigratingShardedClientSuite) TestNoMigration(c *C) {
+	s.set(c, "key", "value")
+	c.Assert(string(s.client.Get("key").Value()), Equals, "value")
+	c.Assert(s.target.NumItems(), Equals, 0)
+}
+
+func (s *MigratingShardedClientSuite) TestShadowWrites(c *C) {
+	s.startMigration(
+		c,
+		memcache.PercentageMigrationPolicy{ShadowWritePercent: 100})
+
+	s.set(c, "key", "value")
+	item, ok := s.target.Item("key")
+	c.Assert(ok, IsTrue)
+	c.Assert(string(item.Value), Equals, "value")
+
+	responses := s.client.SetMulti([]*memcache.Item{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Value: []byte("2")},
+	})
+	c.Assert(responses, HasLen, 2)
+	c.Assert(s.target.NumItems(), Equals, 3)
+
+	// Failed adds are not shadowed.
+	resp := s.client.Add(&memcache.Item{Key: "key", Value: []byte("other")})
+	c.Assert(resp.Status(), Equals, memcache.StatusKeyExists)
+	item, _ = s.target.Item("key")
+	c.Assert(string(item.Value), Equals, "value")
+
+	// Non-idempotent writes are shadowed as deletes.
+	resp = s.client.Append("key", []byte("!"))
+	c.Assert(resp.Error(), IsNil)
+	_, ok = s.target.Item("key")
+	c.Assert(ok, IsFalse)
+
+	resp = s.client.Delete("a")
+	c.Assert(resp.Error(), IsNil)
+	_, ok = s.target.Item("a")
+	c.Assert(ok, IsFalse)
+
+	// Reads are not dual-read.
+	c.Assert(string(s.client.Get("b").Value()), Equals, "2")
+	c.Assert(s.target.Stat("cmd_get"), Equals, uint64(0))
+
+	progress := s.manager.MigrationProgress()
+	c.Assert(progress.ShadowWrites, Equals, uint64(5))
+	c.Assert(progress.ShadowWriteErrors, Equals, uint64(0))
+}
+
+func (s *MigratingShardedClientSuite) TestDualReads(c *C) {
+	s.set(c, "old", "source")
+
+	s.startMigration(
+		c,
+		memcache.PercentageMigrationPolicy{DualReadPercent: 100})
+
+	s.set(c, "new", "value")
+
+	// Served by the target.
+	resp := s.client.Get("new")
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(string(resp.Value()), Equals, "value")
+
+	// The target's version is not returned, hence a cas against the source
+	// cannot succeed by accident.
+	c.Assert(resp.DataVersionId(), Equals, uint64(0))
+	cresps := s.client.CasMulti([]*memcache.Item{
+		{Key: "new", Value: []byte("cas"), DataVersionId: resp.DataVersionId()},
+	})
+	c.Assert(cresps, HasLen, 1)
+	c.Assert(cresps[0].Status(), Equals, memcache.StatusKeyExists)
+
+	// Target misses fall back to the source.
+	resp = s.client.Get("old")
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(string(resp.Value()), Equals, "source")
+
+	responses := s.client.GetMulti([]string{"old", "new", "missing"})
+	c.Assert(responses, HasLen, 3)
+	c.Assert(string(responses["old"].Value()), Equals, "source")
+	c.Assert(string(responses["new"].Value()), Equals, "value")
+	c.Assert(responses["new"].DataVersionId(), Equals, uint64(0))
+	c.Assert(responses["old"].DataVersionId() != 0, IsTrue)
+	c.Assert(responses["missing"].Status(), Equals, memcache.StatusKeyNotFound)
+
+	progress := s.manager.MigrationProgress()
+	c.Assert(progress.DualReadHits, Equals, uint64(2))
+	c.Assert(progress.DualReadMisses, Equals, uint64(3))
+
+	// Switch over.
+	s.manager.UpdateShardStates([]memcache.ShardState{
+		{Address: s.target.Addr(), State: memcache.ActiveServer},
+	})
+	s.manager.StopMigration()
+
+	c.Assert(string(s.client.Get("new").Value()), Equals, "value")
+	c.Assert(
+		s.client.Get("old").Status(),
+		Equals,
+		memcache.StatusKeyNotFound)
+}
+
+func (s *MigratingShardedClientSuite) TestShadowWriteErrors(c *C) {
+	s.startMigration(
+		c,
+		memcache.PercentageMigrationPolicy{ShadowWritePercent: 100})
+
+	_ = s.target.Close()
+
+	// Shadow write failures are ignored.
+	s.set(c, "key", "value")
+	c.Assert(string(s.client.Get("key").Value()), Equals, "value")
+
+	progress := s.manager.MigrationProgress()
+	c.Assert(progress.ShadowWrites, Equals, uint64(0))
+	c.Assert(progress.ShadowWriteErrors, Equals, uint64(1))
+}
+
+func (s *MigratingShardedClientSuite) TestShadowWritesNeverOverwrite(c *C) {
+	s.startMigration(
+		c,
+		memcache.PercentageMigrationPolicy{
+			ShadowWritePercent: 100,
+			DualReadPercent:    100,
+		})
+
+	s.set(c, "key", "v1")
+	item, ok := s.target.Item("key")
+	c.Assert(ok, IsTrue)
+	c.Assert(string(item.Value), Equals, "v1")
+
+	// The target already has the key, hence the key is deleted instead of
+	// overwritten (an overwriting shadow write could land out of order).
+	s.set(c, "key", "v2")
+	_, ok = s.target.Item("key")
+	c.Assert(ok, IsFalse)
+	c.Assert(string(s.client.Get("key").Value()), Equals, "v2")
+
+	s.set(c, "key", "v3")
+	item, ok = s.target.Item("key")
+	c.Assert(ok, IsTrue)
+	c.Assert(string(item.Value), Equals, "v3")
+	c.Assert(string(s.client.Get("key").Value()), Equals, "v3")
+
+	progress := s.manager.MigrationProgress()
+	c.Assert(progress.ShadowWrites, Equals, uint64(3))
+	c.Assert(progress.ShadowWriteErrors, Equals, uint64(0))
+}
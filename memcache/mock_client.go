@@ -1,16 +1,78 @@
 package memcache
 
 import (
+	"container/list"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/dropbox/godropbox/errors"
+	"github.com/dropbox/godropbox/time2"
 )
 
+// Options for configuring MockClient.
+type MockClientOptions struct {
+	// The maximum total size (key + value bytes) of the stored items.  Least
+	// recently used items are evicted once the limit is exceeded.  Zero means
+	// unlimited.
+	MaxBytes int
+
+	// The maximum number of stored items.  Least recently used items are
+	// evicted once the limit is exceeded.  Zero means unlimited.
+	MaxItems int
+
+	// The maximum value length.  Zero means 1MB.
+	MaxValueLength int
+
+	// The clock used for item expiration.  Defaults to time2.DefaultClock.
+	// Use a time2.MockClock to control expiration deterministically.
+	Clock time2.Clock
+}
+
+// An operation recorded by MockClient.
+type MockOperation struct {
+	// The operation's name, e.g., "get", "set_multi" (same as the
+	// ShardedClient stats' op names).
+	Name string
+
+	// The keys the operation was applied to (nil for admin operations).
+	Keys []string
+}
+
+type mockItem struct {
+	Item // Expiration is the expiration as specified by the client.
+
+	expireAt time.Time // zero if the item does not expire
+	storedAt time.Time
+	elem     *list.Element
+}
+
+func (i *mockItem) size() int {
+	return len(i.Key) + len(i.Value)
+}
+
+// An in-memory memcache simulator, which mimics a single memcached shard as
+// seen through ShardedClient.  Items expire according to the clock, and least
+// recently used items are evicted once the memory limit is exceeded.  Data
+// version ids (aka CAS) are assigned and checked the same way as memcached.
+//
+// The shard state (see SetShardState) is simulated the same way as
+// ShardedClient / BaseShardManager do: non-sentinel operations are skipped
+// unless the shard is active (i.e., gets miss, and mutations succeed without
+// storing anything), sentinel operations are skipped when the shard is down,
+// and sentinel write failures are ignored while the shard is warming up.
 type MockClient struct {
-	data                   map[string]*Item
-	version                uint64
-	mutex                  sync.Mutex
+	options MockClientOptions
+
+	mutex    sync.Mutex
+	data     map[string]*mockItem
+	lru      *list.List // front is the most recently used
+	numBytes int
+	version  uint64
+	flushAt  time.Time // items stored before flushAt are invalid after flushAt
+	state    MemcachedState
+	ops      []MockOperation
+
 	forceGetMisses         bool // return StatusKeyNotFound for all gets
 	forceSetInternalErrors bool // return StatusInternalError for all sets
 	forceFailEverything    bool // return StatusInternalError for all functions
@@ -23,32 +85,206 @@ const (
 )
 
 func NewMockClient() Client {
-	return &MockClient{data: make(map[string]*Item)}
+	return NewMockClientWithOptions(MockClientOptions{})
+}
+
+// This creates a MockClient with the given options.
+func NewMockClientWithOptions(options MockClientOptions) *MockClient {
+	if options.Clock == nil {
+		options.Clock = time2.DefaultClock
+	}
+	if options.MaxValueLength == 0 {
+		options.MaxValueLength = defaultMaxValueLength
+	}
+
+	return &MockClient{
+		options: options,
+		data:    make(map[string]*mockItem),
+		lru:     list.New(),
+		state:   ActiveServer,
+	}
 }
 
 func NewMockClientErrorAllSets() Client {
-	return &MockClient{data: make(map[string]*Item), forceSetInternalErrors: true}
+	c := NewMockClientWithOptions(MockClientOptions{})
+	c.forceSetInternalErrors = true
+	return c
 }
 
 func NewMockClientMissAllGets() Client {
-	return &MockClient{data: make(map[string]*Item), forceGetMisses: true}
+	c := NewMockClientWithOptions(MockClientOptions{})
+	c.forceGetMisses = true
+	return c
 }
 
 func NewMockClientFailEverything() Client {
-	return &MockClient{data: make(map[string]*Item), forceFailEverything: true}
+	c := NewMockClientWithOptions(MockClientOptions{})
+	c.forceFailEverything = true
+	return c
+}
+
+// This sets the simulated shard state.
+func (c *MockClient) SetShardState(state MemcachedState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.state = state
+}
+
+// This returns the operations applied so far, in order.
+func (c *MockClient) Operations() []MockOperation {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ops := make([]MockOperation, len(c.ops))
+	copy(ops, c.ops)
+	return ops
+}
+
+// This clears the operation log.
+func (c *MockClient) ClearOperations() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.ops = nil
+}
+
+// This returns the number of live items.
+func (c *MockClient) NumItems() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, item := range c.data {
+		if c.isExpired(item) {
+			c.removeItem(item)
+		}
+	}
+	return len(c.data)
+}
+
+// Bookkeeping.  All methods below must be called while holding the mutex.
+//
+
+func (c *MockClient) record(name string, keys ...string) {
+	c.ops = append(c.ops, MockOperation{Name: name, Keys: keys})
+}
+
+func (c *MockClient) recordItems(name string, items []*Item) {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	c.record(name, keys...)
+}
+
+// Returns true if the operation is skipped due to the shard state.
+func (c *MockClient) isSkipped(sentinel bool) bool {
+	switch c.state {
+	case ActiveServer:
+		return false
+	case WriteOnlyServer, WarmUpServer:
+		return !sentinel
+	}
+	return true
+}
+
+func (c *MockClient) expirationTime(expiration uint32) time.Time {
+	if expiration == 0 {
+		return time.Time{}
+	}
+
+	// Same as memcached: expirations up to 30 days are relative.
+	if expiration <= 60*60*24*30 {
+		return c.options.Clock.Now().Add(
+			time.Duration(expiration) * time.Second)
+	}
+	return time.Unix(int64(expiration), 0)
+}
+
+func (c *MockClient) isExpired(item *mockItem) bool {
+	now := c.options.Clock.Now()
+	if !item.expireAt.IsZero() && !now.Before(item.expireAt) {
+		return true
+	}
+	return !c.flushAt.IsZero() &&
+		!now.Before(c.flushAt) &&
+		!item.storedAt.After(c.flushAt)
+}
+
+func (c *MockClient) removeItem(item *mockItem) {
+	c.lru.Remove(item.elem)
+	delete(c.data, item.Key)
+	c.numBytes -= item.size()
+}
+
+// Returns the live item for the key (bumping its lru position), or nil.
+func (c *MockClient) lookup(key string) *mockItem {
+	item, ok := c.data[key]
+	if !ok {
+		return nil
+	}
+
+	if c.isExpired(item) {
+		c.removeItem(item)
+		return nil
+	}
+
+	c.lru.MoveToFront(item.elem)
+	return item
+}
+
+// Inserts (or replaces) the item with a new data version id, and evicts
+// least recently used items as needed.
+func (c *MockClient) insert(item *Item) *mockItem {
+	if existing, ok := c.data[item.Key]; ok {
+		c.removeItem(existing)
+	}
+
+	c.version++
+	newItem := &mockItem{
+		Item: Item{
+			Key:           item.Key,
+			Value:         append([]byte{}, item.Value...),
+			Flags:         item.Flags,
+			Expiration:    item.Expiration,
+			DataVersionId: c.version,
+		},
+		expireAt: c.expirationTime(item.Expiration),
+		storedAt: c.options.Clock.Now(),
+	}
+	newItem.elem = c.lru.PushFront(newItem)
+	c.data[newItem.Key] = newItem
+	c.numBytes += newItem.size()
+
+	for c.lru.Len() > 1 &&
+		((c.options.MaxItems > 0 && c.lru.Len() > c.options.MaxItems) ||
+			(c.options.MaxBytes > 0 && c.numBytes > c.options.MaxBytes)) {
+
+		c.removeItem(c.lru.Back().Value.(*mockItem))
+	}
+
+	return newItem
+}
+
+func (c *MockClient) isTooLarge(key string, value []byte) bool {
+	return len(value) > c.options.MaxValueLength ||
+		(c.options.MaxBytes > 0 && len(key)+len(value) > c.options.MaxBytes)
 }
 
-func (c *MockClient) getHelper(key string) GetResponse {
+func (c *MockClient) getHelper(key string, sentinel bool) GetResponse {
 	if c.forceFailEverything {
 		return NewGetResponse(
 			key, StatusInternalError, 0, nil, 0)
 	}
-	if v, ok := c.data[key]; ok && !c.forceGetMisses {
+	if c.isSkipped(sentinel) {
+		return NewGetResponse(key, StatusKeyNotFound, 0, nil, 0)
+	}
+	if v := c.lookup(key); v != nil && !c.forceGetMisses {
 		return NewGetResponse(
 			key,
 			StatusNoError,
 			v.Flags,
-			v.Value,
+			append([]byte{}, v.Value...),
 			v.DataVersionId)
 	}
 	return NewGetResponse(key, StatusKeyNotFound, 0, nil, 0)
@@ -59,7 +295,19 @@ func (c *MockClient) Get(key string) GetResponse {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	return c.getHelper(key)
+	c.record("get", key)
+	return c.getHelper(key, false)
+}
+
+func (c *MockClient) getMultiHelper(
+	keys []string,
+	sentinel bool) map[string]GetResponse {
+
+	res := make(map[string]GetResponse)
+	for _, key := range keys {
+		res[key] = c.getHelper(key, sentinel)
+	}
+	return res
 }
 
 // Batch version of the Get method.
@@ -67,65 +315,144 @@ func (c *MockClient) GetMulti(keys []string) map[string]GetResponse {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	c.record("get_multi", keys...)
+	return c.getMultiHelper(keys, false)
+}
+
+func (c *MockClient) GetSentinels(keys []string) map[string]GetResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.record("get_sentinels", keys...)
+	return c.getMultiHelper(keys, true)
+}
+
+func (c *MockClient) getAndTouchHelper(
+	key string,
+	expiration uint32) GetResponse {
+
+	resp := c.getHelper(key, false)
+	if resp.Status() == StatusNoError {
+		item := c.data[key]
+		item.Expiration = expiration
+		item.expireAt = c.expirationTime(expiration)
+	}
+	return resp
+}
+
+// This retrieves a single entry from memcache, and updates the entry's
+// expiration time.
+func (c *MockClient) GetAndTouch(key string, expiration uint32) GetResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.record("get_and_touch", key)
+	return c.getAndTouchHelper(key, expiration)
+}
+
+// Batch version of the GetAndTouch method.
+func (c *MockClient) GetAndTouchMulti(
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.record("get_and_touch_multi", keys...)
+
 	res := make(map[string]GetResponse)
 	for _, key := range keys {
-		res[key] = c.getHelper(key)
+		res[key] = c.getAndTouchHelper(key, expiration)
 	}
 	return res
 }
 
-func (c *MockClient) GetSentinels(keys []string) map[string]GetResponse {
-	return c.GetMulti(keys)
+// This updates a single entry's expiration time without retrieving it.
+func (c *MockClient) Touch(key string, expiration uint32) MutateResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.record("touch", key)
+
+	if c.forceFailEverything {
+		return NewMutateResponse(key, StatusInternalError, 0)
+	}
+	if c.isSkipped(false) {
+		return NewMutateResponse(key, StatusNoError, 0)
+	}
+
+	v := c.lookup(key)
+	if v == nil {
+		return NewMutateResponse(key, StatusKeyNotFound, 0)
+	}
+
+	v.Expiration = expiration
+	v.expireAt = c.expirationTime(expiration)
+	return NewMutateResponse(key, StatusNoError, 0)
 }
 
-func (c *MockClient) setHelper(item *Item) MutateResponse {
-	c.version++
+func (c *MockClient) setHelper(item *Item, sentinel bool) MutateResponse {
 	if c.forceSetInternalErrors || c.forceFailEverything {
 		return NewMutateResponse(
 			item.Key,
 			StatusInternalError,
 			0)
 	}
-
-	newItem := &Item{
-		Key:           item.Key,
-		Value:         item.Value,
-		Flags:         item.Flags,
-		Expiration:    item.Expiration,
-		DataVersionId: c.version,
+	if c.isSkipped(sentinel) {
+		return NewMutateResponse(item.Key, StatusNoError, 0)
+	}
+	if c.isTooLarge(item.Key, item.Value) {
+		return NewMutateResponse(item.Key, StatusValueTooLarge, 0)
 	}
 
-	existing, ok := c.data[newItem.Key]
+	existing := c.lookup(item.Key)
 
 	if item.DataVersionId == 0 ||
-		(ok && item.DataVersionId == existing.DataVersionId) {
+		(existing != nil && item.DataVersionId == existing.DataVersionId) {
 
-		c.data[newItem.Key] = newItem
+		newItem := c.insert(item)
 		return NewMutateResponse(
 			newItem.Key,
 			StatusNoError,
 			newItem.DataVersionId)
-	} else if !ok {
+	} else if existing == nil {
 		return NewMutateResponse(
-			newItem.Key,
+			item.Key,
 			StatusKeyNotFound,
 			0)
 	} else {
 		// CAS mismatch
 		return NewMutateResponse(
-			newItem.Key,
+			item.Key,
 			StatusKeyExists,
 			0)
 	}
 
 }
 
-func (c *MockClient) casHelper(item *Item) MutateResponse {
+func (c *MockClient) casHelper(item *Item, sentinel bool) MutateResponse {
 	if item.DataVersionId == 0 {
-		return c.addHelper(item)
+		return c.addHelper(item, sentinel)
 	} else {
-		return c.setHelper(item)
+		return c.setHelper(item, sentinel)
+	}
+}
+
+// Applies the mutation to each item.  While the shard is warming up,
+// sentinel failures are overridden with success responses.
+func (c *MockClient) mutateMultiHelper(
+	items []*Item,
+	sentinel bool,
+	mutateFunc func(*Item, bool) MutateResponse) []MutateResponse {
+
+	res := make([]MutateResponse, len(items))
+	for i, item := range items {
+		res[i] = mutateFunc(item, sentinel)
+		if sentinel && c.state == WarmUpServer && res[i].Error() != nil {
+			res[i] = NewMutateResponse(item.Key, StatusNoError, 0)
+		}
 	}
+	return res
 }
 
 // This sets a single entry into memcache.  If the item's data version id
@@ -135,7 +462,8 @@ func (c *MockClient) Set(item *Item) MutateResponse {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	return c.setHelper(item)
+	c.record("set", item.Key)
+	return c.setHelper(item, false)
 }
 
 // Batch version of the Set method.  Note that the response entries
@@ -144,58 +472,57 @@ func (c *MockClient) SetMulti(items []*Item) []MutateResponse {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	res := make([]MutateResponse, len(items))
-	for i, item := range items {
-		res[i] = c.setHelper(item)
-	}
-	return res
+	c.recordItems("set_multi", items)
+	return c.mutateMultiHelper(items, false, c.setHelper)
 }
 
 func (c *MockClient) SetSentinels(items []*Item) []MutateResponse {
-	// TODO(patrick): Support state mocking
-	return c.SetMulti(items)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.recordItems("set_sentinels", items)
+	return c.mutateMultiHelper(items, true, c.setHelper)
 }
 
 func (c *MockClient) CasMulti(items []*Item) []MutateResponse {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	res := make([]MutateResponse, len(items))
-	for i, item := range items {
-		res[i] = c.casHelper(item)
-	}
-	return res
+	c.recordItems("cas_multi", items)
+	return c.mutateMultiHelper(items, false, c.casHelper)
 }
 
 func (c *MockClient) CasSentinels(items []*Item) []MutateResponse {
-	return c.CasMulti(items)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.recordItems("cas_sentinels", items)
+	return c.mutateMultiHelper(items, true, c.casHelper)
 }
 
-func (c *MockClient) addHelper(item *Item) MutateResponse {
-	c.version++
+func (c *MockClient) addHelper(item *Item, sentinel bool) MutateResponse {
 	if c.forceFailEverything {
 		return NewMutateResponse(
 			item.Key,
 			StatusInternalError,
 			0)
 	}
-	newItem := &Item{
-		Key:           item.Key,
-		Value:         item.Value,
-		Flags:         item.Flags,
-		Expiration:    item.Expiration,
-		DataVersionId: c.version,
+	if c.isSkipped(sentinel) {
+		return NewMutateResponse(item.Key, StatusNoError, 0)
+	}
+	if c.isTooLarge(item.Key, item.Value) {
+		return NewMutateResponse(item.Key, StatusValueTooLarge, 0)
 	}
 
-	if _, ok := c.data[newItem.Key]; !ok {
-		c.data[newItem.Key] = newItem
+	if c.lookup(item.Key) == nil {
+		newItem := c.insert(item)
 		return NewMutateResponse(
 			newItem.Key,
 			StatusNoError,
 			newItem.DataVersionId)
 	} else {
 		return NewMutateResponse(
-			newItem.Key,
+			item.Key,
 			StatusItemNotStored,
 			0)
 	}
@@ -207,7 +534,8 @@ func (c *MockClient) Add(item *Item) MutateResponse {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	return c.addHelper(item)
+	c.record("add", item.Key)
+	return c.addHelper(item, false)
 }
 
 // Batch version of the Add method.  Note that the response entries
@@ -216,42 +544,50 @@ func (c *MockClient) AddMulti(items []*Item) []MutateResponse {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	res := make([]MutateResponse, len(items))
-	for i, item := range items {
-		res[i] = c.addHelper(item)
-	}
-	return res
+	c.recordItems("add_multi", items)
+	return c.mutateMultiHelper(items, false, c.addHelper)
 }
 
 // This replaces a single entry in memcache.  Note: Replace will fail if
 // the does not exist in memcache.
 func (c *MockClient) Replace(item *Item) MutateResponse {
-	return NewMutateErrorResponse(
-		item.Key,
-		errors.Newf("Replace not implemented"))
-}
-
-// This deletes a single entry from memcache.
-func (c *MockClient) Delete(key string) MutateResponse {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	c.record("replace", item.Key)
+
+	if c.forceSetInternalErrors || c.forceFailEverything {
+		return NewMutateResponse(item.Key, StatusInternalError, 0)
+	}
+	if c.isSkipped(false) {
+		return NewMutateResponse(item.Key, StatusNoError, 0)
+	}
+	if c.lookup(item.Key) == nil {
+		return NewMutateResponse(item.Key, StatusKeyNotFound, 0)
+	}
+	return c.setHelper(item, false)
+}
+
+func (c *MockClient) deleteHelper(key string) MutateResponse {
 	if c.forceFailEverything {
 		return NewMutateResponse(
 			key,
 			StatusInternalError,
 			0)
 	}
+	if c.isSkipped(false) {
+		return NewMutateResponse(key, StatusNoError, 0)
+	}
 
-	_, ok := c.data[key]
-	if !ok {
+	v := c.lookup(key)
+	if v == nil {
 		return NewMutateResponse(
 			key,
 			StatusKeyNotFound,
 			0)
 	}
 
-	delete(c.data, key)
+	c.removeItem(v)
 
 	return NewMutateResponse(
 		key,
@@ -259,26 +595,88 @@ func (c *MockClient) Delete(key string) MutateResponse {
 		0)
 }
 
+// This deletes a single entry from memcache.
+func (c *MockClient) Delete(key string) MutateResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.record("delete", key)
+	return c.deleteHelper(key)
+}
+
 // Batch version of the Delete method.  Note that the response entries
 // ordering is undefined (i.e., may not match the input ordering)
 func (c *MockClient) DeleteMulti(keys []string) []MutateResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.record("delete_multi", keys...)
+
 	res := make([]MutateResponse, len(keys))
 	for i, key := range keys {
-		res[i] = c.Delete(key)
+		res[i] = c.deleteHelper(key)
 	}
 	return res
 }
 
+func (c *MockClient) appendPrependHelper(
+	key string,
+	value []byte,
+	isAppend bool) MutateResponse {
+
+	if c.forceSetInternalErrors || c.forceFailEverything {
+		return NewMutateResponse(key, StatusInternalError, 0)
+	}
+	if c.isSkipped(false) {
+		return NewMutateResponse(key, StatusNoError, 0)
+	}
+
+	v := c.lookup(key)
+	if v == nil {
+		return NewMutateResponse(key, StatusItemNotStored, 0)
+	}
+
+	var newValue []byte
+	if isAppend {
+		newValue = append(append([]byte{}, v.Value...), value...)
+	} else {
+		newValue = append(append([]byte{}, value...), v.Value...)
+	}
+	if c.isTooLarge(key, newValue) {
+		return NewMutateResponse(key, StatusValueTooLarge, 0)
+	}
+
+	// Append / prepend keep the existing flags and expiration.
+	expireAt := v.expireAt
+	newItem := c.insert(&Item{
+		Key:        key,
+		Value:      newValue,
+		Flags:      v.Flags,
+		Expiration: v.Expiration,
+	})
+	newItem.expireAt = expireAt
+
+	return NewMutateResponse(key, StatusNoError, newItem.DataVersionId)
+}
+
 // This appends the value bytes to the end of an existing entry.  Note that
 // this does not allow you to extend past the item limit.
 func (c *MockClient) Append(key string, value []byte) MutateResponse {
-	return NewMutateErrorResponse(key, errors.Newf("Append not implemented"))
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.record("append", key)
+	return c.appendPrependHelper(key, value, true)
 }
 
 // This prepends the value bytes to the end of an existing entry.  Note that
 // this does not allow you to extend past the item limit.
 func (c *MockClient) Prepend(key string, value []byte) MutateResponse {
-	return NewMutateErrorResponse(key, errors.Newf("Prepend not implemented"))
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.record("prepend", key)
+	return c.appendPrependHelper(key, value, false)
 }
 
 func (c *MockClient) incrementDecrementHelper(
@@ -290,51 +688,48 @@ func (c *MockClient) incrementDecrementHelper(
 	if c.forceFailEverything {
 		return NewCountResponse(key, StatusInternalError, 0)
 	}
+	if c.isSkipped(false) {
+		return NewCountResponse(key, StatusNoError, 0)
+	}
 
-	if v, ok := c.data[key]; ok && !c.forceGetMisses {
+	if v := c.lookup(key); v != nil && !c.forceGetMisses {
 		// item already exists
-		valStr, flags, dataVersionId, expiration := v.Value, v.Flags, v.DataVersionId, v.Expiration
-		value, err := strconv.Atoi(string(valStr))
+		value, err := strconv.ParseUint(string(v.Value), 10, 64)
 		if err != nil {
 			return NewCountResponse(key, StatusIncrDecrOnNonNumericValue, 0)
 		}
 		var newValue uint64
 		if operation == Increment {
-			newValue = uint64(value) + delta
+			newValue = value + delta // wraps, same as memcached
+		} else if delta > value {
+			newValue = 0
 		} else {
-			newValue = uint64(value) - delta
-			if newValue < 0 {
-				newValue = 0
-			}
+			newValue = value - delta
 		}
-		c.setHelper(&Item{
-			Key:           key,
-			Value:         []byte(strconv.Itoa(int(newValue))),
-			Flags:         flags,
-			Expiration:    expiration,
-			DataVersionId: dataVersionId,
+
+		// The existing expiration is kept.
+		expireAt := v.expireAt
+		newItem := c.insert(&Item{
+			Key:        key,
+			Value:      []byte(strconv.FormatUint(newValue, 10)),
+			Flags:      v.Flags,
+			Expiration: v.Expiration,
 		})
+		newItem.expireAt = expireAt
 		return NewCountResponse(key, StatusNoError, newValue)
 	}
 	if expiration == 0xffffffff {
 		return NewCountResponse(key, StatusKeyNotFound, 0)
 	} else {
-		var newValue uint64
-		if operation == Increment {
-			newValue = initValue + delta
-		} else {
-			newValue = initValue - delta
-			if newValue < 0 {
-				newValue = 0
-			}
-		}
-		c.addHelper(&Item{
+		// NOTE: Same as memcached, the initial value is stored as is (i.e.,
+		// delta is not applied).
+		c.insert(&Item{
 			Key:        key,
-			Value:      []byte(strconv.Itoa(int(newValue))),
+			Value:      []byte(strconv.FormatUint(initValue, 10)),
 			Flags:      0,
 			Expiration: expiration,
 		})
-		return NewCountResponse(key, StatusNoError, newValue)
+		return NewCountResponse(key, StatusNoError, initValue)
 	}
 
 }
@@ -360,6 +755,8 @@ func (c *MockClient) Increment(
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+
+	c.record("increment", key)
 	return c.incrementDecrementHelper(key, delta, initValue, expiration, Increment)
 
 }
@@ -386,6 +783,8 @@ func (c *MockClient) Decrement(
 
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+
+	c.record("decrement", key)
 	return c.incrementDecrementHelper(key, delta, initValue, expiration, Decrement)
 
 }
@@ -396,8 +795,20 @@ func (c *MockClient) Flush(expiration uint32) Response {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	// TODO(patrick): Use expiration argument
-	c.data = make(map[string]*Item)
+	c.record("flush")
+
+	if c.forceFailEverything {
+		return NewResponse(StatusInternalError)
+	}
+
+	if expiration == 0 {
+		c.data = make(map[string]*mockItem)
+		c.lru.Init()
+		c.numBytes = 0
+		c.flushAt = time.Time{}
+	} else {
+		c.flushAt = c.expirationTime(expiration)
+	}
 	return NewResponse(StatusNoError)
 }
 
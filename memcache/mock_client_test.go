@@ -2,10 +2,12 @@ package memcache
 
 import (
 	"bytes"
+	"time"
 
 	. "gopkg.in/check.v1"
 
 	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/time2"
 )
 
 type MockClientSuite struct {
@@ -70,3 +72,234 @@ func (s *MockClientSuite) TestAddMultiEmpty(c *C) {
 	resps := s.client.AddMulti(items)
 	c.Assert(resps, HasLen, 0)
 }
+
+func (s *MockClientSuite) TestTouch(c *C) {
+	item := createTestItem()
+	item.DataVersionId = 0
+	s.client.Set(item)
+
+	resp := s.client.Touch(item.Key, 5)
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.client.data[item.Key].Expiration, Equals, uint32(5))
+
+	gresp := s.client.GetAndTouch(item.Key, 10)
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(bytes.Equal(gresp.Value(), item.Value), IsTrue)
+	c.Assert(s.client.data[item.Key].Expiration, Equals, uint32(10))
+
+	gresps := s.client.GetAndTouchMulti([]string{item.Key, "missing"}, 15)
+	c.Assert(gresps[item.Key].Error(), IsNil)
+	c.Assert(gresps["missing"].Status(), Equals, StatusKeyNotFound)
+	c.Assert(s.client.data[item.Key].Expiration, Equals, uint32(15))
+
+	resp = s.client.Touch("missing", 5)
+	c.Assert(resp.Status(), Equals, StatusKeyNotFound)
+}
+
+func (s *MockClientSuite) newClient(
+	options MockClientOptions) (*MockClient, *time2.MockClock) {
+
+	clock := time2.NewMockClock(time.Unix(1000000000, 0))
+	options.Clock = clock
+	return NewMockClientWithOptions(options), clock
+}
+
+func (s *MockClientSuite) TestExpiration(c *C) {
+	client, clock := s.newClient(MockClientOptions{})
+
+	client.Set(&Item{Key: "a", Value: []byte("1"), Expiration: 10})
+	client.Add(&Item{Key: "b", Value: []byte("2"), Expiration: 20})
+	client.Increment("c", 1, 5, 10)
+	client.Set(&Item{Key: "d", Value: []byte("4")})
+	client.Set(&Item{Key: "e", Value: []byte("5"), Expiration: 1000000000 + 10})
+
+	clock.Advance(9 * time.Second)
+	c.Assert(client.NumItems(), Equals, 5)
+
+	// Touch extends the expiration.
+	c.Assert(client.Touch("a", 20).Error(), IsNil)
+
+	clock.Advance(time.Second)
+	c.Assert(client.Get("a").Status(), Equals, StatusNoError)
+	c.Assert(client.Get("c").Status(), Equals, StatusKeyNotFound)
+	c.Assert(client.Get("e").Status(), Equals, StatusKeyNotFound)
+
+	// Increment keeps the existing expiration.
+	client.Set(&Item{Key: "c", Value: []byte("1"), Expiration: 5})
+	c.Assert(client.Increment("c", 1, 0, 0).Count(), Equals, uint64(2))
+	clock.Advance(5 * time.Second)
+	c.Assert(client.Get("c").Status(), Equals, StatusKeyNotFound)
+
+	clock.Advance(100 * time.Second)
+	c.Assert(client.NumItems(), Equals, 1)
+	c.Assert(client.Get("d").Status(), Equals, StatusNoError)
+}
+
+func (s *MockClientSuite) TestEviction(c *C) {
+	client, _ := s.newClient(MockClientOptions{MaxItems: 2})
+
+	client.Set(&Item{Key: "a", Value: []byte("1")})
+	client.Set(&Item{Key: "b", Value: []byte("2")})
+	client.Get("a")
+	client.Set(&Item{Key: "c", Value: []byte("3")})
+
+	c.Assert(client.NumItems(), Equals, 2)
+	c.Assert(client.Get("a").Status(), Equals, StatusNoError)
+	c.Assert(client.Get("b").Status(), Equals, StatusKeyNotFound)
+
+	client, _ = s.newClient(MockClientOptions{MaxBytes: 10})
+
+	client.Set(&Item{Key: "a", Value: []byte("1234")})
+	client.Set(&Item{Key: "b", Value: []byte("1234")})
+	c.Assert(client.NumItems(), Equals, 2)
+	client.Set(&Item{Key: "c", Value: []byte("1")})
+	c.Assert(client.NumItems(), Equals, 2)
+	c.Assert(client.Get("a").Status(), Equals, StatusKeyNotFound)
+
+	resp := client.Set(&Item{Key: "d", Value: []byte("0123456789")})
+	c.Assert(resp.Status(), Equals, StatusValueTooLarge)
+}
+
+func (s *MockClientSuite) TestCas(c *C) {
+	client, _ := s.newClient(MockClientOptions{})
+
+	resp := client.Set(&Item{Key: "a", Value: []byte("1"), DataVersionId: 5})
+	c.Assert(resp.Status(), Equals, StatusKeyNotFound)
+
+	resp = client.Set(&Item{Key: "a", Value: []byte("1")})
+	c.Assert(resp.Error(), IsNil)
+	cas := resp.DataVersionId()
+
+	// Failed writes do not change the data version id.
+	resp = client.Add(&Item{Key: "a", Value: []byte("2")})
+	c.Assert(resp.Status(), Equals, StatusItemNotStored)
+	c.Assert(client.Get("a").DataVersionId(), Equals, cas)
+
+	// Every successful mutation changes the data version id.
+	resp = client.Append("a", []byte("0"))
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(resp.DataVersionId() > cas, IsTrue)
+
+	resp = client.Set(&Item{Key: "a", Value: []byte("2"), DataVersionId: cas})
+	c.Assert(resp.Status(), Equals, StatusKeyExists)
+
+	gresp := client.Get("a")
+	c.Assert(string(gresp.Value()), Equals, "10")
+	c.Assert(client.Increment("a", 1, 0, 0).Count(), Equals, uint64(11))
+
+	resps := client.CasMulti([]*Item{
+		{Key: "a", Value: []byte("x"), DataVersionId: gresp.DataVersionId()},
+		{Key: "b", Value: []byte("y")},
+	})
+	c.Assert(resps[0].Status(), Equals, StatusKeyExists)
+	c.Assert(resps[1].Error(), IsNil)
+
+	gresp = client.Get("a")
+	resp = client.Replace(
+		&Item{Key: "a", Value: []byte("z"), DataVersionId: gresp.DataVersionId()})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(string(client.Get("a").Value()), Equals, "z")
+}
+
+func (s *MockClientSuite) TestMutations(c *C) {
+	client, _ := s.newClient(MockClientOptions{})
+
+	c.Assert(
+		client.Replace(&Item{Key: "a", Value: []byte("1")}).Status(),
+		Equals,
+		StatusKeyNotFound)
+	c.Assert(
+		client.Prepend("a", []byte("0")).Status(),
+		Equals,
+		StatusItemNotStored)
+
+	client.Set(&Item{Key: "a", Value: []byte("1"), Flags: 7})
+	c.Assert(client.Prepend("a", []byte("2")).Error(), IsNil)
+	c.Assert(client.Append("a", []byte("3")).Error(), IsNil)
+
+	gresp := client.Get("a")
+	c.Assert(string(gresp.Value()), Equals, "213")
+	c.Assert(gresp.Flags(), Equals, uint32(7))
+
+	c.Assert(client.Decrement("a", 300, 0, 0).Count(), Equals, uint64(0))
+
+	cresp := client.Increment("missing", 1, 0, 0xffffffff)
+	c.Assert(cresp.Status(), Equals, StatusKeyNotFound)
+	cresp = client.Decrement("missing", 1, 10, 0)
+	c.Assert(cresp.Count(), Equals, uint64(10))
+
+	client.Set(&Item{Key: "text", Value: []byte("abc")})
+	cresp = client.Increment("text", 1, 0, 0)
+	c.Assert(cresp.Status(), Equals, StatusIncrDecrOnNonNumericValue)
+}
+
+func (s *MockClientSuite) TestDelayedFlush(c *C) {
+	client, clock := s.newClient(MockClientOptions{})
+
+	client.Set(&Item{Key: "a", Value: []byte("1")})
+	c.Assert(client.Flush(10).Error(), IsNil)
+
+	clock.Advance(9 * time.Second)
+	c.Assert(client.Get("a").Status(), Equals, StatusNoError)
+
+	clock.Advance(time.Second)
+	c.Assert(client.Get("a").Status(), Equals, StatusKeyNotFound)
+
+	// Items stored after the flush time are not affected.
+	clock.Advance(time.Second)
+	client.Set(&Item{Key: "b", Value: []byte("2")})
+	c.Assert(client.Get("b").Status(), Equals, StatusNoError)
+}
+
+func (s *MockClientSuite) TestShardStates(c *C) {
+	client, _ := s.newClient(MockClientOptions{})
+	client.Set(&Item{Key: "a", Value: []byte("1")})
+
+	client.SetShardState(WriteOnlyServer)
+	c.Assert(client.Get("a").Status(), Equals, StatusKeyNotFound)
+	c.Assert(client.GetSentinels([]string{"a"})["a"].Error(), IsNil)
+
+	// Non-sentinel writes are skipped.
+	c.Assert(client.Delete("a").Error(), IsNil)
+	resps := client.SetSentinels([]*Item{{Key: "b", Value: []byte("2")}})
+	c.Assert(resps[0].Error(), IsNil)
+
+	client.SetShardState(WarmUpServer)
+	resps = client.CasSentinels(
+		[]*Item{{Key: "b", Value: []byte("3"), DataVersionId: 12345}})
+	c.Assert(resps[0].Error(), IsNil)
+	c.Assert(
+		string(client.GetSentinels([]string{"b"})["b"].Value()),
+		Equals,
+		"2")
+
+	client.SetShardState(DownServer)
+	c.Assert(client.GetSentinels([]string{"a"})["a"].Status(), Equals, StatusKeyNotFound)
+	resps = client.SetSentinels([]*Item{{Key: "c", Value: []byte("4")}})
+	c.Assert(resps[0].Error(), IsNil)
+
+	client.SetShardState(ActiveServer)
+	c.Assert(client.NumItems(), Equals, 2)
+	c.Assert(client.Get("a").Error(), IsNil)
+	c.Assert(client.Get("c").Status(), Equals, StatusKeyNotFound)
+}
+
+func (s *MockClientSuite) TestOperations(c *C) {
+	client, _ := s.newClient(MockClientOptions{})
+
+	client.Set(&Item{Key: "a", Value: []byte("1")})
+	client.GetMulti([]string{"a", "b"})
+	client.Flush(0)
+
+	c.Assert(
+		client.Operations(),
+		DeepEquals,
+		[]MockOperation{
+			{Name: "set", Keys: []string{"a"}},
+			{Name: "get_multi", Keys: []string{"a", "b"}},
+			{Name: "flush"},
+		})
+
+	client.ClearOperations()
+	c.Assert(client.Operations(), HasLen, 0)
+}
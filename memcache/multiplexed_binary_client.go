@@ -0,0 +1,846 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+const (
+	// The maximum number of requests queued for writing.
+	multiplexedWriteQueueSize = 1024
+)
+
+// A response packet.
+type packet struct {
+	hdr    header
+	extras []byte
+	key    []byte
+	value  []byte
+}
+
+// A request waiting for its response(s).
+type pendingRequest struct {
+	// When true, the request receives multiple responses (terminated by a
+	// response with empty key and value, or by an error status).
+	multi bool
+
+	packets []*packet
+	err     error
+	done    chan struct{}
+}
+
+// An unsharded memcache client implementation which multiplexes concurrent
+// requests over a single io channel, using the binary memcached protocol.
+// Each request is tagged with a unique opaque id, which is used to match the
+// response with the request.  Unlike RawBinaryClient, operations are not
+// serialized; requests from many goroutines are pipelined, and small writes
+// are batched into a single write.
+//
+// The client takes ownership of the channel, and runs a reader and a writer
+// goroutine until the client is closed (or the channel fails).  Once the
+// channel fails, all in-flight and future requests fail, and the client is in
+// invalid state.  Since the client owns its channel, it cannot be built on
+// top of pooled connections; see MultiplexedConnectionPool for using the
+// client with ShardedClient.
+//
+// Since requests are pipelined, io deadlines cannot be applied to individual
+// requests.  Instead, a request which does not receive its response within
+// the client's RequestTimeout fails the channel (and hence all in-flight
+// requests).
+type MultiplexedBinaryClient struct {
+	shard          int
+	maxValueLength int
+
+	*multiplexedChannel
+}
+
+// Options for MultiplexedBinaryClient.
+type MultiplexedBinaryClientOptions struct {
+	// The maximum amount of time to wait for a request's response(s).  When
+	// a request times out, the channel is failed and closed.  Zero means no
+	// timeout.
+	RequestTimeout time.Duration
+}
+
+// The io channel state, which is shared by clients created by withShard.
+type multiplexedChannel struct {
+	channel        io.ReadWriteCloser
+	requestTimeout time.Duration
+
+	writeQueue chan []byte
+	done       chan struct{} // closed when the channel fails / is closed
+
+	mutex      sync.Mutex
+	nextOpaque uint32
+	pending    map[uint32]*pendingRequest // guarded by mutex
+	err        error                      // guarded by mutex
+}
+
+var _ ClientShard = (*MultiplexedBinaryClient)(nil)
+
+// This creates a new memcache MultiplexedBinaryClient (without request
+// timeout).
+func NewMultiplexedBinaryClient(
+	shard int,
+	channel io.ReadWriteCloser) *MultiplexedBinaryClient {
+
+	return NewMultiplexedBinaryClientWithOptions(
+		shard,
+		channel,
+		MultiplexedBinaryClientOptions{})
+}
+
+// This creates a new memcache MultiplexedBinaryClient with the given options.
+func NewMultiplexedBinaryClientWithOptions(
+	shard int,
+	channel io.ReadWriteCloser,
+	options MultiplexedBinaryClientOptions) *MultiplexedBinaryClient {
+
+	c := &multiplexedChannel{
+		channel:        channel,
+		requestTimeout: options.RequestTimeout,
+		writeQueue:     make(chan []byte, multiplexedWriteQueueSize),
+		done:           make(chan struct{}),
+		pending:        make(map[uint32]*pendingRequest),
+	}
+
+	go c.readLoop()
+	go c.writeLoop()
+
+	return &MultiplexedBinaryClient{
+		shard:              shard,
+		maxValueLength:     defaultMaxValueLength,
+		multiplexedChannel: c,
+	}
+}
+
+// Returns a client which shares this client's channel, but reports the given
+// shard id.
+func (c *MultiplexedBinaryClient) withShard(
+	shard int) *MultiplexedBinaryClient {
+
+	if shard == c.shard {
+		return c
+	}
+	return &MultiplexedBinaryClient{
+		shard:              shard,
+		maxValueLength:     c.maxValueLength,
+		multiplexedChannel: c.multiplexedChannel,
+	}
+}
+
+// See ClientShard interface for documentation.
+func (c *MultiplexedBinaryClient) ShardId() int {
+	return c.shard
+}
+
+// See ClientShard interface for documentation.
+func (c *MultiplexedBinaryClient) IsValidState() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.err == nil
+}
+
+// This fails all in-flight requests, and closes the channel (including for
+// clients sharing the channel).
+func (c *MultiplexedBinaryClient) Close() error {
+	c.fail(errors.New("Client is closed"))
+	return nil
+}
+
+// Marks the channel as invalid, and fails all in-flight requests.
+func (c *multiplexedChannel) fail(err error) {
+	c.mutex.Lock()
+	if c.err != nil {
+		c.mutex.Unlock()
+		return
+	}
+
+	c.err = err
+	pending := c.pending
+	c.pending = make(map[uint32]*pendingRequest)
+	close(c.done)
+	c.mutex.Unlock()
+
+	_ = c.channel.Close()
+
+	for _, req := range pending {
+		req.err = err
+		close(req.done)
+	}
+}
+
+// Waits for the request's response(s).  If the request times out, the channel
+// is failed, which also fails the request.
+func (c *multiplexedChannel) wait(req *pendingRequest) ([]*packet, error) {
+	if c.requestTimeout <= 0 {
+		<-req.done
+		return req.packets, req.err
+	}
+
+	timer := time.NewTimer(c.requestTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-req.done:
+	case <-timer.C:
+		c.fail(errors.Newf(
+			"Timed out waiting for response after %v",
+			c.requestTimeout))
+		<-req.done
+	}
+	return req.packets, req.err
+}
+
+func (c *multiplexedChannel) writeLoop() {
+	writer := bufio.NewWriter(c.channel)
+
+	for {
+		var msg []byte
+		select {
+		case msg = <-c.writeQueue:
+		case <-c.done:
+			return
+		}
+
+		if _, err := writer.Write(msg); err != nil {
+			c.fail(errors.Wrap(err, "Failed to send msg"))
+			return
+		}
+
+		// Batch all queued requests into a single write.
+		for len(c.writeQueue) > 0 {
+			if _, err := writer.Write(<-c.writeQueue); err != nil {
+				c.fail(errors.Wrap(err, "Failed to send msg"))
+				return
+			}
+		}
+
+		if err := writer.Flush(); err != nil {
+			c.fail(errors.Wrap(err, "Failed to send msg"))
+			return
+		}
+	}
+}
+
+func (c *multiplexedChannel) readLoop() {
+	reader := bufio.NewReader(c.channel)
+	hdrBytes := make([]byte, headerLength)
+
+	for {
+		if _, err := io.ReadFull(reader, hdrBytes); err != nil {
+			c.fail(errors.Wrap(err, "Failed to read header"))
+			return
+		}
+
+		pkt := &packet{}
+		pkt.hdr.Deserialize(hdrBytes)
+		if pkt.hdr.Magic != respMagicByte {
+			c.fail(errors.Newf("Invalid response magic byte: %d", pkt.hdr.Magic))
+			return
+		}
+		if pkt.hdr.DataType != 0 {
+			c.fail(errors.Newf("Invalid data type: %d", pkt.hdr.DataType))
+			return
+		}
+
+		extrasLength := int(pkt.hdr.ExtrasLength)
+		keyLength := int(pkt.hdr.KeyLength)
+		if extrasLength+keyLength > int(pkt.hdr.TotalBodyLength) {
+			c.fail(errors.New("Invalid response header.  Wrong payload size."))
+			return
+		}
+
+		body := make([]byte, pkt.hdr.TotalBodyLength)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			c.fail(errors.Wrap(err, "Failed to read body"))
+			return
+		}
+		pkt.extras = body[:extrasLength]
+		pkt.key = body[extrasLength : extrasLength+keyLength]
+		pkt.value = body[extrasLength+keyLength:]
+
+		if err := c.deliver(pkt); err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
+
+func (c *multiplexedChannel) deliver(pkt *packet) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	req, ok := c.pending[pkt.hdr.Opaque]
+	if !ok {
+		return errors.Newf("Unexpected response opaque: %d", pkt.hdr.Opaque)
+	}
+
+	req.packets = append(req.packets, pkt)
+	if req.multi &&
+		ResponseStatus(pkt.hdr.VBucketIdOrStatus) == StatusNoError &&
+		(len(pkt.key) != 0 || len(pkt.value) != 0) {
+
+		return nil
+	}
+
+	delete(c.pending, pkt.hdr.Opaque)
+	close(req.done)
+	return nil
+}
+
+// Sends a memcache request without waiting for the response.  NOTE: extras
+// must be fix-sized values.
+func (c *multiplexedChannel) send(
+	code opCode,
+	multi bool,
+	dataVersionId uint64, // aka CAS
+	key []byte, // may be nil
+	value []byte, // may be nil
+	extras ...interface{}) (*pendingRequest, error) {
+
+	if key != nil && len(key) > 255 {
+		return nil, errors.New("Key too long")
+	}
+
+	extrasBuffer := new(bytes.Buffer)
+	for _, extra := range extras {
+		err := binary.Write(extrasBuffer, binary.BigEndian, extra)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to write extra")
+		}
+	}
+
+	req := &pendingRequest{
+		multi: multi,
+		done:  make(chan struct{}),
+	}
+
+	c.mutex.Lock()
+	if c.err != nil {
+		c.mutex.Unlock()
+		return nil, errors.Wrap(c.err, "Skipping due to previous error")
+	}
+	opaque := c.nextOpaque
+	c.nextOpaque++
+	c.pending[opaque] = req
+	c.mutex.Unlock()
+
+	hdr := header{
+		Magic:           reqMagicByte,
+		OpCode:          byte(code),
+		KeyLength:       uint16(len(key)),
+		ExtrasLength:    uint8(extrasBuffer.Len()),
+		TotalBodyLength: uint32(len(key) + len(value) + extrasBuffer.Len()),
+		Opaque:          opaque,
+		DataVersionId:   dataVersionId,
+	}
+
+	msg := make([]byte, headerLength+hdr.TotalBodyLength)
+	hdr.Serialize(msg)
+	offset := headerLength
+	offset += copy(msg[offset:], extrasBuffer.Bytes())
+	offset += copy(msg[offset:], key)
+	copy(msg[offset:], value)
+
+	select {
+	case c.writeQueue <- msg:
+	case <-c.done:
+		// fail() has already failed the pending request.
+	}
+
+	return req, nil
+}
+
+// Sends a memcache request, and waits for its (single) response.
+func (c *multiplexedChannel) roundTrip(
+	code opCode,
+	dataVersionId uint64,
+	key []byte,
+	value []byte,
+	extras ...interface{}) (*packet, error) {
+
+	req, err := c.send(code, false, dataVersionId, key, value, extras...)
+	if err != nil {
+		return nil, err
+	}
+
+	packets, err := c.wait(req)
+	if err != nil {
+		return nil, err
+	}
+	return packets[0], nil
+}
+
+func (p *packet) status() ResponseStatus {
+	return ResponseStatus(p.hdr.VBucketIdOrStatus)
+}
+
+func (c *MultiplexedBinaryClient) sendGetRequest(
+	code opCode,
+	key string,
+	extras ...interface{}) (*pendingRequest, GetResponse) {
+
+	if !isValidKeyString(key) {
+		return nil, NewGetErrorResponse(key, errors.New("Invalid key"))
+	}
+
+	req, err := c.send(code, false, 0, []byte(key), nil, extras...)
+	if err != nil {
+		return nil, NewGetErrorResponse(key, err)
+	}
+	return req, nil
+}
+
+func (c *MultiplexedBinaryClient) receiveGetResponse(
+	req *pendingRequest,
+	key string) GetResponse {
+
+	packets, err := c.wait(req)
+	if err != nil {
+		return NewGetErrorResponse(key, err)
+	}
+
+	pkt := packets[0]
+	var flags uint32
+	if pkt.status() == StatusNoError {
+		if len(pkt.extras) != 4 {
+			return NewGetErrorResponse(
+				key,
+				errors.New("Expecting extras payload"))
+		}
+		flags = binary.BigEndian.Uint32(pkt.extras)
+	}
+
+	value := pkt.value
+	if len(value) == 0 {
+		value = nil
+	}
+	return NewGetResponse(key, pkt.status(), flags, value, pkt.hdr.DataVersionId)
+}
+
+func (c *MultiplexedBinaryClient) get(
+	code opCode,
+	key string,
+	extras ...interface{}) GetResponse {
+
+	req, resp := c.sendGetRequest(code, key, extras...)
+	if resp != nil {
+		return resp
+	}
+	return c.receiveGetResponse(req, key)
+}
+
+// Batch get using the given get op code.  extras are sent with every
+// request.
+func (c *MultiplexedBinaryClient) getMulti(
+	code opCode,
+	keys []string,
+	extras ...interface{}) map[string]GetResponse {
+
+	if keys == nil {
+		return nil
+	}
+
+	responses := make(map[string]GetResponse)
+	requests := make(map[string]*pendingRequest)
+	for _, key := range keys {
+		if _, ok := responses[key]; ok {
+			continue
+		}
+		if _, ok := requests[key]; ok {
+			continue
+		}
+
+		req, resp := c.sendGetRequest(code, key, extras...)
+		if resp != nil {
+			responses[key] = resp
+		} else {
+			requests[key] = req
+		}
+	}
+
+	for key, req := range requests {
+		responses[key] = c.receiveGetResponse(req, key)
+	}
+	return responses
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Get(key string) GetResponse {
+	return c.get(opGet, key)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) GetMulti(keys []string) map[string]GetResponse {
+	return c.getMulti(opGet, keys)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) GetSentinels(
+	keys []string) map[string]GetResponse {
+
+	// For raw clients, there are no difference between GetMulti and
+	// GetSentinels.
+	return c.GetMulti(keys)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) GetAndTouch(
+	key string,
+	expiration uint32) GetResponse {
+
+	return c.get(opGAT, key, expiration)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) GetAndTouchMulti(
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	return c.getMulti(opGAT, keys, expiration)
+}
+
+func (c *MultiplexedBinaryClient) sendMutateRequest(
+	code opCode,
+	item *Item,
+	addExtras bool) (*pendingRequest, MutateResponse) {
+
+	if item == nil {
+		return nil, NewMutateErrorResponse("", errors.New("item is nil"))
+	}
+
+	if !isValidKeyString(item.Key) {
+		return nil, NewMutateErrorResponse(item.Key, errors.New("Invalid key"))
+	}
+
+	if err := validateValue(item.Value, c.maxValueLength); err != nil {
+		return nil, NewMutateErrorResponse(item.Key, err)
+	}
+
+	extras := make([]interface{}, 0, 2)
+	if addExtras {
+		extras = append(extras, item.Flags)
+		extras = append(extras, item.Expiration)
+	}
+
+	req, err := c.send(
+		code,
+		false,
+		item.DataVersionId,
+		[]byte(item.Key),
+		item.Value,
+		extras...)
+	if err != nil {
+		return nil, NewMutateErrorResponse(item.Key, err)
+	}
+	return req, nil
+}
+
+func (c *MultiplexedBinaryClient) receiveMutateResponse(
+	req *pendingRequest,
+	key string) MutateResponse {
+
+	packets, err := c.wait(req)
+	if err != nil {
+		return NewMutateErrorResponse(key, err)
+	}
+	return NewMutateResponse(
+		key,
+		packets[0].status(),
+		packets[0].hdr.DataVersionId)
+}
+
+// Perform a mutation operation specified by the given code.
+func (c *MultiplexedBinaryClient) mutate(
+	code opCode,
+	item *Item,
+	addExtras bool) MutateResponse {
+
+	req, resp := c.sendMutateRequest(code, item, addExtras)
+	if resp != nil {
+		return resp
+	}
+	return c.receiveMutateResponse(req, item.Key)
+}
+
+// Batch version of the mutate method.  The response entries match the input
+// ordering.  When DataVersionId is 0, zeroVersionIdCode is used instead of
+// code.
+func (c *MultiplexedBinaryClient) mutateMulti(
+	code opCode,
+	zeroVersionIdCode opCode,
+	items []*Item) []MutateResponse {
+
+	if items == nil {
+		return nil
+	}
+
+	responses := make([]MutateResponse, len(items))
+	requests := make([]*pendingRequest, len(items))
+	for i, item := range items {
+		itemCode := code
+		if item != nil && item.DataVersionId == 0 {
+			itemCode = zeroVersionIdCode
+		}
+		requests[i], responses[i] = c.sendMutateRequest(itemCode, item, true)
+	}
+
+	for i, item := range items {
+		if responses[i] != nil { // error occurred while sending
+			continue
+		}
+		responses[i] = c.receiveMutateResponse(requests[i], item.Key)
+	}
+	return responses
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Set(item *Item) MutateResponse {
+	return c.mutate(opSet, item, true)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) SetMulti(items []*Item) []MutateResponse {
+	return c.mutateMulti(opSet, opSet, items)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) SetSentinels(items []*Item) []MutateResponse {
+	// For raw clients, there are no difference between SetMulti and
+	// SetSentinels.
+	return c.SetMulti(items)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) CasMulti(items []*Item) []MutateResponse {
+	return c.mutateMulti(opSet, opAdd, items)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) CasSentinels(items []*Item) []MutateResponse {
+	// For raw clients, there are no difference between CasMulti and
+	// CasSentinels.
+	return c.CasMulti(items)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Add(item *Item) MutateResponse {
+	return c.mutate(opAdd, item, true)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) AddMulti(items []*Item) []MutateResponse {
+	return c.mutateMulti(opAdd, opAdd, items)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Replace(item *Item) MutateResponse {
+	return c.mutate(opReplace, item, true)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Append(
+	key string,
+	value []byte) MutateResponse {
+
+	return c.mutate(opAppend, &Item{Key: key, Value: value}, false)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Prepend(
+	key string,
+	value []byte) MutateResponse {
+
+	return c.mutate(opPrepend, &Item{Key: key, Value: value}, false)
+}
+
+func (c *MultiplexedBinaryClient) sendKeyRequest(
+	code opCode,
+	key string,
+	extras ...interface{}) (*pendingRequest, MutateResponse) {
+
+	if !isValidKeyString(key) {
+		return nil, NewMutateErrorResponse(key, errors.New("Invalid key"))
+	}
+
+	req, err := c.send(code, false, 0, []byte(key), nil, extras...)
+	if err != nil {
+		return nil, NewMutateErrorResponse(key, err)
+	}
+	return req, nil
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Delete(key string) MutateResponse {
+	req, resp := c.sendKeyRequest(opDelete, key)
+	if resp != nil {
+		return resp
+	}
+	return c.receiveMutateResponse(req, key)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) DeleteMulti(keys []string) []MutateResponse {
+	if keys == nil {
+		return nil
+	}
+
+	responses := make([]MutateResponse, len(keys))
+	requests := make([]*pendingRequest, len(keys))
+	for i, key := range keys {
+		requests[i], responses[i] = c.sendKeyRequest(opDelete, key)
+	}
+
+	for i, key := range keys {
+		if responses[i] != nil { // error occurred while sending
+			continue
+		}
+		responses[i] = c.receiveMutateResponse(requests[i], key)
+	}
+	return responses
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Touch(
+	key string,
+	expiration uint32) MutateResponse {
+
+	req, resp := c.sendKeyRequest(opTouch, key, expiration)
+	if resp != nil {
+		return resp
+	}
+	return c.receiveMutateResponse(req, key)
+}
+
+func (c *MultiplexedBinaryClient) count(
+	code opCode,
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	if !isValidKeyString(key) {
+		return NewCountErrorResponse(key, errors.New("Invalid key"))
+	}
+
+	pkt, err := c.roundTrip(
+		code,
+		0,
+		[]byte(key),
+		nil,
+		delta,
+		initValue,
+		expiration)
+	if err != nil {
+		return NewCountErrorResponse(key, err)
+	}
+
+	if pkt.status() != StatusNoError {
+		return NewCountResponse(key, pkt.status(), 0)
+	}
+	if len(pkt.value) != 8 {
+		return NewCountErrorResponse(
+			key,
+			errors.Newf("Invalid count value length: %d", len(pkt.value)))
+	}
+	return NewCountResponse(
+		key,
+		StatusNoError,
+		binary.BigEndian.Uint64(pkt.value))
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Increment(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.count(opIncrement, key, delta, initValue, expiration)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Decrement(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.count(opDecrement, key, delta, initValue, expiration)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Stat(statsKey string) StatResponse {
+	shardEntries := make(map[int](map[string]string))
+	entries := make(map[string]string)
+	shardEntries[c.ShardId()] = entries
+
+	if !isValidKeyString(statsKey) {
+		return NewStatErrorResponse(
+			errors.Newf("Invalid key: %s", statsKey),
+			shardEntries)
+	}
+
+	req, err := c.send(opStat, true, 0, []byte(statsKey), nil)
+	if err != nil {
+		return NewStatErrorResponse(err, shardEntries)
+	}
+
+	packets, err := c.wait(req)
+	if err != nil {
+		return NewStatErrorResponse(err, shardEntries)
+	}
+
+	for _, pkt := range packets {
+		if pkt.status() != StatusNoError {
+			return NewStatResponse(pkt.status(), shardEntries)
+		}
+		if len(pkt.key) == 0 && len(pkt.value) == 0 { // the last entry
+			break
+		}
+		entries[string(pkt.key)] = string(pkt.value)
+	}
+	return NewStatResponse(StatusNoError, shardEntries)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Version() VersionResponse {
+	versions := make(map[int]string)
+
+	pkt, err := c.roundTrip(opVersion, 0, nil, nil)
+	if err != nil {
+		return NewVersionErrorResponse(err, versions)
+	}
+
+	versions[c.ShardId()] = string(pkt.value)
+	return NewVersionResponse(pkt.status(), versions)
+}
+
+func (c *MultiplexedBinaryClient) genericOp(
+	code opCode,
+	extras ...interface{}) Response {
+
+	pkt, err := c.roundTrip(code, 0, nil, nil, extras...)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+	return NewResponse(pkt.status())
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Flush(expiration uint32) Response {
+	return c.genericOp(opFlush, expiration)
+}
+
+// See Client interface for documentation.
+func (c *MultiplexedBinaryClient) Verbosity(verbosity uint32) Response {
+	return c.genericOp(opVerbosity, verbosity)
+}
@@ -0,0 +1,279 @@
+package memcache_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/memcache/memcachetest"
+)
+
+type MultiplexedBinaryClientSuite struct {
+	server *memcachetest.Server
+	client *memcache.MultiplexedBinaryClient
+}
+
+var _ = Suite(&MultiplexedBinaryClientSuite{})
+
+func (s *MultiplexedBinaryClientSuite) SetUpTest(c *C) {
+	server, err := memcachetest.NewServer(memcachetest.Options{})
+	c.Assert(err, IsNil)
+	s.server = server
+
+	conn, err := net.Dial("tcp", server.Addr())
+	c.Assert(err, IsNil)
+	s.client = memcache.NewMultiplexedBinaryClient(0, conn)
+}
+
+func (s *MultiplexedBinaryClientSuite) TearDownTest(c *C) {
+	_ = s.client.Close()
+	_ = s.server.Close()
+}
+
+func (s *MultiplexedBinaryClientSuite) TestBasicOps(c *C) {
+	resp := s.client.Set(
+		&memcache.Item{Key: "key", Value: []byte("value"), Flags: 123})
+	c.Assert(resp.Error(), IsNil)
+
+	gresp := s.client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(gresp.Status(), Equals, memcache.StatusNoError)
+	c.Assert(string(gresp.Value()), Equals, "value")
+	c.Assert(gresp.Flags(), Equals, uint32(123))
+
+	resp = s.client.Add(&memcache.Item{Key: "key", Value: []byte("v")})
+	c.Assert(resp.Status(), Equals, memcache.StatusKeyExists)
+
+	resp = s.client.Append("key", []byte("-suffix"))
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(string(s.client.Get("key").Value()), Equals, "value-suffix")
+
+	resp = s.client.Delete("key")
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.client.Get("key").Status(), Equals, memcache.StatusKeyNotFound)
+
+	cresp := s.client.Increment("counter", 2, 10, 0)
+	c.Assert(cresp.Error(), IsNil)
+	c.Assert(cresp.Count(), Equals, uint64(10))
+	cresp = s.client.Increment("counter", 2, 10, 0)
+	c.Assert(cresp.Count(), Equals, uint64(12))
+
+	sresp := s.client.Stat("")
+	c.Assert(sresp.Error(), IsNil)
+	c.Assert(sresp.Entries()[0]["version"], Equals, memcachetest.Version)
+
+	vresp := s.client.Version()
+	c.Assert(vresp.Error(), IsNil)
+	c.Assert(vresp.Versions()[0], Equals, memcachetest.Version)
+
+	c.Assert(s.client.Flush(0).Error(), IsNil)
+	c.Assert(s.server.NumItems(), Equals, 0)
+}
+
+func (s *MultiplexedBinaryClientSuite) TestMultiOps(c *C) {
+	items := []*memcache.Item{}
+	keys := []string{}
+	for i := 0; i < 10; i++ {
+		key := "key" + strconv.Itoa(i)
+		keys = append(keys, key)
+		items = append(items, &memcache.Item{Key: key, Value: []byte(key)})
+	}
+	items = append(items, nil)
+
+	responses := s.client.SetMulti(items)
+	c.Assert(responses, HasLen, len(items))
+	for i, key := range keys {
+		c.Assert(responses[i].Error(), IsNil)
+		c.Assert(responses[i].Key(), Equals, key)
+	}
+	c.Assert(responses[len(keys)].Error(), NotNil)
+
+	gresps := s.client.GetMulti(append(keys, "missing", "key0"))
+	c.Assert(gresps, HasLen, len(keys)+1)
+	for _, key := range keys {
+		c.Assert(string(gresps[key].Value()), Equals, key)
+	}
+	c.Assert(gresps["missing"].Status(), Equals, memcache.StatusKeyNotFound)
+}
+
+func (s *MultiplexedBinaryClientSuite) TestConcurrentRequests(c *C) {
+	wg := sync.WaitGroup{}
+	errs := make(chan string, 100)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			key := "key" + strconv.Itoa(i)
+			for j := 0; j < 20; j++ {
+				value := key + "-" + strconv.Itoa(j)
+				resp := s.client.Set(
+					&memcache.Item{Key: key, Value: []byte(value)})
+				if resp.Error() != nil {
+					errs <- resp.Error().Error()
+					return
+				}
+
+				gresp := s.client.Get(key)
+				if string(gresp.Value()) != value {
+					errs <- "unexpected value: " + string(gresp.Value())
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		c.Error(err)
+	}
+
+	// All requests shared a single connection.
+	c.Assert(s.server.Stat("total_connections"), Equals, uint64(1))
+	c.Assert(s.client.IsValidState(), IsTrue)
+}
+
+func (s *MultiplexedBinaryClientSuite) TestDisconnect(c *C) {
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: []byte("v")})
+	c.Assert(resp.Error(), IsNil)
+
+	s.server.InjectFault(memcachetest.Fault{Command: "get", Disconnect: true})
+
+	gresp := s.client.Get("key")
+	c.Assert(gresp.Error(), NotNil)
+	c.Assert(s.client.IsValidState(), IsFalse)
+
+	// Subsequent requests fail immediately.
+	resp = s.client.Set(&memcache.Item{Key: "key", Value: []byte("v")})
+	c.Assert(resp.Error(), NotNil)
+}
+
+func (s *MultiplexedBinaryClientSuite) TestCloseFailsInFlightRequests(c *C) {
+	s.server.InjectFault(
+		memcachetest.Fault{Command: "get", Delay: 10 * time.Second})
+
+	result := make(chan memcache.GetResponse, 1)
+	go func() {
+		result <- s.client.Get("key")
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	c.Assert(s.client.Close(), IsNil)
+
+	select {
+	case gresp := <-result:
+		c.Assert(gresp.Error(), NotNil)
+	case <-time.After(time.Second):
+		c.Fatal("in-flight request was not failed")
+	}
+}
+
+func (s *MultiplexedBinaryClientSuite) TestRequestTimeout(c *C) {
+	conn, err := net.Dial("tcp", s.server.Addr())
+	c.Assert(err, IsNil)
+	client := memcache.NewMultiplexedBinaryClientWithOptions(
+		0,
+		conn,
+		memcache.MultiplexedBinaryClientOptions{
+			RequestTimeout: 20 * time.Millisecond,
+		})
+	defer client.Close()
+
+	resp := client.Set(&memcache.Item{Key: "key", Value: []byte("v")})
+	c.Assert(resp.Error(), IsNil)
+
+	s.server.InjectFault(
+		memcachetest.Fault{Command: "get", Delay: 10 * time.Second})
+
+	start := time.Now()
+	c.Assert(client.Get("key").Error(), NotNil)
+	c.Assert(time.Since(start) < time.Second, IsTrue)
+
+	// The timed out request fails the channel.
+	c.Assert(client.IsValidState(), IsFalse)
+}
+
+// A fake server which reads a batch of get requests, and responds to the
+// batch in reverse order.  Each response's value is "value-<key>".
+func serveReversedGets(c *C, listener net.Listener, batchSize int) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	responses := [][]byte{}
+	for len(responses) < batchSize {
+		hdr := make([]byte, 24)
+		if _, err := io.ReadFull(conn, hdr); err != nil {
+			c.Error(err)
+			return
+		}
+
+		body := make([]byte, binary.BigEndian.Uint32(hdr[8:12]))
+		if _, err := io.ReadFull(conn, body); err != nil {
+			c.Error(err)
+			return
+		}
+		extrasLength := int(hdr[4])
+		keyLength := int(binary.BigEndian.Uint16(hdr[2:4]))
+		key := body[extrasLength : extrasLength+keyLength]
+
+		value := append([]byte("value-"), key...)
+		resp := make([]byte, 24+4+len(value))
+		resp[0] = 0x81 // response magic
+		resp[1] = hdr[1]
+		resp[4] = 4 // flags extras
+		binary.BigEndian.PutUint32(resp[8:12], uint32(4+len(value)))
+		copy(resp[12:16], hdr[12:16]) // opaque
+		copy(resp[28:], value)
+		responses = append(responses, resp)
+	}
+
+	for i := len(responses) - 1; i >= 0; i-- {
+		if _, err := conn.Write(responses[i]); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	// Wait for the client to close the connection.
+	_, _ = io.Copy(io.Discard, conn)
+}
+
+func (s *MultiplexedBinaryClientSuite) TestReorderedResponses(c *C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer listener.Close()
+
+	keys := []string{"a", "b", "c", "d", "e"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serveReversedGets(c, listener, len(keys))
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	c.Assert(err, IsNil)
+	client := memcache.NewMultiplexedBinaryClient(0, conn)
+
+	gresps := client.GetMulti(keys)
+	c.Assert(gresps, HasLen, len(keys))
+	for _, key := range keys {
+		c.Assert(gresps[key].Error(), IsNil)
+		c.Assert(gresps[key].Key(), Equals, key)
+		c.Assert(string(gresps[key].Value()), Equals, "value-"+key)
+	}
+	c.Assert(client.IsValidState(), IsTrue)
+
+	c.Assert(client.Close(), IsNil)
+	<-done
+}
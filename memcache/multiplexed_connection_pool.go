@@ -0,0 +1,342 @@
+package memcache
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/dropbox/godropbox/net2"
+)
+
+// A connection pool which multiplexes all requests to an address over a
+// single shared connection (see MultiplexedBinaryClient), i.e., the number
+// of connections to each memcache server does not grow with the number of
+// concurrent requests.  The pool hands out lightweight connection handles
+// which do not support direct io; the handles must be used with
+// NewMultiplexedClientShard as the ShardedClient's ClientShardBuilder.  For
+// example:
+//
+//	manager := &BaseShardManager{}
+//	manager.InitWithPool(
+//		shardFunc,
+//		logError,
+//		logInfo,
+//		NewMultiplexedConnectionPool(options))
+//	manager.UpdateShardStates(shardStates)
+//	client := NewShardedClient(manager, NewMultiplexedClientShard)
+//
+// The shared connections are dialed by a net2 connection pool created with
+// the given options (i.e., Dial, TLSConfig, OnConnect, etc. are honored).
+// A shared connection is replaced on the next Get once it fails.  Since
+// requests are pipelined over the shared connections, ReadTimeout and
+// WriteTimeout are not applied as io deadlines; instead, a request which does
+// not complete within ReadTimeout + WriteTimeout (when non-zero) fails its
+// shared connection (see MultiplexedBinaryClientOptions).  NOTE:
+// ShardedClient does not count the bytes sent / received over the shared
+// connections.
+type MultiplexedConnectionPool struct {
+	pool           net2.ConnectionPool // used for dialing the shared connections
+	requestTimeout time.Duration
+
+	mutex    sync.Mutex
+	shared   map[net2.NetworkAddress]*multiplexedConn // guarded by mutex
+	lameDuck bool                                     // guarded by mutex
+
+	numActive           int32 // atomic
+	activeHighWaterMark int32 // atomic
+}
+
+var _ net2.ConnectionPool = (*MultiplexedConnectionPool)(nil)
+
+// An address's shared connection.
+type multiplexedConn struct {
+	mutex  sync.Mutex
+	conn   net2.ManagedConn         // nil until dialed; guarded by mutex
+	client *MultiplexedBinaryClient // guarded by mutex
+}
+
+// This must be called with mutex locked.
+func (c *multiplexedConn) closeLocked() {
+	if c.conn == nil {
+		return
+	}
+
+	_ = c.client.Close()
+	_ = c.conn.DiscardConnection()
+	c.conn = nil
+	c.client = nil
+}
+
+// This creates a MultiplexedConnectionPool.  See MultiplexedConnectionPool
+// for documentation.
+func NewMultiplexedConnectionPool(
+	options net2.ConnectionOptions) *MultiplexedConnectionPool {
+
+	return &MultiplexedConnectionPool{
+		pool:           net2.NewMultiConnectionPool(options),
+		requestTimeout: options.ReadTimeout + options.WriteTimeout,
+		shared:         make(map[net2.NetworkAddress]*multiplexedConn),
+	}
+}
+
+// See ConnectionPool for documentation.
+func (p *MultiplexedConnectionPool) NumActive() int32 {
+	return atomic.LoadInt32(&p.numActive)
+}
+
+// See ConnectionPool for documentation.
+func (p *MultiplexedConnectionPool) ActiveHighWaterMark() int32 {
+	return atomic.LoadInt32(&p.activeHighWaterMark)
+}
+
+// Connection handles are never idle.
+func (p *MultiplexedConnectionPool) NumIdle() int {
+	return 0
+}
+
+// See ConnectionPool for documentation.
+func (p *MultiplexedConnectionPool) Register(
+	network string,
+	address string) error {
+
+	return p.pool.Register(network, address)
+}
+
+// This also closes the address's shared connection, which fails the
+// connection's in-flight requests.
+func (p *MultiplexedConnectionPool) Unregister(
+	network string,
+	address string) error {
+
+	key := net2.NetworkAddress{Network: network, Address: address}
+
+	p.mutex.Lock()
+	shared := p.shared[key]
+	delete(p.shared, key)
+	p.mutex.Unlock()
+
+	if shared != nil {
+		shared.mutex.Lock()
+		shared.closeLocked()
+		shared.mutex.Unlock()
+	}
+
+	return p.pool.Unregister(network, address)
+}
+
+// See ConnectionPool for documentation.
+func (p *MultiplexedConnectionPool) ListRegistered() []net2.NetworkAddress {
+	return p.pool.ListRegistered()
+}
+
+// This returns a handle to the address's shared connection, and dials the
+// shared connection if needed.
+func (p *MultiplexedConnectionPool) Get(
+	network string,
+	address string) (net2.ManagedConn, error) {
+
+	key := net2.NetworkAddress{Network: network, Address: address}
+
+	p.mutex.Lock()
+	if p.lameDuck {
+		p.mutex.Unlock()
+		return nil, errors.Newf(
+			"Multiplexed connection pool is in lame duck mode: %s %s",
+			network,
+			address)
+	}
+	shared, ok := p.shared[key]
+	if !ok {
+		shared = &multiplexedConn{}
+		p.shared[key] = shared
+	}
+	p.mutex.Unlock()
+
+	// NOTE: Dialing only blocks requests to the same address.
+	shared.mutex.Lock()
+	defer shared.mutex.Unlock()
+
+	if shared.client == nil || !shared.client.IsValidState() {
+		shared.closeLocked()
+
+		conn, err := p.pool.Get(network, address)
+		if err != nil {
+			return nil, err
+		}
+		shared.conn = conn
+		shared.client = NewMultiplexedBinaryClientWithOptions(
+			0,
+			conn.RawConn(),
+			MultiplexedBinaryClientOptions{RequestTimeout: p.requestTimeout})
+	}
+
+	active := atomic.AddInt32(&p.numActive, 1)
+	for {
+		highWaterMark := atomic.LoadInt32(&p.activeHighWaterMark)
+		if active <= highWaterMark ||
+			atomic.CompareAndSwapInt32(
+				&p.activeHighWaterMark,
+				highWaterMark,
+				active) {
+
+			break
+		}
+	}
+
+	return &multiplexedConnHandle{
+		pool:    p,
+		key:     key,
+		shared:  shared,
+		client:  shared.client,
+		rawConn: shared.conn.RawConn(),
+	}, nil
+}
+
+func (p *MultiplexedConnectionPool) toHandle(
+	conn net2.ManagedConn) (*multiplexedConnHandle, error) {
+
+	handle, ok := conn.(*multiplexedConnHandle)
+	if !ok || handle.pool != p {
+		return nil, errors.New("Connection is not owned by the pool")
+	}
+	return handle, nil
+}
+
+// See ConnectionPool for documentation.
+func (p *MultiplexedConnectionPool) Release(conn net2.ManagedConn) error {
+	handle, err := p.toHandle(conn)
+	if err != nil {
+		return err
+	}
+	return handle.ReleaseConnection()
+}
+
+// See ConnectionPool for documentation.
+func (p *MultiplexedConnectionPool) Discard(conn net2.ManagedConn) error {
+	handle, err := p.toHandle(conn)
+	if err != nil {
+		return err
+	}
+	return handle.DiscardConnection()
+}
+
+// This closes all shared connections, which fails their in-flight requests.
+func (p *MultiplexedConnectionPool) EnterLameDuckMode() {
+	p.mutex.Lock()
+	p.lameDuck = true
+	shared := p.shared
+	p.shared = make(map[net2.NetworkAddress]*multiplexedConn)
+	p.mutex.Unlock()
+
+	for _, conn := range shared {
+		conn.mutex.Lock()
+		conn.closeLocked()
+		conn.mutex.Unlock()
+	}
+
+	p.pool.EnterLameDuckMode()
+}
+
+// A handle to a shared connection.  The handle does not support direct io.
+type multiplexedConnHandle struct {
+	pool    *MultiplexedConnectionPool
+	key     net2.NetworkAddress
+	shared  *multiplexedConn
+	client  *MultiplexedBinaryClient
+	rawConn net.Conn
+
+	released int32 // atomic
+}
+
+var _ net2.ManagedConn = (*multiplexedConnHandle)(nil)
+
+func (h *multiplexedConnHandle) Read(b []byte) (int, error) {
+	return 0, errors.New(
+		"Multiplexed connection handles do not support direct io")
+}
+
+func (h *multiplexedConnHandle) Write(b []byte) (int, error) {
+	return 0, errors.New(
+		"Multiplexed connection handles do not support direct io")
+}
+
+func (h *multiplexedConnHandle) Close() error {
+	return h.DiscardConnection()
+}
+
+func (h *multiplexedConnHandle) LocalAddr() net.Addr {
+	return h.rawConn.LocalAddr()
+}
+
+func (h *multiplexedConnHandle) RemoteAddr() net.Addr {
+	return h.rawConn.RemoteAddr()
+}
+
+func (h *multiplexedConnHandle) SetDeadline(t time.Time) error {
+	return errors.New("Cannot set deadline for multiplexed connection")
+}
+
+func (h *multiplexedConnHandle) SetReadDeadline(t time.Time) error {
+	return errors.New("Cannot set read deadline for multiplexed connection")
+}
+
+func (h *multiplexedConnHandle) SetWriteDeadline(t time.Time) error {
+	return errors.New("Cannot set write deadline for multiplexed connection")
+}
+
+func (h *multiplexedConnHandle) Key() net2.NetworkAddress {
+	return h.key
+}
+
+func (h *multiplexedConnHandle) RawConn() net.Conn {
+	return h.rawConn
+}
+
+func (h *multiplexedConnHandle) Owner() net2.ConnectionPool {
+	return h.pool
+}
+
+func (h *multiplexedConnHandle) ReleaseConnection() error {
+	if !atomic.CompareAndSwapInt32(&h.released, 0, 1) {
+		return errors.New("Connection handle is already released")
+	}
+	atomic.AddInt32(&h.pool.numActive, -1)
+	return nil
+}
+
+// The shared connection is only closed when it has failed, since a valid
+// shared connection may have other in-flight requests.
+func (h *multiplexedConnHandle) DiscardConnection() error {
+	if err := h.ReleaseConnection(); err != nil {
+		return err
+	}
+
+	if !h.client.IsValidState() {
+		h.shared.mutex.Lock()
+		if h.shared.client == h.client {
+			h.shared.closeLocked()
+		}
+		h.shared.mutex.Unlock()
+	}
+	return nil
+}
+
+// A ClientShardBuilder which returns the shared MultiplexedBinaryClient of
+// a MultiplexedConnectionPool connection handle.  This panics if the channel
+// is not a MultiplexedConnectionPool connection handle.
+func NewMultiplexedClientShard(shard int, channel io.ReadWriter) ClientShard {
+	// Unwrap ShardedClient's byte counting wrapper.
+	if counting, ok := channel.(*countingReadWriter); ok {
+		channel = counting.ReadWriter
+	}
+
+	handle, ok := channel.(*multiplexedConnHandle)
+	if !ok {
+		panic("NewMultiplexedClientShard requires connections from " +
+			"MultiplexedConnectionPool")
+	}
+	return handle.client.withShard(shard)
+}
@@ -0,0 +1,173 @@
+package memcache_test
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/memcache/memcachetest"
+	"github.com/dropbox/godropbox/net2"
+)
+
+type MultiplexedConnectionPoolSuite struct {
+	server *memcachetest.Server
+}
+
+var _ = Suite(&MultiplexedConnectionPoolSuite{})
+
+func (s *MultiplexedConnectionPoolSuite) SetUpTest(c *C) {
+	server, err := memcachetest.NewServer(memcachetest.Options{})
+	c.Assert(err, IsNil)
+	s.server = server
+}
+
+func (s *MultiplexedConnectionPoolSuite) TearDownTest(c *C) {
+	_ = s.server.Close()
+}
+
+func (s *MultiplexedConnectionPoolSuite) newShardManager(
+	pool net2.ConnectionPool) memcache.ShardManager {
+
+	manager := &memcache.BaseShardManager{}
+	manager.InitWithPool(
+		func(key string, numShard int) int { return 0 },
+		func(err error) {},
+		func(v ...interface{}) {},
+		pool)
+	manager.UpdateShardStates(
+		[]memcache.ShardState{
+			{Address: s.server.Addr(), State: memcache.ActiveServer},
+		})
+	return manager
+}
+
+// Issues concurrent (slow) gets, and returns the number of failed gets.
+func (s *MultiplexedConnectionPoolSuite) concurrentGets(
+	c *C,
+	client memcache.Client) int {
+
+	for i := 0; i < 2; i++ {
+		key := "key" + strconv.Itoa(i)
+		resp := client.Set(&memcache.Item{Key: key, Value: []byte(key)})
+		c.Assert(resp.Error(), IsNil)
+	}
+
+	s.server.InjectFault(
+		memcachetest.Fault{Command: "get", Delay: 20 * time.Millisecond})
+	defer s.server.ClearFaults()
+
+	failures := make(chan int, 10)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			key := "key" + strconv.Itoa(i%2)
+			if string(client.Get(key).Value()) != key {
+				failures <- i
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(failures)
+
+	return len(failures)
+}
+
+func (s *MultiplexedConnectionPoolSuite) TestShardedClient(c *C) {
+	pool := memcache.NewMultiplexedConnectionPool(net2.ConnectionOptions{})
+	client := memcache.NewShardedClient(
+		s.newShardManager(pool),
+		memcache.NewMultiplexedClientShard)
+
+	c.Assert(s.concurrentGets(c, client), Equals, 0)
+
+	// The gets were in-flight concurrently, but shared a single connection.
+	c.Assert(pool.ActiveHighWaterMark() > 1, IsTrue)
+	c.Assert(pool.NumActive(), Equals, int32(0))
+	c.Assert(s.server.Stat("total_connections"), Equals, uint64(1))
+
+	vresp := client.Version()
+	c.Assert(vresp.Error(), IsNil)
+	c.Assert(vresp.Versions()[0], Equals, memcachetest.Version)
+}
+
+func (s *MultiplexedConnectionPoolSuite) TestConnectionCountComparison(
+	c *C) {
+
+	// Without multiplexing, each concurrent get uses its own connection.
+	client := memcache.NewShardedClient(
+		s.newShardManager(
+			net2.NewMultiConnectionPool(
+				net2.ConnectionOptions{MaxIdleConnections: 10})),
+		memcache.NewRawBinaryClient)
+
+	c.Assert(s.concurrentGets(c, client), Equals, 0)
+	c.Assert(s.server.Stat("total_connections") > 1, IsTrue)
+}
+
+func (s *MultiplexedConnectionPoolSuite) TestReconnect(c *C) {
+	pool := memcache.NewMultiplexedConnectionPool(net2.ConnectionOptions{})
+	client := memcache.NewShardedClient(
+		s.newShardManager(pool),
+		memcache.NewMultiplexedClientShard)
+
+	resp := client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+
+	s.server.InjectFault(
+		memcachetest.Fault{Command: "get", Disconnect: true, Count: 1})
+	c.Assert(client.Get("key").Error(), NotNil)
+
+	// The failed shared connection is replaced.
+	gresp := client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(string(gresp.Value()), Equals, "value")
+	c.Assert(s.server.Stat("total_connections"), Equals, uint64(2))
+	c.Assert(pool.NumActive(), Equals, int32(0))
+
+	pool.EnterLameDuckMode()
+	c.Assert(client.Get("key").Error(), NotNil)
+}
+
+func (s *MultiplexedConnectionPoolSuite) TestReadTimeout(c *C) {
+	pool := memcache.NewMultiplexedConnectionPool(
+		net2.ConnectionOptions{ReadTimeout: 20 * time.Millisecond})
+	client := memcache.NewShardedClient(
+		s.newShardManager(pool),
+		memcache.NewMultiplexedClientShard)
+
+	resp := client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+
+	s.server.InjectFault(
+		memcachetest.Fault{Command: "get", Delay: 10 * time.Second, Count: 1})
+
+	start := time.Now()
+	c.Assert(client.Get("key").Error(), NotNil)
+	c.Assert(time.Since(start) < time.Second, IsTrue)
+
+	// The timed out shared connection is replaced.
+	gresp := client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(string(gresp.Value()), Equals, "value")
+	c.Assert(s.server.Stat("total_connections"), Equals, uint64(2))
+}
+
+func (s *MultiplexedConnectionPoolSuite) TestDirectIo(c *C) {
+	pool := memcache.NewMultiplexedConnectionPool(net2.ConnectionOptions{})
+	c.Assert(pool.Register("tcp", s.server.Addr()), IsNil)
+
+	conn, err := pool.Get("tcp", s.server.Addr())
+	c.Assert(err, IsNil)
+
+	_, err = conn.Write([]byte("version\r\n"))
+	c.Assert(err, NotNil)
+	c.Assert(conn.ReleaseConnection(), IsNil)
+	c.Assert(conn.ReleaseConnection(), NotNil)
+}
@@ -2,6 +2,7 @@ package memcache
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"strconv"
 	"strings"
@@ -17,10 +18,12 @@ import (
 // operations are serialized (Use multiple channels / clients if parallelism
 // is needed).
 type RawAsciiClient struct {
+	contextOps
+
 	shard   int
 	channel io.ReadWriter
 
-	mutex      sync.Mutex
+	mutex      sync.Locker
 	validState bool
 	writer     *bufio.Writer
 	reader     *bufio.Reader
@@ -28,13 +31,37 @@ type RawAsciiClient struct {
 
 // This creates a new memcache RawAsciiClient.
 func NewRawAsciiClient(shard int, channel io.ReadWriter) ClientShard {
-	return &RawAsciiClient{
+	c := &RawAsciiClient{
 		shard:      shard,
 		channel:    channel,
+		mutex:      &sync.Mutex{},
 		validState: true,
 		writer:     bufio.NewWriter(channel),
 		reader:     bufio.NewReader(channel),
 	}
+	c.contextOps = contextOps{client: c, run: c.runWithContext}
+	return c
+}
+
+var _ ContextClient = (*RawAsciiClient)(nil)
+
+// See RawBinaryClient.runWithContext.
+func (c *RawAsciiClient) runWithContext(
+	ctx context.Context,
+	op func(client Client)) error {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	locked := *c
+	locked.mutex = noOpLocker{}
+	defer func() { c.validState = locked.validState }()
+
+	return runWithContext(
+		ctx,
+		c.channel,
+		func() { op(&locked) },
+		func() { locked.validState = false })
 }
 
 func (c *RawAsciiClient) writeStrings(strs ...string) error {
@@ -122,6 +149,35 @@ func (c *RawAsciiClient) Get(key string) GetResponse {
 }
 
 func (c *RawAsciiClient) GetMulti(keys []string) map[string]GetResponse {
+	// NOTE: Always use gets instead of get since returning the extra cas id
+	// info is relatively cheap.
+	return c.getMulti("gets", keys)
+}
+
+func (c *RawAsciiClient) GetAndTouch(
+	key string,
+	expiration uint32) GetResponse {
+
+	return c.GetAndTouchMulti([]string{key}, expiration)[key]
+}
+
+func (c *RawAsciiClient) GetAndTouchMulti(
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	// NOTE: Always use gats instead of gat since returning the extra cas id
+	// info is relatively cheap.
+	return c.getMulti(
+		"gats "+strconv.FormatUint(uint64(expiration), 10),
+		keys)
+}
+
+// cmd is the retrieval command, including any arguments which precede the
+// keys.
+func (c *RawAsciiClient) getMulti(
+	cmd string,
+	keys []string) map[string]GetResponse {
+
 	responses := make(map[string]GetResponse, len(keys))
 	neededKeys := []string{}
 	for _, key := range keys {
@@ -153,9 +209,7 @@ func (c *RawAsciiClient) GetMulti(keys []string) map[string]GetResponse {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	// NOTE: Always use gets instead of get since returning the extra cas id
-	// info is relatively cheap.
-	err := c.writeStrings("gets")
+	err := c.writeStrings(cmd)
 	if err != nil {
 		populateErrorResponses(err)
 		return responses
@@ -536,6 +590,42 @@ func (c *RawAsciiClient) DeleteMulti(keys []string) []MutateResponse {
 	return responses
 }
 
+func (c *RawAsciiClient) Touch(key string, expiration uint32) MutateResponse {
+	if !isValidKeyString(key) {
+		return NewMutateErrorResponse(key, errors.New("Invalid key"))
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.writeStrings(
+		"touch ",
+		key, " ",
+		strconv.FormatUint(uint64(expiration), 10), "\r\n")
+	if err != nil {
+		return NewMutateErrorResponse(key, err)
+	}
+
+	err = c.flushWriter()
+	if err != nil {
+		return NewMutateErrorResponse(key, err)
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return NewMutateErrorResponse(key, err)
+	}
+
+	_ = c.checkEmptyBuffers()
+
+	if line == "TOUCHED" {
+		return NewMutateResponse(key, StatusNoError, 0)
+	} else if line == "NOT_FOUND" {
+		return NewMutateResponse(key, StatusKeyNotFound, 0)
+	}
+	return NewMutateErrorResponse(key, errors.New(line))
+}
+
 func (c *RawAsciiClient) countRequest(
 	cmd string,
 	key string,
@@ -625,3 +625,40 @@ func (s *RawAsciiClientSuite) TestFlushCheckEmptyBuffers(c *C) {
 
 	c.Assert(resp.Error(), IsNil)
 }
+
+func (s *RawAsciiClientSuite) TestGetAndTouch(c *C) {
+	s.rw.recvBuf.WriteString("VALUE key 333 4 12345\r\nitem\r\n")
+	s.rw.recvBuf.WriteString("END\r\n")
+
+	responses := s.client.GetAndTouchMulti([]string{"key", "key2"}, 100)
+
+	c.Assert(s.rw.sendBuf.String(), Equals, "gats 100 key key2\r\n")
+	c.Assert(s.client.IsValidState(), IsTrue)
+
+	c.Assert(responses["key"].Error(), IsNil)
+	c.Assert(responses["key"].Value(), DeepEquals, []byte("item"))
+	c.Assert(responses["key"].DataVersionId(), Equals, uint64(12345))
+	c.Assert(responses["key2"].Status(), Equals, StatusKeyNotFound)
+}
+
+func (s *RawAsciiClientSuite) TestTouch(c *C) {
+	s.rw.recvBuf.WriteString("TOUCHED\r\n")
+
+	resp := s.client.Touch("key", 100)
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(resp.Status(), Equals, StatusNoError)
+
+	s.rw.recvBuf.WriteString("NOT_FOUND\r\n")
+
+	resp = s.client.Touch("key2", 0)
+	c.Assert(resp.Status(), Equals, StatusKeyNotFound)
+
+	c.Assert(
+		s.rw.sendBuf.String(),
+		Equals,
+		"touch key 100\r\ntouch key2 0\r\n")
+	c.Assert(s.client.IsValidState(), IsTrue)
+
+	resp = s.client.Touch("b a d", 0)
+	c.Assert(resp.Error(), NotNil)
+}
@@ -2,6 +2,7 @@ package memcache
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"io"
 	"sync"
@@ -92,33 +93,43 @@ func (h *header) Deserialize(buffer []byte) {
 // operations are serialized (Use multiple channels / clients if parallelism
 // is needed).
 type RawBinaryClient struct {
+	contextOps
+
 	shard          int
 	channel        io.ReadWriter
-	mutex          sync.Mutex
+	mutex          sync.Locker
 	validState     bool
 	maxValueLength int
 }
 
 // This creates a new memcache RawBinaryClient.
 func NewRawBinaryClient(shard int, channel io.ReadWriter) ClientShard {
-	return &RawBinaryClient{
+	c := &RawBinaryClient{
 		shard:          shard,
 		channel:        channel,
+		mutex:          &sync.Mutex{},
 		validState:     true,
 		maxValueLength: defaultMaxValueLength,
 	}
+	c.contextOps = contextOps{client: c, run: c.runWithContext}
+	return c
 }
 
 // This creates a new memcache RawBinaryClient for use with np-large cluster.
 func NewLargeRawBinaryClient(shard int, channel io.ReadWriter) ClientShard {
-	return &RawBinaryClient{
+	c := &RawBinaryClient{
 		shard:          shard,
 		channel:        channel,
+		mutex:          &sync.Mutex{},
 		validState:     true,
 		maxValueLength: largeMaxValueLength,
 	}
+	c.contextOps = contextOps{client: c, run: c.runWithContext}
+	return c
 }
 
+var _ ContextClient = (*RawBinaryClient)(nil)
+
 // See ClientShard interface for documentation.
 func (c *RawBinaryClient) ShardId() int {
 	return c.shard
@@ -129,6 +140,27 @@ func (c *RawBinaryClient) IsValidState() bool {
 	return c.validState
 }
 
+// The context's deadline is applied (and reset) while holding the mutex, so
+// that the context only affects this operation's request.  The operation runs
+// on a copy of the client which does not re-acquire the mutex.
+func (c *RawBinaryClient) runWithContext(
+	ctx context.Context,
+	op func(client Client)) error {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	locked := *c
+	locked.mutex = noOpLocker{}
+	defer func() { c.validState = locked.validState }()
+
+	return runWithContext(
+		ctx,
+		c.channel,
+		func() { op(&locked) },
+		func() { locked.validState = false })
+}
+
 // Sends a memcache request through the connection.  NOTE: extras must be
 // fix-sized values.
 func (c *RawBinaryClient) sendRequest(
@@ -256,7 +288,7 @@ func (c *RawBinaryClient) receiveResponse(
 		return
 	}
 
-	status = ResponseStatus(hdr.VBucketIdOrStatus)
+	status = normalizeSASLStatus(ResponseStatus(hdr.VBucketIdOrStatus))
 	dataVersionId = hdr.DataVersionId
 
 	if hdr.ExtrasLength == 0 {
@@ -307,14 +339,18 @@ func (c *RawBinaryClient) receiveResponse(
 	return
 }
 
-func (c *RawBinaryClient) sendGetRequest(key string) GetResponse {
+func (c *RawBinaryClient) sendGetRequest(
+	code opCode,
+	key string,
+	extras ...interface{}) GetResponse {
+
 	if !isValidKeyString(key) {
 		return NewGetErrorResponse(
 			key,
 			errors.New("Invalid key"))
 	}
 
-	err := c.sendRequest(opGet, 0, []byte(key), nil)
+	err := c.sendRequest(code, 0, []byte(key), nil, extras...)
 	if err != nil {
 		return NewGetErrorResponse(key, err)
 	}
@@ -322,9 +358,12 @@ func (c *RawBinaryClient) sendGetRequest(key string) GetResponse {
 	return nil
 }
 
-func (c *RawBinaryClient) receiveGetResponse(key string) GetResponse {
+func (c *RawBinaryClient) receiveGetResponse(
+	code opCode,
+	key string) GetResponse {
+
 	var flags uint32
-	status, version, _, value, err := c.receiveResponse(opGet, &flags)
+	status, version, _, value, err := c.receiveResponse(code, &flags)
 	if err != nil {
 		return NewGetErrorResponse(key, err)
 	}
@@ -336,11 +375,11 @@ func (c *RawBinaryClient) Get(key string) GetResponse {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if resp := c.sendGetRequest(key); resp != nil {
+	if resp := c.sendGetRequest(opGet, key); resp != nil {
 		return resp
 	}
 
-	return c.receiveGetResponse(key)
+	return c.receiveGetResponse(opGet, key)
 }
 
 func (c *RawBinaryClient) removeDuplicateKey(keys []string) []string {
@@ -359,6 +398,16 @@ func (c *RawBinaryClient) removeDuplicateKey(keys []string) []string {
 
 // See Client interface for documentation.
 func (c *RawBinaryClient) GetMulti(keys []string) map[string]GetResponse {
+	return c.getMulti(opGet, keys)
+}
+
+// Batch get using the given get op code.  extras are sent with every
+// request.
+func (c *RawBinaryClient) getMulti(
+	code opCode,
+	keys []string,
+	extras ...interface{}) map[string]GetResponse {
+
 	if keys == nil {
 		return nil
 	}
@@ -370,7 +419,7 @@ func (c *RawBinaryClient) GetMulti(keys []string) map[string]GetResponse {
 	defer c.mutex.Unlock()
 
 	for _, key := range cacheKeys {
-		if resp := c.sendGetRequest(key); resp != nil {
+		if resp := c.sendGetRequest(code, key, extras...); resp != nil {
 			responses[key] = resp
 		}
 	}
@@ -379,7 +428,7 @@ func (c *RawBinaryClient) GetMulti(keys []string) map[string]GetResponse {
 		if _, inMap := responses[key]; inMap { // error occurred while sending
 			continue
 		}
-		responses[key] = c.receiveGetResponse(key)
+		responses[key] = c.receiveGetResponse(code, key)
 	}
 
 	return responses
@@ -391,6 +440,46 @@ func (c *RawBinaryClient) GetSentinels(keys []string) map[string]GetResponse {
 	return c.GetMulti(keys)
 }
 
+// See Client interface for documentation.
+func (c *RawBinaryClient) GetAndTouch(
+	key string,
+	expiration uint32) GetResponse {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if resp := c.sendGetRequest(opGAT, key, expiration); resp != nil {
+		return resp
+	}
+
+	return c.receiveGetResponse(opGAT, key)
+}
+
+// See Client interface for documentation.
+func (c *RawBinaryClient) GetAndTouchMulti(
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	return c.getMulti(opGAT, keys, expiration)
+}
+
+// See Client interface for documentation.
+func (c *RawBinaryClient) Touch(key string, expiration uint32) MutateResponse {
+	if !isValidKeyString(key) {
+		return NewMutateErrorResponse(key, errors.New("Invalid key"))
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.sendRequest(opTouch, 0, []byte(key), nil, expiration)
+	if err != nil {
+		return NewMutateErrorResponse(key, err)
+	}
+
+	return c.receiveMutateResponse(opTouch, key)
+}
+
 func (c *RawBinaryClient) sendMutateRequest(
 	code opCode,
 	item *Item,
@@ -530,3 +530,73 @@ func (s *RawBinaryClientSuite) TestGetMultiDupKeys(c *C) {
 	c.Assert(results, HasKey, "foo")
 	c.Assert(string(results["foo"].Value()), Equals, "FOO")
 }
+
+func (s *RawBinaryClientSuite) TestGetAndTouch(c *C) {
+	var serializedResponseMessage = []byte{
+		respMagicByte, // magic
+		uint8(opGAT),  // op code
+		0x00, 0x00,    // key length
+		0x04,       // extras length
+		0x0,        // data type
+		0x00, 0x00, // status
+		0x00, 0x00, 0x00, 0x09, // total length
+		0x00, 0x00, 0x00, 0x00, // opaque
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, // cas
+		0xde, 0xad, 0xbe, 0xef, // flags
+		'W', 'o', 'r', 'l', 'd', // value
+	}
+
+	_, err := s.rw.recvBuf.Write(serializedResponseMessage)
+	c.Assert(err, IsNil)
+
+	resp := s.client.GetAndTouch(testKey, testExpiry)
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(resp.Key(), Equals, testKey)
+	c.Assert(resp.Value(), DeepEquals, testValue)
+	c.Assert(resp.Flags(), Equals, testFlags)
+	c.Assert(resp.DataVersionId(), Equals, uint64(1))
+
+	c.Assert(
+		s.rw.sendBuf.Bytes(),
+		DeepEquals,
+		[]byte{
+			reqMagicByte, // magic
+			uint8(opGAT), // op code
+			0x00, 0x05,   // key length
+			0x04,       // extra length
+			0x00,       // data type
+			0x00, 0x00, // v bucket id
+			0x00, 0x00, 0x00, 0x09, // total body length
+			0x00, 0x00, 0x00, 0x00, // opaque
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // cas
+			0x00, 0x00, 0x0e, 0x10, // expiry
+			'H', 'e', 'l', 'l', 'o', // key
+		})
+}
+
+func (s *RawBinaryClientSuite) TestTouch(c *C) {
+	var serializedResponseMessage = []byte{
+		respMagicByte,  // magic
+		uint8(opTouch), // op code
+		0x00, 0x00,     // key length
+		0x00,       // extras length
+		0x0,        // data type
+		0x00, 0x01, // status
+		0x00, 0x00, 0x00, 0x00, // total length
+		0x00, 0x00, 0x00, 0x00, // opaque
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // cas
+	}
+
+	_, err := s.rw.recvBuf.Write(serializedResponseMessage)
+	c.Assert(err, IsNil)
+
+	resp := s.client.Touch(testKey, testExpiry)
+	c.Assert(resp.Status(), Equals, StatusKeyNotFound)
+	c.Assert(s.client.IsValidState(), IsTrue)
+
+	// opcode, key length, extras length and expiry
+	sent := s.rw.sendBuf.Bytes()
+	c.Assert(sent[1], Equals, uint8(opTouch))
+	c.Assert(sent[2:5], DeepEquals, []byte{0x00, 0x05, 0x04})
+	c.Assert(sent[24:28], DeepEquals, []byte{0x00, 0x00, 0x0e, 0x10})
+}
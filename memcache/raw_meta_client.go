@@ -0,0 +1,912 @@
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+// The mode used by meta set requests.
+type MetaSetMode int
+
+const (
+	MetaSetModeSet MetaSetMode = iota
+	MetaSetModeAdd
+	MetaSetModeReplace
+	MetaSetModeAppend
+	MetaSetModePrepend
+)
+
+// The mode flag token sent with meta set requests.
+func (m MetaSetMode) token() string {
+	switch m {
+	case MetaSetModeAdd:
+		return "ME"
+	case MetaSetModeReplace:
+		return "MR"
+	case MetaSetModeAppend:
+		return "MA"
+	case MetaSetModePrepend:
+		return "MP"
+	}
+	return "MS"
+}
+
+// Options for meta get requests.
+type MetaGetOptions struct {
+	// When true, a miss creates an empty item with VivifyTTL expiration.
+	// The client which triggered the creation receives the win flag, and is
+	// expected to populate the item.
+	VivifyOnMiss bool
+	VivifyTTL    uint32
+
+	// When non-zero, the first client to fetch the item once its remaining
+	// ttl drops below RecacheTTL seconds receives the win flag.
+	RecacheTTL uint32
+
+	// When true, the item's expiration is updated to TouchTTL.
+	Touch    bool
+	TouchTTL uint32
+}
+
+// Options for meta set requests.  The item's flags, expiration and data
+// version id (aka CAS) are sent along with the value.
+type MetaSetOptions struct {
+	Mode MetaSetMode
+
+	// When true, a set with a data version id older than the item's marks
+	// the item as stale instead of failing.
+	Invalidate bool
+}
+
+// Options for meta delete requests.
+type MetaDeleteOptions struct {
+	// When non-zero, the delete only succeeds if the item's data version id
+	// matches.
+	DataVersionId uint64
+
+	// When true, the item is marked as stale instead of being removed.
+	// Subsequent meta gets return the stale value, and hand out a single win
+	// flag so that only one client recaches the item.
+	Invalidate bool
+}
+
+// Options for meta arithmetic requests.
+type MetaArithmeticOptions struct {
+	Decrement bool
+	Delta     uint64
+
+	// When true, a miss creates the counter with InitialValue and
+	// VivifyTTL expiration.
+	VivifyOnMiss bool
+	InitialValue uint64
+	VivifyTTL    uint32
+}
+
+// Response returned by MetaGet/MetaGetMulti requests.
+type MetaGetResponse interface {
+	GetResponse
+
+	// This returns true if the client is responsible for (re)populating the
+	// item (i.e., the server returned the win flag).
+	Won() bool
+
+	// This returns true if the item has been invalidated and the value is
+	// stale.
+	Stale() bool
+
+	// This returns true if a win flag was already handed to another client.
+	WinTokenSent() bool
+
+	// This returns the item's remaining ttl in seconds, or -1 if the item
+	// does not expire.  The value is only valid when the entry is found.
+	TTL() int64
+}
+
+// A memcache client which exposes memcached's meta protocol in addition to
+// the classic commands.
+type MetaClient interface {
+	ClientShard
+
+	// This retrieves a single entry using the meta get command.
+	MetaGet(key string, options MetaGetOptions) MetaGetResponse
+
+	// Batch version of the MetaGet method.
+	MetaGetMulti(
+		keys []string,
+		options MetaGetOptions) map[string]MetaGetResponse
+
+	// This stores a single entry using the meta set command.  Unlike the
+	// ascii set command, the response includes the new data version id.
+	MetaSet(item *Item, options MetaSetOptions) MutateResponse
+
+	// This deletes (or invalidates) a single entry using the meta delete
+	// command.
+	MetaDelete(key string, options MetaDeleteOptions) MutateResponse
+
+	// This increments / decrements a counter using the meta arithmetic
+	// command.
+	MetaArithmetic(key string, options MetaArithmeticOptions) CountResponse
+}
+
+type metaGetResponse struct {
+	GetResponse
+
+	won          bool
+	stale        bool
+	winTokenSent bool
+	ttl          int64
+}
+
+func (r *metaGetResponse) Won() bool {
+	return r.won
+}
+
+func (r *metaGetResponse) Stale() bool {
+	return r.stale
+}
+
+func (r *metaGetResponse) WinTokenSent() bool {
+	return r.winTokenSent
+}
+
+func (r *metaGetResponse) TTL() int64 {
+	return r.ttl
+}
+
+// This creates a MetaGetResponse from an error.
+func NewMetaGetErrorResponse(key string, err error) MetaGetResponse {
+	return &metaGetResponse{GetResponse: NewGetErrorResponse(key, err)}
+}
+
+// The parsed result of a single meta command.
+type metaResult struct {
+	code  string
+	value []byte
+
+	flags        uint32
+	cas          uint64
+	ttl          int64
+	opaque       string
+	won          bool
+	stale        bool
+	winTokenSent bool
+}
+
+func (r *metaResult) status() (ResponseStatus, error) {
+	switch r.code {
+	case "HD", "VA", "OK":
+		return StatusNoError, nil
+	case "EN", "NF":
+		return StatusKeyNotFound, nil
+	case "NS":
+		return StatusItemNotStored, nil
+	case "EX":
+		return StatusKeyExists, nil
+	}
+	return StatusNoError, errors.Newf("Unexpected meta response: %s", r.code)
+}
+
+func (r *metaResult) getResponse(key string) MetaGetResponse {
+	status, err := r.status()
+	if err != nil {
+		return NewMetaGetErrorResponse(key, err)
+	}
+
+	return &metaGetResponse{
+		GetResponse:  NewGetResponse(key, status, r.flags, r.value, r.cas),
+		won:          r.won,
+		stale:        r.stale,
+		winTokenSent: r.winTokenSent,
+		ttl:          r.ttl,
+	}
+}
+
+// An unsharded memcache client implementation which operates on a pre-existing
+// io channel (The user must explicitly setup and close down the channel),
+// using memcached's meta protocol (mg/ms/md/ma).  Flush, Stat, Version and
+// Verbosity requests use the classic ascii commands.  Note that the client
+// assumes nothing else is sending or receiving on the network channel.  In
+// general, all client operations are serialized (Use multiple channels /
+// clients if parallelism is needed).
+type RawMetaClient struct {
+	RawAsciiClient
+
+	// Shadows RawAsciiClient's contextOps, so that the context-aware
+	// operations use the meta protocol.
+	contextOps
+}
+
+// This creates a new memcache RawMetaClient.
+func NewRawMetaClient(shard int, channel io.ReadWriter) ClientShard {
+	c := &RawMetaClient{
+		RawAsciiClient: RawAsciiClient{
+			shard:      shard,
+			channel:    channel,
+			mutex:      &sync.Mutex{},
+			validState: true,
+			writer:     bufio.NewWriter(channel),
+			reader:     bufio.NewReader(channel),
+		},
+	}
+	c.contextOps = contextOps{client: c, run: c.runWithContext}
+	return c
+}
+
+var _ ContextClient = (*RawMetaClient)(nil)
+
+// See RawBinaryClient.runWithContext.
+func (c *RawMetaClient) runWithContext(
+	ctx context.Context,
+	op func(client Client)) error {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	locked := *c
+	locked.mutex = noOpLocker{}
+	defer func() { c.validState = locked.validState }()
+
+	return runWithContext(
+		ctx,
+		c.channel,
+		func() { op(&locked) },
+		func() { locked.validState = false })
+}
+
+// Reads and parses a single meta response.  Error lines (ERROR,
+// CLIENT_ERROR, SERVER_ERROR) are returned as errors without invalidating the
+// channel.
+func (c *RawMetaClient) readResult() (*metaResult, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	if line == "ERROR" ||
+		strings.HasPrefix(line, "CLIENT_ERROR ") ||
+		strings.HasPrefix(line, "SERVER_ERROR ") {
+
+		return nil, errors.New(line)
+	}
+
+	tokens := strings.Split(line, " ")
+	result := &metaResult{
+		code: tokens[0],
+		ttl:  -1,
+	}
+
+	tokens = tokens[1:]
+	if result.code == "VA" {
+		if len(tokens) == 0 {
+			c.validState = false
+			return nil, errors.Newf("Invalid meta response: %s", line)
+		}
+
+		size, err := strconv.ParseUint(tokens[0], 10, 32)
+		if err != nil {
+			c.validState = false
+			return nil, errors.Newf("Invalid meta response: %s", line)
+		}
+		tokens = tokens[1:]
+
+		value, err := c.read(int(size) + 2)
+		if err != nil {
+			return nil, err
+		}
+		if value[size] != '\r' || value[size+1] != '\n' {
+			c.validState = false
+			return nil, errors.New("Corrupted stream")
+		}
+		result.value = value[:size]
+	}
+
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+
+		var err error
+		switch token[0] {
+		case 'f':
+			var flags uint64
+			flags, err = strconv.ParseUint(token[1:], 10, 32)
+			result.flags = uint32(flags)
+		case 'c':
+			result.cas, err = strconv.ParseUint(token[1:], 10, 64)
+		case 't':
+			result.ttl, err = strconv.ParseInt(token[1:], 10, 64)
+		case 'O':
+			result.opaque = token[1:]
+		case 'W':
+			result.won = true
+		case 'X':
+			result.stale = true
+		case 'Z':
+			result.winTokenSent = true
+		}
+
+		if err != nil {
+			c.validState = false
+			return nil, errors.Newf("Invalid meta response: %s", line)
+		}
+	}
+
+	return result, nil
+}
+
+func (c *RawMetaClient) writeGetRequest(
+	key string,
+	options MetaGetOptions,
+	opaque string) error {
+
+	tokens := []string{"mg ", key, " v f c t"}
+	if opaque != "" {
+		tokens = append(tokens, " q O", opaque)
+	}
+	if options.VivifyOnMiss {
+		tokens = append(
+			tokens,
+			" N",
+			strconv.FormatUint(uint64(options.VivifyTTL), 10))
+	}
+	if options.RecacheTTL != 0 {
+		tokens = append(
+			tokens,
+			" R",
+			strconv.FormatUint(uint64(options.RecacheTTL), 10))
+	}
+	if options.Touch {
+		tokens = append(
+			tokens,
+			" T",
+			strconv.FormatUint(uint64(options.TouchTTL), 10))
+	}
+	tokens = append(tokens, "\r\n")
+
+	return c.writeStrings(tokens...)
+}
+
+func (c *RawMetaClient) MetaGet(
+	key string,
+	options MetaGetOptions) MetaGetResponse {
+
+	if !isValidKeyString(key) {
+		return NewMetaGetErrorResponse(key, errors.New("Invalid key"))
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.writeGetRequest(key, options, "")
+	if err != nil {
+		return NewMetaGetErrorResponse(key, err)
+	}
+
+	err = c.flushWriter()
+	if err != nil {
+		return NewMetaGetErrorResponse(key, err)
+	}
+
+	result, err := c.readResult()
+	if err != nil {
+		return NewMetaGetErrorResponse(key, err)
+	}
+
+	_ = c.checkEmptyBuffers()
+
+	return result.getResponse(key)
+}
+
+func (c *RawMetaClient) MetaGetMulti(
+	keys []string,
+	options MetaGetOptions) map[string]MetaGetResponse {
+
+	responses := make(map[string]MetaGetResponse, len(keys))
+	neededKeys := []string{}
+	for _, key := range keys {
+		if _, ok := responses[key]; ok {
+			continue
+		}
+
+		if !isValidKeyString(key) {
+			responses[key] = NewMetaGetErrorResponse(
+				key,
+				errors.New("Invalid key"))
+			continue
+		}
+
+		neededKeys = append(neededKeys, key)
+		responses[key] = nil
+	}
+
+	if len(neededKeys) == 0 {
+		return responses
+	}
+
+	populateErrorResponses := func(e error) {
+		for _, key := range neededKeys {
+			if responses[key] == nil {
+				responses[key] = NewMetaGetErrorResponse(key, e)
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// NOTE: get requests are pipelined in quiet mode (i.e., misses are not
+	// reported), and are terminated by a no-op.  Responses are matched to
+	// keys by opaque (the key's index in neededKeys).
+	for i, key := range neededKeys {
+		err := c.writeGetRequest(key, options, strconv.Itoa(i))
+		if err != nil {
+			populateErrorResponses(err)
+			return responses
+		}
+	}
+
+	err := c.writeStrings("mn\r\n")
+	if err != nil {
+		populateErrorResponses(err)
+		return responses
+	}
+
+	err = c.flushWriter()
+	if err != nil {
+		populateErrorResponses(err)
+		return responses
+	}
+
+	for {
+		result, err := c.readResult()
+		if err != nil {
+			// Error lines do not carry an opaque, so it's impossible to tell
+			// which request failed.
+			c.validState = false
+			populateErrorResponses(err)
+			return responses
+		}
+
+		if result.code == "MN" {
+			break
+		}
+
+		i, err := strconv.Atoi(result.opaque)
+		if err != nil || i < 0 || i >= len(neededKeys) ||
+			responses[neededKeys[i]] != nil {
+
+			c.validState = false
+			populateErrorResponses(
+				errors.Newf("Unexpected meta response opaque: %s", result.opaque))
+			return responses
+		}
+
+		responses[neededKeys[i]] = result.getResponse(neededKeys[i])
+	}
+
+	err = c.checkEmptyBuffers()
+	if err != nil {
+		populateErrorResponses(err)
+		return responses
+	}
+
+	for _, key := range neededKeys {
+		if responses[key] == nil {
+			responses[key] = &metaGetResponse{
+				GetResponse: NewGetResponse(key, StatusKeyNotFound, 0, nil, 0),
+				ttl:         -1,
+			}
+		}
+	}
+
+	return responses
+}
+
+func (c *RawMetaClient) Get(key string) GetResponse {
+	return c.MetaGet(key, MetaGetOptions{})
+}
+
+func (c *RawMetaClient) GetMulti(keys []string) map[string]GetResponse {
+	metaResponses := c.MetaGetMulti(keys, MetaGetOptions{})
+
+	responses := make(map[string]GetResponse, len(metaResponses))
+	for key, resp := range metaResponses {
+		responses[key] = resp
+	}
+	return responses
+}
+
+func (c *RawMetaClient) GetAndTouch(
+	key string,
+	expiration uint32) GetResponse {
+
+	return c.MetaGet(key, MetaGetOptions{Touch: true, TouchTTL: expiration})
+}
+
+func (c *RawMetaClient) GetAndTouchMulti(
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	metaResponses := c.MetaGetMulti(
+		keys,
+		MetaGetOptions{Touch: true, TouchTTL: expiration})
+
+	responses := make(map[string]GetResponse, len(metaResponses))
+	for key, resp := range metaResponses {
+		responses[key] = resp
+	}
+	return responses
+}
+
+func (c *RawMetaClient) GetSentinels(keys []string) map[string]GetResponse {
+	// For raw clients, there are no difference between GetMulti and
+	// GetSentinels.
+	return c.GetMulti(keys)
+}
+
+// NOTE: options[i] applies to items[i].
+func (c *RawMetaClient) storeRequests(
+	items []*Item,
+	options []MetaSetOptions) []MutateResponse {
+
+	responses := make([]MutateResponse, len(items), len(items))
+	needSending := false
+	for i, item := range items {
+		if item == nil {
+			responses[i] = NewMutateErrorResponse("", errors.New("item is nil"))
+			continue
+		}
+
+		if !isValidKeyString(item.Key) {
+			responses[i] = NewMutateErrorResponse(
+				item.Key,
+				errors.New("Invalid key"))
+			continue
+		}
+
+		err := validateValue(item.Value, defaultMaxValueLength)
+		if err != nil {
+			responses[i] = NewMutateErrorResponse(item.Key, err)
+			continue
+		}
+
+		needSending = true
+	}
+
+	if !needSending {
+		return responses
+	}
+
+	populateErrorResponses := func(e error) {
+		for i, item := range items {
+			if responses[i] == nil {
+				responses[i] = NewMutateErrorResponse(item.Key, e)
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// NOTE: store requests are pipelined.
+	for i, item := range items {
+		if responses[i] != nil {
+			continue
+		}
+
+		tokens := []string{
+			"ms ", item.Key, " ",
+			strconv.Itoa(len(item.Value)),
+			" c F", strconv.FormatUint(uint64(item.Flags), 10),
+			" T", strconv.FormatUint(uint64(item.Expiration), 10),
+		}
+		if item.DataVersionId != 0 {
+			tokens = append(
+				tokens,
+				" C",
+				strconv.FormatUint(item.DataVersionId, 10))
+		}
+		if options[i].Mode != MetaSetModeSet {
+			tokens = append(tokens, " ", options[i].Mode.token())
+		}
+		if options[i].Invalidate {
+			tokens = append(tokens, " I")
+		}
+		tokens = append(tokens, "\r\n", string(item.Value), "\r\n")
+
+		err := c.writeStrings(tokens...)
+		if err != nil {
+			populateErrorResponses(err)
+			return responses
+		}
+	}
+
+	err := c.flushWriter()
+	if err != nil {
+		populateErrorResponses(err)
+		return responses
+	}
+
+	for i, item := range items {
+		if responses[i] != nil {
+			continue
+		}
+
+		result, err := c.readResult()
+		if err != nil {
+			if !c.validState {
+				populateErrorResponses(err)
+				return responses
+			}
+			responses[i] = NewMutateErrorResponse(item.Key, err)
+			continue
+		}
+
+		status, err := result.status()
+		if err != nil {
+			responses[i] = NewMutateErrorResponse(item.Key, err)
+			continue
+		}
+		responses[i] = NewMutateResponse(item.Key, status, result.cas)
+	}
+
+	_ = c.checkEmptyBuffers()
+
+	return responses
+}
+
+// Returns the same options for every item.
+func repeatSetOptions(items []*Item, options MetaSetOptions) []MetaSetOptions {
+	result := make([]MetaSetOptions, len(items))
+	for i := range result {
+		result[i] = options
+	}
+	return result
+}
+
+func (c *RawMetaClient) MetaSet(
+	item *Item,
+	options MetaSetOptions) MutateResponse {
+
+	return c.storeRequests([]*Item{item}, []MetaSetOptions{options})[0]
+}
+
+func (c *RawMetaClient) Set(item *Item) MutateResponse {
+	return c.SetMulti([]*Item{item})[0]
+}
+
+func (c *RawMetaClient) SetMulti(items []*Item) []MutateResponse {
+	return c.storeRequests(items, repeatSetOptions(items, MetaSetOptions{}))
+}
+
+func (c *RawMetaClient) SetSentinels(items []*Item) []MutateResponse {
+	// There are no difference between SetMutli and SetSentinels since
+	// SetMulti issues cas sets depending on the items' version ids.
+	return c.SetMulti(items)
+}
+
+func (c *RawMetaClient) CasMulti(items []*Item) []MutateResponse {
+	options := make([]MetaSetOptions, len(items))
+	for i, item := range items {
+		if item != nil && item.DataVersionId == 0 {
+			options[i].Mode = MetaSetModeAdd
+		}
+	}
+	return c.storeRequests(items, options)
+}
+
+func (c *RawMetaClient) CasSentinels(items []*Item) []MutateResponse {
+	// For raw clients, there are no difference between CasMulti and
+	// CasSentinels.
+	return c.CasMulti(items)
+}
+
+func (c *RawMetaClient) Add(item *Item) MutateResponse {
+	return c.AddMulti([]*Item{item})[0]
+}
+
+func (c *RawMetaClient) AddMulti(items []*Item) []MutateResponse {
+	return c.storeRequests(
+		items,
+		repeatSetOptions(items, MetaSetOptions{Mode: MetaSetModeAdd}))
+}
+
+func (c *RawMetaClient) Replace(item *Item) MutateResponse {
+	return c.MetaSet(item, MetaSetOptions{Mode: MetaSetModeReplace})
+}
+
+func (c *RawMetaClient) Append(key string, value []byte) MutateResponse {
+	return c.MetaSet(
+		&Item{Key: key, Value: value},
+		MetaSetOptions{Mode: MetaSetModeAppend})
+}
+
+func (c *RawMetaClient) Prepend(key string, value []byte) MutateResponse {
+	return c.MetaSet(
+		&Item{Key: key, Value: value},
+		MetaSetOptions{Mode: MetaSetModePrepend})
+}
+
+func (c *RawMetaClient) deleteRequests(
+	keys []string,
+	options MetaDeleteOptions) []MutateResponse {
+
+	responses := make([]MutateResponse, len(keys), len(keys))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// NOTE: delete requests are pipelined.
+	for i, key := range keys {
+		if !isValidKeyString(key) {
+			responses[i] = NewMutateErrorResponse(
+				key,
+				errors.New("Invalid key"))
+			continue
+		}
+
+		tokens := []string{"md ", key}
+		if options.DataVersionId != 0 {
+			tokens = append(
+				tokens,
+				" C",
+				strconv.FormatUint(options.DataVersionId, 10))
+		}
+		if options.Invalidate {
+			tokens = append(tokens, " I")
+		}
+		tokens = append(tokens, "\r\n")
+
+		err := c.writeStrings(tokens...)
+		if err != nil {
+			responses[i] = NewMutateErrorResponse(key, err)
+		}
+	}
+
+	err := c.flushWriter()
+	if err != nil {
+		// The delete requests may or may not have successfully reached the
+		// memcached, just error out.
+		for i, key := range keys {
+			if responses[i] == nil {
+				responses[i] = NewMutateErrorResponse(key, err)
+			}
+		}
+	}
+
+	for i, key := range keys {
+		if responses[i] != nil {
+			continue
+		}
+
+		result, err := c.readResult()
+		if err != nil {
+			responses[i] = NewMutateErrorResponse(key, err)
+			continue
+		}
+
+		status, err := result.status()
+		if err != nil {
+			responses[i] = NewMutateErrorResponse(key, err)
+			continue
+		}
+		responses[i] = NewMutateResponse(key, status, 0)
+	}
+
+	_ = c.checkEmptyBuffers()
+
+	return responses
+}
+
+func (c *RawMetaClient) MetaDelete(
+	key string,
+	options MetaDeleteOptions) MutateResponse {
+
+	return c.deleteRequests([]string{key}, options)[0]
+}
+
+func (c *RawMetaClient) Delete(key string) MutateResponse {
+	return c.DeleteMulti([]string{key})[0]
+}
+
+func (c *RawMetaClient) DeleteMulti(keys []string) []MutateResponse {
+	return c.deleteRequests(keys, MetaDeleteOptions{})
+}
+
+func (c *RawMetaClient) MetaArithmetic(
+	key string,
+	options MetaArithmeticOptions) CountResponse {
+
+	if !isValidKeyString(key) {
+		return NewCountErrorResponse(key, errors.New("Invalid key"))
+	}
+
+	tokens := []string{
+		"ma ", key,
+		" v D", strconv.FormatUint(options.Delta, 10),
+	}
+	if options.Decrement {
+		tokens = append(tokens, " MD")
+	}
+	if options.VivifyOnMiss {
+		tokens = append(
+			tokens,
+			" N", strconv.FormatUint(uint64(options.VivifyTTL), 10),
+			" J", strconv.FormatUint(options.InitialValue, 10))
+	}
+	tokens = append(tokens, "\r\n")
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.writeStrings(tokens...)
+	if err != nil {
+		return NewCountErrorResponse(key, err)
+	}
+
+	err = c.flushWriter()
+	if err != nil {
+		return NewCountErrorResponse(key, err)
+	}
+
+	result, err := c.readResult()
+	if err != nil {
+		return NewCountErrorResponse(key, err)
+	}
+
+	_ = c.checkEmptyBuffers()
+
+	status, err := result.status()
+	if err != nil {
+		return NewCountErrorResponse(key, err)
+	}
+	if status != StatusNoError {
+		return NewCountResponse(key, status, 0)
+	}
+
+	val, err := strconv.ParseUint(string(result.value), 10, 64)
+	if err != nil {
+		return NewCountErrorResponse(key, err)
+	}
+
+	return NewCountResponse(key, StatusNoError, val)
+}
+
+func (c *RawMetaClient) Increment(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.MetaArithmetic(
+		key,
+		MetaArithmeticOptions{
+			Delta:        delta,
+			VivifyOnMiss: expiration != 0xffffffff,
+			InitialValue: initValue,
+			VivifyTTL:    expiration,
+		})
+}
+
+func (c *RawMetaClient) Decrement(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.MetaArithmetic(
+		key,
+		MetaArithmeticOptions{
+			Decrement:    true,
+			Delta:        delta,
+			VivifyOnMiss: expiration != 0xffffffff,
+			InitialValue: initValue,
+			VivifyTTL:    expiration,
+		})
+}
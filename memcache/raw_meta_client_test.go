@@ -0,0 +1,579 @@
+package memcache
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+)
+
+type fakeMetaItem struct {
+	value        []byte
+	flags        uint32
+	cas          uint64
+	ttl          int64 // -1 if the item does not expire
+	stale        bool
+	winTokenSent bool
+}
+
+// An in-process fake memcached which speaks (a subset of) the meta protocol.
+// Time does not pass in the fake server, i.e., an item's ttl only changes when
+// it is explicitly updated.
+type fakeMetaServer struct {
+	mutex   sync.Mutex
+	items   map[string]*fakeMetaItem
+	lastCas uint64
+
+	// All received request lines (excluding data blocks).
+	requests []string
+}
+
+func newFakeMetaServer() *fakeMetaServer {
+	return &fakeMetaServer{
+		items: make(map[string]*fakeMetaItem),
+	}
+}
+
+// This returns a client connected to the fake server.
+func (s *fakeMetaServer) newClient() *RawMetaClient {
+	clientConn, serverConn := net.Pipe()
+	go s.serve(serverConn)
+	return NewRawMetaClient(0, clientConn).(*RawMetaClient)
+}
+
+func (s *fakeMetaServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		tokens := strings.Fields(line)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		var data []byte
+		if tokens[0] == "ms" && len(tokens) >= 3 {
+			size, err := strconv.Atoi(tokens[2])
+			if err != nil {
+				return
+			}
+			data = make([]byte, size+2)
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return
+			}
+			data = data[:size]
+		}
+
+		s.mutex.Lock()
+		s.requests = append(s.requests, strings.TrimSpace(line))
+		response := s.handle(tokens, data)
+		s.mutex.Unlock()
+
+		if _, err := writer.WriteString(response); err != nil {
+			return
+		}
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *fakeMetaServer) nextCas() uint64 {
+	s.lastCas++
+	return s.lastCas
+}
+
+func (s *fakeMetaServer) handle(tokens []string, data []byte) string {
+	if tokens[0] == "mn" {
+		return "MN\r\n"
+	}
+	if tokens[0] == "version" {
+		return "VERSION 1.6.21\r\n"
+	}
+	if len(tokens) < 2 {
+		return "ERROR\r\n"
+	}
+
+	key := tokens[1]
+	flags := make(map[byte]string)
+	args := tokens[2:]
+	if tokens[0] == "ms" {
+		args = tokens[3:]
+	}
+	for _, token := range args {
+		flags[token[0]] = token[1:]
+	}
+
+	ret := func(code string, item *fakeMetaItem) string {
+		result := code
+		if item != nil && code != "VA" {
+			if _, ok := flags['c']; ok {
+				result += " c" + strconv.FormatUint(item.cas, 10)
+			}
+		}
+		if opaque, ok := flags['O']; ok {
+			result += " O" + opaque
+		}
+		return result + "\r\n"
+	}
+
+	switch tokens[0] {
+	case "mg":
+		item := s.items[key]
+		won := false
+		if item == nil {
+			ttl, ok := flags['N']
+			if !ok {
+				if _, quiet := flags['q']; quiet {
+					return ""
+				}
+				return ret("EN", nil)
+			}
+			t, _ := strconv.ParseInt(ttl, 10, 64)
+			item = &fakeMetaItem{
+				value:        []byte{},
+				cas:          s.nextCas(),
+				ttl:          t,
+				winTokenSent: true,
+			}
+			s.items[key] = item
+			won = true
+		} else if ttl, ok := flags['T']; ok {
+			item.ttl, _ = strconv.ParseInt(ttl, 10, 64)
+		}
+
+		recache := false
+		if ttl, ok := flags['R']; ok {
+			t, _ := strconv.ParseInt(ttl, 10, 64)
+			recache = item.ttl != -1 && item.ttl < t
+		}
+
+		winTokenSent := false
+		if !won {
+			if item.winTokenSent {
+				winTokenSent = true
+			} else if item.stale || recache {
+				item.winTokenSent = true
+				won = true
+			}
+		}
+
+		result := "VA " + strconv.Itoa(len(item.value))
+		result += " f" + strconv.FormatUint(uint64(item.flags), 10)
+		result += " c" + strconv.FormatUint(item.cas, 10)
+		result += " t" + strconv.FormatInt(item.ttl, 10)
+		if won {
+			result += " W"
+		}
+		if item.stale {
+			result += " X"
+		}
+		if winTokenSent {
+			result += " Z"
+		}
+		if opaque, ok := flags['O']; ok {
+			result += " O" + opaque
+		}
+		return result + "\r\n" + string(item.value) + "\r\n"
+
+	case "ms":
+		item := s.items[key]
+		mode := flags['M']
+		if mode == "" {
+			mode = "S"
+		}
+
+		invalidated := false
+		if cas, ok := flags['C']; ok {
+			c, _ := strconv.ParseUint(cas, 10, 64)
+			if item == nil {
+				return ret("NF", nil)
+			}
+			if c != item.cas {
+				_, invalidate := flags['I']
+				if !invalidate || c > item.cas {
+					return ret("EX", nil)
+				}
+				invalidated = true
+			}
+		}
+
+		switch mode {
+		case "E":
+			if item != nil {
+				return ret("NS", nil)
+			}
+		case "R", "A", "P":
+			if item == nil {
+				return ret("NS", nil)
+			}
+		}
+
+		newItem := &fakeMetaItem{value: data, ttl: -1}
+		switch mode {
+		case "A":
+			newItem = item
+			newItem.value = append(append([]byte{}, item.value...), data...)
+		case "P":
+			newItem = item
+			newItem.value = append(append([]byte{}, data...), item.value...)
+		default:
+			// Sets with an older data version id store a stale item.
+			newItem.stale = invalidated
+			f, _ := strconv.ParseUint(flags['F'], 10, 32)
+			newItem.flags = uint32(f)
+			if t, _ := strconv.ParseInt(flags['T'], 10, 64); t != 0 {
+				newItem.ttl = t
+			}
+		}
+		newItem.cas = s.nextCas()
+		s.items[key] = newItem
+		return ret("HD", newItem)
+
+	case "md":
+		item := s.items[key]
+		if item == nil {
+			return ret("NF", nil)
+		}
+		if cas, ok := flags['C']; ok {
+			if c, _ := strconv.ParseUint(cas, 10, 64); c != item.cas {
+				return ret("EX", nil)
+			}
+		}
+		if _, ok := flags['I']; ok {
+			item.stale = true
+			item.winTokenSent = false
+			item.cas = s.nextCas()
+		} else {
+			delete(s.items, key)
+		}
+		return ret("HD", nil)
+
+	case "ma":
+		item := s.items[key]
+		if item == nil {
+			ttl, ok := flags['N']
+			if !ok {
+				return ret("NF", nil)
+			}
+			t, _ := strconv.ParseInt(ttl, 10, 64)
+			if t == 0 {
+				t = -1
+			}
+			item = &fakeMetaItem{
+				value: []byte(flags['J']),
+				cas:   s.nextCas(),
+				ttl:   t,
+			}
+			if len(item.value) == 0 {
+				item.value = []byte("0")
+			}
+			s.items[key] = item
+		} else {
+			count, err := strconv.ParseUint(string(item.value), 10, 64)
+			if err != nil {
+				return "CLIENT_ERROR cannot increment or decrement " +
+					"non-numeric value\r\n"
+			}
+			delta, _ := strconv.ParseUint(flags['D'], 10, 64)
+			if flags['M'] == "D" {
+				if delta > count {
+					count = 0
+				} else {
+					count -= delta
+				}
+			} else {
+				count += delta
+			}
+			item.value = []byte(strconv.FormatUint(count, 10))
+			item.cas = s.nextCas()
+		}
+		return "VA " + strconv.Itoa(len(item.value)) + "\r\n" +
+			string(item.value) + "\r\n"
+	}
+
+	return "ERROR\r\n"
+}
+
+func (s *fakeMetaServer) lastRequest() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.requests[len(s.requests)-1]
+}
+
+type RawMetaClientSuite struct {
+	server *fakeMetaServer
+	client *RawMetaClient
+}
+
+var _ = Suite(&RawMetaClientSuite{})
+
+func (s *RawMetaClientSuite) SetUpTest(c *C) {
+	s.server = newFakeMetaServer()
+	s.client = s.server.newClient()
+}
+
+func (s *RawMetaClientSuite) TearDownTest(c *C) {
+	_ = s.client.channel.(net.Conn).Close()
+}
+
+func (s *RawMetaClientSuite) TestSetAndGet(c *C) {
+	resp := s.client.Set(&Item{
+		Key:        "key",
+		Value:      []byte("AB\r\nCD"),
+		Flags:      42,
+		Expiration: 100,
+	})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(resp.DataVersionId(), Equals, uint64(1))
+	c.Assert(s.server.lastRequest(), Equals, "ms key 6 c F42 T100")
+
+	getResp := s.client.Get("key")
+	c.Assert(getResp.Error(), IsNil)
+	c.Assert(getResp.Status(), Equals, StatusNoError)
+	c.Assert(getResp.Key(), Equals, "key")
+	c.Assert(getResp.Value(), DeepEquals, []byte("AB\r\nCD"))
+	c.Assert(getResp.Flags(), Equals, uint32(42))
+	c.Assert(getResp.DataVersionId(), Equals, uint64(1))
+	c.Assert(getResp.(MetaGetResponse).TTL(), Equals, int64(100))
+	c.Assert(s.server.lastRequest(), Equals, "mg key v f c t")
+
+	getResp = s.client.Get("missing")
+	c.Assert(getResp.Error(), IsNil)
+	c.Assert(getResp.Status(), Equals, StatusKeyNotFound)
+	c.Assert(getResp.Value(), IsNil)
+
+	c.Assert(s.client.IsValidState(), IsTrue)
+}
+
+func (s *RawMetaClientSuite) TestGetMulti(c *C) {
+	s.client.Set(&Item{Key: "key1", Value: []byte("1")})
+	s.client.Set(&Item{Key: "key3", Value: []byte("3")})
+
+	responses := s.client.GetMulti(
+		[]string{"key1", "key2", "key3", "key1", "b a d"})
+	c.Assert(responses, HasLen, 4)
+
+	c.Assert(responses["key1"].Error(), IsNil)
+	c.Assert(responses["key1"].Value(), DeepEquals, []byte("1"))
+	c.Assert(responses["key2"].Error(), IsNil)
+	c.Assert(responses["key2"].Status(), Equals, StatusKeyNotFound)
+	c.Assert(responses["key3"].Value(), DeepEquals, []byte("3"))
+	c.Assert(responses["b a d"].Error(), NotNil)
+
+	c.Assert(s.server.lastRequest(), Equals, "mn")
+	c.Assert(s.client.IsValidState(), IsTrue)
+}
+
+func (s *RawMetaClientSuite) TestStoreModes(c *C) {
+	c.Assert(
+		s.client.Replace(&Item{Key: "key", Value: []byte("x")}).Status(),
+		Equals,
+		StatusItemNotStored)
+
+	resp := s.client.Add(&Item{Key: "key", Value: []byte("b")})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.server.lastRequest(), Equals, "ms key 1 c F0 T0 ME")
+
+	c.Assert(
+		s.client.Add(&Item{Key: "key", Value: []byte("x")}).Status(),
+		Equals,
+		StatusItemNotStored)
+
+	c.Assert(s.client.Append("key", []byte("c")).Error(), IsNil)
+	c.Assert(s.client.Prepend("key", []byte("a")).Error(), IsNil)
+	c.Assert(s.client.Get("key").Value(), DeepEquals, []byte("abc"))
+
+	// Cas sets.
+	cas := s.client.Get("key").DataVersionId()
+	resp = s.client.Set(
+		&Item{Key: "key", Value: []byte("new"), DataVersionId: cas + 1})
+	c.Assert(resp.Status(), Equals, StatusKeyExists)
+
+	responses := s.client.CasMulti([]*Item{
+		{Key: "key", Value: []byte("new"), DataVersionId: cas},
+		{Key: "key2", Value: []byte("added")},
+		nil,
+	})
+	c.Assert(responses[0].Error(), IsNil)
+	c.Assert(responses[0].DataVersionId(), Not(Equals), cas)
+	c.Assert(responses[1].Error(), IsNil)
+	c.Assert(responses[2].Error(), NotNil)
+	c.Assert(s.client.Get("key").Value(), DeepEquals, []byte("new"))
+}
+
+func (s *RawMetaClientSuite) TestDelete(c *C) {
+	s.client.Set(&Item{Key: "key", Value: []byte("v")})
+
+	responses := s.client.DeleteMulti([]string{"key", "key", "b a d"})
+	c.Assert(responses[0].Error(), IsNil)
+	c.Assert(responses[1].Status(), Equals, StatusKeyNotFound)
+	c.Assert(responses[2].Error(), NotNil)
+	c.Assert(s.client.Get("key").Status(), Equals, StatusKeyNotFound)
+}
+
+func (s *RawMetaClientSuite) TestInvalidate(c *C) {
+	s.client.Set(&Item{Key: "key", Value: []byte("v1")})
+
+	resp := s.client.MetaDelete("key", MetaDeleteOptions{Invalidate: true})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.server.lastRequest(), Equals, "md key I")
+
+	// The first reader wins the right to recache the stale item.
+	getResp := s.client.MetaGet("key", MetaGetOptions{})
+	c.Assert(getResp.Error(), IsNil)
+	c.Assert(getResp.Value(), DeepEquals, []byte("v1"))
+	c.Assert(getResp.Stale(), IsTrue)
+	c.Assert(getResp.Won(), IsTrue)
+	c.Assert(getResp.WinTokenSent(), IsFalse)
+
+	getResp = s.client.MetaGet("key", MetaGetOptions{})
+	c.Assert(getResp.Stale(), IsTrue)
+	c.Assert(getResp.Won(), IsFalse)
+	c.Assert(getResp.WinTokenSent(), IsTrue)
+
+	c.Assert(s.client.Set(&Item{Key: "key", Value: []byte("v2")}).Error(), IsNil)
+
+	getResp = s.client.MetaGet("key", MetaGetOptions{})
+	c.Assert(getResp.Value(), DeepEquals, []byte("v2"))
+	c.Assert(getResp.Stale(), IsFalse)
+	c.Assert(getResp.Won(), IsFalse)
+}
+
+func (s *RawMetaClientSuite) TestInvalidatingSet(c *C) {
+	s.client.Set(&Item{Key: "key", Value: []byte("v1")})
+	cas := s.client.Get("key").DataVersionId()
+	s.client.Set(&Item{Key: "key", Value: []byte("v2")})
+
+	resp := s.client.MetaSet(
+		&Item{Key: "key", Value: []byte("v3"), DataVersionId: cas},
+		MetaSetOptions{Invalidate: true})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.server.lastRequest(), Equals, "ms key 2 c F0 T0 C1 I")
+
+	getResp := s.client.MetaGet("key", MetaGetOptions{})
+	c.Assert(getResp.Value(), DeepEquals, []byte("v3"))
+	c.Assert(getResp.Stale(), IsTrue)
+}
+
+func (s *RawMetaClientSuite) TestVivifyOnMiss(c *C) {
+	options := MetaGetOptions{VivifyOnMiss: true, VivifyTTL: 30}
+
+	resp := s.client.MetaGet("key", options)
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(resp.Status(), Equals, StatusNoError)
+	c.Assert(resp.Value(), DeepEquals, []byte{})
+	c.Assert(resp.Won(), IsTrue)
+	c.Assert(s.server.lastRequest(), Equals, "mg key v f c t N30")
+
+	resp = s.client.MetaGet("key", options)
+	c.Assert(resp.Won(), IsFalse)
+	c.Assert(resp.WinTokenSent(), IsTrue)
+
+	responses := s.client.MetaGetMulti([]string{"key", "key2"}, options)
+	c.Assert(responses["key"].Won(), IsFalse)
+	c.Assert(responses["key2"].Won(), IsTrue)
+}
+
+func (s *RawMetaClientSuite) TestRecache(c *C) {
+	s.client.Set(&Item{Key: "key", Value: []byte("v"), Expiration: 100})
+
+	resp := s.client.MetaGet("key", MetaGetOptions{RecacheTTL: 50})
+	c.Assert(resp.Won(), IsFalse)
+	c.Assert(s.server.lastRequest(), Equals, "mg key v f c t R50")
+
+	resp = s.client.MetaGet(
+		"key",
+		MetaGetOptions{RecacheTTL: 50, Touch: true, TouchTTL: 10})
+	c.Assert(resp.Won(), IsTrue)
+	c.Assert(resp.TTL(), Equals, int64(10))
+	c.Assert(s.server.lastRequest(), Equals, "mg key v f c t R50 T10")
+
+	resp = s.client.MetaGet("key", MetaGetOptions{RecacheTTL: 50})
+	c.Assert(resp.Won(), IsFalse)
+	c.Assert(resp.WinTokenSent(), IsTrue)
+}
+
+func (s *RawMetaClientSuite) TestArithmetic(c *C) {
+	resp := s.client.Increment("counter", 1, 0, 0xffffffff)
+	c.Assert(resp.Status(), Equals, StatusKeyNotFound)
+	c.Assert(s.server.lastRequest(), Equals, "ma counter v D1")
+
+	resp = s.client.Increment("counter", 1, 10, 0)
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(resp.Count(), Equals, uint64(10))
+	c.Assert(s.server.lastRequest(), Equals, "ma counter v D1 N0 J10")
+
+	resp = s.client.Increment("counter", 5, 10, 0)
+	c.Assert(resp.Count(), Equals, uint64(15))
+
+	resp = s.client.Decrement("counter", 20, 0, 0xffffffff)
+	c.Assert(resp.Count(), Equals, uint64(0))
+	c.Assert(s.server.lastRequest(), Equals, "ma counter v D20 MD")
+
+	s.client.Set(&Item{Key: "str", Value: []byte("abc")})
+	resp = s.client.Increment("str", 1, 0, 0xffffffff)
+	c.Assert(resp.Error(), NotNil)
+	c.Assert(s.client.IsValidState(), IsTrue)
+}
+
+func (s *RawMetaClientSuite) TestClassicCommands(c *C) {
+	resp := s.client.Version()
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(resp.Versions(), DeepEquals, map[int]string{0: "1.6.21"})
+}
+
+func (s *RawMetaClientSuite) TestCorruptedStream(c *C) {
+	rw := newMockReadWriter()
+	client := NewRawMetaClient(0, rw)
+
+	rw.recvBuf.WriteString("VA 100 f0\r\nunexpected eof ...")
+
+	resp := client.Get("key")
+	c.Assert(resp.Error(), NotNil)
+	c.Assert(client.IsValidState(), IsFalse)
+}
+
+func (s *RawMetaClientSuite) TestUnexpectedOpaque(c *C) {
+	rw := newMockReadWriter()
+	client := NewRawMetaClient(0, rw)
+
+	rw.recvBuf.WriteString("VA 1 O5\r\nx\r\nMN\r\n")
+
+	responses := client.GetMulti([]string{"key"})
+	c.Assert(
+		rw.sendBuf.String(),
+		Equals,
+		"mg key v f c t q O0\r\nmn\r\n")
+	c.Assert(responses["key"].Error(), NotNil)
+	c.Assert(client.IsValidState(), IsFalse)
+}
+
+func (s *RawMetaClientSuite) TestGetAndTouch(c *C) {
+	s.client.Set(&Item{Key: "key", Value: []byte("v"), Expiration: 100})
+
+	resp := s.client.GetAndTouch("key", 10)
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(resp.Value(), DeepEquals, []byte("v"))
+	c.Assert(resp.(MetaGetResponse).TTL(), Equals, int64(10))
+	c.Assert(s.server.lastRequest(), Equals, "mg key v f c t T10")
+
+	responses := s.client.GetAndTouchMulti([]string{"key", "missing"}, 20)
+	c.Assert(responses["key"].(MetaGetResponse).TTL(), Equals, int64(20))
+	c.Assert(responses["missing"].Status(), Equals, StatusKeyNotFound)
+}
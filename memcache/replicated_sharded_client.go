@@ -0,0 +1,1116 @@
+package memcache
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/dropbox/godropbox/stats"
+	"github.com/dropbox/godropbox/time2"
+)
+
+const (
+	defaultReplicationFactor      = 2
+	defaultMaxConsecutiveFailures = 3
+	defaultUnhealthyDuration      = 10 * time.Second
+)
+
+var (
+	// Counters for number of get requests that were retried on another
+	// replica / were hedged.
+	replicaFailovers = expvar.NewInt("ReplicatedShardedClientGetFailoverCounter")
+	replicaHedges    = expvar.NewInt("ReplicatedShardedClientGetHedgeCounter")
+)
+
+// Options for configuring the ReplicatedShardedClient.
+type ReplicationOptions struct {
+	// The number of distinct shards each key is stored on.  Defaults to 2.
+	ReplicationFactor int
+
+	// When positive, Get / GetAndTouch requests are also sent to the next
+	// replica if the current replica has not responded after this delay, and
+	// the first successful response is used.  Otherwise, replicas are tried
+	// sequentially, and only on failure.
+	HedgingDelay time.Duration
+
+	// A shard is considered unhealthy after this many consecutive failures
+	// (i.e., connection errors, or broken connections).  Unhealthy shards are
+	// tried last.  Defaults to 3.
+	MaxConsecutiveFailures int
+
+	// How long a shard remains unhealthy.  Defaults to 10 seconds.
+	UnhealthyDuration time.Duration
+
+	// Defaults to time2.DefaultClock.
+	Clock time2.Clock
+
+	// Optional.  When set, the client records the same per address
+	// statistics as ShardedClient (see ShardedClientOptions).  Each replica
+	// request is recorded separately.
+	StatsFactory stats.StatsFactory
+}
+
+// Tracks the shards' health, keyed by shard id.
+type shardHealth struct {
+	options ReplicationOptions
+
+	mutex               sync.Mutex
+	consecutiveFailures map[int]int
+	unhealthyUntil      map[int]time.Time
+}
+
+func newShardHealth(options ReplicationOptions) *shardHealth {
+	return &shardHealth{
+		options:             options,
+		consecutiveFailures: make(map[int]int),
+		unhealthyUntil:      make(map[int]time.Time),
+	}
+}
+
+func (h *shardHealth) record(shard int, ok bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if ok {
+		delete(h.consecutiveFailures, shard)
+		delete(h.unhealthyUntil, shard)
+		return
+	}
+
+	h.consecutiveFailures[shard]++
+	if h.consecutiveFailures[shard] >= h.options.MaxConsecutiveFailures {
+		h.unhealthyUntil[shard] = h.options.Clock.Now().Add(
+			h.options.UnhealthyDuration)
+	}
+}
+
+func (h *shardHealth) isHealthy(shard int) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	until, ok := h.unhealthyUntil[shard]
+	if !ok {
+		return true
+	}
+	if h.options.Clock.Now().Before(until) {
+		return false
+	}
+
+	// Give the shard another chance, but mark it unhealthy again on the
+	// next failure.
+	delete(h.unhealthyUntil, shard)
+	h.consecutiveFailures[shard] = h.options.MaxConsecutiveFailures - 1
+	return true
+}
+
+// A sharded memcache client which stores each key on multiple replica shards
+// (as determined by the ReplicaShardManager).  Writes are sent to every
+// replica, and the response from the most preferred available replica is
+// returned.  Reads are served by the most preferred healthy replica, and fail
+// over to the other replicas on errors.  Losing a single memcache shard
+// therefore does not turn every read for its keys into a miss.
+//
+// NOTE:
+//   - Replicas are written independently, hence replicas may diverge (e.g.,
+//     when a write to one of the replicas failed).  Data version ids (aka
+//     CAS) are per replica.  Compare and set is only applied to the most
+//     preferred healthy replica (i.e., the replica which served the read),
+//     and the key is deleted from the other replicas on success.
+//   - Sentinel operations, Flush, Stat, Version and Verbosity are not
+//     replicated (they behave the same as ShardedClient's).
+//   - The context-aware operations only check the context before the
+//     operation starts (i.e., replicated operations are not interrupted).
+type ReplicatedShardedClient struct {
+	ShardedClient
+
+	replicaManager ReplicaShardManager
+	options        ReplicationOptions
+	health         *shardHealth
+
+	// Shadows ShardedClient's context-aware operations, which are not
+	// replicated.
+	ctxOps contextOps
+}
+
+// This creates a new ReplicatedShardedClient.
+func NewReplicatedShardedClient(
+	manager ReplicaShardManager,
+	builder ClientShardBuilder,
+	options ReplicationOptions) Client {
+
+	if options.ReplicationFactor <= 0 {
+		options.ReplicationFactor = defaultReplicationFactor
+	}
+	if options.MaxConsecutiveFailures <= 0 {
+		options.MaxConsecutiveFailures = defaultMaxConsecutiveFailures
+	}
+	if options.UnhealthyDuration <= 0 {
+		options.UnhealthyDuration = defaultUnhealthyDuration
+	}
+	if options.Clock == nil {
+		options.Clock = time2.DefaultClock
+	}
+
+	c := &ReplicatedShardedClient{
+		ShardedClient: ShardedClient{
+			manager: manager,
+			builder: builder,
+			stats:   newStatsCache(options.StatsFactory),
+		},
+		replicaManager: manager,
+		options:        options,
+		health:         newShardHealth(options),
+	}
+	c.ctxOps = newCheckOnlyContextOps(c)
+	return c
+}
+
+// Returns the key's replica shards in preference order, with unhealthy
+// shards moved to the end.
+func (c *ReplicatedShardedClient) replicaShards(key string) []int {
+	shards := c.replicaManager.GetReplicaShardIds(
+		key,
+		c.options.ReplicationFactor)
+
+	result := make([]int, 0, len(shards))
+	unhealthy := []int{}
+	for _, shard := range shards {
+		if c.health.isHealthy(shard) {
+			result = append(result, shard)
+		} else {
+			unhealthy = append(unhealthy, shard)
+		}
+	}
+	return append(result, unhealthy...)
+}
+
+// Runs op with a client for the shard.  Returns an error if the shard is
+// unavailable (i.e., connection error, or the connection broke during the
+// operation).  Returns (false, nil) without running op if the shard is not in
+// active state (similar to ShardedClient, inactive shards are treated as
+// empty shards which discard writes).
+func (c *ReplicatedShardedClient) withShard(
+	shard int,
+	op string,
+	run func(Client) []Response) (ran bool, err error) {
+
+	return c.runOnShard(shard, op, false, run)
+}
+
+// Same as withShard, but also updates the per-address get counters.
+func (c *ReplicatedShardedClient) withGetShard(
+	shard int,
+	op string,
+	run func(Client) []Response) (ran bool, err error) {
+
+	return c.runOnShard(shard, op, true, run)
+}
+
+// run returns the operation's responses, which are recorded in the stats.
+func (c *ReplicatedShardedClient) runOnShard(
+	shard int,
+	op string,
+	isGet bool,
+	run func(Client) []Response) (ran bool, err error) {
+
+	conn, err := c.replicaManager.GetShardConnection(shard)
+	if err != nil {
+		c.health.record(shard, false)
+		return false, c.connectionError(shard, err)
+	}
+	if conn == nil {
+		return false, nil
+	}
+
+	client := c.newShardClient(shard, conn)
+	defer c.release(client, conn)
+
+	start := time.Now()
+	responses := run(client)
+
+	failed := !client.IsValidState()
+	for _, resp := range responses {
+		if resp == nil {
+			continue
+		}
+		failed = failed || resp.Error() != nil
+		if isGet {
+			c.recordGet(conn, resp.(GetResponse))
+		}
+	}
+	c.recordOp(op, conn, start, failed)
+
+	if !client.IsValidState() {
+		c.health.record(shard, false)
+		if isGet {
+			getErrByAddr.Add(conn.Key().Address, 1)
+		}
+		return true, errors.Newf("Connection to memcache shard %d failed", shard)
+	}
+
+	c.health.record(shard, true)
+	if isGet {
+		getOkByAddr.Add(conn.Key().Address, 1)
+	}
+	return true, nil
+}
+
+func (c *ReplicatedShardedClient) getFromShard(
+	key string,
+	shard int,
+	op string,
+	getFunc func(Client) GetResponse) GetResponse {
+
+	var resp GetResponse
+	ran, err := c.withGetShard(
+		shard,
+		op,
+		func(client Client) []Response {
+			resp = getFunc(client)
+			if resp == nil {
+				return nil
+			}
+			return []Response{resp}
+		})
+	if !ran && err == nil {
+		return nil // inactive shard
+	}
+	if resp == nil || (err != nil && resp.Error() == nil) {
+		resp = NewGetErrorResponse(key, err)
+	}
+	return resp
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) Get(key string) GetResponse {
+	return c.get(
+		key,
+		"get",
+		func(shardClient Client) GetResponse {
+			return shardClient.Get(key)
+		})
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) GetAndTouch(
+	key string,
+	expiration uint32) GetResponse {
+
+	return c.get(
+		key,
+		"get_and_touch",
+		func(shardClient Client) GetResponse {
+			return shardClient.GetAndTouch(key, expiration)
+		})
+}
+
+func (c *ReplicatedShardedClient) get(
+	key string,
+	op string,
+	getFunc func(Client) GetResponse) GetResponse {
+
+	shards := c.replicaShards(key)
+	if len(shards) == 0 {
+		return NewGetErrorResponse(key, c.unmappedError(key))
+	}
+
+	if c.options.HedgingDelay > 0 {
+		return c.hedgedGet(key, shards, op, getFunc)
+	}
+
+	var firstErr GetResponse
+	for i, shard := range shards {
+		if i > 0 {
+			replicaFailovers.Add(1)
+		}
+
+		resp := c.getFromShard(key, shard, op, getFunc)
+		if resp == nil {
+			continue
+		}
+		if resp.Error() == nil {
+			return resp
+		}
+		if firstErr == nil {
+			firstErr = resp
+		}
+	}
+	return c.getResult(key, firstErr)
+}
+
+// Returns the error response if there's one.  Otherwise, all replicas were
+// inactive, and the key is treated as a miss.
+func (c *ReplicatedShardedClient) getResult(
+	key string,
+	errResp GetResponse) GetResponse {
+
+	if errResp != nil {
+		return errResp
+	}
+	// NOTE: zero is an invalid version id.
+	return NewGetResponse(key, StatusKeyNotFound, 0, nil, 0)
+}
+
+func (c *ReplicatedShardedClient) hedgedGet(
+	key string,
+	shards []int,
+	op string,
+	getFunc func(Client) GetResponse) GetResponse {
+
+	// Buffered, so that slow replicas do not block after we return.
+	results := make(chan GetResponse, len(shards))
+
+	launched := 0
+	launch := func() {
+		shard := shards[launched]
+		launched++
+		go func() {
+			results <- c.getFromShard(key, shard, op, getFunc)
+		}()
+	}
+
+	launch()
+	timer := c.options.Clock.After(c.options.HedgingDelay)
+
+	var firstErr GetResponse
+	for received := 0; received < launched; {
+		select {
+		case resp := <-results:
+			received++
+			if resp != nil && resp.Error() == nil {
+				return resp
+			}
+			if firstErr == nil {
+				firstErr = resp
+			}
+
+			if launched < len(shards) && received == launched {
+				// Fail over immediately.
+				replicaFailovers.Add(1)
+				launch()
+				timer = c.options.Clock.After(c.options.HedgingDelay)
+			}
+		case <-timer:
+			if launched < len(shards) {
+				replicaHedges.Add(1)
+				launch()
+				timer = c.options.Clock.After(c.options.HedgingDelay)
+			} else {
+				timer = nil
+			}
+		}
+	}
+	return c.getResult(key, firstErr)
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) GetMulti(
+	keys []string) map[string]GetResponse {
+
+	return c.getMulti(keys, "get_multi", getMultiGetter)
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) GetAndTouchMulti(
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	return c.getMulti(
+		keys,
+		"get_and_touch_multi",
+		func(shardClient Client, keys []string) map[string]GetResponse {
+			return shardClient.GetAndTouchMulti(keys, expiration)
+		})
+}
+
+func (c *ReplicatedShardedClient) getMulti(
+	keys []string,
+	op string,
+	getMultiFunc func(Client, []string) map[string]GetResponse) map[string]GetResponse {
+
+	results := make(map[string]GetResponse)
+	pending := make(map[string][]int)
+	for _, key := range keys {
+		if _, ok := pending[key]; ok {
+			continue
+		}
+
+		shards := c.replicaShards(key)
+		if len(shards) == 0 {
+			results[key] = NewGetErrorResponse(key, c.unmappedError(key))
+			continue
+		}
+		pending[key] = shards
+	}
+
+	// In each round, the pending keys are fetched from their next replica.
+	for replica := 0; len(pending) > 0; replica++ {
+		shardKeys := make(map[int][]string)
+		for key, shards := range pending {
+			if replica >= len(shards) {
+				// All replicas failed or were inactive.
+				results[key] = c.getResult(key, results[key])
+				delete(pending, key)
+				continue
+			}
+			shardKeys[shards[replica]] = append(
+				shardKeys[shards[replica]],
+				key)
+		}
+
+		type shardResult struct {
+			keys      []string
+			responses map[string]GetResponse
+			ran       bool
+			err       error
+		}
+
+		resultsChannel := make(chan shardResult, len(shardKeys))
+		for shard, keys := range shardKeys {
+			go func(shard int, keys []string) {
+				result := shardResult{keys: keys}
+				result.ran, result.err = c.withGetShard(
+					shard,
+					op,
+					func(client Client) []Response {
+						result.responses = getMultiFunc(client, keys)
+
+						responses := make([]Response, 0, len(result.responses))
+						for _, resp := range result.responses {
+							responses = append(responses, resp)
+						}
+						return responses
+					})
+				resultsChannel <- result
+			}(shard, keys)
+		}
+
+		for i := 0; i < len(shardKeys); i++ {
+			result := <-resultsChannel
+			if !result.ran && result.err == nil {
+				continue // inactive shard
+			}
+
+			for _, key := range result.keys {
+				resp := result.responses[key]
+				if resp == nil || (result.err != nil && resp.Error() == nil) {
+					resp = NewGetErrorResponse(key, result.err)
+				}
+
+				if resp.Error() == nil {
+					results[key] = resp
+					delete(pending, key)
+				} else if results[key] == nil {
+					results[key] = resp
+				}
+			}
+		}
+	}
+
+	return results
+}
+
+// Runs mutateFunc against every replica in parallel.  Returns the response
+// from the most preferred available replica.  Otherwise, returns
+// errorResponse(err) for the first error, or errorResponse(nil) if all
+// replicas are inactive.
+func (c *ReplicatedShardedClient) fanOut(
+	shards []int,
+	op string,
+	mutateFunc func(Client) Response,
+	errorResponse func(error) interface{}) interface{} {
+
+	responses := make([]Response, len(shards))
+	ran := make([]bool, len(shards))
+	errs := make([]error, len(shards))
+
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard int) {
+			defer wg.Done()
+			ran[i], errs[i] = c.withShard(
+				shard,
+				op,
+				func(client Client) []Response {
+					responses[i] = mutateFunc(client)
+					return []Response{responses[i]}
+				})
+		}(i, shard)
+	}
+	wg.Wait()
+
+	for i := range shards {
+		if ran[i] && errs[i] == nil {
+			return responses[i]
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return errorResponse(err)
+		}
+	}
+	return errorResponse(nil)
+}
+
+func (c *ReplicatedShardedClient) mutate(
+	key string,
+	op string,
+	mutateFunc func(Client) MutateResponse) MutateResponse {
+
+	shards := c.replicaShards(key)
+	if len(shards) == 0 {
+		return NewMutateErrorResponse(key, c.unmappedError(key))
+	}
+
+	return c.fanOut(
+		shards,
+		op,
+		func(client Client) Response {
+			return mutateFunc(client)
+		},
+		func(err error) interface{} {
+			return c.mutateResult(key, err)
+		}).(MutateResponse)
+}
+
+// Returns an error response for the error.  A nil error indicates that all
+// replicas were inactive.
+func (c *ReplicatedShardedClient) mutateResult(
+	key string,
+	err error) MutateResponse {
+
+	if err != nil {
+		return NewMutateErrorResponse(key, err)
+	}
+	// NOTE: zero is an invalid version id.
+	return NewMutateResponse(key, StatusNoError, 0)
+}
+
+// Applies the compare and set to the most preferred replica only, and
+// deletes the key from the other replicas on success.
+func (c *ReplicatedShardedClient) cas(item *Item) MutateResponse {
+	shards := c.replicaShards(item.Key)
+	if len(shards) == 0 {
+		return NewMutateErrorResponse(item.Key, c.unmappedError(item.Key))
+	}
+
+	var resp MutateResponse
+	_, err := c.withShard(
+		shards[0],
+		"set",
+		func(client Client) []Response {
+			resp = client.Set(item)
+			return []Response{resp}
+		})
+	if resp == nil {
+		return c.mutateResult(item.Key, err)
+	}
+
+	if resp.Error() == nil && len(shards) > 1 {
+		c.fanOut(
+			shards[1:],
+			"delete",
+			func(client Client) Response {
+				return client.Delete(item.Key)
+			},
+			func(err error) interface{} {
+				return nil
+			})
+	}
+	return resp
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) Set(item *Item) MutateResponse {
+	if item == nil {
+		return NewMutateErrorResponse("", errors.New("item is nil"))
+	}
+	if item.DataVersionId != 0 {
+		return c.cas(item)
+	}
+
+	return c.mutate(
+		item.Key,
+		"set",
+		func(shardClient Client) MutateResponse {
+			return shardClient.Set(item)
+		})
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) Add(item *Item) MutateResponse {
+	if item == nil {
+		return NewMutateErrorResponse("", errors.New("item is nil"))
+	}
+
+	return c.mutate(
+		item.Key,
+		"add",
+		func(shardClient Client) MutateResponse {
+			return shardClient.Add(item)
+		})
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) Replace(item *Item) MutateResponse {
+	if item == nil {
+		return NewMutateErrorResponse("", errors.New("item is nil"))
+	}
+
+	return c.mutate(
+		item.Key,
+		"replace",
+		func(shardClient Client) MutateResponse {
+			return shardClient.Replace(item)
+		})
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) Delete(key string) MutateResponse {
+	return c.mutate(
+		key,
+		"delete",
+		func(shardClient Client) MutateResponse {
+			return shardClient.Delete(key)
+		})
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) Append(
+	key string,
+	value []byte) MutateResponse {
+
+	return c.mutate(
+		key,
+		"append",
+		func(shardClient Client) MutateResponse {
+			return shardClient.Append(key, value)
+		})
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) Prepend(
+	key string,
+	value []byte) MutateResponse {
+
+	return c.mutate(
+		key,
+		"prepend",
+		func(shardClient Client) MutateResponse {
+			return shardClient.Prepend(key, value)
+		})
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) Touch(
+	key string,
+	expiration uint32) MutateResponse {
+
+	return c.mutate(
+		key,
+		"touch",
+		func(shardClient Client) MutateResponse {
+			return shardClient.Touch(key, expiration)
+		})
+}
+
+// Fans out the entries (keys, and optionally items) to their replicas,
+// grouped by shard.  Returns the responses (in the entries' order) from each
+// entry's most preferred available replica.
+func (c *ReplicatedShardedClient) mutateMulti(
+	keys []string,
+	items []*Item, // may be nil
+	op string,
+	mutateMultiFunc func(Client, *ShardMapping) []MutateResponse) []MutateResponse {
+
+	type shardEntries struct {
+		mapping   *ShardMapping
+		positions []int
+		replicas  []int
+	}
+
+	shardsByKey := make([][]int, len(keys))
+	entries := make(map[int]*shardEntries)
+	for pos, key := range keys {
+		shardsByKey[pos] = c.replicaShards(key)
+		for replica, shard := range shardsByKey[pos] {
+			entry, ok := entries[shard]
+			if !ok {
+				entry = &shardEntries{mapping: &ShardMapping{}}
+				entries[shard] = entry
+			}
+			entry.mapping.Keys = append(entry.mapping.Keys, key)
+			if items != nil {
+				entry.mapping.Items = append(entry.mapping.Items, items[pos])
+			}
+			entry.positions = append(entry.positions, pos)
+			entry.replicas = append(entry.replicas, replica)
+		}
+	}
+
+	// (position, replica) -> response.  Nil responses are unavailable
+	// replicas.
+	replicaResponses := make([][]MutateResponse, len(keys))
+	replicaErrs := make([]error, len(keys))
+	for pos, shards := range shardsByKey {
+		replicaResponses[pos] = make([]MutateResponse, len(shards))
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for shard, entry := range entries {
+		wg.Add(1)
+		go func(shard int, entry *shardEntries) {
+			defer wg.Done()
+
+			var responses []MutateResponse
+			_, err := c.withShard(
+				shard,
+				op,
+				func(client Client) []Response {
+					responses = mutateMultiFunc(client, entry.mapping)
+
+					result := make([]Response, len(responses))
+					for i, resp := range responses {
+						result[i] = resp
+					}
+					return result
+				})
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			for i, pos := range entry.positions {
+				if err == nil && i < len(responses) {
+					replicaResponses[pos][entry.replicas[i]] = responses[i]
+				} else if err != nil &&
+					(replicaErrs[pos] == nil || entry.replicas[i] == 0) {
+
+					replicaErrs[pos] = err
+				}
+			}
+		}(shard, entry)
+	}
+	wg.Wait()
+
+	results := make([]MutateResponse, len(keys))
+	for pos, key := range keys {
+		if len(shardsByKey[pos]) == 0 {
+			results[pos] = NewMutateErrorResponse(key, c.unmappedError(key))
+			continue
+		}
+
+		for _, resp := range replicaResponses[pos] {
+			if resp != nil {
+				results[pos] = resp
+				break
+			}
+		}
+		if results[pos] == nil {
+			results[pos] = c.mutateResult(key, replicaErrs[pos])
+		}
+	}
+	return results
+}
+
+// Same as mutateMulti, except items with data version ids are compare and
+// set (see cas).
+func (c *ReplicatedShardedClient) mutateMultiWithCas(
+	items []*Item,
+	op string,
+	mutateMultiFunc func(Client, *ShardMapping) []MutateResponse) []MutateResponse {
+
+	results := make([]MutateResponse, len(items))
+
+	var wg sync.WaitGroup
+	positions := []int{}
+	keys := []string{}
+	mutateItems := []*Item{}
+	for pos, item := range items {
+		if item == nil {
+			results[pos] = NewMutateErrorResponse("", errors.New("item is nil"))
+			continue
+		}
+
+		if item.DataVersionId == 0 {
+			positions = append(positions, pos)
+			keys = append(keys, item.Key)
+			mutateItems = append(mutateItems, item)
+			continue
+		}
+
+		wg.Add(1)
+		go func(pos int, item *Item) {
+			defer wg.Done()
+			results[pos] = c.cas(item)
+		}(pos, item)
+	}
+
+	if len(keys) > 0 {
+		responses := c.mutateMulti(keys, mutateItems, op, mutateMultiFunc)
+		for i, pos := range positions {
+			results[pos] = responses[i]
+		}
+	}
+
+	wg.Wait()
+	return results
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) SetMulti(items []*Item) []MutateResponse {
+	return c.mutateMultiWithCas(items, "set_multi", setMultiMutator)
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) CasMulti(items []*Item) []MutateResponse {
+	// Similar to the raw clients, items without data version ids are added.
+	return c.mutateMultiWithCas(items, "cas_multi", addMultiMutator)
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) AddMulti(items []*Item) []MutateResponse {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		if item == nil {
+			return c.mutateMultiWithCas(items, "add_multi", addMultiMutator)
+		}
+		keys[i] = item.Key
+	}
+	return c.mutateMulti(keys, items, "add_multi", addMultiMutator)
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) DeleteMulti(keys []string) []MutateResponse {
+	return c.mutateMulti(keys, nil, "delete_multi", deleteMultiMutator)
+}
+
+func (c *ReplicatedShardedClient) count(
+	key string,
+	op string,
+	countFunc func(Client) CountResponse) CountResponse {
+
+	shards := c.replicaShards(key)
+	if len(shards) == 0 {
+		return NewCountErrorResponse(key, c.unmappedError(key))
+	}
+
+	return c.fanOut(
+		shards,
+		op,
+		func(client Client) Response {
+			return countFunc(client)
+		},
+		func(err error) interface{} {
+			if err != nil {
+				return NewCountErrorResponse(key, err)
+			}
+			return NewCountResponse(key, StatusNoError, 0)
+		}).(CountResponse)
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) Increment(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.count(
+		key,
+		"increment",
+		func(shardClient Client) CountResponse {
+			return shardClient.Increment(key, delta, initValue, expiration)
+		})
+}
+
+// See Client interface for documentation.
+func (c *ReplicatedShardedClient) Decrement(
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.count(
+		key,
+		"decrement",
+		func(shardClient Client) CountResponse {
+			return shardClient.Decrement(key, delta, initValue, expiration)
+		})
+}
+
+var _ ContextClient = (*ReplicatedShardedClient)(nil)
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) GetCtx(
+	ctx context.Context,
+	key string) GetResponse {
+
+	return c.ctxOps.GetCtx(ctx, key)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) GetMultiCtx(
+	ctx context.Context,
+	keys []string) map[string]GetResponse {
+
+	return c.ctxOps.GetMultiCtx(ctx, keys)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) GetSentinelsCtx(
+	ctx context.Context,
+	keys []string) map[string]GetResponse {
+
+	return c.ctxOps.GetSentinelsCtx(ctx, keys)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) GetAndTouchCtx(
+	ctx context.Context,
+	key string,
+	expiration uint32) GetResponse {
+
+	return c.ctxOps.GetAndTouchCtx(ctx, key, expiration)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) GetAndTouchMultiCtx(
+	ctx context.Context,
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	return c.ctxOps.GetAndTouchMultiCtx(ctx, keys, expiration)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) TouchCtx(
+	ctx context.Context,
+	key string,
+	expiration uint32) MutateResponse {
+
+	return c.ctxOps.TouchCtx(ctx, key, expiration)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) SetCtx(
+	ctx context.Context,
+	item *Item) MutateResponse {
+
+	return c.ctxOps.SetCtx(ctx, item)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) SetMultiCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.ctxOps.SetMultiCtx(ctx, items)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) SetSentinelsCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.ctxOps.SetSentinelsCtx(ctx, items)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) CasMultiCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.ctxOps.CasMultiCtx(ctx, items)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) CasSentinelsCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.ctxOps.CasSentinelsCtx(ctx, items)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) AddCtx(
+	ctx context.Context,
+	item *Item) MutateResponse {
+
+	return c.ctxOps.AddCtx(ctx, item)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) AddMultiCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.ctxOps.AddMultiCtx(ctx, items)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) ReplaceCtx(
+	ctx context.Context,
+	item *Item) MutateResponse {
+
+	return c.ctxOps.ReplaceCtx(ctx, item)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) DeleteCtx(
+	ctx context.Context,
+	key string) MutateResponse {
+
+	return c.ctxOps.DeleteCtx(ctx, key)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) DeleteMultiCtx(
+	ctx context.Context,
+	keys []string) []MutateResponse {
+
+	return c.ctxOps.DeleteMultiCtx(ctx, keys)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) AppendCtx(
+	ctx context.Context,
+	key string,
+	value []byte) MutateResponse {
+
+	return c.ctxOps.AppendCtx(ctx, key, value)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) PrependCtx(
+	ctx context.Context,
+	key string,
+	value []byte) MutateResponse {
+
+	return c.ctxOps.PrependCtx(ctx, key, value)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) IncrementCtx(
+	ctx context.Context,
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.ctxOps.IncrementCtx(ctx, key, delta, initValue, expiration)
+}
+
+// See ContextClient interface for documentation.
+func (c *ReplicatedShardedClient) DecrementCtx(
+	ctx context.Context,
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.ctxOps.DecrementCtx(ctx, key, delta, initValue, expiration)
+}
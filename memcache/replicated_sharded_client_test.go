@@ -0,0 +1,336 @@
+package memcache_test
+
+import (
+	"expvar"
+	"strconv"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/memcache/memcachetest"
+	"github.com/dropbox/godropbox/net2"
+	"github.com/dropbox/godropbox/time2"
+)
+
+type ReplicatedShardedClientSuite struct {
+	clock   *time2.MockClock
+	servers []*memcachetest.Server
+	manager *memcache.ConsistentHashShardManager
+	client  memcache.Client
+}
+
+var _ = Suite(&ReplicatedShardedClientSuite{})
+
+func (s *ReplicatedShardedClientSuite) SetUpTest(c *C) {
+	s.clock = time2.NewMockClock(time.Now())
+
+	s.servers = nil
+	states := []memcache.WeightedShardState{}
+	for i := 0; i < 3; i++ {
+		server, err := memcachetest.NewServer(memcachetest.Options{})
+		c.Assert(err, IsNil)
+		s.servers = append(s.servers, server)
+
+		state := memcache.WeightedShardState{}
+		state.Address = server.Addr()
+		state.State = memcache.ActiveServer
+		states = append(states, state)
+	}
+
+	s.manager = memcache.NewConsistentHashShardManager(
+		states,
+		memcache.ConsistentHashOptions{},
+		net2.ConnectionOptions{MaxActiveConnections: 10})
+
+	s.client = s.newClient(memcache.ReplicationOptions{})
+}
+
+func (s *ReplicatedShardedClientSuite) TearDownTest(c *C) {
+	for _, server := range s.servers {
+		_ = server.Close()
+	}
+}
+
+func (s *ReplicatedShardedClientSuite) newClient(
+	options memcache.ReplicationOptions) memcache.Client {
+
+	options.Clock = s.clock
+	return memcache.NewReplicatedShardedClient(
+		s.manager,
+		memcache.NewRawBinaryClient,
+		options)
+}
+
+// Returns the servers storing the key.
+func (s *ReplicatedShardedClientSuite) replicas(
+	key string) []*memcachetest.Server {
+
+	result := []*memcachetest.Server{}
+	for _, shard := range s.manager.GetReplicaShardIds(key, 2) {
+		result = append(result, s.servers[shard])
+	}
+	return result
+}
+
+func (s *ReplicatedShardedClientSuite) numCopies(key string) int {
+	copies := 0
+	for _, server := range s.servers {
+		if _, ok := server.Item(key); ok {
+			copies++
+		}
+	}
+	return copies
+}
+
+func (s *ReplicatedShardedClientSuite) TestReplicatedWrites(c *C) {
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+
+	replicas := s.replicas("key")
+	c.Assert(replicas, HasLen, 2)
+	for _, server := range replicas {
+		item, ok := server.Item("key")
+		c.Assert(ok, IsTrue)
+		c.Assert(string(item.Value), Equals, "value")
+	}
+	c.Assert(s.numCopies("key"), Equals, 2)
+
+	resp = s.client.Delete("key")
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.numCopies("key"), Equals, 0)
+
+	cresp := s.client.Increment("counter", 1, 5, 0)
+	c.Assert(cresp.Error(), IsNil)
+	c.Assert(cresp.Count(), Equals, uint64(5))
+	c.Assert(s.numCopies("counter"), Equals, 2)
+}
+
+func (s *ReplicatedShardedClientSuite) TestReadFailover(c *C) {
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+
+	_ = s.replicas("key")[0].Close()
+
+	gresp := s.client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(string(gresp.Value()), Equals, "value")
+
+	// Writes still succeed as long as one replica is available.
+	resp = s.client.Set(&memcache.Item{Key: "key", Value: []byte("value2")})
+	c.Assert(resp.Error(), IsNil)
+
+	gresp = s.client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(string(gresp.Value()), Equals, "value2")
+}
+
+func (s *ReplicatedShardedClientSuite) TestMultiFailover(c *C) {
+	items := []*memcache.Item{}
+	keys := []string{}
+	for i := 0; i < 20; i++ {
+		key := "key" + strconv.Itoa(i)
+		keys = append(keys, key)
+		items = append(items, &memcache.Item{Key: key, Value: []byte(key)})
+	}
+
+	responses := s.client.SetMulti(items)
+	c.Assert(responses, HasLen, len(items))
+	for i, resp := range responses {
+		c.Assert(resp.Error(), IsNil)
+		c.Assert(resp.Key(), Equals, keys[i])
+		c.Assert(s.numCopies(keys[i]), Equals, 2)
+	}
+
+	_ = s.servers[1].Close()
+
+	gresps := s.client.GetMulti(keys)
+	c.Assert(gresps, HasLen, len(keys))
+	for _, key := range keys {
+		c.Assert(gresps[key].Error(), IsNil)
+		c.Assert(string(gresps[key].Value()), Equals, key)
+	}
+
+	responses = s.client.DeleteMulti(keys)
+	for _, resp := range responses {
+		c.Assert(resp.Error(), IsNil)
+	}
+	for _, key := range keys {
+		// The closed server still holds its (unreachable) copies.
+		_, ok := s.servers[1].Item(key)
+		if ok {
+			c.Assert(s.numCopies(key), Equals, 1)
+		} else {
+			c.Assert(s.numCopies(key), Equals, 0)
+		}
+	}
+}
+
+func (s *ReplicatedShardedClientSuite) TestCas(c *C) {
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: []byte("v1")})
+	c.Assert(resp.Error(), IsNil)
+
+	gresp := s.client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+
+	resp = s.client.Set(&memcache.Item{
+		Key:           "key",
+		Value:         []byte("v2"),
+		DataVersionId: gresp.DataVersionId(),
+	})
+	c.Assert(resp.Error(), IsNil)
+
+	// The other replica is invalidated.
+	replicas := s.replicas("key")
+	item, ok := replicas[0].Item("key")
+	c.Assert(ok, IsTrue)
+	c.Assert(string(item.Value), Equals, "v2")
+	_, ok = replicas[1].Item("key")
+	c.Assert(ok, IsFalse)
+
+	resp = s.client.Set(&memcache.Item{
+		Key:           "key",
+		Value:         []byte("v3"),
+		DataVersionId: gresp.DataVersionId(),
+	})
+	c.Assert(resp.Status(), Equals, memcache.StatusKeyExists)
+}
+
+func (s *ReplicatedShardedClientSuite) TestHealthTracking(c *C) {
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+
+	primary := s.replicas("key")[0]
+	primary.InjectFault(memcachetest.Fault{Command: "get", Disconnect: true})
+
+	for i := 0; i < 3; i++ {
+		gresp := s.client.Get("key")
+		c.Assert(gresp.Error(), IsNil)
+	}
+
+	// The primary is now unhealthy, and is no longer tried first.
+	primary.ClearFaults()
+	c.Assert(s.client.Get("key").Error(), IsNil)
+	c.Assert(primary.Stat("cmd_get"), Equals, uint64(0))
+
+	// Once the unhealthy duration elapses, the primary is tried again.
+	s.clock.Advance(11 * time.Second)
+	c.Assert(s.client.Get("key").Error(), IsNil)
+	c.Assert(primary.Stat("cmd_get"), Equals, uint64(1))
+}
+
+func (s *ReplicatedShardedClientSuite) TestHedging(c *C) {
+	client := s.newClient(
+		memcache.ReplicationOptions{HedgingDelay: 10 * time.Millisecond})
+
+	resp := client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+
+	primary := s.replicas("key")[0]
+	primary.InjectFault(
+		memcachetest.Fault{Command: "get", Delay: time.Second, Count: 1})
+
+	// The mock clock does not advance on its own.
+	go func() {
+		for i := 0; i < 100 && s.clock.WakeupsCount() == 0; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		s.clock.Advance(10 * time.Millisecond)
+	}()
+
+	start := time.Now()
+	gresp := client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(string(gresp.Value()), Equals, "value")
+	c.Assert(time.Since(start) < 500*time.Millisecond, IsTrue)
+}
+
+func (s *ReplicatedShardedClientSuite) TestInactiveShards(c *C) {
+	states := []memcache.ShardState{}
+	for _, server := range s.servers {
+		states = append(
+			states,
+			memcache.ShardState{
+				Address: server.Addr(),
+				State:   memcache.DownServer,
+			})
+	}
+	s.manager.UpdateShardStates(states)
+
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.numCopies("key"), Equals, 0)
+
+	gresp := s.client.Get("key")
+	c.Assert(gresp.Error(), IsNil)
+	c.Assert(gresp.Status(), Equals, memcache.StatusKeyNotFound)
+}
+
+// Returns the total of the per-address get counter across the servers.
+func (s *ReplicatedShardedClientSuite) getCount(name string) int64 {
+	counters := expvar.Get(name).(*expvar.Map)
+
+	total := int64(0)
+	for _, server := range s.servers {
+		if count, ok := counters.Get(server.Addr()).(*expvar.Int); ok {
+			total += count.Value()
+		}
+	}
+	return total
+}
+
+func (s *ReplicatedShardedClientSuite) TestGetCountersByAddr(c *C) {
+	okName := "ShardedClientGetOkByAddrCounter"
+	errName := "ShardedClientGetErrByAddrCounter"
+	numOk := s.getCount(okName)
+	numErr := s.getCount(errName)
+
+	// Mutations are not counted.
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(s.client.Delete("other").Error(), NotNil)
+	c.Assert(s.getCount(okName), Equals, numOk)
+
+	c.Assert(s.client.Get("key").Error(), IsNil)
+	c.Assert(s.getCount(okName), Equals, numOk+1)
+
+	responses := s.client.GetMulti([]string{"key"})
+	c.Assert(responses["key"].Error(), IsNil)
+	c.Assert(s.getCount(okName), Equals, numOk+2)
+	c.Assert(s.getCount(errName), Equals, numErr)
+}
+
+func (s *ReplicatedShardedClientSuite) TestStats(c *C) {
+	factory := newRecordingStatsFactory()
+	client := s.newClient(memcache.ReplicationOptions{StatsFactory: factory})
+
+	total := func(metric string, op string) float64 {
+		sum := 0.0
+		for _, server := range s.servers {
+			tags := map[string]string{"address": server.Addr()}
+			if op != "" {
+				tags["op"] = op
+			}
+			sum += factory.get(metric, tags)
+		}
+		return sum
+	}
+
+	resp := client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(client.Get("key").Error(), IsNil)
+	c.Assert(client.Get("missing").Error(), IsNil)
+
+	// Each replica request is recorded.
+	c.Assert(total("memcache_op_latency_ms", "set"), Equals, 2.0)
+	c.Assert(total("memcache_op_latency_ms", "get"), Equals, 2.0)
+	c.Assert(total("memcache_op_errors", "get"), Equals, 0.0)
+	c.Assert(total("memcache_get_hits", ""), Equals, 1.0)
+	c.Assert(total("memcache_get_misses", ""), Equals, 1.0)
+	c.Assert(total("memcache_bytes_sent", "") > 0, IsTrue)
+	c.Assert(total("memcache_bytes_received", "") > 0, IsTrue)
+
+	c.Assert(client.Delete("missing").Error(), NotNil)
+	c.Assert(total("memcache_op_errors", "delete"), Equals, 2.0)
+}
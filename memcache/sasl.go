@@ -0,0 +1,102 @@
+package memcache
+
+import (
+	"net"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+)
+
+const saslMechanismPlain = "PLAIN"
+
+// memcached rejects unauthenticated requests with statusSASLAuthError.
+func normalizeSASLStatus(status ResponseStatus) ResponseStatus {
+	switch status {
+	case statusSASLAuthError:
+		return StatusAuthenticationError
+	case statusSASLAuthContinue:
+		return StatusAuthenticationContinue
+	}
+	return status
+}
+
+// This returns the SASL mechanisms supported by the server.
+func (c *RawBinaryClient) SASLMechanisms() ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.sendRequest(opSASLListMechs, 0, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	status, _, _, value, err := c.receiveResponse(opSASLListMechs)
+	if err != nil {
+		return nil, err
+	}
+	if status != StatusNoError {
+		return nil, NewStatusCodeError(status)
+	}
+
+	return strings.Fields(string(value)), nil
+}
+
+// This authenticates the connection using the SASL PLAIN mechanism.  The
+// response's status is StatusAuthenticationError when the server rejects the
+// credentials.  NOTE: PLAIN sends the password in clear text; use TLS (see
+// net2.ConnectionOptions.TLSConfig) when the network is untrusted.
+func (c *RawBinaryClient) AuthenticatePlain(
+	username string,
+	password string) Response {
+
+	// PLAIN's message is: authzid NUL authcid NUL passwd, with an empty
+	// authzid.
+	value := make([]byte, 0, len(username)+len(password)+2)
+	value = append(value, 0)
+	value = append(value, username...)
+	value = append(value, 0)
+	value = append(value, password...)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.sendRequest(opSASLAuth, 0, []byte(saslMechanismPlain), value)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	status, _, _, _, err := c.receiveResponse(opSASLAuth)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+	return NewResponse(status)
+}
+
+// This returns a net2.ConnectionOptions.OnConnect function which
+// authenticates new (binary protocol) connections using the SASL PLAIN
+// mechanism, e.g.,
+//
+//	options := net2.ConnectionOptions{
+//	    TLSConfig: &tls.Config{},
+//	    OnConnect: memcache.NewSASLPlainAuthenticator(username, password),
+//	}
+//
+// Since connections are authenticated once when they are dialed, the
+// authentication cost is amortized across the pooled connections' requests.
+func NewSASLPlainAuthenticator(
+	username string,
+	password string) func(conn net.Conn) error {
+
+	return func(conn net.Conn) error {
+		client := NewRawBinaryClient(0, conn).(*RawBinaryClient)
+
+		resp := client.AuthenticatePlain(username, password)
+		if err := resp.Error(); err != nil {
+			return errors.Wrapf(
+				err,
+				"Failed to authenticate memcache connection to %s",
+				conn.RemoteAddr())
+		}
+		return nil
+	}
+}
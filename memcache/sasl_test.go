@@ -0,0 +1,106 @@
+package memcache_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/memcache/memcachetest"
+	"github.com/dropbox/godropbox/net2"
+	"github.com/dropbox/godropbox/net2/http2/test_utils"
+)
+
+type SASLSuite struct {
+	server *memcachetest.Server
+}
+
+var _ = Suite(&SASLSuite{})
+
+func (s *SASLSuite) TearDownTest(c *C) {
+	if s.server != nil {
+		_ = s.server.Close()
+		s.server = nil
+	}
+}
+
+func (s *SASLSuite) startServer(c *C, options memcachetest.Options) {
+	options.SASLUsername = "user"
+	options.SASLPassword = "secret"
+
+	var err error
+	s.server, err = memcachetest.NewServer(options)
+	c.Assert(err, IsNil)
+}
+
+func (s *SASLSuite) dial(c *C) *memcache.RawBinaryClient {
+	conn, err := net.Dial("tcp", s.server.Addr())
+	c.Assert(err, IsNil)
+	return memcache.NewRawBinaryClient(0, conn).(*memcache.RawBinaryClient)
+}
+
+func (s *SASLSuite) TestAuthenticatePlain(c *C) {
+	s.startServer(c, memcachetest.Options{})
+
+	client := s.dial(c)
+
+	mechs, err := client.SASLMechanisms()
+	c.Assert(err, IsNil)
+	c.Assert(mechs, DeepEquals, []string{"PLAIN"})
+
+	// Unauthenticated requests are rejected.
+	resp := client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Status(), Equals, memcache.StatusAuthenticationError)
+	c.Assert(resp.Error(), NotNil)
+
+	authResp := client.AuthenticatePlain("user", "wrong")
+	c.Assert(authResp.Status(), Equals, memcache.StatusAuthenticationError)
+	c.Assert(authResp.Error(), NotNil)
+
+	authResp = client.AuthenticatePlain("user", "secret")
+	c.Assert(authResp.Error(), IsNil)
+
+	resp = client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(string(client.Get("key").Value()), Equals, "value")
+}
+
+func (s *SASLSuite) TestShardedClientWithTLS(c *C) {
+	certPem, keyPem, err := test_utils.GenerateSelfSignedCert("127.0.0.1")
+	c.Assert(err, IsNil)
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	c.Assert(err, IsNil)
+
+	s.startServer(c, memcachetest.Options{
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	})
+
+	roots := x509.NewCertPool()
+	c.Assert(roots.AppendCertsFromPEM(certPem), IsTrue)
+
+	newClient := func(password string) memcache.Client {
+		manager := memcache.NewStaticShardManager(
+			[]string{s.server.Addr()},
+			func(key string, numShard int) int { return 0 },
+			net2.ConnectionOptions{
+				MaxActiveConnections: 10,
+				TLSConfig:            &tls.Config{RootCAs: roots},
+				OnConnect: memcache.NewSASLPlainAuthenticator(
+					"user",
+					password),
+			})
+		return memcache.NewShardedClient(manager, memcache.NewRawBinaryClient)
+	}
+
+	client := newClient("secret")
+	resp := client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+	c.Assert(string(client.Get("key").Value()), Equals, "value")
+
+	// Connections which fail to authenticate are never handed out.
+	client = newClient("wrong")
+	c.Assert(client.Get("key").Error(), NotNil)
+}
@@ -0,0 +1,256 @@
+package memcache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/dropbox/godropbox/murmur3"
+)
+
+// Decides which of the migrating keys (i.e., keys whose target shard is on a
+// different server) are dual-read and shadow-written.  Keys selected for dual
+// reads are always shadow-written, since otherwise the target shard could
+// serve stale values.
+type MigrationPolicy interface {
+	DualRead(key string) bool
+	ShadowWrite(key string) bool
+}
+
+// A MigrationPolicy which selects a fixed percentage of the migrating keys,
+// by key hash.  Since the selection is consistent, raising the percentages
+// only adds keys to the selection.
+type PercentageMigrationPolicy struct {
+	// The percentage (0 - 100) of migrating keys which are shadow-written.
+	ShadowWritePercent float64
+
+	// The percentage (0 - 100) of migrating keys which are dual-read.
+	DualReadPercent float64
+}
+
+func keyPercentile(key string) float64 {
+	return float64(murmur3.Hash32([]byte(key), 0)%10000) / 100
+}
+
+// See MigrationPolicy interface for documentation.
+func (p PercentageMigrationPolicy) DualRead(key string) bool {
+	return keyPercentile(key) < p.DualReadPercent
+}
+
+// See MigrationPolicy interface for documentation.
+func (p PercentageMigrationPolicy) ShadowWrite(key string) bool {
+	percentile := keyPercentile(key)
+	return percentile < p.ShadowWritePercent ||
+		percentile < p.DualReadPercent
+}
+
+// A migration's progress report.
+type MigrationProgress struct {
+	InProgress bool
+	StartTime  time.Time
+
+	// The number of dual reads served by the target shards (hits), and the
+	// number of dual reads which fell back to the current shards (misses).
+	DualReadHits   uint64
+	DualReadMisses uint64
+
+	// The number of successful / failed shadow writes.
+	ShadowWrites      uint64
+	ShadowWriteErrors uint64
+}
+
+// This returns the fraction of dual reads served by the target shards, i.e.,
+// how warm the target shards are.
+func (p MigrationProgress) DualReadHitRate() float64 {
+	total := p.DualReadHits + p.DualReadMisses
+	if total == 0 {
+		return 0
+	}
+	return float64(p.DualReadHits) / float64(total)
+}
+
+type shardMigration struct {
+	// Shares the migrating shard manager's connection pool.
+	target *BaseShardManager
+
+	policy    MigrationPolicy
+	startTime time.Time
+
+	// Updated atomically.
+	dualReadHits      uint64
+	dualReadMisses    uint64
+	shadowWrites      uint64
+	shadowWriteErrors uint64
+}
+
+// This starts migrating keys to the target shard states.  While migrating,
+// MigratingShardedClient shadows writes to (and dual-reads from) the target
+// shards, as selected by the policy.  targetShardFunc maps keys to target
+// shards; when nil, the manager's shard function is used (NOTE: this is only
+// valid for stateless shard functions, e.g., StaticShardManager's).
+//
+// A typical migration shadows writes for at least the items' expiration
+// time, then ramps up dual reads (via SetMigrationPolicy) while watching
+// MigrationProgress, and finally switches over by calling UpdateShardStates
+// with the target shard states, followed by StopMigration.
+func (m *BaseShardManager) StartMigration(
+	targetStates []ShardState,
+	targetShardFunc func(key string, numShard int) (shard int),
+	policy MigrationPolicy) error {
+
+	if policy == nil {
+		return errors.New("Migration policy is nil")
+	}
+	if targetShardFunc == nil {
+		targetShardFunc = m.getShardId
+	}
+
+	m.rwMutex.Lock()
+	defer m.rwMutex.Unlock()
+
+	if m.migration != nil {
+		return errors.New("Memcache shard migration is already in progress")
+	}
+
+	oldAddrs := m.registeredAddrsLocked()
+	m.migration = &shardMigration{
+		target: &BaseShardManager{
+			getShardId:  targetShardFunc,
+			pool:        m.pool,
+			shardStates: targetStates,
+			logError:    m.logError,
+			logInfo:     m.logInfo,
+			stats:       m.stats,
+		},
+		policy:    policy,
+		startTime: time.Now(),
+	}
+	m.updateRegisteredAddrsLocked(oldAddrs)
+
+	return nil
+}
+
+// This replaces the policy of the migration in progress.
+func (m *BaseShardManager) SetMigrationPolicy(policy MigrationPolicy) error {
+	if policy == nil {
+		return errors.New("Migration policy is nil")
+	}
+
+	m.rwMutex.Lock()
+	defer m.rwMutex.Unlock()
+
+	if m.migration == nil {
+		return errors.New("No memcache shard migration in progress")
+	}
+	m.migration.policy = policy
+	return nil
+}
+
+// This stops the migration in progress (if any), and unregisters the target
+// shards' addresses which are no longer in use.
+func (m *BaseShardManager) StopMigration() {
+	m.rwMutex.Lock()
+	defer m.rwMutex.Unlock()
+
+	if m.migration == nil {
+		return
+	}
+
+	oldAddrs := m.registeredAddrsLocked()
+	m.migration = nil
+	m.updateRegisteredAddrsLocked(oldAddrs)
+}
+
+// This returns the progress report of the migration in progress.
+func (m *BaseShardManager) MigrationProgress() MigrationProgress {
+	m.rwMutex.RLock()
+	defer m.rwMutex.RUnlock()
+
+	migration := m.migration
+	if migration == nil {
+		return MigrationProgress{}
+	}
+
+	return MigrationProgress{
+		InProgress:        true,
+		StartTime:         migration.startTime,
+		DualReadHits:      atomic.LoadUint64(&migration.dualReadHits),
+		DualReadMisses:    atomic.LoadUint64(&migration.dualReadMisses),
+		ShadowWrites:      atomic.LoadUint64(&migration.shadowWrites),
+		ShadowWriteErrors: atomic.LoadUint64(&migration.shadowWriteErrors),
+	}
+}
+
+// See MigratingShardManager interface for documentation.
+func (m *BaseShardManager) GetMigrationTarget() ShardManager {
+	m.rwMutex.RLock()
+	defer m.rwMutex.RUnlock()
+
+	if m.migration == nil {
+		return nil
+	}
+	return m.migration.target
+}
+
+// This method assumes that m.rwMutex is locked.
+func (m *BaseShardManager) shardAddressLocked(
+	getShardId func(key string, numShard int) int,
+	shardStates []ShardState,
+	key string) string {
+
+	shardId := getShardId(key, len(shardStates))
+	if shardId < 0 || shardId >= len(shardStates) {
+		return ""
+	}
+	return shardStates[shardId].Address
+}
+
+// See MigratingShardManager interface for documentation.
+func (m *BaseShardManager) GetMigrationRoute(
+	key string) (
+	dualRead bool,
+	shadowWrite bool) {
+
+	m.rwMutex.RLock()
+	defer m.rwMutex.RUnlock()
+
+	migration := m.migration
+	if migration == nil {
+		return false, false
+	}
+
+	current := m.shardAddressLocked(m.getShardId, m.shardStates, key)
+	target := m.shardAddressLocked(
+		migration.target.getShardId,
+		migration.target.shardStates,
+		key)
+	if target == "" || current == target {
+		return false, false
+	}
+
+	dualRead = migration.policy.DualRead(key)
+	shadowWrite = dualRead || migration.policy.ShadowWrite(key)
+	return dualRead, shadowWrite
+}
+
+// See MigratingShardManager interface for documentation.
+func (m *BaseShardManager) RecordDualReads(hits int, misses int) {
+	m.rwMutex.RLock()
+	defer m.rwMutex.RUnlock()
+
+	if m.migration != nil {
+		atomic.AddUint64(&m.migration.dualReadHits, uint64(hits))
+		atomic.AddUint64(&m.migration.dualReadMisses, uint64(misses))
+	}
+}
+
+// See MigratingShardManager interface for documentation.
+func (m *BaseShardManager) RecordShadowWrites(successes int, failures int) {
+	m.rwMutex.RLock()
+	defer m.rwMutex.RUnlock()
+
+	if m.migration != nil {
+		atomic.AddUint64(&m.migration.shadowWrites, uint64(successes))
+		atomic.AddUint64(&m.migration.shadowWriteErrors, uint64(failures))
+	}
+}
@@ -0,0 +1,167 @@
+package memcache
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/dropbox/godropbox/container/set"
+	. "github.com/dropbox/godropbox/gocheck2"
+)
+
+type ShardMigrationSuite struct {
+	manager *BaseShardManager
+	pool    *mockPool
+}
+
+var _ = Suite(&ShardMigrationSuite{})
+
+func (s *ShardMigrationSuite) SetUpTest(c *C) {
+	s.pool = newMockPool()
+	s.manager = &BaseShardManager{}
+	s.manager.InitWithPool(
+		func(key string, numShard int) int {
+			if key == "unmapped" {
+				return -1
+			}
+			return len(key) % numShard
+		},
+		func(err error) {},
+		func(v ...interface{}) {},
+		s.pool)
+
+	s.manager.UpdateShardStates([]ShardState{
+		{Address: "a", State: ActiveServer},
+		{Address: "b", State: ActiveServer},
+	})
+}
+
+func (s *ShardMigrationSuite) TestRegistration(c *C) {
+	err := s.manager.StartMigration(
+		[]ShardState{
+			{Address: "a", State: ActiveServer},
+			{Address: "c", State: ActiveServer},
+		},
+		nil,
+		PercentageMigrationPolicy{ShadowWritePercent: 100})
+	c.Assert(err, IsNil)
+	c.Assert(set.NewSet("a", "b", "c").IsEqual(s.pool.registered), IsTrue)
+
+	// Only one migration at a time.
+	err = s.manager.StartMigration(
+		nil,
+		nil,
+		PercentageMigrationPolicy{})
+	c.Assert(err, NotNil)
+
+	// Switch over.
+	s.manager.UpdateShardStates([]ShardState{
+		{Address: "a", State: ActiveServer},
+		{Address: "c", State: ActiveServer},
+	})
+	c.Assert(set.NewSet("a", "c").IsEqual(s.pool.registered), IsTrue)
+
+	s.manager.StopMigration()
+	c.Assert(set.NewSet("a", "c").IsEqual(s.pool.registered), IsTrue)
+	c.Assert(s.manager.GetMigrationTarget(), IsNil)
+	c.Assert(s.manager.MigrationProgress().InProgress, IsFalse)
+}
+
+func (s *ShardMigrationSuite) TestAbort(c *C) {
+	err := s.manager.StartMigration(
+		[]ShardState{{Address: "c", State: ActiveServer}},
+		nil,
+		PercentageMigrationPolicy{ShadowWritePercent: 100})
+	c.Assert(err, IsNil)
+	c.Assert(set.NewSet("a", "b", "c").IsEqual(s.pool.registered), IsTrue)
+
+	s.manager.StopMigration()
+	c.Assert(set.NewSet("a", "b").IsEqual(s.pool.registered), IsTrue)
+}
+
+func (s *ShardMigrationSuite) TestRoute(c *C) {
+	dualRead, shadowWrite := s.manager.GetMigrationRoute("k")
+	c.Assert(dualRead, IsFalse)
+	c.Assert(shadowWrite, IsFalse)
+
+	err := s.manager.StartMigration(
+		[]ShardState{
+			{Address: "a", State: ActiveServer},
+			{Address: "c", State: ActiveServer},
+		},
+		nil,
+		PercentageMigrationPolicy{ShadowWritePercent: 100})
+	c.Assert(err, IsNil)
+
+	// "k" moves from b to c.
+	dualRead, shadowWrite = s.manager.GetMigrationRoute("k")
+	c.Assert(dualRead, IsFalse)
+	c.Assert(shadowWrite, IsTrue)
+
+	// "kk" stays on a.
+	dualRead, shadowWrite = s.manager.GetMigrationRoute("kk")
+	c.Assert(dualRead, IsFalse)
+	c.Assert(shadowWrite, IsFalse)
+
+	dualRead, shadowWrite = s.manager.GetMigrationRoute("unmapped")
+	c.Assert(dualRead, IsFalse)
+	c.Assert(shadowWrite, IsFalse)
+
+	// Dual reads imply shadow writes.
+	err = s.manager.SetMigrationPolicy(
+		PercentageMigrationPolicy{DualReadPercent: 100})
+	c.Assert(err, IsNil)
+	dualRead, shadowWrite = s.manager.GetMigrationRoute("k")
+	c.Assert(dualRead, IsTrue)
+	c.Assert(shadowWrite, IsTrue)
+
+	err = s.manager.SetMigrationPolicy(PercentageMigrationPolicy{})
+	c.Assert(err, IsNil)
+	dualRead, shadowWrite = s.manager.GetMigrationRoute("k")
+	c.Assert(dualRead, IsFalse)
+	c.Assert(shadowWrite, IsFalse)
+}
+
+func (s *ShardMigrationSuite) TestPercentagePolicy(c *C) {
+	policy := PercentageMigrationPolicy{
+		ShadowWritePercent: 50,
+		DualReadPercent:    10,
+	}
+
+	numShadowWrites := 0
+	numDualReads := 0
+	for i := 0; i < 1000; i++ {
+		key := string(rune('a'+i%26)) + string(rune('a'+i/26))
+		if policy.ShadowWrite(key) {
+			numShadowWrites++
+		}
+		if policy.DualRead(key) {
+			numDualReads++
+			c.Assert(policy.ShadowWrite(key), IsTrue)
+		}
+	}
+	c.Assert(numShadowWrites > 350 && numShadowWrites < 650, IsTrue)
+	c.Assert(numDualReads > 30 && numDualReads < 200, IsTrue)
+}
+
+func (s *ShardMigrationSuite) TestProgress(c *C) {
+	// Not recorded when there is no migration in progress.
+	s.manager.RecordDualReads(1, 1)
+	c.Assert(s.manager.MigrationProgress(), Equals, MigrationProgress{})
+
+	err := s.manager.StartMigration(
+		[]ShardState{{Address: "c", State: ActiveServer}},
+		nil,
+		PercentageMigrationPolicy{})
+	c.Assert(err, IsNil)
+
+	s.manager.RecordDualReads(3, 1)
+	s.manager.RecordShadowWrites(5, 2)
+
+	progress := s.manager.MigrationProgress()
+	c.Assert(progress.InProgress, IsTrue)
+	c.Assert(progress.StartTime.IsZero(), IsFalse)
+	c.Assert(progress.DualReadHits, Equals, uint64(3))
+	c.Assert(progress.DualReadMisses, Equals, uint64(1))
+	c.Assert(progress.DualReadHitRate(), Equals, 0.75)
+	c.Assert(progress.ShadowWrites, Equals, uint64(5))
+	c.Assert(progress.ShadowWriteErrors, Equals, uint64(2))
+}
@@ -1,17 +1,30 @@
 package memcache
 
 import (
+	"context"
 	"expvar"
+	"time"
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/dropbox/godropbox/net2"
+	"github.com/dropbox/godropbox/stats"
 )
 
+// Options for configuring ShardedClient.
+type ShardedClientOptions struct {
+	// Optional.  When set, the client records per address (and per
+	// operation) latency summaries, error counters, get hit / miss counters,
+	// and bytes sent / received counters.
+	StatsFactory stats.StatsFactory
+}
+
 // A sharded memcache client implementation where sharding management is
 // handled by the provided ShardManager.
 type ShardedClient struct {
 	manager ShardManager
 	builder ClientShardBuilder
+
+	stats *statsCache // nil when stats are disabled
 }
 
 var (
@@ -31,6 +44,71 @@ func NewShardedClient(
 	}
 }
 
+// This creates a new ShardedClient with the given options.
+func NewShardedClientWithOptions(
+	manager ShardManager,
+	builder ClientShardBuilder,
+	options ShardedClientOptions) Client {
+
+	return &ShardedClient{
+		manager: manager,
+		builder: builder,
+		stats:   newStatsCache(options.StatsFactory),
+	}
+}
+
+// Builds a shard client for the connection.  When stats are enabled, the
+// connection is wrapped to count the bytes sent / received.
+func (c *ShardedClient) newShardClient(
+	shard int,
+	conn net2.ManagedConn) ClientShard {
+
+	if c.stats == nil {
+		return c.builder(shard, conn)
+	}
+
+	address := conn.Key().Address
+	return c.builder(
+		shard,
+		&countingReadWriter{
+			ReadWriter: conn,
+			sent:       c.stats.counter(bytesSentMetric, address, ""),
+			received:   c.stats.counter(bytesReceivedMetric, address, ""),
+		})
+}
+
+// Records the operation's latency, and whether the operation failed.
+func (c *ShardedClient) recordOp(
+	op string,
+	conn net2.ManagedConn,
+	start time.Time,
+	failed bool) {
+
+	if c.stats == nil {
+		return
+	}
+
+	address := conn.Key().Address
+	c.stats.observeLatency(opLatencyMsMetric, address, op, start)
+	if failed {
+		c.stats.counter(opErrorsMetric, address, op).Inc()
+	}
+}
+
+// Records the get response's hit / miss.
+func (c *ShardedClient) recordGet(conn net2.ManagedConn, resp GetResponse) {
+	if c.stats == nil || resp.Error() != nil {
+		return
+	}
+
+	switch resp.Status() {
+	case StatusNoError:
+		c.stats.counter(getHitsMetric, conn.Key().Address, "").Inc()
+	case StatusKeyNotFound:
+		c.stats.counter(getMissesMetric, conn.Key().Address, "").Inc()
+	}
+}
+
 func (c *ShardedClient) release(rawClient ClientShard, conn net2.ManagedConn) {
 	if rawClient.IsValidState() {
 		_ = conn.ReleaseConnection()
@@ -55,6 +133,29 @@ func (c *ShardedClient) connectionError(shard int, err error) error {
 
 // See Client interface for documentation.
 func (c *ShardedClient) Get(key string) GetResponse {
+	return c.get(
+		"get",
+		key,
+		func(shardClient Client) GetResponse {
+			return shardClient.Get(key)
+		})
+}
+
+// See Client interface for documentation.
+func (c *ShardedClient) GetAndTouch(key string, expiration uint32) GetResponse {
+	return c.get(
+		"get_and_touch",
+		key,
+		func(shardClient Client) GetResponse {
+			return shardClient.GetAndTouch(key, expiration)
+		})
+}
+
+func (c *ShardedClient) get(
+	op string,
+	key string,
+	getFunc func(Client) GetResponse) GetResponse {
+
 	shard, conn, err := c.manager.GetShard(key)
 	if shard == -1 {
 		return NewGetErrorResponse(key, c.unmappedError(key))
@@ -67,10 +168,14 @@ func (c *ShardedClient) Get(key string) GetResponse {
 		return NewGetResponse(key, StatusKeyNotFound, 0, nil, 0)
 	}
 
-	client := c.builder(shard, conn)
+	client := c.newShardClient(shard, conn)
 	defer c.release(client, conn)
 
-	result := client.Get(key)
+	start := time.Now()
+	result := getFunc(client)
+	c.recordOp(op, conn, start, result.Error() != nil || !client.IsValidState())
+	c.recordGet(conn, result)
+
 	if client.IsValidState() {
 		getOkByAddr.Add(conn.Key().Address, 1)
 	} else {
@@ -80,6 +185,8 @@ func (c *ShardedClient) Get(key string) GetResponse {
 }
 
 func (c *ShardedClient) getMultiHelper(
+	op string,
+	getMultiFunc func(Client, []string) map[string]GetResponse,
 	shard int,
 	conn net2.ManagedConn,
 	connErr error,
@@ -106,10 +213,19 @@ func (c *ShardedClient) getMultiHelper(
 			results[key] = NewGetResponse(key, StatusKeyNotFound, 0, nil, 0)
 		}
 	} else {
-		client := c.builder(shard, conn)
+		client := c.newShardClient(shard, conn)
 		defer c.release(client, conn)
 
-		results = client.GetMulti(keys)
+		start := time.Now()
+		results = getMultiFunc(client, keys)
+
+		failed := !client.IsValidState()
+		for _, resp := range results {
+			failed = failed || resp.Error() != nil
+			c.recordGet(conn, resp)
+		}
+		c.recordOp(op, conn, start, failed)
+
 		if client.IsValidState() {
 			getOkByAddr.Add(conn.Key().Address, 1)
 		} else {
@@ -119,20 +235,57 @@ func (c *ShardedClient) getMultiHelper(
 	resultsChannel <- results
 }
 
+// A helper used to specify a GetMulti operation on a shard client.
+func getMultiGetter(shardClient Client, keys []string) map[string]GetResponse {
+	return shardClient.GetMulti(keys)
+}
+
 // See Client interface for documentation.
 func (c *ShardedClient) GetMulti(keys []string) map[string]GetResponse {
-	return c.getMulti(c.manager.GetShardsForKeys(keys))
+	return c.getMulti(
+		context.Background(),
+		"get_multi",
+		c.manager.GetShardsForKeys(keys),
+		getMultiGetter)
 }
 
 // See Client interface for documentation.
 func (c *ShardedClient) GetSentinels(keys []string) map[string]GetResponse {
-	return c.getMulti(c.manager.GetShardsForSentinelsFromKeys(keys))
+	return c.getMulti(
+		context.Background(),
+		"get_sentinels",
+		c.manager.GetShardsForSentinelsFromKeys(keys),
+		getMultiGetter)
 }
 
-func (c *ShardedClient) getMulti(shardMapping map[int]*ShardMapping) map[string]GetResponse {
+// See Client interface for documentation.
+func (c *ShardedClient) GetAndTouchMulti(
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	return c.getMulti(
+		context.Background(),
+		"get_and_touch_multi",
+		c.manager.GetShardsForKeys(keys),
+		func(shardClient Client, keys []string) map[string]GetResponse {
+			return shardClient.GetAndTouchMulti(keys, expiration)
+		})
+}
+
+// NOTE: When the context is done, this returns without waiting for the
+// in-flight shard requests, and the remaining keys are populated with error
+// responses.
+func (c *ShardedClient) getMulti(
+	ctx context.Context,
+	op string,
+	shardMapping map[int]*ShardMapping,
+	getMultiFunc func(Client, []string) map[string]GetResponse) map[string]GetResponse {
+
 	resultsChannel := make(chan map[string]GetResponse, len(shardMapping))
 	for shard, mapping := range shardMapping {
 		go c.getMultiHelper(
+			op,
+			getMultiFunc,
 			shard,
 			mapping.Connection,
 			mapping.ConnErr,
@@ -142,14 +295,28 @@ func (c *ShardedClient) getMulti(shardMapping map[int]*ShardMapping) map[string]
 
 	results := make(map[string]GetResponse)
 	for i := 0; i < len(shardMapping); i++ {
-		for key, resp := range <-resultsChannel {
-			results[key] = resp
+		select {
+		case shardResults := <-resultsChannel:
+			for key, resp := range shardResults {
+				results[key] = resp
+			}
+		case <-ctx.Done():
+			err := errors.Wrap(ctx.Err(), "Memcache request interrupted")
+			for _, mapping := range shardMapping {
+				for _, key := range mapping.Keys {
+					if _, ok := results[key]; !ok {
+						results[key] = NewGetErrorResponse(key, err)
+					}
+				}
+			}
+			return results
 		}
 	}
 	return results
 }
 
 func (c *ShardedClient) mutate(
+	op string,
 	key string,
 	mutateFunc func(Client) MutateResponse) MutateResponse {
 	shard, conn, err := c.manager.GetShard(key)
@@ -164,15 +331,19 @@ func (c *ShardedClient) mutate(
 		return NewMutateResponse(key, StatusNoError, 0)
 	}
 
-	client := c.builder(shard, conn)
+	client := c.newShardClient(shard, conn)
 	defer c.release(client, conn)
 
-	return mutateFunc(client)
+	start := time.Now()
+	result := mutateFunc(client)
+	c.recordOp(op, conn, start, result.Error() != nil || !client.IsValidState())
+	return result
 }
 
 // See Client interface for documentation.
 func (c *ShardedClient) Set(item *Item) MutateResponse {
 	return c.mutate(
+		"set",
 		item.Key,
 		func(shardClient Client) MutateResponse {
 			return shardClient.Set(item)
@@ -180,6 +351,7 @@ func (c *ShardedClient) Set(item *Item) MutateResponse {
 }
 
 func (c *ShardedClient) mutateMultiHelper(
+	op string,
 	mutateMultiFunc func(Client, *ShardMapping) []MutateResponse,
 	shard int,
 	mapping *ShardMapping,
@@ -214,10 +386,17 @@ func (c *ShardedClient) mutateMultiHelper(
 				NewMutateResponse(key, StatusNoError, 0))
 		}
 	} else {
-		client := c.builder(shard, conn)
+		client := c.newShardClient(shard, conn)
 		defer c.release(client, conn)
 
+		start := time.Now()
 		results = mutateMultiFunc(client, mapping)
+
+		failed := !client.IsValidState()
+		for _, resp := range results {
+			failed = failed || resp.Error() != nil
+		}
+		c.recordOp(op, conn, start, failed)
 	}
 
 	// If server is warming up, we override all failures with success message.
@@ -232,8 +411,12 @@ func (c *ShardedClient) mutateMultiHelper(
 	resultsChannel <- results
 }
 
-// See Client interface for documentation.
+// NOTE: When the context is done, this returns without waiting for the
+// in-flight shard requests, and the remaining keys are populated with error
+// responses.
 func (c *ShardedClient) mutateMulti(
+	ctx context.Context,
+	op string,
 	shards map[int]*ShardMapping,
 	mutateMultiFunc func(Client, *ShardMapping) []MutateResponse) []MutateResponse {
 
@@ -243,6 +426,7 @@ func (c *ShardedClient) mutateMulti(
 	for shard, mapping := range shards {
 		numKeys += len(mapping.Keys)
 		go c.mutateMultiHelper(
+			op,
 			mutateMultiFunc,
 			shard,
 			mapping,
@@ -251,7 +435,27 @@ func (c *ShardedClient) mutateMulti(
 
 	results := make([]MutateResponse, 0, numKeys)
 	for i := 0; i < len(shards); i++ {
-		results = append(results, (<-resultsChannel)...)
+		select {
+		case shardResults := <-resultsChannel:
+			results = append(results, shardResults...)
+		case <-ctx.Done():
+			done := make(map[string]int)
+			for _, resp := range results {
+				done[resp.Key()]++
+			}
+
+			err := errors.Wrap(ctx.Err(), "Memcache request interrupted")
+			for _, mapping := range shards {
+				for _, key := range mapping.Keys {
+					if done[key] > 0 {
+						done[key]--
+						continue
+					}
+					results = append(results, NewMutateErrorResponse(key, err))
+				}
+			}
+			return results
+		}
 	}
 	return results
 }
@@ -268,27 +472,44 @@ func casMultiMutator(shardClient Client, mapping *ShardMapping) []MutateResponse
 
 // See Client interface for documentation.
 func (c *ShardedClient) SetMulti(items []*Item) []MutateResponse {
-	return c.mutateMulti(c.manager.GetShardsForItems(items), setMultiMutator)
+	return c.mutateMulti(
+		context.Background(),
+		"set_multi",
+		c.manager.GetShardsForItems(items),
+		setMultiMutator)
 }
 
 // See Client interface for documentation.
 func (c *ShardedClient) SetSentinels(items []*Item) []MutateResponse {
-	return c.mutateMulti(c.manager.GetShardsForSentinelsFromItems(items), setMultiMutator)
+	return c.mutateMulti(
+		context.Background(),
+		"set_sentinels",
+		c.manager.GetShardsForSentinelsFromItems(items),
+		setMultiMutator)
 }
 
 // See Client interface for documentation.
 func (c *ShardedClient) CasMulti(items []*Item) []MutateResponse {
-	return c.mutateMulti(c.manager.GetShardsForItems(items), casMultiMutator)
+	return c.mutateMulti(
+		context.Background(),
+		"cas_multi",
+		c.manager.GetShardsForItems(items),
+		casMultiMutator)
 }
 
 // See Client interface for documentation.
 func (c *ShardedClient) CasSentinels(items []*Item) []MutateResponse {
-	return c.mutateMulti(c.manager.GetShardsForSentinelsFromItems(items), casMultiMutator)
+	return c.mutateMulti(
+		context.Background(),
+		"cas_sentinels",
+		c.manager.GetShardsForSentinelsFromItems(items),
+		casMultiMutator)
 }
 
 // See Client interface for documentation.
 func (c *ShardedClient) Add(item *Item) MutateResponse {
 	return c.mutate(
+		"add",
 		item.Key,
 		func(shardClient Client) MutateResponse {
 			return shardClient.Add(item)
@@ -302,12 +523,17 @@ func addMultiMutator(shardClient Client, mapping *ShardMapping) []MutateResponse
 
 // See Client interface for documentation.
 func (c *ShardedClient) AddMulti(items []*Item) []MutateResponse {
-	return c.mutateMulti(c.manager.GetShardsForItems(items), addMultiMutator)
+	return c.mutateMulti(
+		context.Background(),
+		"add_multi",
+		c.manager.GetShardsForItems(items),
+		addMultiMutator)
 }
 
 // See Client interface for documentation.
 func (c *ShardedClient) Replace(item *Item) MutateResponse {
 	return c.mutate(
+		"replace",
 		item.Key,
 		func(shardClient Client) MutateResponse {
 			return shardClient.Replace(item)
@@ -317,6 +543,7 @@ func (c *ShardedClient) Replace(item *Item) MutateResponse {
 // See Client interface for documentation.
 func (c *ShardedClient) Delete(key string) MutateResponse {
 	return c.mutate(
+		"delete",
 		key,
 		func(shardClient Client) MutateResponse {
 			return shardClient.Delete(key)
@@ -330,12 +557,17 @@ func deleteMultiMutator(shardClient Client, mapping *ShardMapping) []MutateRespo
 
 // See Client interface for documentation.
 func (c *ShardedClient) DeleteMulti(keys []string) []MutateResponse {
-	return c.mutateMulti(c.manager.GetShardsForKeys(keys), deleteMultiMutator)
+	return c.mutateMulti(
+		context.Background(),
+		"delete_multi",
+		c.manager.GetShardsForKeys(keys),
+		deleteMultiMutator)
 }
 
 // See Client interface for documentation.
 func (c *ShardedClient) Append(key string, value []byte) MutateResponse {
 	return c.mutate(
+		"append",
 		key,
 		func(shardClient Client) MutateResponse {
 			return shardClient.Append(key, value)
@@ -345,13 +577,25 @@ func (c *ShardedClient) Append(key string, value []byte) MutateResponse {
 // See Client interface for documentation.
 func (c *ShardedClient) Prepend(key string, value []byte) MutateResponse {
 	return c.mutate(
+		"prepend",
 		key,
 		func(shardClient Client) MutateResponse {
 			return shardClient.Prepend(key, value)
 		})
 }
 
+// See Client interface for documentation.
+func (c *ShardedClient) Touch(key string, expiration uint32) MutateResponse {
+	return c.mutate(
+		"touch",
+		key,
+		func(shardClient Client) MutateResponse {
+			return shardClient.Touch(key, expiration)
+		})
+}
+
 func (c *ShardedClient) count(
+	op string,
 	key string,
 	countFunc func(Client) CountResponse) CountResponse {
 	shard, conn, err := c.manager.GetShard(key)
@@ -365,10 +609,13 @@ func (c *ShardedClient) count(
 		return NewCountResponse(key, StatusNoError, 0)
 	}
 
-	client := c.builder(shard, conn)
+	client := c.newShardClient(shard, conn)
 	defer c.release(client, conn)
 
-	return countFunc(client)
+	start := time.Now()
+	result := countFunc(client)
+	c.recordOp(op, conn, start, result.Error() != nil || !client.IsValidState())
+	return result
 }
 
 // See Client interface for documentation.
@@ -379,6 +626,7 @@ func (c *ShardedClient) Increment(
 	expiration uint32) CountResponse {
 
 	return c.count(
+		"increment",
 		key,
 		func(shardClient Client) CountResponse {
 			return shardClient.Increment(key, delta, initValue, expiration)
@@ -393,6 +641,7 @@ func (c *ShardedClient) Decrement(
 	expiration uint32) CountResponse {
 
 	return c.count(
+		"decrement",
 		key,
 		func(shardClient Client) CountResponse {
 			return shardClient.Decrement(key, delta, initValue, expiration)
@@ -407,10 +656,13 @@ func (c *ShardedClient) flushHelper(
 	if conn == nil {
 		return NewErrorResponse(c.connectionError(shard, nil))
 	}
-	client := c.builder(shard, conn)
+	client := c.newShardClient(shard, conn)
 	defer c.release(client, conn)
 
-	return client.Flush(expiration)
+	start := time.Now()
+	result := client.Flush(expiration)
+	c.recordOp("flush", conn, start, result.Error() != nil || !client.IsValidState())
+	return result
 }
 
 // See Client interface for documentation.
@@ -444,10 +696,13 @@ func (c *ShardedClient) statHelper(
 			c.connectionError(shard, nil),
 			make(map[int](map[string]string)))
 	}
-	client := c.builder(shard, conn)
+	client := c.newShardClient(shard, conn)
 	defer c.release(client, conn)
 
-	return client.Stat(statsKey)
+	start := time.Now()
+	result := client.Stat(statsKey)
+	c.recordOp("stat", conn, start, result.Error() != nil || !client.IsValidState())
+	return result
 }
 
 // See Client interface for documentation.
@@ -486,10 +741,13 @@ func (c *ShardedClient) versionHelper(
 			c.connectionError(shard, nil),
 			make(map[int]string))
 	}
-	client := c.builder(shard, conn)
+	client := c.newShardClient(shard, conn)
 	defer c.release(client, conn)
 
-	return client.Version()
+	start := time.Now()
+	result := client.Version()
+	c.recordOp("version", conn, start, result.Error() != nil || !client.IsValidState())
+	return result
 }
 
 // See Client interface for documentation.
@@ -529,10 +787,13 @@ func (c *ShardedClient) verbosityHelper(
 	if conn == nil {
 		return NewErrorResponse(c.connectionError(shard, nil))
 	}
-	client := c.builder(shard, conn)
+	client := c.newShardClient(shard, conn)
 	defer c.release(client, conn)
 
-	return client.Verbosity(verbosity)
+	start := time.Now()
+	result := client.Verbosity(verbosity)
+	c.recordOp("verbosity", conn, start, result.Error() != nil || !client.IsValidState())
+	return result
 }
 
 // See Client interface for documentation.
@@ -555,3 +816,387 @@ func (c *ShardedClient) Verbosity(verbosity uint32) Response {
 
 	return NewResponse(StatusNoError)
 }
+
+var _ ContextClient = (*ShardedClient)(nil)
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) GetCtx(ctx context.Context, key string) GetResponse {
+	if err := checkContext(ctx); err != nil {
+		return NewGetErrorResponse(key, err)
+	}
+
+	return c.get(
+		"get",
+		key,
+		func(shardClient Client) GetResponse {
+			return asContextClient(shardClient).GetCtx(ctx, key)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) GetAndTouchCtx(
+	ctx context.Context,
+	key string,
+	expiration uint32) GetResponse {
+
+	if err := checkContext(ctx); err != nil {
+		return NewGetErrorResponse(key, err)
+	}
+
+	return c.get(
+		"get_and_touch",
+		key,
+		func(shardClient Client) GetResponse {
+			return asContextClient(shardClient).GetAndTouchCtx(
+				ctx,
+				key,
+				expiration)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) GetMultiCtx(
+	ctx context.Context,
+	keys []string) map[string]GetResponse {
+
+	if err := checkContext(ctx); err != nil {
+		return getErrorResponses(keys, err)
+	}
+
+	return c.getMulti(
+		ctx,
+		"get_multi",
+		c.manager.GetShardsForKeys(keys),
+		func(shardClient Client, keys []string) map[string]GetResponse {
+			return asContextClient(shardClient).GetMultiCtx(ctx, keys)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) GetSentinelsCtx(
+	ctx context.Context,
+	keys []string) map[string]GetResponse {
+
+	if err := checkContext(ctx); err != nil {
+		return getErrorResponses(keys, err)
+	}
+
+	return c.getMulti(
+		ctx,
+		"get_sentinels",
+		c.manager.GetShardsForSentinelsFromKeys(keys),
+		func(shardClient Client, keys []string) map[string]GetResponse {
+			return asContextClient(shardClient).GetMultiCtx(ctx, keys)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) GetAndTouchMultiCtx(
+	ctx context.Context,
+	keys []string,
+	expiration uint32) map[string]GetResponse {
+
+	if err := checkContext(ctx); err != nil {
+		return getErrorResponses(keys, err)
+	}
+
+	return c.getMulti(
+		ctx,
+		"get_and_touch_multi",
+		c.manager.GetShardsForKeys(keys),
+		func(shardClient Client, keys []string) map[string]GetResponse {
+			return asContextClient(shardClient).GetAndTouchMultiCtx(
+				ctx,
+				keys,
+				expiration)
+		})
+}
+
+func (c *ShardedClient) mutateCtx(
+	ctx context.Context,
+	op string,
+	key string,
+	mutateFunc func(ContextClient) MutateResponse) MutateResponse {
+
+	if err := checkContext(ctx); err != nil {
+		return NewMutateErrorResponse(key, err)
+	}
+
+	return c.mutate(
+		op,
+		key,
+		func(shardClient Client) MutateResponse {
+			return mutateFunc(asContextClient(shardClient))
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) TouchCtx(
+	ctx context.Context,
+	key string,
+	expiration uint32) MutateResponse {
+
+	return c.mutateCtx(
+		ctx,
+		"touch",
+		key,
+		func(shardClient ContextClient) MutateResponse {
+			return shardClient.TouchCtx(ctx, key, expiration)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) SetCtx(ctx context.Context, item *Item) MutateResponse {
+	return c.mutateCtx(
+		ctx,
+		"set",
+		item.Key,
+		func(shardClient ContextClient) MutateResponse {
+			return shardClient.SetCtx(ctx, item)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) AddCtx(ctx context.Context, item *Item) MutateResponse {
+	return c.mutateCtx(
+		ctx,
+		"add",
+		item.Key,
+		func(shardClient ContextClient) MutateResponse {
+			return shardClient.AddCtx(ctx, item)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) ReplaceCtx(
+	ctx context.Context,
+	item *Item) MutateResponse {
+
+	return c.mutateCtx(
+		ctx,
+		"replace",
+		item.Key,
+		func(shardClient ContextClient) MutateResponse {
+			return shardClient.ReplaceCtx(ctx, item)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) DeleteCtx(
+	ctx context.Context,
+	key string) MutateResponse {
+
+	return c.mutateCtx(
+		ctx,
+		"delete",
+		key,
+		func(shardClient ContextClient) MutateResponse {
+			return shardClient.DeleteCtx(ctx, key)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) AppendCtx(
+	ctx context.Context,
+	key string,
+	value []byte) MutateResponse {
+
+	return c.mutateCtx(
+		ctx,
+		"append",
+		key,
+		func(shardClient ContextClient) MutateResponse {
+			return shardClient.AppendCtx(ctx, key, value)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) PrependCtx(
+	ctx context.Context,
+	key string,
+	value []byte) MutateResponse {
+
+	return c.mutateCtx(
+		ctx,
+		"prepend",
+		key,
+		func(shardClient ContextClient) MutateResponse {
+			return shardClient.PrependCtx(ctx, key, value)
+		})
+}
+
+func (c *ShardedClient) mutateMultiCtx(
+	ctx context.Context,
+	op string,
+	keys []string,
+	shards func() map[int]*ShardMapping,
+	mutateMultiFunc func(ContextClient, *ShardMapping) []MutateResponse) []MutateResponse {
+
+	if err := checkContext(ctx); err != nil {
+		return mutateErrorResponses(keys, err)
+	}
+
+	return c.mutateMulti(
+		ctx,
+		op,
+		shards(),
+		func(shardClient Client, mapping *ShardMapping) []MutateResponse {
+			return mutateMultiFunc(asContextClient(shardClient), mapping)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) SetMultiCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.mutateMultiCtx(
+		ctx,
+		"set_multi",
+		itemKeys(items),
+		func() map[int]*ShardMapping { return c.manager.GetShardsForItems(items) },
+		func(shardClient ContextClient, mapping *ShardMapping) []MutateResponse {
+			return shardClient.SetMultiCtx(ctx, mapping.Items)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) SetSentinelsCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.mutateMultiCtx(
+		ctx,
+		"set_sentinels",
+		itemKeys(items),
+		func() map[int]*ShardMapping {
+			return c.manager.GetShardsForSentinelsFromItems(items)
+		},
+		func(shardClient ContextClient, mapping *ShardMapping) []MutateResponse {
+			return shardClient.SetMultiCtx(ctx, mapping.Items)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) CasMultiCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.mutateMultiCtx(
+		ctx,
+		"cas_multi",
+		itemKeys(items),
+		func() map[int]*ShardMapping { return c.manager.GetShardsForItems(items) },
+		func(shardClient ContextClient, mapping *ShardMapping) []MutateResponse {
+			return shardClient.CasMultiCtx(ctx, mapping.Items)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) CasSentinelsCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.mutateMultiCtx(
+		ctx,
+		"cas_sentinels",
+		itemKeys(items),
+		func() map[int]*ShardMapping {
+			return c.manager.GetShardsForSentinelsFromItems(items)
+		},
+		func(shardClient ContextClient, mapping *ShardMapping) []MutateResponse {
+			return shardClient.CasMultiCtx(ctx, mapping.Items)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) AddMultiCtx(
+	ctx context.Context,
+	items []*Item) []MutateResponse {
+
+	return c.mutateMultiCtx(
+		ctx,
+		"add_multi",
+		itemKeys(items),
+		func() map[int]*ShardMapping { return c.manager.GetShardsForItems(items) },
+		func(shardClient ContextClient, mapping *ShardMapping) []MutateResponse {
+			return shardClient.AddMultiCtx(ctx, mapping.Items)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) DeleteMultiCtx(
+	ctx context.Context,
+	keys []string) []MutateResponse {
+
+	return c.mutateMultiCtx(
+		ctx,
+		"delete_multi",
+		keys,
+		func() map[int]*ShardMapping { return c.manager.GetShardsForKeys(keys) },
+		func(shardClient ContextClient, mapping *ShardMapping) []MutateResponse {
+			return shardClient.DeleteMultiCtx(ctx, mapping.Keys)
+		})
+}
+
+func (c *ShardedClient) countCtx(
+	ctx context.Context,
+	op string,
+	key string,
+	countFunc func(ContextClient) CountResponse) CountResponse {
+
+	if err := checkContext(ctx); err != nil {
+		return NewCountErrorResponse(key, err)
+	}
+
+	return c.count(
+		op,
+		key,
+		func(shardClient Client) CountResponse {
+			return countFunc(asContextClient(shardClient))
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) IncrementCtx(
+	ctx context.Context,
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.countCtx(
+		ctx,
+		"increment",
+		key,
+		func(shardClient ContextClient) CountResponse {
+			return shardClient.IncrementCtx(
+				ctx,
+				key,
+				delta,
+				initValue,
+				expiration)
+		})
+}
+
+// See ContextClient interface for documentation.
+func (c *ShardedClient) DecrementCtx(
+	ctx context.Context,
+	key string,
+	delta uint64,
+	initValue uint64,
+	expiration uint32) CountResponse {
+
+	return c.countCtx(
+		ctx,
+		"decrement",
+		key,
+		func(shardClient ContextClient) CountResponse {
+			return shardClient.DecrementCtx(
+				ctx,
+				key,
+				delta,
+				initValue,
+				expiration)
+		})
+}
@@ -21,6 +21,10 @@ func (m *MockShardManager) GetShardsForSentinelsFromItems(items []*Item) map[int
 	return m.shardMap
 }
 
+func (m *MockShardManager) GetShardsForKeys(keys []string) map[int]*ShardMapping {
+	return m.shardMap
+}
+
 // BadMemcacheConn fails all write operations.
 type BadMemcacheConn struct {
 	net2.ManagedConn
@@ -34,6 +38,10 @@ func (BadMemcacheConn) DiscardConnection() error {
 	return nil
 }
 
+func (BadMemcacheConn) Key() net2.NetworkAddress {
+	return net2.NetworkAddress{Network: "tcp", Address: "bad"}
+}
+
 // ShardedClientSuite
 type ShardedClientSuite struct {
 	sm *MockShardManager
@@ -102,3 +110,27 @@ func (s *ShardedClientSuite) TestSetSentinels(c *C) {
 	c.Assert(response, HasLen, 1)
 	c.Assert(response[0].Error(), NotNil)
 }
+
+func (s *ShardedClientSuite) TestGetAndTouchMulti(c *C) {
+	s.sm.shardMap = map[int]*ShardMapping{
+		// Shard is DOWN
+		0: {
+			Connection: nil,
+			Keys:       []string{"key1"},
+		},
+		1: {
+			Connection: BadMemcacheConn{},
+			Keys:       []string{"key2"},
+		},
+		-1: {
+			Keys: []string{"key3"},
+		},
+	}
+
+	responses := s.mc.GetAndTouchMulti([]string{"key1", "key2", "key3"}, 10)
+	c.Assert(responses, HasLen, 3)
+	c.Assert(responses["key1"].Error(), IsNil)
+	c.Assert(responses["key1"].Status(), Equals, StatusKeyNotFound)
+	c.Assert(responses["key2"].Error(), NotNil)
+	c.Assert(responses["key3"].Error(), NotNil)
+}
@@ -0,0 +1,156 @@
+package memcache
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/dropbox/godropbox/stats"
+)
+
+const (
+	// Per address and operation.
+	opLatencyMsMetric = "memcache_op_latency_ms"
+	opErrorsMetric    = "memcache_op_errors"
+
+	// Per address.
+	getHitsMetric       = "memcache_get_hits"
+	getMissesMetric     = "memcache_get_misses"
+	bytesSentMetric     = "memcache_bytes_sent"
+	bytesReceivedMetric = "memcache_bytes_received"
+	poolWaitMsMetric    = "memcache_pool_wait_ms"
+	connErrorsMetric    = "memcache_conn_errors"
+)
+
+type statKey struct {
+	metric  string
+	address string
+	op      string
+}
+
+// A cache of stats created by a stats factory, keyed by metric, address and
+// (optionally) operation.  A nil *statsCache returns no-op stats.
+type statsCache struct {
+	factory stats.StatsFactory
+
+	mutex     sync.Mutex
+	counters  map[statKey]stats.CounterStat // guarded by mutex
+	summaries map[statKey]stats.SummaryStat // guarded by mutex
+}
+
+// Returns nil when the factory is nil.
+func newStatsCache(factory stats.StatsFactory) *statsCache {
+	if factory == nil {
+		return nil
+	}
+
+	return &statsCache{
+		factory:   factory,
+		counters:  make(map[statKey]stats.CounterStat),
+		summaries: make(map[statKey]stats.SummaryStat),
+	}
+}
+
+func (k statKey) tags() map[string]string {
+	tags := map[string]string{"address": k.address}
+	if k.op != "" {
+		tags["op"] = k.op
+	}
+	return tags
+}
+
+func (s *statsCache) counter(metric string, address string, op string) stats.CounterStat {
+	if s == nil {
+		return stats.NoOpStatsFactory.NewCounter(metric, nil)
+	}
+
+	key := statKey{metric, address, op}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	counter, ok := s.counters[key]
+	if !ok {
+		counter = s.factory.NewCounter(metric, key.tags())
+		s.counters[key] = counter
+	}
+	return counter
+}
+
+func (s *statsCache) summary(metric string, address string, op string) stats.SummaryStat {
+	if s == nil {
+		return stats.NoOpStatsFactory.NewSummary(metric, nil)
+	}
+
+	key := statKey{metric, address, op}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	summary, ok := s.summaries[key]
+	if !ok {
+		summary = s.factory.NewSummary(metric, key.tags())
+		s.summaries[key] = summary
+	}
+	return summary
+}
+
+// Records the duration since start (in milliseconds).
+func (s *statsCache) observeLatency(
+	metric string,
+	address string,
+	op string,
+	start time.Time) {
+
+	if s == nil {
+		return
+	}
+
+	elapsed := time.Since(start)
+	s.summary(metric, address, op).Observe(
+		float64(elapsed) / float64(time.Millisecond))
+}
+
+// An io.ReadWriter wrapper which counts the bytes sent / received.
+type countingReadWriter struct {
+	io.ReadWriter
+
+	sent     stats.CounterStat
+	received stats.CounterStat
+}
+
+func (rw *countingReadWriter) Read(p []byte) (int, error) {
+	n, err := rw.ReadWriter.Read(p)
+	if n > 0 {
+		rw.received.Add(float64(n))
+	}
+	return n, err
+}
+
+func (rw *countingReadWriter) Write(p []byte) (int, error) {
+	n, err := rw.ReadWriter.Write(p)
+	if n > 0 {
+		rw.sent.Add(float64(n))
+	}
+	return n, err
+}
+
+// Forwards the deadline to the underlying channel, which allows context-aware
+// operations to interrupt the channel's I/O.
+func (rw *countingReadWriter) SetDeadline(t time.Time) error {
+	if conn, ok := rw.ReadWriter.(deadlineSetter); ok {
+		return conn.SetDeadline(t)
+	}
+	return errors.New("Channel does not support deadlines")
+}
+
+// Forwards the deadline limit to the underlying channel (see
+// net2.DeadlineLimiter).  Channels which do not limit deadlines have their
+// deadline set instead.
+func (rw *countingReadWriter) SetDeadlineLimit(t time.Time) error {
+	if setDeadline := channelDeadlineFunc(rw.ReadWriter); setDeadline != nil {
+		return setDeadline(t)
+	}
+	return errors.New("Channel does not support deadlines")
+}
@@ -0,0 +1,173 @@
+package memcache_test
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/memcache"
+	"github.com/dropbox/godropbox/memcache/memcachetest"
+	"github.com/dropbox/godropbox/net2"
+	"github.com/dropbox/godropbox/stats"
+)
+
+// A stats factory which records the counters' values, and the number of
+// summary observations.
+type recordingStatsFactory struct {
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+func newRecordingStatsFactory() *recordingStatsFactory {
+	return &recordingStatsFactory{values: make(map[string]float64)}
+}
+
+func statName(metric string, tags map[string]string) string {
+	parts := []string{}
+	for key, value := range tags {
+		parts = append(parts, key+"="+value)
+	}
+	sort.Strings(parts)
+	return metric + "{" + strings.Join(parts, ",") + "}"
+}
+
+func (f *recordingStatsFactory) add(name string, value float64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.values[name] += value
+}
+
+func (f *recordingStatsFactory) get(
+	metric string,
+	tags map[string]string) float64 {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.values[statName(metric, tags)]
+}
+
+type recordingStat struct {
+	stats.GaugeStat
+
+	factory *recordingStatsFactory
+	name    string
+}
+
+func (s recordingStat) Inc() {
+	s.factory.add(s.name, 1)
+}
+
+func (s recordingStat) Add(value float64) {
+	s.factory.add(s.name, value)
+}
+
+func (s recordingStat) Observe(value float64) {
+	s.factory.add(s.name, 1)
+}
+
+func (f *recordingStatsFactory) NewCounter(
+	metric string,
+	tags map[string]string) stats.CounterStat {
+
+	return recordingStat{factory: f, name: statName(metric, tags)}
+}
+
+func (f *recordingStatsFactory) NewGauge(
+	metric string,
+	tags map[string]string) stats.GaugeStat {
+
+	return recordingStat{factory: f, name: statName(metric, tags)}
+}
+
+func (f *recordingStatsFactory) NewSummary(
+	metric string,
+	tags map[string]string) stats.SummaryStat {
+
+	return recordingStat{factory: f, name: statName(metric, tags)}
+}
+
+type StatsSuite struct {
+	server  *memcachetest.Server
+	factory *recordingStatsFactory
+	client  memcache.Client
+}
+
+var _ = Suite(&StatsSuite{})
+
+func (s *StatsSuite) SetUpTest(c *C) {
+	server, err := memcachetest.NewServer(memcachetest.Options{})
+	c.Assert(err, IsNil)
+	s.server = server
+
+	s.factory = newRecordingStatsFactory()
+
+	manager := &memcache.StaticShardManager{}
+	manager.Init(
+		func(key string, numShard int) int { return 0 },
+		func(err error) {},
+		func(v ...interface{}) {},
+		net2.ConnectionOptions{MaxActiveConnections: 10})
+	manager.SetStatsFactory(s.factory)
+	manager.UpdateShardStates([]memcache.ShardState{
+		{Address: server.Addr(), State: memcache.ActiveServer},
+	})
+
+	s.client = memcache.NewShardedClientWithOptions(
+		manager,
+		memcache.NewRawBinaryClient,
+		memcache.ShardedClientOptions{StatsFactory: s.factory})
+}
+
+func (s *StatsSuite) TearDownTest(c *C) {
+	_ = s.server.Close()
+}
+
+func (s *StatsSuite) TestClientStats(c *C) {
+	address := map[string]string{"address": s.server.Addr()}
+	opTags := func(op string) map[string]string {
+		return map[string]string{"address": s.server.Addr(), "op": op}
+	}
+
+	resp := s.client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), IsNil)
+
+	c.Assert(s.client.Get("key").Error(), IsNil)
+	c.Assert(s.client.Get("missing").Error(), IsNil)
+	gresps := s.client.GetMulti([]string{"key", "missing"})
+	c.Assert(gresps, HasLen, 2)
+
+	c.Assert(s.factory.get("memcache_op_latency_ms", opTags("set")), Equals, 1.0)
+	c.Assert(s.factory.get("memcache_op_latency_ms", opTags("get")), Equals, 2.0)
+	c.Assert(
+		s.factory.get("memcache_op_latency_ms", opTags("get_multi")),
+		Equals,
+		1.0)
+	c.Assert(s.factory.get("memcache_get_hits", address), Equals, 2.0)
+	c.Assert(s.factory.get("memcache_get_misses", address), Equals, 2.0)
+	c.Assert(s.factory.get("memcache_op_errors", opTags("get")), Equals, 0.0)
+
+	c.Assert(s.factory.get("memcache_bytes_sent", address) > 0, IsTrue)
+	c.Assert(s.factory.get("memcache_bytes_received", address) > 0, IsTrue)
+
+	// Pool wait time is recorded by the shard manager.
+	c.Assert(s.factory.get("memcache_pool_wait_ms", address), Equals, 4.0)
+
+	s.server.InjectFault(memcachetest.Fault{Command: "set", Disconnect: true})
+	resp = s.client.Set(&memcache.Item{Key: "key", Value: []byte("value")})
+	c.Assert(resp.Error(), NotNil)
+	c.Assert(s.factory.get("memcache_op_errors", opTags("set")), Equals, 1.0)
+}
+
+func (s *StatsSuite) TestConnectionErrors(c *C) {
+	address := map[string]string{"address": s.server.Addr()}
+
+	_ = s.server.Close()
+
+	c.Assert(s.client.Get("key").Error(), NotNil)
+	c.Assert(s.factory.get("memcache_conn_errors", address), Equals, 1.0)
+}
@@ -1,6 +1,7 @@
 package net2
 
 import (
+	"crypto/tls"
 	"net"
 	"strings"
 	"time"
@@ -10,10 +11,70 @@ import (
 
 const defaultDialTimeout = 1 * time.Second
 
+const defaultHandshakeTimeout = 5 * time.Second
+
 func defaultDialFunc(network string, address string) (net.Conn, error) {
 	return net.DialTimeout(network, address, defaultDialTimeout)
 }
 
+// This performs the TLS handshake (when configured) and runs the OnConnect
+// callback (when specified) on a newly dialed connection.  The connection is
+// closed on error.
+func setUpConn(
+	options ConnectionOptions,
+	conn net.Conn,
+	address string) (net.Conn, error) {
+
+	if options.TLSConfig == nil && options.OnConnect == nil {
+		return conn, nil
+	}
+
+	timeout := options.HandshakeTimeout
+	if timeout == 0 {
+		timeout = defaultHandshakeTimeout
+	}
+
+	var err error
+	defer func() {
+		if err != nil {
+			_ = conn.Close()
+		}
+	}()
+
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	if options.TLSConfig != nil {
+		config := options.TLSConfig
+		if config.ServerName == "" && !config.InsecureSkipVerify {
+			host, _, splitErr := net.SplitHostPort(address)
+			if splitErr != nil {
+				host = address
+			}
+			config = config.Clone()
+			config.ServerName = host
+		}
+
+		tlsConn := tls.Client(conn, config)
+		if err = tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	if options.OnConnect != nil {
+		if err = options.OnConnect(conn); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
 func parseResourceLocation(resourceLocation string) (
 	network string,
 	address string) {
@@ -46,7 +107,11 @@ func newBaseConnectionPool(
 
 	openFunc := func(loc string) (interface{}, error) {
 		network, address := parseResourceLocation(loc)
-		return dial(network, address)
+		conn, err := dial(network, address)
+		if err != nil {
+			return nil, err
+		}
+		return setUpConn(options, conn, address)
 	}
 
 	closeFunc := func(handle interface{}) error {
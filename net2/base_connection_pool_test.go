@@ -1,7 +1,10 @@
 package net2
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"testing"
@@ -10,6 +13,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	. "github.com/dropbox/godropbox/gocheck2"
+	"github.com/dropbox/godropbox/net2/http2/test_utils"
 	"github.com/dropbox/godropbox/time2"
 )
 
@@ -632,3 +636,137 @@ func (s *BaseConnectionPoolSuite) TestManyConcurrentDialsWhichTimeout(c *C) {
 	c.Assert(duration > dialer.dialLatency, IsTrue)
 	c.Assert(duration < dialer.dialLatency*2, IsTrue)
 }
+
+func (s *BaseConnectionPoolSuite) TestOnConnect(c *C) {
+	dialer := fakeDialer{}
+
+	connected := []int{}
+	options := ConnectionOptions{
+		Dial: dialer.FakeDial,
+		OnConnect: func(conn net.Conn) error {
+			id := conn.(*mockConn).Id()
+			connected = append(connected, id)
+			if id == 2 {
+				return fmt.Errorf("rejected")
+			}
+			return nil
+		},
+	}
+
+	pool := NewSimpleConnectionPool(options)
+	pool.Register("foo", "bar")
+
+	c1, err := pool.Get("foo", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(c1.RawConn().(*mockConn).Id(), Equals, 1)
+
+	_, err = pool.Get("foo", "bar")
+	c.Assert(err, NotNil)
+
+	c.Assert(connected, DeepEquals, []int{1, 2})
+}
+
+func (s *BaseConnectionPoolSuite) TestTLS(c *C) {
+	certPem, keyPem, err := test_utils.GenerateSelfSignedCert("127.0.0.1")
+	c.Assert(err, IsNil)
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	c.Assert(err, IsNil)
+
+	listener, err := tls.Listen(
+		"tcp",
+		"127.0.0.1:0",
+		&tls.Config{Certificates: []tls.Certificate{cert}})
+	c.Assert(err, IsNil)
+	defer func() { _ = listener.Close() }()
+
+	// Echo server.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	c.Assert(roots.AppendCertsFromPEM(certPem), IsTrue)
+
+	options := ConnectionOptions{
+		TLSConfig: &tls.Config{RootCAs: roots},
+		OnConnect: func(conn net.Conn) error {
+			_, isTLS := conn.(*tls.Conn)
+			if !isTLS {
+				return fmt.Errorf("not a tls connection")
+			}
+			return nil
+		},
+	}
+
+	pool := NewSimpleConnectionPool(options)
+	err = pool.Register("tcp", listener.Addr().String())
+	c.Assert(err, IsNil)
+
+	conn, err := pool.Get("tcp", listener.Addr().String())
+	c.Assert(err, IsNil)
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write([]byte("ping"))
+	c.Assert(err, IsNil)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf), Equals, "ping")
+
+	// Certificate verification failure.
+	pool = NewSimpleConnectionPool(ConnectionOptions{
+		TLSConfig: &tls.Config{RootCAs: x509.NewCertPool()},
+	})
+	err = pool.Register("tcp", listener.Addr().String())
+	c.Assert(err, IsNil)
+
+	_, err = pool.Get("tcp", listener.Addr().String())
+	c.Assert(err, NotNil)
+}
+
+func (s *BaseConnectionPoolSuite) TestDeadlineLimit(c *C) {
+	mockClock := time2.MockClock{}
+	dialer := fakeDialer{
+		readLatency: 10 * time.Nanosecond,
+		nowFunc:     mockClock.Now,
+	}
+
+	options := ConnectionOptions{
+		Dial:        dialer.FakeDial,
+		NowFunc:     mockClock.Now,
+		ReadTimeout: 20 * time.Nanosecond,
+	}
+
+	pool := NewSimpleConnectionPool(options)
+	pool.Register("foo", "bar")
+
+	conn, err := pool.Get("foo", "bar")
+	c.Assert(err, IsNil)
+
+	_, err = conn.Read([]byte{})
+	c.Assert(err, IsNil)
+
+	// SetDeadline is disabled, but the deadline limit caps the read timeout.
+	c.Assert(conn.SetDeadline(mockClock.Now()), NotNil)
+
+	limiter, ok := conn.(DeadlineLimiter)
+	c.Assert(ok, IsTrue)
+	c.Assert(limiter.SetDeadlineLimit(mockClock.Now().Add(5)), IsNil)
+
+	_, err = conn.Read([]byte{})
+	c.Assert(err, NotNil)
+
+	c.Assert(limiter.SetDeadlineLimit(time.Time{}), IsNil)
+
+	_, err = conn.Read([]byte{})
+	c.Assert(err, IsNil)
+}
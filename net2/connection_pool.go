@@ -1,6 +1,7 @@
 package net2
 
 import (
+	"crypto/tls"
 	"net"
 	"time"
 )
@@ -26,6 +27,23 @@ type ConnectionOptions struct {
 	// If Dial is nil, net.DialTimeout is used, with timeout set to 1 second.
 	Dial func(network string, address string) (net.Conn, error)
 
+	// When TLSConfig is non-nil, newly dialed connections are wrapped in TLS
+	// client connections, and the TLS handshake is performed before the
+	// connections are handed out.  If TLSConfig.ServerName is empty (and
+	// InsecureSkipVerify is false), the dialed address's host is used as the
+	// server name.
+	TLSConfig *tls.Config
+
+	// When OnConnect is non-nil, it is called on each newly dialed (and TLS
+	// wrapped, if TLSConfig is set) connection before the connection is
+	// handed out, e.g., to authenticate the connection.  The connection is
+	// closed when OnConnect returns an error.
+	OnConnect func(conn net.Conn) error
+
+	// The maximum amount of time the TLS handshake and OnConnect may take.
+	// If zero, the timeout is set to 5 seconds.
+	HandshakeTimeout time.Duration
+
 	// This specifies the now time function.  When the function is non-nil, the
 	// connection pool will use the specified function instead of time.Now to
 	// generate the current time.
@@ -2,6 +2,7 @@ package net2
 
 import (
 	"net"
+	"sync"
 	"time"
 
 	"github.com/dropbox/godropbox/errors"
@@ -16,7 +17,8 @@ type NetworkAddress struct {
 
 // A connection managed by a connection pool.  NOTE: SetDeadline,
 // SetReadDeadline and SetWriteDeadline are disabled for managed connections.
-// (The deadlines are set by the connection pool).
+// (The deadlines are set by the connection pool; see DeadlineLimiter for
+// capping the pool's deadlines).
 type ManagedConn interface {
 	net.Conn
 
@@ -39,14 +41,30 @@ type ManagedConn interface {
 	DiscardConnection() error
 }
 
+// Implemented by managed connections which allow callers to cap the
+// connection pool's read / write deadlines, e.g., to apply a request's
+// context deadline.
+type DeadlineLimiter interface {
+	// This caps the connection's read / write deadlines at t, in addition to
+	// the read / write timeouts specified in ConnectionOptions.  Unlike the
+	// timeouts, the cap also applies to pending reads / writes (i.e., a cap
+	// in the past interrupts them).  A zero t removes the cap.
+	SetDeadlineLimit(t time.Time) error
+}
+
 // A physical implementation of ManagedConn
 type managedConnImpl struct {
 	addr    NetworkAddress
 	handle  resource_pool.ManagedHandle
 	pool    ConnectionPool
 	options ConnectionOptions
+
+	deadlineMutex sync.Mutex
+	deadlineLimit time.Time
 }
 
+var _ DeadlineLimiter = (*managedConnImpl)(nil)
+
 // This creates a managed connection wrapper.
 func NewManagedConn(
 	network string,
@@ -99,6 +117,44 @@ func (c *managedConnImpl) DiscardConnection() error {
 	return c.handle.Discard()
 }
 
+// Sets the raw connection's read / write deadline to the earlier of the
+// timeout and the deadline limit (if any).
+func (c *managedConnImpl) setDeadline(
+	timeout time.Duration,
+	setRawDeadline func(t time.Time) error) {
+
+	// NOTE: The lock ensures a concurrent SetDeadlineLimit call is not
+	// overwritten by a stale deadline.
+	c.deadlineMutex.Lock()
+	defer c.deadlineMutex.Unlock()
+
+	deadline := c.deadlineLimit
+	if timeout > 0 {
+		timeoutDeadline := c.options.getCurrentTime().Add(timeout)
+		if deadline.IsZero() || timeoutDeadline.Before(deadline) {
+			deadline = timeoutDeadline
+		}
+	}
+
+	if !deadline.IsZero() {
+		_ = setRawDeadline(deadline)
+	}
+}
+
+// See DeadlineLimiter for documentation.
+func (c *managedConnImpl) SetDeadlineLimit(t time.Time) error {
+	conn, err := c.rawConn()
+	if err != nil {
+		return err
+	}
+
+	c.deadlineMutex.Lock()
+	defer c.deadlineMutex.Unlock()
+
+	c.deadlineLimit = t
+	return conn.SetDeadline(t)
+}
+
 // See net.Conn for documentation
 func (c *managedConnImpl) Read(b []byte) (n int, err error) {
 	conn, err := c.rawConn()
@@ -106,10 +162,7 @@ func (c *managedConnImpl) Read(b []byte) (n int, err error) {
 		return 0, err
 	}
 
-	if c.options.ReadTimeout > 0 {
-		deadline := c.options.getCurrentTime().Add(c.options.ReadTimeout)
-		_ = conn.SetReadDeadline(deadline)
-	}
+	c.setDeadline(c.options.ReadTimeout, conn.SetReadDeadline)
 	n, err = conn.Read(b)
 	if err != nil {
 		var localAddr string
@@ -137,10 +190,7 @@ func (c *managedConnImpl) Write(b []byte) (n int, err error) {
 		return 0, err
 	}
 
-	if c.options.WriteTimeout > 0 {
-		deadline := c.options.getCurrentTime().Add(c.options.WriteTimeout)
-		_ = conn.SetWriteDeadline(deadline)
-	}
+	c.setDeadline(c.options.WriteTimeout, conn.SetWriteDeadline)
 	n, err = conn.Write(b)
 	if err != nil {
 		err = errors.Wrap(err, "Write error")